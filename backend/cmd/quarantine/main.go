@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/ioprocessors/quarantiners"
+	"turion-takehome/internal/ioprocessors/writers"
+	"turion-takehome/internal/turiondatapacket"
+	"turion-takehome/internal/utils"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // register the "pgx" driver
+	"go.uber.org/zap"
+)
+
+const usage = `Inspect and replay messages quarantined by the telemetry gateway.
+
+Usage:
+  quarantine [-dir DIR] list [-all]
+  quarantine [-dir DIR] inspect ID
+  quarantine [-dir DIR] replay -writer WRITER [-dictionary PATH] [-error-control] [-force] (-all | ID...)
+
+Entries are read from the segment files in -dir, or from the quarantine table
+of the database at PG_HOST_URL when -dir isn't set.
+
+Writers:
+  sql         decode the packet and insert it into its table
+  anomaly     upsert an anomaly event
+  link_event  insert a link event
+  command_ack record a command acknowledgement
+  noop        accept everything, for a dry run
+`
+
+func main() {
+	dir := flag.String("dir", "", "quarantine segment directory (default: use the database)")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	logger := utils.InitDevLogger()
+
+	var db *sql.DB
+	var store quarantiners.Store
+	if *dir != "" {
+		fileStore, err := quarantiners.NewFileStore(*dir)
+		if err != nil {
+			fatal(err)
+		}
+		defer fileStore.Close()
+		store = fileStore
+	} else {
+		var err error
+		db, err = openDB(ctx)
+		if err != nil {
+			fatal(err)
+		}
+		defer db.Close()
+		store = quarantiners.NewSQLStore(db)
+	}
+
+	var err error
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "list":
+		err = list(ctx, store, args)
+	case "inspect":
+		err = inspect(ctx, store, args)
+	case "replay":
+		err = replay(ctx, logger, store, db, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+func list(ctx context.Context, store quarantiners.Store, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	all := fs.Bool("all", false, "include entries that were already replayed")
+	fs.Parse(args)
+
+	entries, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tTIME\tSOURCE\tREADER\tBYTES\tREPLAYED\tERROR")
+	for _, e := range entries {
+		if e.ReplayedAt != nil && !*all {
+			continue
+		}
+		replayed := "-"
+		if e.ReplayedAt != nil {
+			replayed = e.ReplayedAt.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			e.ID, e.Time.Format(time.RFC3339), e.Source, e.Reader,
+			len(e.Payload), replayed, firstLine(e.Error))
+	}
+	return tw.Flush()
+}
+
+func inspect(ctx context.Context, store quarantiners.Store, args []string) error {
+	if len(args) != 1 {
+		return errors.New("inspect takes exactly one ID")
+	}
+
+	e, err := store.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("ID:       %s\n", e.ID)
+	fmt.Printf("Time:     %s\n", e.Time.Format(time.RFC3339Nano))
+	fmt.Printf("Source:   %s\n", e.Source)
+	fmt.Printf("Reader:   %s\n", e.Reader)
+	if e.ReplayedAt != nil {
+		fmt.Printf("Replayed: %s\n", e.ReplayedAt.Format(time.RFC3339))
+	}
+	fmt.Printf("Error:    %s\n", e.Error)
+
+	// Most quarantined messages are space packets, show what the header says
+	if h, err := turiondatapacket.DecodePrimaryHeader(e.Payload); err == nil {
+		fmt.Printf("Header:   APID %#x, seq count %d, data length %d\n",
+			h.APID(), h.SequenceCount(), h.DataLength())
+	} else {
+		fmt.Printf("Header:   not a valid space packet: %v\n", err)
+	}
+
+	fmt.Printf("Payload:  %d bytes\n%s", len(e.Payload), hex.Dump(e.Payload))
+	return nil
+}
+
+func replay(
+	ctx context.Context,
+	logger *zap.Logger,
+	store quarantiners.Store,
+	db *sql.DB,
+	args []string,
+) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	writerName := fs.String("writer", "", "writer to replay entries through (required)")
+	dictionaryPath := fs.String("dictionary", "", "telemetry dictionary for the sql writer (default: main bus packet only)")
+	errorControl := fs.Bool("error-control", false, "main bus packets end with packet error control, as with PACKET_ERROR_CONTROL")
+	all := fs.Bool("all", false, "replay every entry that hasn't been replayed")
+	force := fs.Bool("force", false, "replay entries even if they were already replayed")
+	fs.Parse(args)
+
+	if *all == (fs.NArg() > 0) {
+		return errors.New("replay takes either -all or a list of IDs")
+	}
+
+	w, err := newWriter(ctx, logger, *writerName, *dictionaryPath, *errorControl, db)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	var entries []quarantiners.Entry
+	if *all {
+		entries, err = store.List(ctx)
+		if err != nil {
+			return err
+		}
+	} else {
+		for _, id := range fs.Args() {
+			e, err := store.Get(ctx, id)
+			if err != nil {
+				return err
+			}
+			entries = append(entries, e)
+		}
+	}
+
+	var replayed, failed int
+	for _, e := range entries {
+		if e.ReplayedAt != nil && !*force {
+			continue
+		}
+
+		m := messages.New(e.Payload)
+		_, err := w.Write(ctx, m)
+		m.Release()
+		if err != nil {
+			fmt.Printf("%s: failed: %v\n", e.ID, err)
+			failed++
+			continue
+		}
+		if err := store.MarkReplayed(ctx, e.ID); err != nil {
+			return fmt.Errorf("%s was replayed but couldn't be marked: %w", e.ID, err)
+		}
+		fmt.Printf("%s: replayed\n", e.ID)
+		replayed++
+	}
+
+	fmt.Printf("%d replayed, %d failed\n", replayed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d entries failed to replay", failed)
+	}
+	return nil
+}
+
+// newWriter builds the writer named name. Writers that store to the database
+// connect to PG_HOST_URL if the entries didn't come from there.
+func newWriter(
+	ctx context.Context,
+	logger *zap.Logger,
+	name string,
+	dictionaryPath string,
+	errorControl bool,
+	db *sql.DB,
+) (writers.Writer, error) {
+	if name == "noop" {
+		return writers.NewNoOpWriter(logger)
+	}
+
+	if name != "sql" && name != "anomaly" && name != "link_event" && name != "command_ack" {
+		return nil, fmt.Errorf("unknown writer %q", name)
+	}
+
+	if db == nil {
+		var err error
+		db, err = openDB(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch name {
+	case "sql":
+		// Registered like the gateway does, command acks aren't stored so
+		// they replay as a no-op
+		registry := turiondatapacket.NewRegistry()
+		if err := registry.Register(turiondatapacket.CommandAckDefinition); err != nil {
+			return nil, err
+		}
+		if dictionaryPath == "" {
+			def := turiondatapacket.TurionDataPacketDefinition
+			def.ErrorControl = errorControl
+			if err := registry.Register(def); err != nil {
+				return nil, err
+			}
+		} else {
+			dictionary, err := turiondatapacket.LoadDictionary(dictionaryPath)
+			if err != nil {
+				return nil, err
+			}
+			if err := dictionary.Register(registry); err != nil {
+				return nil, err
+			}
+		}
+		return writers.NewTelemetryToSQLWriter(logger, db, registry)
+	case "anomaly":
+		return writers.NewAnomalyWriter(logger, db)
+	case "command_ack":
+		return writers.NewCommandAckWriter(logger, db)
+	default:
+		return writers.NewLinkEventWriter(logger, db)
+	}
+}
+
+func openDB(ctx context.Context) (*sql.DB, error) {
+	url := strings.TrimSpace(os.Getenv("PG_HOST_URL"))
+	if url == "" {
+		return nil, errors.New("env variable PG_HOST_URL is empty")
+	}
+
+	db, err := sql.Open("pgx", url)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		return nil, fmt.Errorf("connecting to database: %w", err)
+	}
+	return db, nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "quarantine:", err)
+	os.Exit(1)
+}
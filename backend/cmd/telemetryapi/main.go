@@ -3,19 +3,29 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"turion-takehome/internal/api"
+	"turion-takehome/internal/commanding"
 	"turion-takehome/internal/config"
+	"turion-takehome/internal/ioprocessors/writers"
+	"turion-takehome/internal/pubsub"
 	"turion-takehome/internal/store"
+	"turion-takehome/internal/turiondatapacket"
 	"turion-takehome/internal/utils"
 
 	_ "github.com/jackc/pgx/v5/stdlib" // register the "pgx" driver
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
 )
 
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	logger := utils.InitLogger()
 
@@ -43,7 +53,94 @@ func main() {
 
 	store := store.NewSQLDataPacketStore(db, logger)
 
-	api.RegisterRoutes(e, store, logger)
+	// Packets and anomaly events are pushed by the gateway over Postgres
+	// NOTIFY
+	broker := pubsub.NewBroker(logger)
+	listener := pubsub.NewPGListener(logger, envConfig.PGHostURL, broker)
+	// The API shuts down if the listener stops, live streams would go quiet
+	// otherwise
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.Go(func() error {
+		return listener.Run(ctx)
+	})
+
+	commander, err := newCommander(ctx, logger, envConfig, store)
+	if err != nil {
+		logger.Fatal("Failed to create commander", zap.Error(err))
+	}
+	if commander != nil {
+		go commander.ExpireCommands(ctx, envConfig.CommandAckTimeout)
+	}
+
+	api.RegisterRoutes(e, store, broker, commander, logger)
+
+	go func() {
+		if err := e.Start(":8090"); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("Telemetry API server failed", zap.Error(err))
+		}
+	}()
+
+	<-ctx.Done()
+	logger.Info("Shutting down, waiting for in-flight requests",
+		zap.Duration("Timeout", envConfig.ShutdownTimeout))
+
+	// Streams only end when their client or the server goes away, so they're
+	// cut off once the timeout passes
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), envConfig.ShutdownTimeout)
+	defer cancel()
+	if err := e.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down gracefully", zap.Error(err))
+		e.Close()
+	}
+	if commander != nil {
+		if err := commander.Close(); err != nil {
+			logger.Error("Failed to close uplink", zap.Error(err))
+		}
+	}
+	if err := eg.Wait(); err != nil {
+		logger.Error("Postgres notification listener failed", zap.Error(err))
+	}
+	logger.Info("Telemetry API server stopped")
+}
+
+// newCommander sends commands from the dictionary at COMMAND_DICTIONARY_PATH,
+// or the built-in one, to UPLINK_ADDRESS, carrying on the sequence counts of
+// the command history. It returns nil when no uplink is configured.
+func newCommander(
+	ctx context.Context,
+	logger *zap.Logger,
+	envConfig *config.TelemetryAPIConfig,
+	s store.DataPacketStore,
+) (*commanding.Commander, error) {
+	if envConfig.UplinkAddress == "" {
+		logger.Info("UPLINK_ADDRESS is not set, commanding is disabled")
+		return nil, nil
+	}
+
+	dictionary := &turiondatapacket.DefaultCommandDictionary
+	if envConfig.CommandDictionaryPath != "" {
+		d, err := turiondatapacket.LoadCommandDictionary(envConfig.CommandDictionaryPath)
+		if err != nil {
+			return nil, err
+		}
+		dictionary = d
+	}
+
+	uplink, err := writers.NewUplinkWriter(logger, envConfig.UplinkNetwork, envConfig.UplinkAddress)
+	if err != nil {
+		return nil, err
+	}
 
-	e.Logger.Fatal(e.Start(":8090"))
+	logger.Info("Commanding enabled",
+		zap.String("Uplink", envConfig.UplinkNetwork+"://"+envConfig.UplinkAddress),
+		zap.Int("Commands", len(dictionary.Commands)),
+	)
+	commander, err := commanding.NewCommander(logger, dictionary, s, uplink)
+	if err != nil {
+		return nil, err
+	}
+	if err := commander.RestoreSequenceCounts(ctx); err != nil {
+		return nil, errors.Join(err, commander.Close())
+	}
+	return commander, nil
 }
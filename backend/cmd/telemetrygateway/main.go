@@ -3,18 +3,30 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
+	"syscall"
+	"time"
+	"turion-takehome/internal/api"
 	"turion-takehome/internal/config"
 	"turion-takehome/internal/ioprocessors"
+	"turion-takehome/internal/ioprocessors/messages"
 	"turion-takehome/internal/ioprocessors/quarantiners"
 	"turion-takehome/internal/ioprocessors/readers"
 	"turion-takehome/internal/ioprocessors/writers"
+	"turion-takehome/internal/metrics"
+	"turion-takehome/internal/notifier"
+	"turion-takehome/internal/store"
+	"turion-takehome/internal/turiondatapacket"
 	"turion-takehome/internal/utils"
 
 	_ "github.com/jackc/pgx/v5/stdlib" // register the "pgx" driver
@@ -24,16 +36,53 @@ import (
 
 const (
 	TDP_BUFFER_SIZE int = 1024
+	// Messages each channel between processors holds, so a short stall in
+	// one stage doesn't hold up the telemetry processor, and the channel depth
+	// metrics show how far behind a stage is
+	CHANNEL_BUFFER_SIZE int = 256
 )
 
+// Outputs of the telemetry processor and the sinks they can be sent to, see
+// TOPOLOGY_PATH
+const (
+	OUTPUT_PACKETS     = "packets"
+	OUTPUT_ANOMALIES   = "anomalies"
+	OUTPUT_LINK_EVENTS = "link_events"
+
+	SINK_SQL         = "sql"
+	SINK_ANOMALY     = "anomaly"
+	SINK_LINK_EVENT  = "link_event"
+	SINK_COMMAND_ACK = "command_ack"
+)
+
+// defaultTopology sends every output to the processor that handles it.
+// Command acknowledgements are packets too, they also go to command_ack to
+// update the command history.
+var defaultTopology = writers.Topology{
+	OUTPUT_PACKETS: {Tee: &writers.TeeSpec{
+		Policy: writers.TEE_ALL,
+		Writers: []writers.WriterSpec{
+			{Sink: SINK_SQL},
+			{Filter: &writers.FilterSpec{
+				APIDs:  []uint16{turiondatapacket.COMMAND_ACK_APID},
+				Writer: writers.WriterSpec{Sink: SINK_COMMAND_ACK},
+			}},
+		},
+	}},
+	OUTPUT_ANOMALIES:   {Sink: SINK_ANOMALY},
+	OUTPUT_LINK_EVENTS: {Sink: SINK_LINK_EVENT},
+}
+
 // The primary entrypoint for telemetry
 // 1. Opens up a UDP client to receive data from the telemetry generator
 // 2. Deserializes the UDP packet and extracts the telemetry payload
 // 3. Checks for any anomalies
 // 4. Persists the data in a PG db
 func main() {
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// SIGINT or SIGTERM stops the UDP reader. Packets already read are still
+	// written, see drainCtx below.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	devMode := flag.Bool("loc", false, "Run in local development mode")
 	flag.Parse()
@@ -50,6 +99,13 @@ func main() {
 
 	eg, ctx := errgroup.WithContext(ctx)
 
+	// The processors downstream of the UDP reader run until the channel they
+	// read is closed and drained, not until ctx is done. drainCtx is only
+	// cancelled if draining is still going SHUTDOWN_TIMEOUT after ctx is done.
+	drainCtx, cancelDrain := context.WithCancel(context.Background())
+	defer cancelDrain()
+	var drain errgroup.Group
+
 	db, err := sql.Open("pgx", envConfig.PGHostURL)
 	if err != nil {
 		logger.Fatal(
@@ -70,102 +126,227 @@ func main() {
 		logger.Fatal("Failed to run migrations", zap.Error(err))
 	}
 
-	// Channels for processes
-	anomalyChannel := make(chan []byte)
-	defer close(anomalyChannel)
-	sqlChannel := make(chan []byte)
-	defer close(sqlChannel)
+	packetRegistry, defaultLimits, err := newPacketRegistry(envConfig.TelemetryDictionaryPath, envConfig.PacketErrorControl)
+	if err != nil {
+		logger.Fatal("Failed to load telemetry dictionary", zap.Error(err))
+	}
+
+	// Start from the dictionary limits and swap in the configured limit set as
+	// soon as it loads
+	limitChecker, err := turiondatapacket.NewLimitChecker(defaultLimits)
+	if err != nil {
+		logger.Fatal("Failed to create limit checker", zap.Error(err))
+	}
+	var limitSource turiondatapacket.LimitSource = store.NewSQLLimitSource(db)
+	if envConfig.LimitsPath != "" {
+		limitSource = turiondatapacket.FileLimitSource{Path: envConfig.LimitsPath}
+	}
+	eg.Go(func() error {
+		watchLimits(ctx, logger, limitSource, limitChecker, envConfig.LimitsReloadInterval)
+		return nil
+	})
+
+	// Channels for processes. They are closed by their ChannelWriter when the
+	// telemetry processor is closed.
+	anomalyChannel := make(chan *messages.Message, CHANNEL_BUFFER_SIZE)
+	sqlChannel := make(chan *messages.Message, CHANNEL_BUFFER_SIZE)
+	linkEventChannel := make(chan *messages.Message, CHANNEL_BUFFER_SIZE)
+	commandAckChannel := make(chan *messages.Message, CHANNEL_BUFFER_SIZE)
+
+	metrics.WatchChannel("anomaly", anomalyChannel)
+	metrics.WatchChannel("sql", sqlChannel)
+	metrics.WatchChannel("link_event", linkEventChannel)
+	metrics.WatchChannel("command_ack", commandAckChannel)
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", metrics.Handler())
+	metricsServer := &http.Server{Addr: envConfig.MetricsAddress, Handler: metricsMux}
+	eg.Go(func() error {
+		logger.Info("Serving metrics", zap.String("Address", envConfig.MetricsAddress))
+		if err := metricsServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+	eg.Go(func() error {
+		<-ctx.Done()
+		return metricsServer.Close()
+	})
 
 	anomalyChannelWriter := writers.NewChannelWriter(logger, anomalyChannel)
 	sqlChannelWriter := writers.NewChannelWriter(logger, sqlChannel)
+	linkEventChannelWriter := writers.NewChannelWriter(logger, linkEventChannel)
+	commandAckChannelWriter := writers.NewChannelWriter(logger, commandAckChannel)
 
-	groundStationEmulatorUDPAddr, err := net.ResolveUDPAddr(
-		"udp",
-		envConfig.GroundStationEmulatorAddress,
-	)
+	tdpReader, tdpReaderName, err := newTelemetryReader(logger, envConfig, packetRegistry)
 	if err != nil {
-		logger.Fatal("Failed to create mock GS UDP server address", zap.Error(err))
+		logger.Fatal("Failed to create telemetry reader", zap.Error(err))
 	}
 
-	tdpReader, err := readers.NewUDPReader(
-		logger,
-		readers.WithUDPAddr(groundStationEmulatorUDPAddr),
-	)
-	if err != nil {
-		logger.Fatal("Failed to create telemetry UDP reader", zap.Error(err))
+	// Messages any stage fails to process are kept for debugging and can be
+	// replayed with cmd/quarantine. They go to the database unless a
+	// directory is given, and to files when the database fails too, since it
+	// is often the reason they are quarantined.
+	var quarantineStore quarantiners.Store
+	if envConfig.QuarantineDir != "" {
+		fileStore, err := quarantiners.NewFileStore(envConfig.QuarantineDir)
+		if err != nil {
+			logger.Fatal("Failed to open quarantine dir", zap.Error(err))
+		}
+		defer fileStore.Close()
+		quarantineStore = fileStore
+	} else {
+		fileStore, err := quarantiners.NewFileStore(envConfig.QuarantineFallbackDir)
+		if err != nil {
+			logger.Fatal("Failed to open quarantine fallback dir", zap.Error(err))
+		}
+		defer fileStore.Close()
+		quarantineStore, err = quarantiners.NewFallbackStore(logger, quarantiners.NewSQLStore(db), fileStore)
+		if err != nil {
+			logger.Fatal("Failed to create quarantine store", zap.Error(err))
+		}
 	}
 
-	// I would normally put quarantined messages in a different table or in some
-	// other quarantine zone to attempt reprocessing/manual debugging, but due to
-	// time constraints I will just log it
-	tdpQuarantiner, err := quarantiners.NewNoOpQuarantiner(logger)
+	tdpQuarantiner, err := quarantiners.NewStoreQuarantiner(logger, quarantineStore, "telemetry", tdpReaderName)
 	if err != nil {
 		logger.Fatal("Failed to create new quarantiner", zap.Error(err))
 	}
 
+	sinks := map[string]writers.Writer{
+		SINK_SQL:         sqlChannelWriter,
+		SINK_ANOMALY:     anomalyChannelWriter,
+		SINK_LINK_EVENT:  linkEventChannelWriter,
+		SINK_COMMAND_ACK: commandAckChannelWriter,
+	}
+	outputs, err := buildOutputs(logger, envConfig.TopologyPath, sinks)
+	if err != nil {
+		logger.Fatal("Failed to build writer topology", zap.Error(err))
+	}
+
+	anomalyTracker, err := restoreAnomalyTracker(ctx, logger, store.NewSQLDataPacketStore(db, logger))
+	if err != nil {
+		logger.Fatal("Failed to restore open anomaly events", zap.Error(err))
+	}
+
 	tdpWriter, err := writers.NewTelemetryMessageWriter(
 		logger,
-		sqlChannelWriter,
-		anomalyChannelWriter,
+		packetRegistry,
+		limitChecker,
+		outputs[OUTPUT_PACKETS],
+		outputs[OUTPUT_ANOMALIES],
+		writers.WithLinkQuality(turiondatapacket.NewSequenceTracker(), outputs[OUTPUT_LINK_EVENTS]),
+		writers.WithAnomalyTracker(anomalyTracker),
 	)
 	if err != nil {
 		logger.Fatal("Failed to create new Turion Data Packet writer", zap.Error(err))
 	}
 
+	// Sequence and anomaly tracking need each APID's packets in order, and
+	// frames need each virtual channel's frames in order
+	var telemetryWriter writers.Writer = tdpWriter
+	orderingKey := ioprocessors.APIDKey
+	if envConfig.FrameType != "" {
+		telemetryWriter, err = newFrameDemuxer(logger, envConfig, tdpWriter, quarantineStore)
+		if err != nil {
+			logger.Fatal("Failed to create frame demultiplexer", zap.Error(err))
+		}
+		orderingKey = ioprocessors.FrameKey
+	}
+
+	// Recording mirrors what was read. A capture that fails is logged rather
+	// than getting the packets quarantined.
+	if envConfig.CaptureDir != "" {
+		captureWriter, err := writers.NewCaptureWriter(logger, envConfig.CaptureDir)
+		if err != nil {
+			logger.Fatal("Failed to create capture writer", zap.Error(err))
+		}
+		telemetryWriter, err = writers.NewTee(logger, writers.TEE_FIRST, telemetryWriter, captureWriter)
+		if err != nil {
+			logger.Fatal("Failed to create capture tee", zap.Error(err))
+		}
+	}
+
 	telemetryProcessor := ioprocessors.NewProcessor(
 		logger,
 		TDP_BUFFER_SIZE,
-		tdpReader, tdpWriter, tdpQuarantiner,
+		tdpReader, telemetryWriter, tdpQuarantiner,
+		ioprocessors.WithName("telemetry"),
+		ioprocessors.WithWorkers(envConfig.TelemetryWorkers),
+		ioprocessors.WithOrderingKey(orderingKey),
+		// ctx only stops the reader, packets already read or queued for the
+		// workers are written until draining times out
+		ioprocessors.WithWriteContext(drainCtx),
 	)
-	defer func() {
-		if err := telemetryProcessor.Close(); err != nil {
-			logger.Fatal("Failed to close telemetry to SQL processor", zap.Error(err))
-		}
-	}()
 	eg.Go(func() error {
 		err := telemetryProcessor.Start(ctx)
 		if err != nil {
 			logger.Error("Param processor encountered an error", zap.Error(err))
-			return err
 		}
-		return nil
+		// A replay is over once the capture has been read, so shut down the
+		// way a signal would
+		if envConfig.ReplayDir != "" {
+			logger.Info("Finished replaying capture, shutting down")
+			stop()
+		}
+		closeErr := telemetryProcessor.Close()
+		// Closes the channels so the processors below drain them and stop
+		for _, sink := range sinks {
+			closeErr = errors.Join(closeErr, sink.Close())
+		}
+		if closeErr != nil {
+			logger.Error("Failed to close telemetry processor", zap.Error(closeErr))
+			err = errors.Join(err, closeErr)
+		}
+		return err
 	})
 	logger.Info("Param processor started")
 
 	sqlChannelReader := readers.NewChannelReader(logger, sqlChannel)
-	sqlWriter, err := writers.NewTelemetryToSQLWriter(logger, db)
+	sqlQuarantiner, err := quarantiners.NewStoreQuarantiner(logger, quarantineStore, "sql", "channel")
 	if err != nil {
-		logger.Fatal("Failed to create new SQL writer", zap.Error(err))
+		logger.Fatal("Failed to create new quarantiner", zap.Error(err))
 	}
-	sqlQuarantiner, err := quarantiners.NewNoOpQuarantiner(logger)
+	sqlWriter, err := writers.NewTelemetryToSQLWriter(
+		logger,
+		db,
+		packetRegistry,
+		writers.WithLiveUpdates(),
+		writers.WithBatching(writers.DEFAULT_BATCH_SIZE, writers.DEFAULT_FLUSH_INTERVAL, sqlQuarantiner),
+	)
 	if err != nil {
-		logger.Fatal("Failed to create new quarantiner", zap.Error(err))
+		logger.Fatal("Failed to create new SQL writer", zap.Error(err))
 	}
 	telemetryToSQLProcessor := ioprocessors.NewProcessor(
 		logger,
 		TDP_BUFFER_SIZE,
 		sqlChannelReader, sqlWriter, sqlQuarantiner,
+		ioprocessors.WithName("sql"),
 	)
-	defer func() {
-		if err := telemetryToSQLProcessor.Close(); err != nil {
-			logger.Fatal("Failed to close telemetry to SQL processor", zap.Error(err))
-		}
-	}()
-	eg.Go(func() error {
-		err := telemetryToSQLProcessor.Start(ctx)
-		if err != nil {
-			logger.Error("Telemetry to SQL processor failed to start", zap.Error(err))
-			return err
-		}
-		return nil
+	drain.Go(func() error {
+		return runUntilDrained(drainCtx, logger, telemetryToSQLProcessor)
 	})
 
 	anomalyChannelReader := readers.NewChannelReader(logger, anomalyChannel)
-	anomalyWriter, err := writers.NewAnomalyWriter(logger, db)
+	alertDispatcher, err := newAlertDispatcher(logger, envConfig)
+	if err != nil {
+		logger.Fatal("Failed to create alert notifier", zap.Error(err))
+	}
+	// Keep delivering alerts until the anomaly processor has drained
+	alertCtx, stopAlerts := context.WithCancel(drainCtx)
+	drain.Go(func() error {
+		err := alertDispatcher.Run(alertCtx)
+		alertDispatcher.Flush(drainCtx)
+		return err
+	})
+
+	anomalyWriter, err := writers.NewAnomalyWriter(logger, db,
+		writers.WithNotifier(alertDispatcher),
+		writers.WithAnomalyLiveUpdates(),
+	)
 	if err != nil {
 		logger.Fatal("Failed to create new anomaly writer", zap.Error(err))
 	}
-	anomalyQuarantiner, err := quarantiners.NewNoOpQuarantiner(logger)
+	anomalyQuarantiner, err := quarantiners.NewStoreQuarantiner(logger, quarantineStore, "anomaly", "channel")
 	if err != nil {
 		logger.Fatal("Failed to create new quarantiner", zap.Error(err))
 	}
@@ -173,27 +354,343 @@ func main() {
 		logger,
 		TDP_BUFFER_SIZE,
 		anomalyChannelReader, anomalyWriter, anomalyQuarantiner,
+		ioprocessors.WithName("anomaly"),
 	)
-	defer func() {
-		if err := anomalyProcessor.Close(); err != nil {
-			logger.Fatal("Failed to close anomaly processor", zap.Error(err))
-		}
-	}()
-	eg.Go(func() error {
-		err := anomalyProcessor.Start(ctx)
-		if err != nil {
-			logger.Error("Anomaly processor failed to start", zap.Error(err))
-			return err
-		}
-		return nil
+	drain.Go(func() error {
+		defer stopAlerts()
+		return runUntilDrained(drainCtx, logger, anomalyProcessor)
+	})
+
+	linkEventChannelReader := readers.NewChannelReader(logger, linkEventChannel)
+	linkEventWriter, err := writers.NewLinkEventWriter(logger, db)
+	if err != nil {
+		logger.Fatal("Failed to create new link event writer", zap.Error(err))
+	}
+	linkEventQuarantiner, err := quarantiners.NewStoreQuarantiner(logger, quarantineStore, "link_event", "channel")
+	if err != nil {
+		logger.Fatal("Failed to create new quarantiner", zap.Error(err))
+	}
+	linkEventProcessor := ioprocessors.NewProcessor(
+		logger,
+		TDP_BUFFER_SIZE,
+		linkEventChannelReader, linkEventWriter, linkEventQuarantiner,
+		ioprocessors.WithName("link_event"),
+	)
+	drain.Go(func() error {
+		return runUntilDrained(drainCtx, logger, linkEventProcessor)
+	})
+
+	commandAckChannelReader := readers.NewChannelReader(logger, commandAckChannel)
+	commandAckWriter, err := writers.NewCommandAckWriter(logger, db)
+	if err != nil {
+		logger.Fatal("Failed to create new command ack writer", zap.Error(err))
+	}
+	commandAckQuarantiner, err := quarantiners.NewStoreQuarantiner(logger, quarantineStore, "command_ack", "channel")
+	if err != nil {
+		logger.Fatal("Failed to create new quarantiner", zap.Error(err))
+	}
+	commandAckProcessor := ioprocessors.NewProcessor(
+		logger,
+		TDP_BUFFER_SIZE,
+		commandAckChannelReader, commandAckWriter, commandAckQuarantiner,
+		ioprocessors.WithName("command_ack"),
+	)
+	drain.Go(func() error {
+		return runUntilDrained(drainCtx, logger, commandAckProcessor)
+	})
+
+	// The shutdown timeout runs from the signal, so it also bounds writing
+	// the packets the telemetry processor had queued
+	context.AfterFunc(ctx, func() {
+		time.AfterFunc(envConfig.ShutdownTimeout, func() {
+			logger.Warn("Shutdown timeout reached, dropping undrained packets")
+			cancelDrain()
+		})
 	})
 
 	if err := eg.Wait(); err != nil {
 		logger.Error("Some process has terminated", zap.Error(err))
 	}
+
+	logger.Info("Stopped reading packets, draining processors",
+		zap.Duration("Timeout", envConfig.ShutdownTimeout))
+
+	if err := drain.Wait(); err != nil {
+		logger.Error("Some process failed while draining", zap.Error(err))
+	}
 	logger.Info("All services have stopped")
 }
 
+// runUntilDrained runs p until its reader runs out of messages or ctx is done,
+// then closes it so its writer flushes anything it buffered
+func runUntilDrained(ctx context.Context, logger *zap.Logger, p *ioprocessors.Processor) error {
+	err := p.Start(ctx)
+	if err != nil {
+		logger.Error("Processor stopped with an error", zap.Error(err))
+	}
+	if closeErr := p.Close(); closeErr != nil {
+		err = errors.Join(err, closeErr)
+	}
+	return err
+}
+
+// buildOutputs builds the writer for each output of the telemetry processor
+// from the topology at path, or from defaultTopology when path is empty.
+// Outputs the topology leaves out are sent to their default sink.
+func buildOutputs(
+	logger *zap.Logger,
+	path string,
+	sinks map[string]writers.Writer,
+) (map[string]writers.Writer, error) {
+	topology := writers.Topology{}
+	if path != "" {
+		t, err := writers.LoadTopology(path)
+		if err != nil {
+			return nil, err
+		}
+		topology = t
+		logger.Info("Loaded writer topology", zap.String("Path", path))
+	}
+
+	outputs := map[string]writers.Writer{}
+	var errs error
+	for output, spec := range defaultTopology {
+		if _, ok := topology[output]; !ok {
+			topology[output] = spec
+		}
+		w, err := topology.Build(logger, output, sinks)
+		errs = errors.Join(errs, err)
+		outputs[output] = w
+	}
+
+	for output := range topology {
+		if _, ok := defaultTopology[output]; !ok {
+			errs = errors.Join(errs, fmt.Errorf("unknown output %q", output))
+		}
+	}
+
+	return outputs, errs
+}
+
+// newTelemetryReader replays a capture if REPLAY_DIR is set, reads packets from
+// a TCP stream if TCP_DIAL_ADDRESS or TCP_LISTEN_ADDRESS is set, and from UDP
+// datagrams otherwise. It also returns the reader's name for quarantined
+// messages.
+func newTelemetryReader(
+	logger *zap.Logger,
+	envConfig *config.TelemetryGatewayConfig,
+	registry *turiondatapacket.Registry,
+) (readers.Reader, string, error) {
+	// Only packets the dictionary knows are accepted when looking for the
+	// next packet after corrupt data
+	known := func(apid uint16) bool {
+		_, ok := registry.Lookup(apid)
+		return ok
+	}
+
+	switch {
+	case envConfig.ReplayDir != "":
+		r, err := readers.NewCaptureReader(logger, envConfig.ReplayDir,
+			readers.WithReplaySpeed(envConfig.ReplaySpeed),
+		)
+		return r, "replay", err
+
+	case envConfig.TCPDialAddress != "":
+		r, err := readers.NewTCPReader(logger,
+			readers.WithTCPDial(envConfig.TCPDialAddress),
+			readers.WithKnownAPIDs(known),
+		)
+		return r, "tcp", err
+
+	case envConfig.TCPListenAddress != "":
+		r, err := readers.NewTCPReader(logger,
+			readers.WithTCPListen(envConfig.TCPListenAddress),
+			readers.WithKnownAPIDs(known),
+		)
+		return r, "tcp", err
+	}
+
+	addr, err := net.ResolveUDPAddr("udp", envConfig.GroundStationEmulatorAddress)
+	if err != nil {
+		return nil, "", fmt.Errorf("resolving ground station address: %w", err)
+	}
+
+	r, err := readers.NewUDPReader(
+		logger,
+		readers.WithUDPAddr(addr),
+		readers.WithReceiveBuffer(envConfig.UDPReceiveBufferBytes),
+		readers.WithMulticastInterface(envConfig.UDPMulticastInterface),
+		readers.WithAllowedSources(envConfig.UDPAllowedSources...),
+	)
+	return r, "udp", err
+}
+
+// newFrameDemuxer extracts packets from the transfer frames in each datagram
+// and writes them to tdpWriter. Packets tdpWriter fails are quarantined by
+// themselves, from the "frame" reader.
+func newFrameDemuxer(
+	logger *zap.Logger,
+	envConfig *config.TelemetryGatewayConfig,
+	tdpWriter writers.Writer,
+	store quarantiners.Store,
+) (*writers.FrameDemuxer, error) {
+	packetQuarantiner, err := quarantiners.NewStoreQuarantiner(logger, store, "telemetry", "frame")
+	if err != nil {
+		return nil, err
+	}
+
+	format := turiondatapacket.FrameFormat{
+		Type:             turiondatapacket.FrameType(envConfig.FrameType),
+		HasFECF:          envConfig.FrameFECF,
+		HasOCF:           envConfig.FrameAOSOCF,
+		InsertZoneLength: envConfig.FrameAOSInsertZoneSize,
+	}
+	return writers.NewFrameDemuxer(logger, format, tdpWriter, packetQuarantiner)
+}
+
+// newPacketRegistry builds the packet registry from the telemetry dictionary at
+// path, or falls back to the built-in main bus packet when no path is set,
+// with packet error control if errorControl is set. Command acknowledgements
+// are always registered. It also returns the limits to use until a limit set
+// is loaded.
+func newPacketRegistry(path string, errorControl bool) (*turiondatapacket.Registry, *turiondatapacket.LimitSet, error) {
+	registry := turiondatapacket.NewRegistry()
+	if err := registry.Register(turiondatapacket.CommandAckDefinition); err != nil {
+		return nil, nil, err
+	}
+
+	if path == "" {
+		def := turiondatapacket.TurionDataPacketDefinition
+		def.ErrorControl = errorControl
+		if err := registry.Register(def); err != nil {
+			return nil, nil, err
+		}
+		return registry, &turiondatapacket.DefaultLimitSet, nil
+	}
+
+	dictionary, err := turiondatapacket.LoadDictionary(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := dictionary.Register(registry); err != nil {
+		return nil, nil, err
+	}
+	return registry, dictionary.LimitSet(), nil
+}
+
+// newAlertDispatcher sends anomaly events to every URL in ALERT_WEBHOOK_URLS,
+// or to the telemetry API when none are set
+func newAlertDispatcher(
+	logger *zap.Logger,
+	envConfig *config.TelemetryGatewayConfig,
+) (*notifier.Dispatcher, error) {
+	urls := envConfig.AlertWebhookURLs
+	if len(urls) == 0 {
+		u, err := url.JoinPath(envConfig.TelemetryAPIServerURL, api.ROUTE_ANOMALIES_NEW)
+		if err != nil {
+			return nil, fmt.Errorf("building anomaly webhook url: %w", err)
+		}
+		urls = []string{u}
+	}
+
+	var notifiers []notifier.Notifier
+	for _, u := range urls {
+		if u == config.ALERT_LOG_SINK {
+			notifiers = append(notifiers, notifier.NewLogNotifier(logger))
+			continue
+		}
+
+		n, err := notifier.NewWebhookNotifier(logger, u)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, n)
+		logger.Info("Sending alerts to webhook", zap.String("URL", u))
+	}
+
+	return notifier.NewDispatcher(
+		logger,
+		notifiers,
+		notifier.WithRateLimit(envConfig.AlertRateLimit, max(1, int(envConfig.AlertRateLimit))),
+		notifier.WithDedupWindow(envConfig.AlertDedupWindow),
+	)
+}
+
+// restoreAnomalyTracker returns a tracker that carries on the anomaly events
+// left unresolved by the last run. Duplicate open events for one parameter are
+// resolved in s.
+func restoreAnomalyTracker(
+	ctx context.Context,
+	logger *zap.Logger,
+	s store.DataPacketStore,
+) (*turiondatapacket.AnomalyTracker, error) {
+	events, err := s.FetchOpenAnomalies(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tracker := turiondatapacket.NewAnomalyTracker()
+	for _, event := range tracker.Restore(events) {
+		if _, err := s.UpsertAnomaly(ctx, event); err != nil {
+			return nil, fmt.Errorf("resolve duplicate anomaly event %s: %w", event.EventId, err)
+		}
+	}
+
+	logger.Info("Restored open anomaly events", zap.Int("Events", len(events)))
+	return tracker, nil
+}
+
+// watchLimits reloads the limit set from source on start, every interval and
+// whenever the gateway receives SIGHUP, until ctx is done. A limit set that
+// fails to load or validate leaves the current limits in place.
+func watchLimits(
+	ctx context.Context,
+	logger *zap.Logger,
+	source turiondatapacket.LimitSource,
+	checker *turiondatapacket.LimitChecker,
+	interval time.Duration,
+) {
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	reload := func() {
+		set, err := source.LoadLimits(ctx)
+		if errors.Is(err, store.ErrNoActiveLimitSet) {
+			logger.Debug("No active limit set, keeping current limits", zap.String("Limit set", checker.Name()))
+			return
+		}
+		if err == nil {
+			err = checker.Reload(set)
+		}
+		if err != nil {
+			logger.Error("Failed to reload limits, keeping current limits",
+				zap.String("Limit set", checker.Name()),
+				zap.Error(err))
+			return
+		}
+		logger.Debug("Reloaded limits", zap.String("Limit set", set.Name), zap.Int("Limits", len(set.Limits)))
+	}
+
+	reload()
+	logger.Info("Limit checking started", zap.String("Limit set", checker.Name()))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reload()
+		case <-hangup:
+			logger.Info("Received SIGHUP, reloading limits")
+			reload()
+		}
+	}
+}
+
 // I couldn't get the golang docker migrator to work so I threw this together instead
 func applyMigrations(db *sql.DB, dir string) error {
 	entries, err := os.ReadDir(dir)
@@ -2,16 +2,28 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"errors"
+	"io"
 	"log"
 	"math/rand"
 	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 	"turion-takehome/internal/config"
+	"turion-takehome/internal/scenario"
+	"turion-takehome/internal/simulation"
 	tdp "turion-takehome/internal/turiondatapacket"
 )
 
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	config, err := config.NewTelemetryGeneratorConfig()
 	if err != nil {
 		log.Fatal(err)
@@ -21,9 +33,31 @@ func main() {
 		log.Fatal(err)
 	}
 	defer conn.Close()
+
+	if config.CommandListenAddress != "" {
+		dictionary := &tdp.DefaultCommandDictionary
+		if config.CommandDictionaryPath != "" {
+			dictionary, err = tdp.LoadCommandDictionary(config.CommandDictionaryPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+		}
+		acker := &commandAcker{dictionary: dictionary, conn: conn}
+		go listenForCommands(ctx, config.CommandListenNetwork, config.CommandListenAddress, acker)
+	}
+
+	if config.ScenarioPath != "" {
+		runScenario(ctx, config, conn)
+		return
+	}
+	if config.SimulationPath != "" {
+		runSimulation(ctx, config, conn)
+		return
+	}
+
 	packetCount := uint16(0)
 	for {
-		data := createTelemetryPacket(&packetCount)
+		data := createTelemetryPacket(&packetCount, config.PacketErrorControl)
 		_, err := conn.Write(data)
 		if err != nil {
 			log.Printf("Error sending telemetry: %v", err)
@@ -34,32 +68,154 @@ func main() {
 		} else {
 			log.Printf("Sent normal telemetry packet #%d\n", packetCount)
 		}
-		time.Sleep(1 * time.Second)
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping after %d packets", packetCount+1)
+			return
+		case <-time.After(1 * time.Second):
+		}
 		packetCount++
 	}
 }
-func createTelemetryPacket(seqCount *uint16) []byte {
-	buf := new(bytes.Buffer)
-	// Create primary header
-	// PacketID: Version(3) | Type(1) | SecHdrFlag(1) | APID(11)
-	packetID := uint16(tdp.PACKET_VERSION)<<13 |
-		uint16(tdp.PACKET_TYPE)<<12 |
-		uint16(tdp.SEC_HDR_FLAG)<<11 |
-		uint16(tdp.APID)
 
-	// PacketSeqCtrl: SeqFlags(2) | SeqCount(14)
-	packetSeqCtrl := uint16(tdp.SEQ_FLAGS)<<14 | (*seqCount & 0x3FFF)
+// runScenario plays the scenario at SCENARIO_PATH until it ends or the
+// generator is stopped
+func runScenario(ctx context.Context, config *config.TelemetryGeneratorConfig, conn net.Conn) {
+	s, err := scenario.Load(config.ScenarioPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	runner, err := scenario.NewRunner(s, telemetryDictionary(config), time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Playing scenario %q", s.Name)
+	sent := 0
+	err = runner.Run(ctx, func(b []byte) {
+		if _, err := conn.Write(b); err != nil {
+			log.Printf("Error sending telemetry: %v", err)
+			return
+		}
+		sent++
+	})
+	if err != nil {
+		log.Printf("Stopping scenario after %d packets", sent)
+		return
+	}
+	log.Printf("Scenario %q finished after %d packets", s.Name, sent)
+}
+
+// runSimulation sends the simulated spacecraft's telemetry every interval
+// until the generator is stopped. Every dictionary packet with a simulated
+// parameter is sent, with the others as zero.
+func runSimulation(ctx context.Context, config *config.TelemetryGeneratorConfig, conn net.Conn) {
+	c, err := simulation.Load(config.SimulationPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sim, err := simulation.NewSimulator(c, time.Now())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var packets []tdp.PacketSpec
+	values := sim.Values()
+	for _, p := range telemetryDictionary(config).Packets {
+		for _, param := range p.Parameters {
+			if _, ok := values[param.Name]; ok {
+				packets = append(packets, p)
+				break
+			}
+		}
+	}
+	if len(packets) == 0 {
+		log.Fatal("no packet in the telemetry dictionary has a simulated parameter")
+	}
+	seqCounts := make([]uint16, len(packets))
+
+	log.Printf("Simulating at %vx, %d packets every %s", c.TimeScale, len(packets), c.Interval)
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	step := time.Duration(float64(c.Interval) * c.TimeScale)
+	state := sim.State()
+	for {
+		values := sim.Values()
+		for i, p := range packets {
+			b, err := p.Encode(seqCounts[i], uint64(time.Now().Unix()), values)
+			if err != nil {
+				log.Fatal(err)
+			}
+			seqCounts[i] = (seqCounts[i] + 1) & tdp.MAX_SEQUENCE_COUNT
+			if _, err := conn.Write(b); err != nil {
+				log.Printf("Error sending telemetry: %v", err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("Stopping simulation at %s", state.Time.Format(time.RFC3339))
+			return
+		case <-ticker.C:
+		}
+
+		next := sim.Advance(step)
+		switch {
+		case next.Eclipse && !state.Eclipse:
+			log.Printf("Entering eclipse at %s, battery at %.1f%%", next.Time.Format(time.RFC3339), next.Charge)
+		case !next.Eclipse && state.Eclipse:
+			log.Printf("Leaving eclipse at %s, battery at %.1f%%", next.Time.Format(time.RFC3339), next.Charge)
+		}
+		switch {
+		case next.StationInView && !state.StationInView:
+			log.Printf("Ground station in view at %s, range %.0f km", next.Time.Format(time.RFC3339), next.Range)
+		case !next.StationInView && state.StationInView:
+			log.Printf("Ground station lost at %s, range %.0f km", next.Time.Format(time.RFC3339), next.Range)
+		}
+		state = next
+	}
+}
+
+// telemetryDictionary loads TELEMETRY_DICTIONARY_PATH, or falls back to the
+// built-in main bus packet, with packet error control if PACKET_ERROR_CONTROL
+// is set
+func telemetryDictionary(config *config.TelemetryGeneratorConfig) *tdp.Dictionary {
+	if config.TelemetryDictionaryPath == "" {
+		dictionary := &tdp.Dictionary{Packets: []tdp.PacketSpec{tdp.MainBusPacketSpec}}
+		dictionary.Packets[0].ErrorControl = config.PacketErrorControl
+		return dictionary
+	}
+
+	dictionary, err := tdp.LoadDictionary(config.TelemetryDictionaryPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return dictionary
+}
+
+func createTelemetryPacket(seqCount *uint16, errorControl bool) []byte {
+	buf := new(bytes.Buffer)
 	// Generate telemetry data
 	payload := generateTelemetryPayload(*seqCount%5 == 0)
-	// Calculate total packet length (excluding primary header first 6 bytes)
-	packetDataLength := uint16(binary.Size(tdp.CCSDSSecondaryHeader{}) +
-		binary.Size(tdp.TelemetryPayload{}) - 1)
-
-	primaryHeader := tdp.CCSDSPrimaryHeader{
-		PacketID:      packetID,
-		PacketSeqCtrl: packetSeqCtrl,
-		PacketLength:  packetDataLength,
+	// Packet data length excludes the 6 byte primary header
+	packetDataLength := binary.Size(tdp.CCSDSSecondaryHeader{}) +
+		binary.Size(tdp.TelemetryPayload{})
+	if errorControl {
+		packetDataLength += tdp.PEC_SIZE
 	}
+
+	// Create primary header
+	// PacketID: Version(3) | Type(1) | SecHdrFlag(1) | APID(11)
+	// PacketSeqCtrl: SeqFlags(2) | SeqCount(14)
+	primaryHeader := tdp.NewCCSDSPrimaryHeader(tdp.PrimaryHeaderFields{
+		Version:             tdp.PACKET_VERSION,
+		Type:                tdp.PACKET_TYPE,
+		SecondaryHeaderFlag: tdp.SEC_HDR_FLAG == 1,
+		APID:                tdp.APID,
+		SequenceFlags:       tdp.SEQ_FLAGS,
+		SequenceCount:       *seqCount,
+	}, packetDataLength)
 	// Create secondary header
 	secondaryHeader := tdp.CCSDSSecondaryHeader{
 		Timestamp: uint64(time.Now().Unix()),
@@ -70,6 +226,9 @@ func createTelemetryPacket(seqCount *uint16) []byte {
 	binary.Write(buf, binary.BigEndian, primaryHeader) // CCSDS uses big-endian
 	binary.Write(buf, binary.BigEndian, secondaryHeader)
 	binary.Write(buf, binary.BigEndian, payload)
+	if errorControl {
+		return tdp.AppendErrorControl(buf.Bytes())
+	}
 	return buf.Bytes()
 }
 
@@ -119,3 +278,136 @@ func generateTelemetryPayload(generateAnomaly bool) tdp.TelemetryPayload {
 func randomFloat(min, max float32) float32 {
 	return min + rand.Float32()*(max-min)
 }
+
+// listenForCommands stands in for the spacecraft's command receiver. Every
+// telecommand received on address is handed to acker, one per datagram over
+// UDP or back to back on each TCP connection.
+func listenForCommands(ctx context.Context, network, address string, acker *commandAcker) {
+	if network == "tcp" {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			<-ctx.Done()
+			listener.Close()
+		}()
+		log.Printf("Listening for commands on tcp %s", address)
+
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Printf("Error accepting uplink connection: %v", err)
+				continue
+			}
+			go func() {
+				defer c.Close()
+				readCommandStream(c, acker)
+			}()
+		}
+	}
+
+	pc, err := net.ListenPacket("udp", address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		<-ctx.Done()
+		pc.Close()
+	}()
+	log.Printf("Listening for commands on udp %s", address)
+
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Error reading command: %v", err)
+			continue
+		}
+		acker.handle(buf[:n])
+	}
+}
+
+// readCommandStream reads packets off a TCP stream, using each primary header
+// to know where the packet ends, until the connection closes
+func readCommandStream(r io.Reader, acker *commandAcker) {
+	header := make([]byte, tdp.PRIMARY_HEADER_SIZE)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return
+		}
+		h, err := tdp.ParsePrimaryHeader(header)
+		if err != nil {
+			log.Printf("Dropping uplink connection, bad packet header: %v", err)
+			return
+		}
+		pkt := make([]byte, h.TotalLength())
+		copy(pkt, header)
+		if _, err := io.ReadFull(r, pkt[tdp.PRIMARY_HEADER_SIZE:]); err != nil {
+			return
+		}
+		acker.handle(pkt)
+	}
+}
+
+// commandAcker checks telecommands against the command dictionary and sends
+// an acknowledgement for each one down to the gateway
+type commandAcker struct {
+	dictionary *tdp.CommandDictionary
+	conn       net.Conn
+
+	mu       sync.Mutex
+	seqCount uint16
+}
+
+func (a *commandAcker) handle(b []byte) {
+	h, err := tdp.ParsePrimaryHeader(b)
+	if err != nil {
+		log.Printf("Ignoring command that isn't a space packet: %v", err)
+		return
+	}
+	ack := tdp.CommandAckPayload{
+		CommandAPID:     h.APID(),
+		CommandSeqCount: h.SequenceCount(),
+	}
+
+	tc, err := tdp.DecodeTelecommand(b)
+	switch {
+	case errors.Is(err, tdp.ErrChecksum):
+		ack.Status = tdp.ACK_STATUS_CHECKSUM
+	case err != nil:
+		log.Printf("Ignoring malformed command: %v", err)
+		return
+	default:
+		ack.Opcode = tc.Opcode
+		spec, ok := a.dictionary.LookupOpcode(h.APID(), tc.Opcode)
+		if !ok {
+			ack.Status = tdp.ACK_STATUS_UNKNOWN_COMMAND
+			break
+		}
+		args, err := spec.DecodeArguments(tc.Arguments)
+		if err != nil {
+			ack.Status = tdp.ACK_STATUS_INVALID_ARGUMENTS
+			break
+		}
+		log.Printf("Executing command %s #%d %v", spec.Name, h.SequenceCount(), args)
+	}
+
+	a.mu.Lock()
+	pkt := tdp.EncodeCommandAck(a.seqCount, ack, time.Now())
+	a.seqCount = (a.seqCount + 1) & tdp.MAX_SEQUENCE_COUNT
+	a.mu.Unlock()
+
+	if _, err := a.conn.Write(pkt); err != nil {
+		log.Printf("Error sending command acknowledgement: %v", err)
+		return
+	}
+	log.Printf("Acknowledged command #%d on APID %#x: %s",
+		ack.CommandSeqCount, ack.CommandAPID, tdp.AckStatusText(ack.Status))
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"strconv"
+	"time"
+	"turion-takehome/internal/metrics"
+
+	"github.com/labstack/echo/v4"
+)
+
+// MetricsMiddleware counts and times every request by route template, so
+// /api/v1/anomaly/:id/ack is one series no matter how many IDs are acked
+func MetricsMiddleware() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			start := time.Now()
+			err := next(c)
+			if err != nil {
+				// Let echo write the error response now so the status is known
+				c.Error(err)
+			}
+
+			route := c.Path()
+			if route == "" {
+				route = "unmatched"
+			}
+			method := c.Request().Method
+
+			metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(c.Response().Status)).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+			return nil
+		}
+	}
+}
@@ -2,7 +2,14 @@ package api
 
 import (
 	"net/http"
+	anomalyhandlers "turion-takehome/internal/api/v1/anomaly"
+	commandhandlers "turion-takehome/internal/api/v1/command"
+	linkhandlers "turion-takehome/internal/api/v1/link"
+	streamhandlers "turion-takehome/internal/api/v1/stream"
 	telemhandlers "turion-takehome/internal/api/v1/telemetry"
+	"turion-takehome/internal/commanding"
+	"turion-takehome/internal/metrics"
+	"turion-takehome/internal/pubsub"
 	"turion-takehome/internal/store"
 
 	"github.com/labstack/echo/v4"
@@ -11,21 +18,44 @@ import (
 
 const (
 	ROUTE_PING                   = "/api/v1/ping"
+	ROUTE_METRICS                = "/metrics"
 	ROUTE_TELEMETRY              = "/api/v1/telemetry"
 	ROUTE_TELEMETRY_CURRENT      = "/api/v1/telemetry/current"
 	ROUTE_TELEMETRY_ANOMALIES    = "/api/v1/telemetry/anomaly"
 	ROUTE_TELEMETRY_AGGREGATIONS = "/api/v1/telemetry/aggregation"
 	ROUTE_ANOMALIES_NEW          = "/api/v1/anomaly/new"
+	ROUTE_ANOMALIES_OPEN         = "/api/v1/anomaly/open"
+	ROUTE_ANOMALIES_ACK          = "/api/v1/anomaly/:id/ack"
+	ROUTE_LINK_GAPS              = "/api/v1/link/gaps"
+	ROUTE_LINK_CORRUPTED         = "/api/v1/link/corrupted"
+	ROUTE_COMMANDS               = "/api/v1/commands"
+	ROUTE_COMMANDS_DICTIONARY    = "/api/v1/commands/dictionary"
+	ROUTE_COMMAND                = "/api/v1/commands/:id"
+	ROUTE_STREAM_WS              = "/api/v1/stream/ws"
+	ROUTE_STREAM_SSE             = "/api/v1/stream/sse"
 )
 
 // RegisterRoutes mounts all of your telemetry routes onto the Echo instance.
-func RegisterRoutes(e *echo.Echo, store store.DataPacketStore, logger *zap.Logger) {
+// commander is nil when commanding is disabled.
+func RegisterRoutes(
+	e *echo.Echo,
+	store store.DataPacketStore,
+	broker *pubsub.Broker,
+	commander *commanding.Commander,
+	logger *zap.Logger,
+) {
+	e.Use(MetricsMiddleware())
+
+	// Prometheus metrics
+	e.GET(ROUTE_METRICS, echo.WrapHandler(metrics.Handler()))
+
 	// Basic health check
 	e.GET(ROUTE_PING, func(c echo.Context) error {
 		return c.String(http.StatusOK, "pong")
 	})
 
-	// GET /api/v1/telemetry?start_time=<ISO>&end_time=<ISO>
+	// GET /api/v1/telemetry?start_time=<ISO>&end_time=<ISO>[&limit=<int>][&cursor=<cursor>]
+	//   [&order=<asc|desc>][&fields=<name,...>][&subsystem_id=<int>][&apid=<int>]
 	e.GET(ROUTE_TELEMETRY, telemhandlers.TelemetryList(store, logger))
 
 	// GET /api/v1/telemetry/current
@@ -34,6 +64,41 @@ func RegisterRoutes(e *echo.Echo, store store.DataPacketStore, logger *zap.Logge
 	// GET /api/v1/telemetry/anomalies?start_time=<ISO>&end_time=<ISO>
 	e.GET(ROUTE_TELEMETRY_ANOMALIES, telemhandlers.AnomaliesHandler(store, logger))
 
-	// GET /api/v1/telemetry/stats?start_time=<ISO>&end_time=<ISO>
+	// GET /api/v1/telemetry/aggregation?start_time=<ISO>&end_time=<ISO>
+	//   [&bucket=<1m|5m|15m|1h|6h|1d>[&percentiles=<number,...>] | &mode=lttb[&points=<int>]]
+	//   [&parameters=<name,...>]
 	e.GET(ROUTE_TELEMETRY_AGGREGATIONS, telemhandlers.AggregationHandler(store, logger))
+
+	// POST /api/v1/anomaly/new with a JSON anomaly event, sent by the gateway
+	e.POST(ROUTE_ANOMALIES_NEW, anomalyhandlers.NewHandler(store, logger))
+
+	// GET /api/v1/anomaly/open
+	e.GET(ROUTE_ANOMALIES_OPEN, anomalyhandlers.OpenHandler(store, logger))
+
+	// POST /api/v1/anomaly/:id/ack {"operator": "...", "note": "..."}
+	e.POST(ROUTE_ANOMALIES_ACK, anomalyhandlers.AcknowledgeHandler(store, logger))
+
+	// GET /api/v1/link/gaps?start_time=<ISO>&end_time=<ISO>[&apid=<int>]
+	e.GET(ROUTE_LINK_GAPS, linkhandlers.GapsHandler(store, logger))
+
+	// GET /api/v1/link/corrupted?start_time=<ISO>&end_time=<ISO>
+	e.GET(ROUTE_LINK_CORRUPTED, linkhandlers.CorruptedHandler(store, logger))
+
+	// POST /api/v1/commands {"name": "...", "arguments": {"<name>": <number>}}
+	e.POST(ROUTE_COMMANDS, commandhandlers.SendHandler(commander, logger))
+
+	// GET /api/v1/commands[?limit=<int>]
+	e.GET(ROUTE_COMMANDS, commandhandlers.HistoryHandler(store, logger))
+
+	// GET /api/v1/commands/dictionary
+	e.GET(ROUTE_COMMANDS_DICTIONARY, commandhandlers.DictionaryHandler(commander))
+
+	// GET /api/v1/commands/:id
+	e.GET(ROUTE_COMMAND, commandhandlers.GetHandler(store, logger))
+
+	// GET /api/v1/stream/ws[?topic=<telemetry|anomalies>][&apid=<int>][&parameter=<name>]
+	e.GET(ROUTE_STREAM_WS, streamhandlers.WebSocketHandler(broker, logger))
+
+	// GET /api/v1/stream/sse[?topic=<telemetry|anomalies>][&apid=<int>][&parameter=<name>]
+	e.GET(ROUTE_STREAM_SSE, streamhandlers.SSEHandler(broker, logger))
 }
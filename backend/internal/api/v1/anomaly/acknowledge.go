@@ -0,0 +1,52 @@
+package anomaly
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"turion-takehome/internal/store"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type acknowledgeRequest struct {
+	Operator string `json:"operator"`
+	Note     string `json:"note"`
+}
+
+// AcknowledgeHandler marks the anomaly event :id as seen by an operator. The
+// event stays acknowledged until its parameter is back within limits.
+func AcknowledgeHandler(
+	s store.DataPacketStore,
+	logger *zap.Logger,
+) func(echo.Context) error {
+	return func(c echo.Context) error {
+		var req acknowledgeRequest
+		if err := c.Bind(&req); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid request body: "+err.Error())
+		}
+
+		req.Operator = strings.TrimSpace(req.Operator)
+		if req.Operator == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "`operator` is required")
+		}
+
+		id := c.Param("id")
+		anomaly, err := s.AcknowledgeAnomaly(c.Request().Context(), id, req.Operator, req.Note)
+		if errors.Is(err, store.ErrAnomalyNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			logger.Error("failed to acknowledge anomaly", zap.String("event id", id), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		logger.Info("anomaly acknowledged",
+			zap.String("event id", id),
+			zap.String("operator", req.Operator),
+		)
+		return c.JSON(http.StatusOK, anomaly)
+	}
+}
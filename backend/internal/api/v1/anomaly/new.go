@@ -0,0 +1,55 @@
+package anomaly
+
+import (
+	"io"
+	"net/http"
+	"turion-takehome/internal/store"
+	"turion-takehome/internal/turiondatapacket"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// NewHandler receives anomaly events POSTed by the gateway's webhook notifier
+// and stores them. The same update may be delivered more than once, storing it
+// is idempotent. Subscribers get anomaly events from the gateway over Postgres
+// NOTIFY, not from here.
+func NewHandler(
+	s store.DataPacketStore,
+	logger *zap.Logger,
+) func(echo.Context) error {
+	return func(c echo.Context) error {
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"reading request body: "+err.Error())
+		}
+
+		var a turiondatapacket.Anomaly
+		if err := protojson.Unmarshal(body, &a); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid anomaly: "+err.Error())
+		}
+
+		if a.EventId == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "`eventId` is required")
+		}
+
+		if _, err := s.UpsertAnomaly(c.Request().Context(), &a); err != nil {
+			logger.Error("failed to store anomaly", zap.String("event id", a.EventId), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		logger.Warn("🚨 Anomaly received",
+			zap.String("event id", a.EventId),
+			zap.String("state", a.State.String()),
+			zap.String("severity", a.Severity.String()),
+			zap.Uint32("apid", a.Apid),
+			zap.String("parameter", a.Parameter),
+			zap.Float32("value", a.Value),
+		)
+
+		return c.NoContent(http.StatusAccepted)
+	}
+}
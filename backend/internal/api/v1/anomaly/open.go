@@ -0,0 +1,26 @@
+package anomaly
+
+import (
+	"net/http"
+	"turion-takehome/internal/store"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// OpenHandler returns every anomaly event that has not resolved yet, including
+// the acknowledged ones
+func OpenHandler(
+	store store.DataPacketStore,
+	logger *zap.Logger,
+) func(echo.Context) error {
+	return func(c echo.Context) error {
+		anomalies, err := store.FetchOpenAnomalies(c.Request().Context())
+		if err != nil {
+			logger.Error("failed to fetch open anomalies", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, anomalies)
+	}
+}
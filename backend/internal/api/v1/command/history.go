@@ -0,0 +1,70 @@
+package command
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"turion-takehome/internal/store"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Number of commands returned by HistoryHandler when no limit is given, and
+// the most it returns
+const (
+	DEFAULT_HISTORY_LIMIT = 100
+	MAX_HISTORY_LIMIT     = 1000
+)
+
+// HistoryHandler returns the most recent commands and their state, newest
+// first
+func HistoryHandler(
+	s store.DataPacketStore,
+	logger *zap.Logger,
+) func(echo.Context) error {
+	return func(c echo.Context) error {
+		limit := DEFAULT_HISTORY_LIMIT
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			v, err := strconv.Atoi(limitStr)
+			if err != nil || v <= 0 || v > MAX_HISTORY_LIMIT {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					"`limit` must be a whole number from 1 to "+strconv.Itoa(MAX_HISTORY_LIMIT))
+			}
+			limit = v
+		}
+
+		commands, err := s.FetchCommands(c.Request().Context(), limit)
+		if err != nil {
+			logger.Error("failed to fetch commands", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, commands)
+	}
+}
+
+// GetHandler returns the command :id
+func GetHandler(
+	s store.DataPacketStore,
+	logger *zap.Logger,
+) func(echo.Context) error {
+	return func(c echo.Context) error {
+		id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid id: "+err.Error())
+		}
+
+		cmd, err := s.FetchCommand(c.Request().Context(), id)
+		if errors.Is(err, store.ErrCommandNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		if err != nil {
+			logger.Error("failed to fetch command", zap.Int64("id", id), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, cmd)
+	}
+}
@@ -0,0 +1,70 @@
+package command
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"turion-takehome/internal/commanding"
+	"turion-takehome/internal/turiondatapacket"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+type sendRequest struct {
+	Name      string             `json:"name"`
+	Arguments map[string]float64 `json:"arguments"`
+}
+
+// SendHandler validates a command against the command dictionary, records it
+// and sends it to the spacecraft. The command is returned once it has been
+// written to the uplink, its acknowledgement is recorded later by the gateway.
+// commander is nil when no uplink is configured.
+func SendHandler(
+	commander *commanding.Commander,
+	logger *zap.Logger,
+) func(echo.Context) error {
+	return func(c echo.Context) error {
+		if commander == nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable,
+				"commanding is disabled, UPLINK_ADDRESS is not set")
+		}
+
+		var req sendRequest
+		if err := c.Bind(&req); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid request body: "+err.Error())
+		}
+
+		req.Name = strings.TrimSpace(req.Name)
+		if req.Name == "" {
+			return echo.NewHTTPError(http.StatusBadRequest, "`name` is required")
+		}
+
+		cmd, err := commander.Send(c.Request().Context(), req.Name, req.Arguments)
+		switch {
+		case errors.Is(err, turiondatapacket.ErrUnknownCommand),
+			errors.Is(err, turiondatapacket.ErrInvalidArgument):
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		case errors.Is(err, commanding.ErrUplink) && cmd != nil:
+			// The command is in the history as failed
+			return c.JSON(http.StatusBadGateway, cmd)
+		case err != nil:
+			logger.Error("failed to send command", zap.String("command", req.Name), zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(http.StatusCreated, cmd)
+	}
+}
+
+// DictionaryHandler returns every command that can be sent and its arguments
+func DictionaryHandler(commander *commanding.Commander) func(echo.Context) error {
+	return func(c echo.Context) error {
+		if commander == nil {
+			return echo.NewHTTPError(http.StatusServiceUnavailable,
+				"commanding is disabled, UPLINK_ADDRESS is not set")
+		}
+		return c.JSON(http.StatusOK, commander.Dictionary().Commands)
+	}
+}
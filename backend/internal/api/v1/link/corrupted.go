@@ -0,0 +1,47 @@
+package link
+
+import (
+	"net/http"
+	"time"
+	"turion-takehome/internal/store"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// CorruptedHandler returns how many packets on each APID failed their packet
+// error control check in a time range
+func CorruptedHandler(
+	store store.DataPacketStore,
+	logger *zap.Logger,
+) func(echo.Context) error {
+	return func(c echo.Context) error {
+		startStr := c.QueryParam("start_time")
+		endStr := c.QueryParam("end_time")
+		if startStr == "" || endStr == "" {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"`start_time` and `end_time` are required (ISO8601)")
+		}
+
+		startT, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid start_time: "+err.Error())
+		}
+
+		endT, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid end_time: "+err.Error())
+		}
+
+		counts, err := store.FetchCorruptedPacketCounts(c.Request().Context(),
+			uint64(startT.Unix()), uint64(endT.Unix()))
+		if err != nil {
+			logger.Error("failed to fetch corrupted packet counts", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, counts)
+	}
+}
@@ -0,0 +1,62 @@
+package link
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+	"turion-takehome/internal/store"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// GapsHandler returns the link quality events (dropped, duplicated, reordered
+// and rolled over packets) detected by the gateway in a time range
+func GapsHandler(
+	store store.DataPacketStore,
+	logger *zap.Logger,
+) func(echo.Context) error {
+	return func(c echo.Context) error {
+		startStr := c.QueryParam("start_time")
+		endStr := c.QueryParam("end_time")
+		if startStr == "" || endStr == "" {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"`start_time` and `end_time` are required (ISO8601)")
+		}
+
+		startT, err := time.Parse(time.RFC3339, startStr)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid start_time: "+err.Error())
+		}
+
+		endT, err := time.Parse(time.RFC3339, endStr)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid end_time: "+err.Error())
+		}
+
+		var apid *uint16
+		if apidStr := c.QueryParam("apid"); apidStr != "" {
+			// base 0 so both 1 and 0x01 work
+			v, err := strconv.ParseUint(apidStr, 0, 11)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					"invalid apid: "+err.Error())
+			}
+			a := uint16(v)
+			apid = &a
+		}
+
+		startTS := uint64(startT.Unix())
+		endTS := uint64(endT.Unix())
+
+		events, err := store.FetchLinkEventsByTimeRange(c.Request().Context(), startTS, endTS, apid)
+		if err != nil {
+			logger.Error("failed to fetch link events", zap.Error(err))
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+
+		return c.JSON(http.StatusOK, events)
+	}
+}
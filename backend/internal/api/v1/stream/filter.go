@@ -0,0 +1,58 @@
+package stream
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"turion-takehome/internal/pubsub"
+)
+
+const (
+	// Events buffered per client before it starts missing them
+	SUBSCRIPTION_BUFFER = 256
+)
+
+// parseFilter reads the topic, apid and parameter query parameters. Each may
+// be repeated or comma separated, e.g. ?topic=telemetry&apid=1,0x02
+func parseFilter(q url.Values) (pubsub.Filter, error) {
+	f := pubsub.Filter{
+		Topics:     map[string]bool{},
+		APIDs:      map[uint32]bool{},
+		Parameters: map[string]bool{},
+	}
+
+	for _, topic := range splitValues(q["topic"]) {
+		if topic != pubsub.TOPIC_TELEMETRY && topic != pubsub.TOPIC_ANOMALIES {
+			return f, fmt.Errorf("unknown topic %q", topic)
+		}
+		f.Topics[topic] = true
+	}
+
+	for _, apid := range splitValues(q["apid"]) {
+		// base 0 so both 1 and 0x01 work
+		v, err := strconv.ParseUint(apid, 0, 11)
+		if err != nil {
+			return f, fmt.Errorf("invalid apid: %w", err)
+		}
+		f.APIDs[uint32(v)] = true
+	}
+
+	for _, parameter := range splitValues(q["parameter"]) {
+		f.Parameters[parameter] = true
+	}
+
+	return f, nil
+}
+
+func splitValues(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
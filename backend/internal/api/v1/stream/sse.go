@@ -0,0 +1,72 @@
+package stream
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"turion-takehome/internal/pubsub"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Proxies drop idle connections, so send a comment at least this often
+const SSE_KEEPALIVE_INTERVAL = 15 * time.Second
+
+// SSEHandler streams live telemetry and anomaly events as Server-Sent Events.
+// The event name is the topic and the data is a pubsub.Event as JSON.
+func SSEHandler(
+	broker *pubsub.Broker,
+	logger *zap.Logger,
+) func(echo.Context) error {
+	return func(c echo.Context) error {
+		filter, err := parseFilter(c.QueryParams())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		sub := broker.Subscribe(SUBSCRIPTION_BUFFER)
+		defer sub.Close()
+
+		w := c.Response()
+		w.Header().Set(echo.HeaderContentType, "text/event-stream")
+		w.Header().Set(echo.HeaderCacheControl, "no-cache")
+		w.Header().Set(echo.HeaderConnection, "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		w.Flush()
+
+		keepalive := time.NewTicker(SSE_KEEPALIVE_INTERVAL)
+		defer keepalive.Stop()
+
+		ctx := c.Request().Context()
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+
+			case <-keepalive.C:
+				if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+					return nil
+				}
+				w.Flush()
+
+			case e, ok := <-sub.C:
+				if !ok {
+					return nil
+				}
+				data, send, err := filter.Apply(e)
+				if err != nil {
+					logger.Error("failed to filter live event", zap.Error(err))
+					continue
+				}
+				if !send {
+					continue
+				}
+				if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Topic, data); err != nil {
+					return nil
+				}
+				w.Flush()
+			}
+		}
+	}
+}
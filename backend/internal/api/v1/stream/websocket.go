@@ -0,0 +1,87 @@
+package stream
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"turion-takehome/internal/pubsub"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketHandler streams live telemetry and anomaly events over a WebSocket,
+// one pubsub.Event as a JSON text message per event. Messages from the client
+// are ignored. See checkOrigin for who may connect.
+func WebSocketHandler(
+	broker *pubsub.Broker,
+	logger *zap.Logger,
+) func(echo.Context) error {
+	return func(c echo.Context) error {
+		filter, err := parseFilter(c.QueryParams())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		server := websocket.Server{Handshake: checkOrigin}
+		server.Handler = func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			sub := broker.Subscribe(SUBSCRIPTION_BUFFER)
+			defer sub.Close()
+
+			// Reading is the only way to notice the client went away
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				var discard []byte
+				for websocket.Message.Receive(ws, &discard) == nil {
+				}
+			}()
+
+			for {
+				select {
+				case <-closed:
+					return
+				case e, ok := <-sub.C:
+					if !ok {
+						return
+					}
+					data, send, err := filter.Apply(e)
+					if err != nil {
+						logger.Error("failed to filter live event", zap.Error(err))
+						continue
+					}
+					if !send {
+						continue
+					}
+					if err := websocket.Message.Send(ws, string(data)); err != nil {
+						return
+					}
+				}
+			}
+		}
+		server.ServeHTTP(c.Response(), c.Request())
+		return nil
+	}
+}
+
+// checkOrigin is the WebSocket's origin policy. Clients that aren't browsers,
+// like scripts and other services, usually send no Origin and are accepted.
+// Browsers always send one, which has to be a valid URL. Any origin may
+// connect, since the stream is read only and needs no credentials, the same
+// as the SSE stream.
+func checkOrigin(config *websocket.Config, req *http.Request) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	u, err := url.ParseRequestURI(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %q: %w", origin, err)
+	}
+	config.Origin = u
+	return nil
+}
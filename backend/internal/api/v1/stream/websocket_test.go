@@ -0,0 +1,38 @@
+package stream
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"golang.org/x/net/websocket"
+)
+
+func TestCheckOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		wantErr bool
+	}{
+		{name: "no origin", origin: ""},
+		{name: "browser", origin: "http://localhost:3000"},
+		{name: "invalid", origin: "not a url", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/stream/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			var config websocket.Config
+			err := checkOrigin(&config, req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkOrigin() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && tt.origin != "" && config.Origin.String() != tt.origin {
+				t.Errorf("config.Origin = %v; want %s", config.Origin, tt.origin)
+			}
+		})
+	}
+}
@@ -1,16 +1,49 @@
 package telemetry
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 	"turion-takehome/internal/store"
+	"turion-takehome/internal/turiondatapacket"
 
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap"
 )
 
+// Number of packets returned by TelemetryList when no limit is given, about
+// an hour at one packet a second, and the most it returns
+const (
+	DEFAULT_TELEMETRY_LIMIT = 5000
+	MAX_TELEMETRY_LIMIT     = 10000
+)
+
+// Response header carrying the cursor of the next page, also given as a
+// rel="next" Link
+const HEADER_NEXT_CURSOR = "X-Next-Cursor"
+
+// Payload parameters that can be picked with `fields`
+var payloadFields = map[string]func(turiondatapacket.TelemetryPayload) float32{
+	"temperature": func(p turiondatapacket.TelemetryPayload) float32 { return p.Temperature },
+	"battery":     func(p turiondatapacket.TelemetryPayload) float32 { return p.Battery },
+	"altitude":    func(p turiondatapacket.TelemetryPayload) float32 { return p.Altitude },
+	"signal":      func(p turiondatapacket.TelemetryPayload) float32 { return p.Signal },
+}
+
+// projectedPacket is a packet with only the payload fields asked for
+type projectedPacket struct {
+	CCSDSPrimaryHeader   turiondatapacket.CCSDSPrimaryHeader   `json:"ccsdsPrimaryHeader"`
+	CCSDSSecondaryHeader turiondatapacket.CCSDSSecondaryHeader `json:"ccsdsSecondaryHeader"`
+	TelemetryPayload     map[string]float32                    `json:"telemetryPayload"`
+}
+
+// TelemetryList returns a page of packets in a time range as a JSON array,
+// oldest first unless order=desc. When there are more, the cursor of the next
+// page is in the X-Next-Cursor header and the Link header.
 func TelemetryList(
-	store store.DataPacketStore,
+	s store.DataPacketStore,
 	logger *zap.Logger,
 ) func(echo.Context) error {
 	return func(c echo.Context) error {
@@ -33,17 +66,108 @@ func TelemetryList(
 		}
 
 		// 2) Convert to uint64 seconds
-		startTS := uint64(startT.Unix())
-		endTS := uint64(endT.Unix())
+		q := store.TelemetryQuery{
+			StartTS: uint64(startT.Unix()),
+			EndTS:   uint64(endT.Unix()),
+			Limit:   DEFAULT_TELEMETRY_LIMIT,
+		}
 
-		// 3) Fetch from store
-		packets, err := store.FetchByTimeRange(c.Request().Context(), startTS, endTS)
+		// 3) Paging, filters and fields
+		if limitStr := c.QueryParam("limit"); limitStr != "" {
+			v, err := strconv.Atoi(limitStr)
+			if err != nil || v <= 0 || v > MAX_TELEMETRY_LIMIT {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					"`limit` must be a whole number from 1 to "+strconv.Itoa(MAX_TELEMETRY_LIMIT))
+			}
+			q.Limit = v
+		}
+
+		if cursorStr := c.QueryParam("cursor"); cursorStr != "" {
+			cursor, err := store.ParseTelemetryCursor(cursorStr)
+			if err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+			q.After = &cursor
+		}
+
+		switch order := strings.ToLower(c.QueryParam("order")); order {
+		case "", "asc":
+		case "desc":
+			q.Descending = true
+		default:
+			return echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("`order` must be asc or desc, got %q", order))
+		}
+
+		if q.SubsystemID, err = parseUint16(c.QueryParam("subsystem_id"), 16); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid subsystem_id: "+err.Error())
+		}
+		if q.APID, err = parseUint16(c.QueryParam("apid"), 11); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"invalid apid: "+err.Error())
+		}
+
+		var fields []string
+		for _, field := range strings.Split(c.QueryParam("fields"), ",") {
+			if field = strings.TrimSpace(field); field == "" {
+				continue
+			}
+			if _, ok := payloadFields[field]; !ok {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					fmt.Sprintf("unknown field %q", field))
+			}
+			fields = append(fields, field)
+		}
+
+		// 4) Fetch from store
+		page, err := s.FetchTelemetryPage(c.Request().Context(), q)
 		if err != nil {
-			// if it's a SQL error you might inspect it, but 500 is fine
+			logger.Error("failed to fetch telemetry", zap.Error(err))
 			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 		}
 
-		// 4) Return JSON array of TurionDataPacket
-		return c.JSON(http.StatusOK, packets)
+		if page.Next != nil {
+			next := *c.Request().URL
+			values := next.Query()
+			values.Set("cursor", page.Next.String())
+			next.RawQuery = values.Encode()
+
+			c.Response().Header().Set(HEADER_NEXT_CURSOR, page.Next.String())
+			c.Response().Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+		}
+
+		// 5) Return JSON array of TurionDataPacket, with only the fields asked
+		// for
+		if len(fields) == 0 {
+			return c.JSON(http.StatusOK, page.Packets)
+		}
+		projected := make([]projectedPacket, len(page.Packets))
+		for i, pkt := range page.Packets {
+			projected[i] = projectedPacket{
+				CCSDSPrimaryHeader:   pkt.CCSDSPrimaryHeader,
+				CCSDSSecondaryHeader: pkt.CCSDSSecondaryHeader,
+				TelemetryPayload:     make(map[string]float32, len(fields)),
+			}
+			for _, field := range fields {
+				projected[i].TelemetryPayload[field] = payloadFields[field](pkt.TelemetryPayload)
+			}
+		}
+		return c.JSON(http.StatusOK, projected)
+	}
+}
+
+// parseUint16 parses an optional query parameter of up to bits bits, in
+// decimal or hex
+func parseUint16(s string, bits int) (*uint16, error) {
+	if s == "" {
+		return nil, nil
+	}
+	// base 0 so both 1 and 0x01 work
+	v, err := strconv.ParseUint(s, 0, bits)
+	if err != nil {
+		return nil, err
 	}
+	u := uint16(v)
+	return &u, nil
 }
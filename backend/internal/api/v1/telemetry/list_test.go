@@ -0,0 +1,137 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"turion-takehome/internal/store"
+	"turion-takehome/internal/turiondatapacket"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// fakeStore records the queries it gets. Methods the tests don't use panic.
+type fakeStore struct {
+	store.DataPacketStore
+
+	query   store.TelemetryQuery
+	page    store.TelemetryPage
+	payload payloadCall
+}
+
+func (s *fakeStore) FetchTelemetryPage(_ context.Context, q store.TelemetryQuery) (*store.TelemetryPage, error) {
+	s.query = q
+	return &s.page, nil
+}
+
+// serve runs handler on a GET of target and returns the response
+func serve(handler echo.HandlerFunc, target string) *httptest.ResponseRecorder {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, target, nil), rec)
+
+	err := handler(c)
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		rec.Code = he.Code
+	}
+	return rec
+}
+
+func TestTelemetryList(t *testing.T) {
+	const timeRange = "/api/v1/telemetry?start_time=2024-01-01T00:00:00Z&end_time=2024-01-01T01:00:00Z"
+	cursor := store.TelemetryCursor{Timestamp: 1704067300, ID: 7}
+
+	tests := []struct {
+		name       string
+		params     string
+		wantStatus int
+		wantQuery  store.TelemetryQuery
+		wantFields []string
+	}{
+		{name: "defaults", wantStatus: http.StatusOK,
+			wantQuery: store.TelemetryQuery{Limit: DEFAULT_TELEMETRY_LIMIT}},
+		{name: "limit", params: "&limit=10", wantStatus: http.StatusOK,
+			wantQuery: store.TelemetryQuery{Limit: 10}},
+		{name: "max limit", params: "&limit=10000", wantStatus: http.StatusOK,
+			wantQuery: store.TelemetryQuery{Limit: MAX_TELEMETRY_LIMIT}},
+		{name: "limit too big", params: "&limit=10001", wantStatus: http.StatusBadRequest},
+		{name: "zero limit", params: "&limit=0", wantStatus: http.StatusBadRequest},
+		{name: "limit not a number", params: "&limit=ten", wantStatus: http.StatusBadRequest},
+		{name: "descending", params: "&order=DESC", wantStatus: http.StatusOK,
+			wantQuery: store.TelemetryQuery{Limit: DEFAULT_TELEMETRY_LIMIT, Descending: true}},
+		{name: "unknown order", params: "&order=newest", wantStatus: http.StatusBadRequest},
+		{name: "cursor", params: "&cursor=" + cursor.String(), wantStatus: http.StatusOK,
+			wantQuery: store.TelemetryQuery{Limit: DEFAULT_TELEMETRY_LIMIT, After: &cursor}},
+		{name: "bad cursor", params: "&cursor=nope", wantStatus: http.StatusBadRequest},
+		{name: "fields", params: "&fields=battery,%20signal", wantStatus: http.StatusOK,
+			wantQuery: store.TelemetryQuery{Limit: DEFAULT_TELEMETRY_LIMIT}, wantFields: []string{"battery", "signal"}},
+		{name: "unknown field", params: "&fields=battery,speed", wantStatus: http.StatusBadRequest},
+		{name: "apid out of range", params: "&apid=0x800", wantStatus: http.StatusBadRequest},
+		{name: "subsystem id not a number", params: "&subsystem_id=main", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeStore{page: store.TelemetryPage{
+				Packets: []*turiondatapacket.TurionDataPacket{{
+					TelemetryPayload: turiondatapacket.TelemetryPayload{Battery: 80, Signal: -50},
+				}},
+			}}
+			rec := serve(TelemetryList(s, zap.NewNop()), timeRange+tt.params)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			want := tt.wantQuery
+			want.StartTS, want.EndTS = 1704067200, 1704070800
+			got := s.query
+			if got.After != nil && want.After != nil && *got.After == *want.After {
+				got.After, want.After = nil, nil
+			}
+			if got.StartTS != want.StartTS || got.EndTS != want.EndTS || got.Limit != want.Limit ||
+				got.Descending != want.Descending || got.After != want.After {
+				t.Errorf("query = %+v; want %+v", s.query, tt.wantQuery)
+			}
+
+			if tt.wantFields == nil {
+				return
+			}
+			var body []projectedPacket
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body) != 1 || len(body[0].TelemetryPayload) != len(tt.wantFields) {
+				t.Fatalf("body = %s; want only fields %v", rec.Body, tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if _, ok := body[0].TelemetryPayload[field]; !ok {
+					t.Errorf("body = %s; want field %q", rec.Body, field)
+				}
+			}
+		})
+	}
+}
+
+func TestTelemetryListNextPage(t *testing.T) {
+	next := store.TelemetryCursor{Timestamp: 1704067300, ID: 7}
+	s := &fakeStore{page: store.TelemetryPage{Next: &next}}
+	rec := serve(TelemetryList(s, zap.NewNop()),
+		"/api/v1/telemetry?start_time=2024-01-01T00:00:00Z&end_time=2024-01-01T01:00:00Z&limit=1")
+
+	if got := rec.Header().Get(HEADER_NEXT_CURSOR); got != next.String() {
+		t.Errorf("%s = %q; want %q", HEADER_NEXT_CURSOR, got, next.String())
+	}
+	want := `</api/v1/telemetry?cursor=` + next.String() + `&end_time=2024-01-01T01%3A00%3A00Z&limit=1&start_time=2024-01-01T00%3A00%3A00Z>; rel="next"`
+	if got := rec.Header().Get("Link"); got != want {
+		t.Errorf("Link = %s; want %s", got, want)
+	}
+}
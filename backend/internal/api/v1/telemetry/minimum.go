@@ -1,6 +1,7 @@
 package telemetry
 
 import (
+	"fmt"
 	"net/http"
 	"time"
 	"turion-takehome/internal/store"
@@ -9,6 +10,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// AggregationHandler returns the min, max and average of every payload
+// parameter over a time range. With `bucket` it returns them per time bucket
+// instead, along with the count, standard deviation and percentiles, and with
+// `mode=lttb` it returns each parameter downsampled to `points` points.
 func AggregationHandler(store store.DataPacketStore, logger *zap.Logger) func(echo.Context) error {
 	return func(c echo.Context) error {
 		startStr := c.QueryParam("start_time")
@@ -32,6 +37,20 @@ func AggregationHandler(store store.DataPacketStore, logger *zap.Logger) func(ec
 		startTS := uint64(startT.Unix())
 		endTS := uint64(endT.Unix())
 
+		bucket, mode := c.QueryParam("bucket"), c.QueryParam("mode")
+		switch {
+		case bucket != "" && mode != "":
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"`bucket` and `mode` cannot be used together")
+		case bucket != "":
+			return bucketedAggregation(c, store, logger, startTS, endTS)
+		case mode == MODE_LTTB:
+			return downsampledAggregation(c, store, logger, startTS, endTS)
+		case mode != "":
+			return echo.NewHTTPError(http.StatusBadRequest,
+				fmt.Sprintf("`mode` must be %s, got %q", MODE_LTTB, mode))
+		}
+
 		stats, err := store.FetchPayloadStatsByTimeRange(c.Request().Context(), startTS, endTS)
 		if err != nil {
 			logger.Error("failed to fetch payload stats", zap.Error(err))
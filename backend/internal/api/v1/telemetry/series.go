@@ -0,0 +1,171 @@
+package telemetry
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"turion-takehome/internal/downsample"
+	"turion-takehome/internal/store"
+	"turion-takehome/internal/turiondatapacket"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+const (
+	// Most buckets a bucketed aggregation may span
+	MAX_BUCKETS = 10000
+	// Points per parameter returned by the lttb mode when none are asked
+	// for, and the most it returns
+	DEFAULT_DOWNSAMPLE_POINTS = 1000
+	MAX_DOWNSAMPLE_POINTS     = 10000
+	// How many samples per point LTTB picks from. Ranges with more packets
+	// than that are pre-reduced to the lowest and highest value of each
+	// bucket in Postgres, so memory stays bounded however long the range.
+	DOWNSAMPLE_OVERSAMPLING = 4
+	// The only downsampling mode
+	MODE_LTTB = "lttb"
+)
+
+// Bucket sizes accepted by `bucket`, in seconds
+var bucketSizes = map[string]uint64{
+	"1m":  60,
+	"5m":  5 * 60,
+	"15m": 15 * 60,
+	"1h":  60 * 60,
+	"6h":  6 * 60 * 60,
+	"1d":  24 * 60 * 60,
+}
+
+// Percentiles of each bucket when `percentiles` isn't given
+var defaultPercentiles = []float64{50, 95, 99}
+
+type bucketedStats struct {
+	Bucket  string                           `json:"bucket"`
+	Buckets []turiondatapacket.PayloadBucket `json:"buckets"`
+}
+
+type seriesPoint struct {
+	Timestamp uint64  `json:"timestamp"`
+	Value     float64 `json:"value"`
+}
+
+type downsampledSeries struct {
+	// Packets in the range, before downsampling
+	Total  int                      `json:"total"`
+	Series map[string][]seriesPoint `json:"series"`
+}
+
+// bucketedAggregation answers AggregationHandler when `bucket` is given
+func bucketedAggregation(c echo.Context, s store.DataPacketStore, logger *zap.Logger, startTS, endTS uint64) error {
+	bucket := c.QueryParam("bucket")
+	size, ok := bucketSizes[bucket]
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("`bucket` must be one of 1m, 5m, 15m, 1h, 6h or 1d, got %q", bucket))
+	}
+	if endTS >= startTS && (endTS-startTS)/size >= MAX_BUCKETS {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("range spans more than %d buckets of %s", MAX_BUCKETS, bucket))
+	}
+
+	parameters, err := parseParameters(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	percentiles := defaultPercentiles
+	if c.QueryParams().Has("percentiles") {
+		percentiles = nil
+		for _, p := range splitList(c.QueryParam("percentiles")) {
+			v, err := strconv.ParseFloat(p, 64)
+			if err != nil || v < 0 || v > 100 {
+				return echo.NewHTTPError(http.StatusBadRequest,
+					fmt.Sprintf("percentile %q must be a number from 0 to 100", p))
+			}
+			percentiles = append(percentiles, v)
+		}
+	}
+
+	buckets, err := s.FetchPayloadBuckets(c.Request().Context(), startTS, endTS, size, parameters, percentiles)
+	if err != nil {
+		logger.Error("failed to fetch payload buckets", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, bucketedStats{Bucket: bucket, Buckets: buckets})
+}
+
+// downsampledAggregation answers AggregationHandler when `mode=lttb`
+func downsampledAggregation(c echo.Context, s store.DataPacketStore, logger *zap.Logger, startTS, endTS uint64) error {
+	points := DEFAULT_DOWNSAMPLE_POINTS
+	if pointsStr := c.QueryParam("points"); pointsStr != "" {
+		v, err := strconv.Atoi(pointsStr)
+		if err != nil || v < 3 || v > MAX_DOWNSAMPLE_POINTS {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				"`points` must be a whole number from 3 to "+strconv.Itoa(MAX_DOWNSAMPLE_POINTS))
+		}
+		points = v
+	}
+
+	parameters, err := parseParameters(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	series, err := s.FetchPayloadSeries(c.Request().Context(), startTS, endTS, parameters, points*DOWNSAMPLE_OVERSAMPLING)
+	if err != nil {
+		logger.Error("failed to fetch payload series", zap.Error(err))
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	out := downsampledSeries{
+		Total:  series.Total,
+		Series: make(map[string][]seriesPoint, len(parameters)),
+	}
+	// Each parameter keeps the points that shape its own plot
+	for _, param := range parameters {
+		p := series.Parameters[param]
+		xs := make([]float64, len(p.Timestamps))
+		for i, ts := range p.Timestamps {
+			xs[i] = float64(ts)
+		}
+		picked := downsample.LTTB(xs, p.Values, points)
+		out.Series[param] = make([]seriesPoint, len(picked))
+		for i, j := range picked {
+			out.Series[param][i] = seriesPoint{Timestamp: p.Timestamps[j], Value: p.Values[j]}
+		}
+	}
+	return c.JSON(http.StatusOK, out)
+}
+
+// parseParameters reads `parameters`, every payload parameter when it isn't
+// given
+func parseParameters(c echo.Context) ([]string, error) {
+	parameters := splitList(c.QueryParam("parameters"))
+	if len(parameters) == 0 {
+		return turiondatapacket.PayloadParameters, nil
+	}
+
+	var out []string
+	for _, p := range parameters {
+		if !slices.Contains(turiondatapacket.PayloadParameters, p) {
+			return nil, fmt.Errorf("%w: %q", store.ErrUnknownParameter, p)
+		}
+		if !slices.Contains(out, p) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
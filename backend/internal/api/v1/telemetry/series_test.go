@@ -0,0 +1,85 @@
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// payloadCall is what the aggregation handler asked fakeStore for
+type payloadCall struct {
+	method        string
+	bucketSeconds uint64
+	parameters    []string
+	percentiles   []float64
+	maxPoints     int
+}
+
+func (s *fakeStore) FetchPayloadStatsByTimeRange(context.Context, uint64, uint64) (turiondatapacket.PayloadStats, error) {
+	s.payload = payloadCall{method: "stats"}
+	return turiondatapacket.PayloadStats{}, nil
+}
+
+func (s *fakeStore) FetchPayloadBuckets(_ context.Context, _, _, bucketSeconds uint64, parameters []string, percentiles []float64) ([]turiondatapacket.PayloadBucket, error) {
+	s.payload = payloadCall{method: "buckets", bucketSeconds: bucketSeconds, parameters: parameters, percentiles: percentiles}
+	return nil, nil
+}
+
+func (s *fakeStore) FetchPayloadSeries(_ context.Context, _, _ uint64, parameters []string, maxPoints int) (*turiondatapacket.PayloadSeries, error) {
+	s.payload = payloadCall{method: "series", parameters: parameters, maxPoints: maxPoints}
+	return &turiondatapacket.PayloadSeries{}, nil
+}
+
+func TestAggregationHandler(t *testing.T) {
+	const hour = "/api/v1/telemetry/aggregation?start_time=2024-01-01T00:00:00Z&end_time=2024-01-01T01:00:00Z"
+	const month = "/api/v1/telemetry/aggregation?start_time=2024-01-01T00:00:00Z&end_time=2024-02-01T00:00:00Z"
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		want       payloadCall
+	}{
+		{name: "stats", target: hour, wantStatus: http.StatusOK, want: payloadCall{method: "stats"}},
+		{name: "bucket", target: hour + "&bucket=5m", wantStatus: http.StatusOK,
+			want: payloadCall{method: "buckets", bucketSeconds: 300, parameters: turiondatapacket.PayloadParameters, percentiles: defaultPercentiles}},
+		{name: "bucket with parameters and percentiles", target: hour + "&bucket=1m&parameters=battery,battery&percentiles=10,%2090", wantStatus: http.StatusOK,
+			want: payloadCall{method: "buckets", bucketSeconds: 60, parameters: []string{"battery"}, percentiles: []float64{10, 90}}},
+		{name: "no percentiles", target: hour + "&bucket=1m&percentiles=", wantStatus: http.StatusOK,
+			want: payloadCall{method: "buckets", bucketSeconds: 60, parameters: turiondatapacket.PayloadParameters}},
+		{name: "unknown bucket", target: hour + "&bucket=2m", wantStatus: http.StatusBadRequest},
+		{name: "too many buckets", target: month + "&bucket=1m", wantStatus: http.StatusBadRequest},
+		{name: "percentile out of range", target: hour + "&bucket=1m&percentiles=50,101", wantStatus: http.StatusBadRequest},
+		{name: "percentile not a number", target: hour + "&bucket=1m&percentiles=median", wantStatus: http.StatusBadRequest},
+		{name: "unknown parameter", target: hour + "&bucket=1m&parameters=speed", wantStatus: http.StatusBadRequest},
+		{name: "bucket and mode", target: hour + "&bucket=1m&mode=lttb", wantStatus: http.StatusBadRequest},
+		{name: "lttb", target: month + "&mode=lttb", wantStatus: http.StatusOK,
+			want: payloadCall{method: "series", parameters: turiondatapacket.PayloadParameters, maxPoints: DEFAULT_DOWNSAMPLE_POINTS * DOWNSAMPLE_OVERSAMPLING}},
+		{name: "lttb with points", target: hour + "&mode=lttb&points=3&parameters=signal", wantStatus: http.StatusOK,
+			want: payloadCall{method: "series", parameters: []string{"signal"}, maxPoints: 3 * DOWNSAMPLE_OVERSAMPLING}},
+		{name: "too few points", target: hour + "&mode=lttb&points=2", wantStatus: http.StatusBadRequest},
+		{name: "too many points", target: hour + "&mode=lttb&points=10001", wantStatus: http.StatusBadRequest},
+		{name: "unknown mode", target: hour + "&mode=average", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &fakeStore{}
+			rec := serve(AggregationHandler(s, zap.NewNop()), tt.target)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d; want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			got := s.payload
+			if got.method != tt.want.method || got.bucketSeconds != tt.want.bucketSeconds ||
+				!slices.Equal(got.parameters, tt.want.parameters) ||
+				!slices.Equal(got.percentiles, tt.want.percentiles) || got.maxPoints != tt.want.maxPoints {
+				t.Errorf("store called with %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
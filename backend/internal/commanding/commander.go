@@ -0,0 +1,213 @@
+package commanding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/ioprocessors/writers"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// ErrUplink is returned when a command was recorded but couldn't be sent. The
+// command is marked failed.
+var ErrUplink = errors.New("uplink failed")
+
+// CommandStore records the command history, see store.DataPacketStore
+type CommandStore interface {
+	InsertCommand(ctx context.Context, cmd *turiondatapacket.Command, packet []byte) error
+	UpdateCommandState(ctx context.Context, id int64, state, reason string) (*turiondatapacket.Command, error)
+	FetchLastSeqCounts(ctx context.Context) (map[uint16]uint16, error)
+	ExpireCommands(ctx context.Context, before time.Time, reason string) ([]*turiondatapacket.Command, error)
+}
+
+// Commander checks commands against the command dictionary, records them in
+// the command history and sends them up as telecommand packets. Each APID has
+// its own sequence count, carried on from the command history by
+// RestoreSequenceCounts.
+type Commander struct {
+	logger     *zap.Logger
+	dictionary *turiondatapacket.CommandDictionary
+	store      CommandStore
+	uplink     writers.Writer
+
+	// Held from picking a command's sequence count until it is written, so
+	// commands go up in sequence count order
+	mu      sync.Mutex
+	counter *turiondatapacket.CommandCounter
+}
+
+func NewCommander(
+	logger *zap.Logger,
+	dictionary *turiondatapacket.CommandDictionary,
+	store CommandStore,
+	uplink writers.Writer,
+) (*Commander, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if dictionary == nil {
+		errs = errors.Join(errs, errors.New("command dictionary cannot be nil"))
+	}
+
+	if store == nil {
+		errs = errors.Join(errs, errors.New("command store cannot be nil"))
+	}
+
+	if uplink == nil {
+		errs = errors.Join(errs, errors.New("uplink writer cannot be nil"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &Commander{
+		logger:     logger,
+		dictionary: dictionary,
+		store:      store,
+		uplink:     uplink,
+		counter:    turiondatapacket.NewCommandCounter(),
+	}, nil
+}
+
+// Dictionary returns the commands the commander accepts
+func (c *Commander) Dictionary() *turiondatapacket.CommandDictionary {
+	return c.dictionary
+}
+
+// RestoreSequenceCounts carries on each APID's sequence count from the last
+// command in the history, so commands sent after a restart aren't mistaken
+// for repeats of earlier ones and their acknowledgements don't match old
+// commands. Call it before the first Send.
+func (c *Commander) RestoreSequenceCounts(ctx context.Context) error {
+	last, err := c.store.FetchLastSeqCounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counter.Restore(last)
+	c.logger.Info("Restored command sequence counts", zap.Any("Last sent", last))
+	return nil
+}
+
+// ExpireCommands fails commands that are still sent, without an
+// acknowledgement, timeout after they were sent, and commands still queued
+// timeout after they were accepted. It checks every timeout/2 until ctx is
+// done, so a command is failed at most 1.5 timeouts after it was sent. An
+// acknowledgement that comes down after that is ignored.
+func (c *Commander) ExpireCommands(ctx context.Context, timeout time.Duration) {
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	reason := fmt.Sprintf("not sent and acknowledged within %s", timeout)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			expired, err := c.store.ExpireCommands(ctx, now.Add(-timeout), reason)
+			if err != nil {
+				c.logger.Error("Failed to expire unacknowledged commands", zap.Error(err))
+				continue
+			}
+			for _, cmd := range expired {
+				c.logger.Warn("Command was never acknowledged",
+					zap.String("Command", cmd.Name),
+					zap.Int64("ID", cmd.ID),
+					zap.Uint16("APID", cmd.APID),
+					zap.Uint16("Seq count", cmd.SeqCount),
+				)
+			}
+		}
+	}
+}
+
+// Send validates the command called name with args, records it as queued and
+// writes it to the uplink. It returns the command as it was left: sent, or
+// failed along with an ErrUplink error. Commands that don't validate return
+// ErrUnknownCommand or ErrInvalidArgument and aren't recorded. Once the
+// command is written, its state is recorded even if ctx is cancelled.
+func (c *Commander) Send(
+	ctx context.Context,
+	name string,
+	args map[string]float64,
+) (*turiondatapacket.Command, error) {
+	spec, ok := c.dictionary.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", turiondatapacket.ErrUnknownCommand, name)
+	}
+
+	encodedArgs, err := spec.EncodeArguments(args)
+	if err != nil {
+		return nil, err
+	}
+	if args == nil {
+		args = map[string]float64{}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// The sequence count is only taken once the command is recorded, so a
+	// failed insert doesn't leave a gap
+	cmd := &turiondatapacket.Command{
+		Name:      spec.Name,
+		APID:      spec.APID,
+		Opcode:    spec.Opcode,
+		SeqCount:  c.counter.Peek(spec.APID),
+		Arguments: args,
+		State:     turiondatapacket.COMMAND_QUEUED,
+	}
+	packet := turiondatapacket.EncodeTelecommand(cmd.APID, cmd.SeqCount, cmd.Opcode, time.Now(), encodedArgs)
+	if err := c.store.InsertCommand(ctx, cmd, packet); err != nil {
+		return nil, err
+	}
+	c.counter.Next(spec.APID)
+
+	m := messages.New(packet)
+	defer m.Release()
+	_, err = c.uplink.Write(ctx, m)
+
+	// Whatever happened is recorded, even if the client has gone away
+	updateCtx := context.WithoutCancel(ctx)
+	if err != nil {
+		c.logger.Error("Failed to send command",
+			zap.String("Command", cmd.Name),
+			zap.Int64("ID", cmd.ID),
+			zap.Error(err),
+		)
+		failed, updateErr := c.store.UpdateCommandState(updateCtx, cmd.ID, turiondatapacket.COMMAND_FAILED, err.Error())
+		if updateErr != nil {
+			return nil, errors.Join(fmt.Errorf("%w: %w", ErrUplink, err), updateErr)
+		}
+		return failed, fmt.Errorf("%w: %w", ErrUplink, err)
+	}
+
+	sent, err := c.store.UpdateCommandState(updateCtx, cmd.ID, turiondatapacket.COMMAND_SENT, "")
+	if err != nil {
+		return nil, err
+	}
+	c.logger.Info("Sent command",
+		zap.String("Command", cmd.Name),
+		zap.Int64("ID", cmd.ID),
+		zap.Uint16("APID", cmd.APID),
+		zap.Uint16("Seq count", cmd.SeqCount),
+	)
+	return sent, nil
+}
+
+// Close closes the uplink
+func (c *Commander) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.uplink.Close()
+}
@@ -0,0 +1,288 @@
+package commanding
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// memoryStore keeps the command history in memory. Inserts fail with
+// insertErr if it is set, and updates fail once their ctx is done.
+type memoryStore struct {
+	mu        sync.Mutex
+	insertErr error
+	commands  []*turiondatapacket.Command
+}
+
+func (s *memoryStore) InsertCommand(_ context.Context, cmd *turiondatapacket.Command, _ []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.insertErr != nil {
+		return s.insertErr
+	}
+	cmd.ID = int64(len(s.commands) + 1)
+	cmd.CreatedAt = time.Now()
+	c := *cmd
+	s.commands = append(s.commands, &c)
+	return nil
+}
+
+func (s *memoryStore) UpdateCommandState(ctx context.Context, id int64, state, reason string) (*turiondatapacket.Command, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.commands[id-1]
+	c.State = state
+	c.Error = reason
+	if state == turiondatapacket.COMMAND_SENT {
+		now := time.Now()
+		c.SentAt = &now
+	}
+	out := *c
+	return &out, nil
+}
+
+func (s *memoryStore) FetchLastSeqCounts(context.Context) (map[uint16]uint16, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	last := map[uint16]uint16{}
+	for _, c := range s.commands {
+		last[c.APID] = c.SeqCount
+	}
+	return last, nil
+}
+
+func (s *memoryStore) ExpireCommands(_ context.Context, before time.Time, reason string) ([]*turiondatapacket.Command, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var expired []*turiondatapacket.Command
+	for _, c := range s.commands {
+		if c.State == turiondatapacket.COMMAND_SENT && c.SentAt.Before(before) ||
+			c.State == turiondatapacket.COMMAND_QUEUED && c.CreatedAt.Before(before) {
+			c.State = turiondatapacket.COMMAND_FAILED
+			c.Error = reason
+			out := *c
+			expired = append(expired, &out)
+		}
+	}
+	return expired, nil
+}
+
+func (s *memoryStore) state(id int64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands[id-1].State
+}
+
+// uplink records the packets written to it, or fails them with err. It calls
+// written, if set, after each write, e.g. to cancel the request.
+type uplink struct {
+	err     error
+	written func()
+	packets [][]byte
+}
+
+func (u *uplink) Write(_ context.Context, m *messages.Message) (int, error) {
+	if u.written != nil {
+		defer u.written()
+	}
+	if u.err != nil {
+		return 0, u.err
+	}
+	u.packets = append(u.packets, append([]byte{}, m.Bytes()...))
+	return m.Len(), nil
+}
+
+func (u *uplink) Close() error { return nil }
+
+func TestCommanderSend(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   string
+		args      map[string]float64
+		uplinkErr error
+		wantErr   error
+		wantState string
+	}{
+		{name: "sent", command: "SET_HEATER", args: map[string]float64{"heater": 1, "on": 1}, wantState: turiondatapacket.COMMAND_SENT},
+		{name: "uplink down", command: "NOOP", uplinkErr: errors.New("connection refused"), wantErr: ErrUplink, wantState: turiondatapacket.COMMAND_FAILED},
+		{name: "unknown command", command: "SELF_DESTRUCT", wantErr: turiondatapacket.ErrUnknownCommand},
+		{name: "invalid arguments", command: "SET_HEATER", args: map[string]float64{"heater": 9, "on": 1}, wantErr: turiondatapacket.ErrInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memoryStore{}
+			up := &uplink{err: tt.uplinkErr}
+			c, err := NewCommander(zap.NewNop(), &turiondatapacket.DefaultCommandDictionary, store, up)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cmd, err := c.Send(context.Background(), tt.command, tt.args)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Send() error = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantState == "" {
+				if len(store.commands) != 0 {
+					t.Errorf("Send() recorded %d commands; want none", len(store.commands))
+				}
+				return
+			}
+			if cmd.State != tt.wantState || store.commands[0].State != tt.wantState {
+				t.Errorf("Send() state = %q, stored %q; want %q", cmd.State, store.commands[0].State, tt.wantState)
+			}
+			if tt.uplinkErr == nil {
+				if _, err := turiondatapacket.DecodeTelecommand(up.packets[0]); err != nil {
+					t.Errorf("uplinked packet doesn't decode: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCommanderSequenceCounts(t *testing.T) {
+	store := &memoryStore{}
+	c, err := NewCommander(zap.NewNop(), &turiondatapacket.DefaultCommandDictionary, store, &uplink{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var got []uint16
+	for _, name := range []string{"NOOP", "NOOP", "SET_TRANSMIT_POWER", "NOOP"} {
+		args := map[string]float64{}
+		if name == "SET_TRANSMIT_POWER" {
+			args["power"] = 10
+		}
+		cmd, err := c.Send(ctx, name, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, cmd.SeqCount)
+	}
+
+	// SET_TRANSMIT_POWER goes out on its own APID
+	want := []uint16{0, 1, 0, 2}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("sequence counts = %v; want %v", got, want)
+		}
+	}
+}
+
+func TestCommanderRestoreSequenceCounts(t *testing.T) {
+	// The history the last run left, with APID 0x40 just past a rollover
+	store := &memoryStore{commands: []*turiondatapacket.Command{
+		{ID: 1, APID: 0x40, SeqCount: 0x3FFF},
+		{ID: 2, APID: 0x41, SeqCount: 7},
+		{ID: 3, APID: 0x40, SeqCount: 0},
+	}}
+	c, err := NewCommander(zap.NewNop(), &turiondatapacket.DefaultCommandDictionary, store, &uplink{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := c.RestoreSequenceCounts(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	noop, err := c.Send(ctx, "NOOP", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	power, err := c.Send(ctx, "SET_TRANSMIT_POWER", map[string]float64{"power": 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if noop.SeqCount != 1 || power.SeqCount != 8 {
+		t.Errorf("sequence counts after restore = %d, %d; want 1, 8", noop.SeqCount, power.SeqCount)
+	}
+}
+
+func TestCommanderExpireCommands(t *testing.T) {
+	store := &memoryStore{}
+	c, err := NewCommander(zap.NewNop(), &turiondatapacket.DefaultCommandDictionary, store, &uplink{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd, err := c.Send(ctx, "NOOP", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Left queued by an API that stopped before sending it
+	queued := &turiondatapacket.Command{APID: 0x40, State: turiondatapacket.COMMAND_QUEUED}
+	if err := store.InsertCommand(ctx, queued, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	go c.ExpireCommands(ctx, 20*time.Millisecond)
+	deadline := time.Now().Add(5 * time.Second)
+	for _, id := range []int64{cmd.ID, queued.ID} {
+		for store.state(id) != turiondatapacket.COMMAND_FAILED {
+			if time.Now().After(deadline) {
+				t.Fatalf("command %d was never failed, state %q", id, store.state(id))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+}
+
+func TestCommanderSendRecordsStateAfterWrite(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// The client goes away once the packet is up
+	store := &memoryStore{}
+	c, err := NewCommander(zap.NewNop(), &turiondatapacket.DefaultCommandDictionary, store, &uplink{written: cancel})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := c.Send(ctx, "NOOP", nil)
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if got := store.state(cmd.ID); got != turiondatapacket.COMMAND_SENT {
+		t.Errorf("state = %q; want %q", got, turiondatapacket.COMMAND_SENT)
+	}
+}
+
+func TestCommanderSendInsertFailureKeepsSequenceCount(t *testing.T) {
+	store := &memoryStore{insertErr: errors.New("database down")}
+	up := &uplink{}
+	c, err := NewCommander(zap.NewNop(), &turiondatapacket.DefaultCommandDictionary, store, up)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if _, err := c.Send(ctx, "NOOP", nil); err == nil {
+		t.Fatal("Send() error = nil; want the insert error")
+	}
+	if len(up.packets) != 0 {
+		t.Errorf("sent %d packets for a command that wasn't recorded", len(up.packets))
+	}
+
+	store.insertErr = nil
+	cmd, err := c.Send(ctx, "NOOP", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.SeqCount != 0 {
+		t.Errorf("sequence count = %d; want 0, without a gap", cmd.SeqCount)
+	}
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DEFAULT_SHUTDOWN_TIMEOUT is how long a service has to finish in-flight work
+// after SIGINT or SIGTERM before it exits anyway
+const DEFAULT_SHUTDOWN_TIMEOUT = 15 * time.Second
+
+// durationFromEnv parses the env variable name as a positive duration, or
+// returns def if it isn't set
+func durationFromEnv(name string, def time.Duration) (time.Duration, error) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("env variable %s must be a positive duration: %q", name, v)
+	}
+	return d, nil
+}
+
+// positiveIntFromEnv parses the env variable name as a positive integer, or
+// returns def if it isn't set
+func positiveIntFromEnv(name string, def int) (int, error) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("env variable %s must be a positive integer: %q", name, v)
+	}
+	return n, nil
+}
+
+// boolFromEnv parses the env variable name as a boolean, or returns def if it
+// isn't set
+func boolFromEnv(name string, def bool) (bool, error) {
+	v := strings.TrimSpace(os.Getenv(name))
+	if v == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("env variable %s must be true or false: %q", name, v)
+	}
+	return b, nil
+}
@@ -2,13 +2,31 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_UPLINK_NETWORK      = "udp"
+	DEFAULT_COMMAND_ACK_TIMEOUT = 30 * time.Second
 )
 
 // TelemetryAPIConfig is the env config for the telemetry API service
 type TelemetryAPIConfig struct {
 	PGHostURL string
+	// Optional. When empty, commanding is disabled
+	UplinkAddress string
+	// "udp" or "tcp"
+	UplinkNetwork string
+	// Optional. When empty, the built-in command dictionary is used
+	CommandDictionaryPath string
+	// How long a sent command may go without an acknowledgement before it is
+	// failed
+	CommandAckTimeout time.Duration
+	// How long in-flight requests get to finish on shutdown
+	ShutdownTimeout time.Duration
 }
 
 func NewTelemetryAPIConfig() (*TelemetryAPIConfig, error) {
@@ -17,7 +35,34 @@ func NewTelemetryAPIConfig() (*TelemetryAPIConfig, error) {
 		return nil, errors.New("env variable PG_HOST_URL is empty")
 	}
 
+	uplinkAddress := strings.TrimSpace(os.Getenv("UPLINK_ADDRESS"))
+
+	uplinkNetwork := strings.ToLower(strings.TrimSpace(os.Getenv("UPLINK_NETWORK")))
+	if uplinkNetwork == "" {
+		uplinkNetwork = DEFAULT_UPLINK_NETWORK
+	}
+	if uplinkNetwork != "udp" && uplinkNetwork != "tcp" {
+		return nil, fmt.Errorf("env variable UPLINK_NETWORK must be udp or tcp: %q", uplinkNetwork)
+	}
+
+	commandDictionaryPath := strings.TrimSpace(os.Getenv("COMMAND_DICTIONARY_PATH"))
+
+	commandAckTimeout, err := durationFromEnv("COMMAND_ACK_TIMEOUT", DEFAULT_COMMAND_ACK_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTimeout, err := durationFromEnv("SHUTDOWN_TIMEOUT", DEFAULT_SHUTDOWN_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+
 	return &TelemetryAPIConfig{
-		PGHostURL: pgHostURL,
+		PGHostURL:             pgHostURL,
+		UplinkAddress:         uplinkAddress,
+		UplinkNetwork:         uplinkNetwork,
+		CommandDictionaryPath: commandDictionaryPath,
+		CommandAckTimeout:     commandAckTimeout,
+		ShutdownTimeout:       shutdownTimeout,
 	}, nil
 }
@@ -2,16 +2,86 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_LIMITS_RELOAD_INTERVAL = 30 * time.Second
+	DEFAULT_ALERT_RATE_LIMIT       = 10 // alerts per second
+	DEFAULT_ALERT_DEDUP_WINDOW     = 5 * time.Minute
+	DEFAULT_METRICS_ADDRESS        = ":8080"
+	DEFAULT_TELEMETRY_WORKERS      = 4
+	DEFAULT_REPLAY_SPEED           = 1
+	DEFAULT_QUARANTINE_FALLBACK    = "quarantine"
+
+	// ALERT_LOG_SINK can be listed in ALERT_WEBHOOK_URLS to log alerts instead
+	// of POSTing them
+	ALERT_LOG_SINK = "log"
 )
 
 // Config variables pulled from user's environment. When service is deployed using
 // k8s, these secrets would come from the service's configmap
 type TelemetryGatewayConfig struct {
-	PGHostURL                    string
+	PGHostURL string
+	// Where packets are read from over UDP. Not needed with TCP input or when
+	// replaying a capture.
 	GroundStationEmulatorAddress string
 	TelemetryAPIServerURL        string
+	// Optional UDP socket settings. The receive buffer is left to the kernel
+	// when zero, and datagrams from any source are accepted when
+	// UDPAllowedSources is empty.
+	UDPReceiveBufferBytes int
+	UDPMulticastInterface string
+	UDPAllowedSources     []netip.Prefix
+	// Optional. When either is set, packets are read from a TCP stream that
+	// the gateway connects to or accepts instead of over UDP
+	TCPDialAddress   string
+	TCPListenAddress string
+	// Optional. When set, raw datagrams are recorded to this capture
+	// directory
+	CaptureDir string
+	// Optional. When set, the capture in this directory is replayed instead
+	// of reading live telemetry, ReplaySpeed times faster than it was
+	// recorded or as fast as possible when zero
+	ReplayDir   string
+	ReplaySpeed float64
+	// Optional. When set to "tm" or "aos", each UDP datagram holds one
+	// transfer frame instead of one space packet. The other settings describe
+	// the optional fields of the mission's frames.
+	FrameType              string
+	FrameFECF              bool
+	FrameAOSOCF            bool
+	FrameAOSInsertZoneSize int
+	// Optional. When empty, only the built-in main bus packet is decoded
+	TelemetryDictionaryPath string
+	// Set when the built-in main bus packet ends with packet error control.
+	// Dictionary packets set errorControl in the dictionary instead.
+	PacketErrorControl bool
+	// Optional. When empty, limits are loaded from the database
+	LimitsPath           string
+	LimitsReloadInterval time.Duration
+	// Optional. When empty, packets, anomalies and link events each go to
+	// their own processor
+	TopologyPath string
+	// Optional. When empty, alerts are POSTed to the telemetry API
+	AlertWebhookURLs []string
+	AlertRateLimit   float64
+	AlertDedupWindow time.Duration
+	// Optional. When empty, quarantined messages are kept in the database,
+	// and in QuarantineFallbackDir when the database can't take them
+	QuarantineDir         string
+	QuarantineFallbackDir string
+	// Address the Prometheus /metrics endpoint listens on
+	MetricsAddress string
+	// Goroutines decoding and routing packets while the UDP socket is read
+	TelemetryWorkers int
+	// How long to drain in-flight packets on shutdown
+	ShutdownTimeout time.Duration
 }
 
 func NewTelemetryGatewayConfig() (*TelemetryGatewayConfig, error) {
@@ -21,18 +91,189 @@ func NewTelemetryGatewayConfig() (*TelemetryGatewayConfig, error) {
 	}
 
 	telemetryAPIServerURL := strings.TrimSpace(os.Getenv("TELEMETRY_API_SERVER_URL"))
-	if pgHostURL == "" {
+	if telemetryAPIServerURL == "" {
 		return nil, errors.New("env variable TELEMETRY_API_SERVER_URL is empty")
 	}
 
+	udpReceiveBufferBytes, err := positiveIntFromEnv("UDP_RECEIVE_BUFFER_BYTES", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	udpMulticastInterface := strings.TrimSpace(os.Getenv("UDP_MULTICAST_INTERFACE"))
+
+	var udpAllowedSources []netip.Prefix
+	for _, v := range strings.Split(os.Getenv("UDP_ALLOWED_SOURCES"), ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		prefix, err := parseSource(v)
+		if err != nil {
+			return nil, fmt.Errorf("env variable UDP_ALLOWED_SOURCES: %w", err)
+		}
+		udpAllowedSources = append(udpAllowedSources, prefix)
+	}
+
+	tcpDialAddress := strings.TrimSpace(os.Getenv("TCP_DIAL_ADDRESS"))
+	tcpListenAddress := strings.TrimSpace(os.Getenv("TCP_LISTEN_ADDRESS"))
+	if tcpDialAddress != "" && tcpListenAddress != "" {
+		return nil, errors.New("env variables TCP_DIAL_ADDRESS and TCP_LISTEN_ADDRESS can't both be set")
+	}
+
+	captureDir := strings.TrimSpace(os.Getenv("CAPTURE_DIR"))
+
+	replayDir := strings.TrimSpace(os.Getenv("REPLAY_DIR"))
+	if replayDir != "" && (tcpDialAddress != "" || tcpListenAddress != "") {
+		return nil, errors.New("env variable REPLAY_DIR can't be used with TCP input")
+	}
+
+	// Only read over UDP
 	groundStationEmulatorAddress := strings.TrimSpace(os.Getenv("GROUND_STATION_EMULATOR_ADDRESS"))
-	if groundStationEmulatorAddress == "" {
+	if groundStationEmulatorAddress == "" && tcpDialAddress == "" && tcpListenAddress == "" && replayDir == "" {
 		return nil, errors.New("env variable GROUND_STATION_EMULATOR_ADDRESS is empty")
 	}
 
+	replaySpeed := float64(DEFAULT_REPLAY_SPEED)
+	if v := strings.TrimSpace(os.Getenv("REPLAY_SPEED")); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r < 0 {
+			return nil, fmt.Errorf("env variable REPLAY_SPEED must be a number, 0 for as fast as possible: %q", v)
+		}
+		replaySpeed = r
+	}
+
+	frameType := strings.ToLower(strings.TrimSpace(os.Getenv("FRAME_TYPE")))
+	switch frameType {
+	case "", "tm", "aos":
+	default:
+		return nil, fmt.Errorf("env variable FRAME_TYPE must be tm or aos: %q", frameType)
+	}
+	if frameType != "" && (tcpDialAddress != "" || tcpListenAddress != "") {
+		return nil, errors.New("env variable FRAME_TYPE needs frames in UDP datagrams and can't be used with TCP input")
+	}
+
+	frameFECF, err := boolFromEnv("FRAME_FECF", true)
+	if err != nil {
+		return nil, err
+	}
+
+	frameAOSOCF, err := boolFromEnv("FRAME_AOS_OCF", false)
+	if err != nil {
+		return nil, err
+	}
+
+	frameAOSInsertZoneSize := 0
+	if v := strings.TrimSpace(os.Getenv("FRAME_AOS_INSERT_ZONE_BYTES")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("env variable FRAME_AOS_INSERT_ZONE_BYTES must be a whole number: %q", v)
+		}
+		frameAOSInsertZoneSize = n
+	}
+
+	telemetryDictionaryPath := strings.TrimSpace(os.Getenv("TELEMETRY_DICTIONARY_PATH"))
+
+	packetErrorControl, err := boolFromEnv("PACKET_ERROR_CONTROL", false)
+	if err != nil {
+		return nil, err
+	}
+
+	limitsPath := strings.TrimSpace(os.Getenv("LIMITS_PATH"))
+
+	topologyPath := strings.TrimSpace(os.Getenv("TOPOLOGY_PATH"))
+
+	limitsReloadInterval, err := durationFromEnv("LIMITS_RELOAD_INTERVAL", DEFAULT_LIMITS_RELOAD_INTERVAL)
+	if err != nil {
+		return nil, err
+	}
+
+	var alertWebhookURLs []string
+	for _, u := range strings.Split(os.Getenv("ALERT_WEBHOOK_URLS"), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			alertWebhookURLs = append(alertWebhookURLs, u)
+		}
+	}
+
+	alertRateLimit := float64(DEFAULT_ALERT_RATE_LIMIT)
+	if v := strings.TrimSpace(os.Getenv("ALERT_RATE_LIMIT")); v != "" {
+		r, err := strconv.ParseFloat(v, 64)
+		if err != nil || r <= 0 {
+			return nil, fmt.Errorf("env variable ALERT_RATE_LIMIT must be a positive number: %q", v)
+		}
+		alertRateLimit = r
+	}
+
+	alertDedupWindow := DEFAULT_ALERT_DEDUP_WINDOW
+	if v := strings.TrimSpace(os.Getenv("ALERT_DEDUP_WINDOW")); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("env variable ALERT_DEDUP_WINDOW must be a duration: %q", v)
+		}
+		alertDedupWindow = d
+	}
+
+	quarantineDir := strings.TrimSpace(os.Getenv("QUARANTINE_DIR"))
+	quarantineFallbackDir := strings.TrimSpace(os.Getenv("QUARANTINE_FALLBACK_DIR"))
+	if quarantineFallbackDir == "" {
+		quarantineFallbackDir = DEFAULT_QUARANTINE_FALLBACK
+	}
+
+	metricsAddress := strings.TrimSpace(os.Getenv("METRICS_ADDRESS"))
+	if metricsAddress == "" {
+		metricsAddress = DEFAULT_METRICS_ADDRESS
+	}
+
+	telemetryWorkers, err := positiveIntFromEnv("TELEMETRY_WORKERS", DEFAULT_TELEMETRY_WORKERS)
+	if err != nil {
+		return nil, err
+	}
+
+	shutdownTimeout, err := durationFromEnv("SHUTDOWN_TIMEOUT", DEFAULT_SHUTDOWN_TIMEOUT)
+	if err != nil {
+		return nil, err
+	}
+
 	return &TelemetryGatewayConfig{
 		PGHostURL:                    pgHostURL,
 		TelemetryAPIServerURL:        telemetryAPIServerURL,
 		GroundStationEmulatorAddress: groundStationEmulatorAddress,
+		UDPReceiveBufferBytes:        udpReceiveBufferBytes,
+		UDPMulticastInterface:        udpMulticastInterface,
+		UDPAllowedSources:            udpAllowedSources,
+		TCPDialAddress:               tcpDialAddress,
+		TCPListenAddress:             tcpListenAddress,
+		CaptureDir:                   captureDir,
+		ReplayDir:                    replayDir,
+		ReplaySpeed:                  replaySpeed,
+		FrameType:                    frameType,
+		FrameFECF:                    frameFECF,
+		FrameAOSOCF:                  frameAOSOCF,
+		FrameAOSInsertZoneSize:       frameAOSInsertZoneSize,
+		TelemetryDictionaryPath:      telemetryDictionaryPath,
+		PacketErrorControl:           packetErrorControl,
+		LimitsPath:                   limitsPath,
+		LimitsReloadInterval:         limitsReloadInterval,
+		TopologyPath:                 topologyPath,
+		AlertWebhookURLs:             alertWebhookURLs,
+		AlertRateLimit:               alertRateLimit,
+		AlertDedupWindow:             alertDedupWindow,
+		QuarantineDir:                quarantineDir,
+		QuarantineFallbackDir:        quarantineFallbackDir,
+		MetricsAddress:               metricsAddress,
+		TelemetryWorkers:             telemetryWorkers,
+		ShutdownTimeout:              shutdownTimeout,
 	}, nil
 }
+
+// parseSource parses an IP address or CIDR block
+func parseSource(v string) (netip.Prefix, error) {
+	if strings.Contains(v, "/") {
+		return netip.ParsePrefix(v)
+	}
+
+	addr, err := netip.ParseAddr(v)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewTelemetryGatewayConfigInput(t *testing.T) {
+	tests := []struct {
+		name            string
+		env             map[string]string
+		wantErrContains string
+	}{
+		{
+			name:            "udp without an address",
+			env:             map[string]string{},
+			wantErrContains: "GROUND_STATION_EMULATOR_ADDRESS is empty",
+		},
+		{
+			name: "udp",
+			env:  map[string]string{"GROUND_STATION_EMULATOR_ADDRESS": ":8089"},
+		},
+		{
+			name: "tcp dial",
+			env:  map[string]string{"TCP_DIAL_ADDRESS": "modem:5000"},
+		},
+		{
+			name: "tcp listen",
+			env:  map[string]string{"TCP_LISTEN_ADDRESS": ":5000"},
+		},
+		{
+			name: "replay",
+			env:  map[string]string{"REPLAY_DIR": "/captures/pass-42"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("PG_HOST_URL", "postgres://localhost/turion")
+			t.Setenv("TELEMETRY_API_SERVER_URL", "http://localhost:8090")
+			for _, key := range []string{"GROUND_STATION_EMULATOR_ADDRESS", "TCP_DIAL_ADDRESS", "TCP_LISTEN_ADDRESS", "REPLAY_DIR"} {
+				t.Setenv(key, tc.env[key])
+			}
+
+			_, err := NewTelemetryGatewayConfig()
+			switch {
+			case tc.wantErrContains == "" && err != nil:
+				t.Fatalf("NewTelemetryGatewayConfig() error = %v", err)
+			case tc.wantErrContains != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErrContains)):
+				t.Errorf("NewTelemetryGatewayConfig() error = %v; want to contain %q", err, tc.wantErrContains)
+			}
+		})
+	}
+}
@@ -11,6 +11,26 @@ import (
 // k8s, these secrets would come from the service's configmap
 type TelemetryGeneratorConfig struct {
 	GroundStationEmulatorAddress string
+	// Appends packet error control to every packet, see PACKET_ERROR_CONTROL
+	// on the gateway
+	PacketErrorControl bool
+	// Optional. When set, the generator accepts telecommands on this address
+	// and acknowledges them in its telemetry, like a spacecraft would
+	CommandListenAddress string
+	// "udp" or "tcp", see UPLINK_NETWORK on the API
+	CommandListenNetwork string
+	// Optional. When empty, the built-in command dictionary is used
+	CommandDictionaryPath string
+	// Optional. When set, the generator plays this scenario instead of its
+	// built-in telemetry, and exits when it ends
+	ScenarioPath string
+	// Optional. When set, the generator simulates the spacecraft described
+	// there instead, see simulation.Config
+	SimulationPath string
+	// Optional. The telemetry dictionary scenario and simulation packets are
+	// encoded with, the built-in main bus packet when empty. Should match the
+	// gateway's.
+	TelemetryDictionaryPath string
 }
 
 func NewTelemetryGeneratorConfig() (*TelemetryGeneratorConfig, error) {
@@ -27,7 +47,38 @@ func NewTelemetryGeneratorConfig() (*TelemetryGeneratorConfig, error) {
 		return nil, errors.New("env variable GROUND_STATION_EMULATOR_ADDRESS is empty")
 	}
 
+	packetErrorControl, err := boolFromEnv("PACKET_ERROR_CONTROL", false)
+	if err != nil {
+		return nil, err
+	}
+
+	commandListenAddress := strings.TrimSpace(os.Getenv("COMMAND_LISTEN_ADDRESS"))
+
+	commandListenNetwork := strings.ToLower(strings.TrimSpace(os.Getenv("COMMAND_LISTEN_NETWORK")))
+	if commandListenNetwork == "" {
+		commandListenNetwork = DEFAULT_UPLINK_NETWORK
+	}
+	if commandListenNetwork != "udp" && commandListenNetwork != "tcp" {
+		return nil, fmt.Errorf("env variable COMMAND_LISTEN_NETWORK must be udp or tcp: %q", commandListenNetwork)
+	}
+
+	commandDictionaryPath := strings.TrimSpace(os.Getenv("COMMAND_DICTIONARY_PATH"))
+
+	scenarioPath := strings.TrimSpace(os.Getenv("SCENARIO_PATH"))
+	simulationPath := strings.TrimSpace(os.Getenv("SIMULATION_PATH"))
+	if scenarioPath != "" && simulationPath != "" {
+		return nil, errors.New("env variables SCENARIO_PATH and SIMULATION_PATH cannot both be set")
+	}
+	telemetryDictionaryPath := strings.TrimSpace(os.Getenv("TELEMETRY_DICTIONARY_PATH"))
+
 	return &TelemetryGeneratorConfig{
 		GroundStationEmulatorAddress: (groundStationEmulatorAddress),
+		PacketErrorControl:           packetErrorControl,
+		CommandListenAddress:         commandListenAddress,
+		CommandListenNetwork:         commandListenNetwork,
+		CommandDictionaryPath:        commandDictionaryPath,
+		ScenarioPath:                 scenarioPath,
+		SimulationPath:               simulationPath,
+		TelemetryDictionaryPath:      telemetryDictionaryPath,
 	}, nil
 }
@@ -0,0 +1,59 @@
+// Package downsample reduces series to fewer points for plotting
+package downsample
+
+import "math"
+
+// LTTB picks threshold points out of the series (xs[i], ys[i]) with the
+// Largest-Triangle-Three-Buckets algorithm, which keeps the points that shape
+// the plot, spikes included, rather than averaging them away. xs must be
+// sorted. It returns the indices of the points picked, in order, always
+// including the first and last. When threshold is below 3 or there are
+// already no more points than that, every index is returned.
+func LTTB(xs, ys []float64, threshold int) []int {
+	n := min(len(xs), len(ys))
+	if threshold < 3 || threshold >= n {
+		all := make([]int, n)
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+
+	picked := make([]int, 0, threshold)
+	picked = append(picked, 0)
+
+	// The points between the first and last are split into threshold-2
+	// buckets, and one point is picked from each
+	every := float64(n-2) / float64(threshold-2)
+	a := 0
+	for i := 0; i < threshold-2; i++ {
+		// Average of the next bucket, the last point for the last bucket
+		nextStart := int(math.Floor(float64(i+1)*every)) + 1
+		nextEnd := min(int(math.Floor(float64(i+2)*every))+1, n)
+		var avgX, avgY float64
+		for j := nextStart; j < nextEnd; j++ {
+			avgX += xs[j]
+			avgY += ys[j]
+		}
+		count := float64(nextEnd - nextStart)
+		avgX /= count
+		avgY /= count
+
+		// The point of this bucket making the largest triangle with the last
+		// point picked and that average
+		start := int(math.Floor(float64(i)*every)) + 1
+		end := int(math.Floor(float64(i+1)*every)) + 1
+		best, bestArea := start, -1.0
+		for j := start; j < end; j++ {
+			area := math.Abs((xs[a]-avgX)*(ys[j]-ys[a]) - (xs[a]-xs[j])*(avgY-ys[a]))
+			if area > bestArea {
+				best, bestArea = j, area
+			}
+		}
+
+		picked = append(picked, best)
+		a = best
+	}
+
+	return append(picked, n-1)
+}
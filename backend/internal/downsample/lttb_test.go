@@ -0,0 +1,60 @@
+package downsample
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestLTTB(t *testing.T) {
+	line := func(n int) ([]float64, []float64) {
+		xs, ys := make([]float64, n), make([]float64, n)
+		for i := range xs {
+			xs[i] = float64(i)
+			ys[i] = math.Sin(float64(i) / 10)
+		}
+		return xs, ys
+	}
+
+	tests := []struct {
+		name      string
+		n         int
+		threshold int
+		want      int
+	}{
+		{"fewer points than threshold", 5, 10, 5},
+		{"as many points as threshold", 10, 10, 10},
+		{"threshold too small", 100, 2, 100},
+		{"downsampled", 1000, 100, 100},
+		{"uneven buckets", 1001, 7, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xs, ys := line(tt.n)
+			got := LTTB(xs, ys, tt.threshold)
+			if len(got) != tt.want {
+				t.Fatalf("len(LTTB()) = %d; want %d", len(got), tt.want)
+			}
+			if got[0] != 0 || got[len(got)-1] != tt.n-1 {
+				t.Errorf("LTTB() = %v, first and last points missing", got)
+			}
+			if !slices.IsSorted(got) || len(slices.Compact(slices.Clone(got))) != len(got) {
+				t.Errorf("LTTB() = %v, not strictly increasing", got)
+			}
+		})
+	}
+}
+
+func TestLTTBKeepsSpikes(t *testing.T) {
+	xs, ys := make([]float64, 100), make([]float64, 100)
+	for i := range xs {
+		xs[i] = float64(i)
+	}
+	ys[37] = 50
+	ys[71] = -20
+
+	got := LTTB(xs, ys, 10)
+	if !slices.Contains(got, 37) || !slices.Contains(got, 71) {
+		t.Errorf("LTTB() = %v, spikes at 37 and 71 dropped", got)
+	}
+}
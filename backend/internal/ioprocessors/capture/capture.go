@@ -0,0 +1,178 @@
+// Package capture is the file format raw downlink is recorded in, so a pass
+// can be replayed through the gateway later. A capture is a directory of
+// numbered segment files. Each segment starts with a header, the magic bytes
+// and a version, followed by one record per datagram:
+//
+//	int64   receive time, unix nanoseconds
+//	uint8   source address length, 0, 4 or 16
+//	[]byte  source address
+//	uint16  source port
+//	uint32  payload length
+//	[]byte  payload
+//
+// Every value is big-endian.
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MAGIC   = "TCAP"
+	VERSION = 1
+	// HEADER_SIZE is the size of the segment header, the magic bytes and a
+	// uint16 version
+	HEADER_SIZE = len(MAGIC) + 2
+
+	// SEGMENT_EXT is the extension of segment files, named by their number
+	SEGMENT_EXT = ".cap"
+
+	// MAX_PAYLOAD_SIZE bounds the payloads Decoder accepts, so a corrupt
+	// length can't make it allocate gigabytes
+	MAX_PAYLOAD_SIZE = 1 << 20
+)
+
+var (
+	ErrNotCapture         = errors.New("not a capture segment")
+	ErrUnsupportedVersion = errors.New("unsupported capture version")
+	ErrCorruptRecord      = errors.New("corrupt capture record")
+)
+
+// Record is one captured datagram
+type Record struct {
+	ReceivedAt time.Time
+	Source     netip.AddrPort
+	Payload    []byte
+}
+
+// AppendHeader appends the segment header to b
+func AppendHeader(b []byte) []byte {
+	b = append(b, MAGIC...)
+	return binary.BigEndian.AppendUint16(b, VERSION)
+}
+
+// AppendRecord appends r, encoded, to b
+func AppendRecord(b []byte, r Record) []byte {
+	b = binary.BigEndian.AppendUint64(b, uint64(r.ReceivedAt.UnixNano()))
+	addr := r.Source.Addr()
+	if addr.IsValid() {
+		addrBytes := addr.AsSlice()
+		b = append(b, byte(len(addrBytes)))
+		b = append(b, addrBytes...)
+	} else {
+		b = append(b, 0)
+	}
+	b = binary.BigEndian.AppendUint16(b, r.Source.Port())
+	b = binary.BigEndian.AppendUint32(b, uint32(len(r.Payload)))
+	return append(b, r.Payload...)
+}
+
+// Decoder reads the records of one segment
+type Decoder struct {
+	br      *bufio.Reader
+	payload []byte
+}
+
+// NewDecoder checks the segment header at the start of r
+func NewDecoder(r io.Reader) (*Decoder, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, HEADER_SIZE)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrNotCapture, err)
+	}
+
+	if string(header[:len(MAGIC)]) != MAGIC {
+		return nil, ErrNotCapture
+	}
+	if version := binary.BigEndian.Uint16(header[len(MAGIC):]); version != VERSION {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrUnsupportedVersion, version, VERSION)
+	}
+
+	return &Decoder{br: br}, nil
+}
+
+// Next returns the next record, or io.EOF at the end of the segment. A record
+// cut short, e.g. because the recorder crashed mid-write, returns
+// io.ErrUnexpectedEOF. The payload is only valid until the next call.
+func (d *Decoder) Next() (Record, error) {
+	var fixed [9]byte
+	if _, err := io.ReadFull(d.br, fixed[:]); err != nil {
+		return Record{}, err
+	}
+	receivedAt := time.Unix(0, int64(binary.BigEndian.Uint64(fixed[:8])))
+
+	addrLen := int(fixed[8])
+	if addrLen != 0 && addrLen != 4 && addrLen != 16 {
+		return Record{}, fmt.Errorf("%w: address length %d", ErrCorruptRecord, addrLen)
+	}
+
+	rest := make([]byte, addrLen+6)
+	if _, err := io.ReadFull(d.br, rest); err != nil {
+		return Record{}, unexpected(err)
+	}
+
+	var source netip.AddrPort
+	if addr, ok := netip.AddrFromSlice(rest[:addrLen]); ok {
+		source = netip.AddrPortFrom(addr, binary.BigEndian.Uint16(rest[addrLen:]))
+	}
+
+	n := int(binary.BigEndian.Uint32(rest[addrLen+2:]))
+	if n > MAX_PAYLOAD_SIZE {
+		return Record{}, fmt.Errorf("%w: payload length %d", ErrCorruptRecord, n)
+	}
+	if cap(d.payload) < n {
+		d.payload = make([]byte, n)
+	}
+	d.payload = d.payload[:n]
+	if _, err := io.ReadFull(d.br, d.payload); err != nil {
+		return Record{}, unexpected(err)
+	}
+
+	return Record{ReceivedAt: receivedAt, Source: source, Payload: d.payload}, nil
+}
+
+func unexpected(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// SegmentPath is the path of segment number n in dir
+func SegmentPath(dir string, n int) string {
+	return filepath.Join(dir, fmt.Sprintf("%08d%s", n, SEGMENT_EXT))
+}
+
+// Segments returns the numbers of the segments in dir, in order
+func Segments(dir string) ([]int, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading capture dir: %w", err)
+	}
+
+	var segments []int
+	for _, d := range dirEntries {
+		name, ok := strings.CutSuffix(d.Name(), SEGMENT_EXT)
+		if d.IsDir() || !ok {
+			continue
+		}
+		n, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, n)
+	}
+	sort.Ints(segments)
+	return segments, nil
+}
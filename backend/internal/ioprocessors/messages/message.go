@@ -0,0 +1,146 @@
+// Package messages holds the buffers processors pass from readers to writers
+// and quarantiners.
+//
+// Every Message is reference counted. Whoever creates or is handed a message
+// owns one reference and must Release it when done with it. Writers and
+// quarantiners only borrow the message they are given for the length of the
+// call, so one that keeps it afterwards, e.g. to send it to another goroutine
+// or to batch it, must Retain it first and Release it later. Once the last
+// reference is released a pooled message goes back to its Pool and its bytes
+// may be overwritten at any time.
+package messages
+
+import (
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Message is one message and the buffer holding it, along with where and when
+// it was received if the reader knows
+type Message struct {
+	pool *Pool
+	buf  []byte
+	n    int
+	refs atomic.Int32
+
+	source     netip.AddrPort
+	receivedAt time.Time
+}
+
+// New wraps b in a message that doesn't belong to a pool, e.g. one a writer
+// builds to send on. b must not be modified once it is wrapped.
+func New(b []byte) *Message {
+	m := &Message{buf: b, n: len(b)}
+	m.refs.Store(1)
+	return m
+}
+
+// Bytes returns the message. The slice is only valid until the message is
+// released.
+func (m *Message) Bytes() []byte {
+	return m.buf[:m.n]
+}
+
+func (m *Message) Len() int {
+	return m.n
+}
+
+// Buffer returns the whole buffer for a reader to read into, followed by a
+// call to SetLen
+func (m *Message) Buffer() []byte {
+	return m.buf
+}
+
+// SetLen sets how much of the buffer holds the message
+func (m *Message) SetLen(n int) {
+	m.n = n
+}
+
+// SetOrigin records where the message came from and when it arrived
+func (m *Message) SetOrigin(source netip.AddrPort, receivedAt time.Time) {
+	m.source = source
+	m.receivedAt = receivedAt
+}
+
+// Source is the address the message was received from. It isn't valid if the
+// reader doesn't know, e.g. for messages a writer built.
+func (m *Message) Source() netip.AddrPort {
+	return m.source
+}
+
+// ReceivedAt is when the message arrived, zero if the reader doesn't know
+func (m *Message) ReceivedAt() time.Time {
+	return m.receivedAt
+}
+
+// Retain takes another reference to m and returns it
+func (m *Message) Retain() *Message {
+	if m.refs.Add(1) <= 1 {
+		panic("messages: retained a released message")
+	}
+	return m
+}
+
+// Release drops a reference to m, returning it to its pool once none are
+// left
+func (m *Message) Release() {
+	refs := m.refs.Add(-1)
+	if refs < 0 {
+		panic("messages: released more times than retained")
+	}
+	if refs == 0 && m.pool != nil {
+		m.n = 0
+		m.pool.pool.Put(m)
+	}
+}
+
+// Pool hands out messages with buffers of the same size and takes them back
+// once they are released
+type Pool struct {
+	pool       sync.Pool
+	size       int
+	onAllocate func()
+}
+
+type PoolOption func(*Pool)
+
+// WithOnAllocate calls fn whenever the pool is empty and a new buffer has to be
+// allocated
+func WithOnAllocate(fn func()) PoolOption {
+	return func(p *Pool) {
+		p.onAllocate = fn
+	}
+}
+
+func NewPool(bufferSize int, opts ...PoolOption) *Pool {
+	p := &Pool{size: bufferSize}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	p.pool.New = func() any {
+		if p.onAllocate != nil {
+			p.onAllocate()
+		}
+		return &Message{pool: p, buf: make([]byte, p.size)}
+	}
+	return p
+}
+
+// BufferSize is the size of every message's buffer, the longest message the
+// pool can hold
+func (p *Pool) BufferSize() int {
+	return p.size
+}
+
+// Get returns an empty message the caller owns
+func (p *Pool) Get() *Message {
+	m := p.pool.Get().(*Message)
+	m.n = 0
+	m.source = netip.AddrPort{}
+	m.receivedAt = time.Time{}
+	m.refs.Store(1)
+	return m
+}
@@ -0,0 +1,60 @@
+package messages
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestMessageRefCounting(t *testing.T) {
+	allocations := 0
+	pool := NewPool(8, WithOnAllocate(func() { allocations++ }))
+
+	m := pool.Get()
+	n := copy(m.Buffer(), "abc")
+	m.SetLen(n)
+
+	// Every holder releases its own reference, in any order
+	m.Retain()
+	m.Retain()
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := string(m.Bytes()); got != "abc" {
+				t.Errorf("Bytes() = %q; want %q", got, "abc")
+			}
+			m.Release()
+		}()
+	}
+	wg.Wait()
+	m.Release()
+
+	if got := pool.Get(); got.Len() != 0 {
+		t.Errorf("message from pool has length %d; want 0", got.Len())
+	}
+	if allocations == 0 {
+		t.Error("pool never allocated")
+	}
+}
+
+func TestMessageReleasePanics(t *testing.T) {
+	tests := []struct {
+		name string
+		use  func(m *Message)
+	}{
+		{name: "released twice", use: func(m *Message) { m.Release(); m.Release() }},
+		{name: "retained after release", use: func(m *Message) { m.Release(); m.Retain() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("did not panic")
+				}
+			}()
+			tt.use(New([]byte("abc")))
+		})
+	}
+}
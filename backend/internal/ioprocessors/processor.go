@@ -3,10 +3,13 @@ package ioprocessors
 import (
 	"context"
 	"errors"
-	"sync"
+	"io"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
 	"turion-takehome/internal/ioprocessors/quarantiners"
 	"turion-takehome/internal/ioprocessors/readers"
 	"turion-takehome/internal/ioprocessors/writers"
+	"turion-takehome/internal/metrics"
 
 	"go.uber.org/zap"
 )
@@ -16,10 +19,14 @@ import (
 // needs to be broken
 const BUFFER_SIZE_WARNING_LIMIT = 2048
 
+// DEFAULT_PROCESSOR_NAME labels the metrics of processors created without
+// WithName
+const DEFAULT_PROCESSOR_NAME = "processor"
+
 type Processor struct {
 	logger *zap.Logger
 
-	pool        *sync.Pool
+	pool        *messages.Pool
 	reader      readers.Reader
 	writer      writers.Writer
 	quarantiner quarantiners.Quarantiner
@@ -44,20 +51,21 @@ func NewProcessor(
 			zap.Int("Soft limit", BUFFER_SIZE_WARNING_LIMIT))
 	}
 
-	options := &ProcessorOptions{}
+	options := &ProcessorOptions{
+		name:      DEFAULT_PROCESSOR_NAME,
+		workers:   1,
+		queueSize: DEFAULT_QUEUE_SIZE,
+	}
 	for _, opt := range opts {
 		opt(options)
 	}
 
-	pool := sync.Pool{
-		New: func() any {
-			return make([]byte, bufferSize)
-		},
-	}
+	allocations := metrics.BufferAllocations.WithLabelValues(options.name)
+	pool := messages.NewPool(bufferSize, messages.WithOnAllocate(allocations.Inc))
 
 	return &Processor{
 		logger:      logger,
-		pool:        &pool,
+		pool:        pool,
 		quarantiner: quarantiner,
 		reader:      reader,
 		writer:      writer,
@@ -67,94 +75,206 @@ func NewProcessor(
 
 // Start will initialize the processor
 func (p *Processor) Start(ctx context.Context) error {
+	if p.option.workers > 1 {
+		return p.startWorkers(ctx)
+	}
+	writeCtx := p.writeContext(ctx)
+
 	for {
 		select {
 		case <-ctx.Done():
-			ctxErr := ctx.Err()
-			if p.option.returnContextError {
-				if ctxErr != nil {
-					p.logger.Error("Context has completed, processor stopped", zap.Error(ctxErr))
-				}
-
-				return ctxErr
-			}
-
-			return nil
+			return p.stopped(ctx)
 
 		default:
 			// listen to reader
-			interfaceFromPool := p.pool.Get()
-			if interfaceFromPool == nil {
+			m, err := p.read(ctx)
+			if errors.Is(err, io.EOF) {
+				p.logger.Info("Reader has no more messages, processor stopped", zap.String("Processor", p.option.name))
 				return nil
 			}
+			if err != nil {
+				return err
+			}
 
-			bytesSlice, ok := interfaceFromPool.([]byte)
-			if !ok {
-				return errors.New("could not get bytes from pool")
+			if m == nil {
+				continue
 			}
 
-			bytesRead, err := p.reader.Read(ctx, bytesSlice)
+			// The buffer only goes back to the pool once the writer, and
+			// anything it handed the message to, is done with it
+			err = p.write(writeCtx, m)
+			m.Release()
 			if err != nil {
 				return err
 			}
+		}
+	}
+}
 
-			if bytesRead == 0 {
-				continue
-			}
+// writeContext is the context messages are written and quarantined with,
+// see WithWriteContext
+func (p *Processor) writeContext(ctx context.Context) context.Context {
+	if p.option.writeCtx != nil {
+		return p.option.writeCtx
+	}
+	return ctx
+}
 
-			bytesToSend := bytesSlice[:bytesRead]
-			p.pool.Put(bytesSlice)
+// stopped is what Start returns once ctx is done
+func (p *Processor) stopped(ctx context.Context) error {
+	ctxErr := ctx.Err()
+	if p.option.returnContextError {
+		if ctxErr != nil {
+			p.logger.Error("Context has completed, processor stopped", zap.Error(ctxErr))
+		}
 
-			_, err = p.writer.Write(ctx, bytesToSend)
-			if err != nil {
+		return ctxErr
+	}
 
-				handleWriteError := p.handleWriteErrorToQuarantine(ctx, bytesToSend, err)
-				if handleWriteError != nil {
-					err = handleWriteError
-				}
+	return nil
+}
 
-				// return as an option
-				if p.option.returnOnWriterError {
-					return err
-				}
-			}
+// read reads one message, or returns nil if nothing arrived. Empty messages
+// are released and skipped.
+func (p *Processor) read(ctx context.Context) (*messages.Message, error) {
+	m, err := p.reader.Read(ctx, p.pool)
+	if err != nil || m == nil {
+		return nil, err
+	}
 
-			// TODO: Do metrics here if you have time
-		}
+	if m.Len() == 0 {
+		m.Release()
+		return nil, nil
 	}
+
+	metrics.MessagesRead.WithLabelValues(p.option.name).Inc()
+	metrics.BytesRead.WithLabelValues(p.option.name).Add(float64(m.Len()))
+	return m, nil
 }
 
+// write writes one message, quarantining it if the writer fails. It only
+// returns an error when the processor should stop.
+func (p *Processor) write(ctx context.Context, m *messages.Message) error {
+	start := time.Now()
+	_, err := p.writer.Write(ctx, m)
+	metrics.WriteDuration.WithLabelValues(p.option.name).Observe(time.Since(start).Seconds())
+	if err == nil {
+		return nil
+	}
+
+	metrics.WriteErrors.WithLabelValues(p.option.name).Inc()
+
+	handleWriteError := p.handleWriteErrorToQuarantine(ctx, m, err)
+	if handleWriteError != nil {
+		err = handleWriteError
+	}
+
+	// return as an option
+	if p.option.returnOnWriterError {
+		return err
+	}
+	return nil
+}
+
+// Close closes the reader, if it can be closed, and then the writer, flushing
+// anything it buffered. Call it once Start has returned.
 func (p *Processor) Close() error {
+	var errs error
+	if closer, ok := p.reader.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			p.logger.Error("error closing reader on processor", zap.Error(err))
+			errs = errors.Join(errs, err)
+		}
+	}
+
 	err := p.writer.Close()
 	if err != nil {
 		p.logger.Error("error closing writer on processor", zap.Error(err))
+		errs = errors.Join(errs, err)
 	}
 
-	return err
+	return errs
 }
 
-func (p *Processor) handleWriteErrorToQuarantine(ctx context.Context, bytesToSend []byte, writeError error) error {
+func (p *Processor) handleWriteErrorToQuarantine(ctx context.Context, m *messages.Message, writeError error) error {
 	if p.option.ignoreContextError && (errors.Is(writeError, context.Canceled) || errors.Is(writeError, context.DeadlineExceeded)) {
 		return nil
 	}
 
-	_, quarantineError := p.quarantiner.Quarantine(ctx, bytesToSend, writeError)
+	_, quarantineError := p.quarantiner.Quarantine(ctx, m, writeError)
 	if quarantineError != nil {
+		metrics.QuarantineErrors.WithLabelValues(p.option.name).Inc()
 		err := errors.Join(writeError, quarantineError)
 		return err
 	}
 
+	metrics.Quarantined.WithLabelValues(p.option.name).Inc()
 	return nil
 }
 
 type ProcessorOptions struct {
+	name                string
+	workers             int
+	queueSize           int
+	orderingKey         KeyFunc
 	returnOnWriterError bool
 	returnContextError  bool
 	ignoreContextError  bool
+	writeCtx            context.Context
 }
 
 type ProcessorOption func(*ProcessorOptions)
 
+// WithName labels the processor's metrics, e.g. "telemetry" or "sql"
+func WithName(name string) ProcessorOption {
+	return func(o *ProcessorOptions) {
+		o.name = name
+	}
+}
+
+// WithWorkers writes messages on n goroutines while Start keeps reading, so a
+// slow write doesn't hold up the reader. Messages wait for a worker in a queue
+// of WithQueueSize messages. When it is full the reader waits too, and the
+// turion_processor_queue_full_total metric goes up.
+//
+// Messages may be written out of order unless WithOrderingKey is set, and the
+// writer must be safe to call from several goroutines.
+func WithWorkers(n int) ProcessorOption {
+	return func(o *ProcessorOptions) {
+		o.workers = max(n, 1)
+	}
+}
+
+// WithQueueSize sets how many messages a processor with workers reads ahead of
+// them, DEFAULT_QUEUE_SIZE by default
+func WithQueueSize(size int) ProcessorOption {
+	return func(o *ProcessorOptions) {
+		if size > 0 {
+			o.queueSize = size
+		}
+	}
+}
+
+// WithOrderingKey writes the messages that share a key in the order they were
+// read, by always handing them to the same worker, e.g. APIDKey. Each worker
+// then has its own queue of the queue size divided by the number of workers.
+func WithOrderingKey(key KeyFunc) ProcessorOption {
+	return func(o *ProcessorOptions) {
+		o.orderingKey = key
+	}
+}
+
+// WithWriteContext writes and quarantines messages with ctx rather than the
+// context given to Start, which then only stops reading. Messages already
+// read, including those queued for workers, are still written after Start's
+// context is done, until ctx is. Use a context that ends when draining times
+// out, not one that ends on the shutdown signal.
+func WithWriteContext(ctx context.Context) ProcessorOption {
+	return func(o *ProcessorOptions) {
+		o.writeCtx = ctx
+	}
+}
+
 // WithReturnOnWriterError will exit the process when
 // we cannot get the message.
 // It will return after we send the message to quarantine.
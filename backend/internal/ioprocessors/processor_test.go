@@ -0,0 +1,318 @@
+package ioprocessors
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/ioprocessors/quarantiners"
+	"turion-takehome/internal/ioprocessors/readers"
+	"turion-takehome/internal/ioprocessors/writers"
+
+	"go.uber.org/zap"
+)
+
+// orderWriter records the sequence numbers written for each key. Messages are
+// {key, seq} and writes take a random amount of time, so workers finish out
+// of order.
+type orderWriter struct {
+	mu  sync.Mutex
+	got map[byte][]byte
+}
+
+func (w *orderWriter) Write(_ context.Context, m *messages.Message) (int, error) {
+	b := m.Bytes()
+	time.Sleep(time.Duration(rand.Intn(200)) * time.Microsecond)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.got[b[0]] = append(w.got[b[0]], b[1])
+	return m.Len(), nil
+}
+
+func (w *orderWriter) Close() error {
+	return nil
+}
+
+func TestProcessorWorkersOrderByKey(t *testing.T) {
+	const keys, perKey = 4, 50
+
+	ch := make(chan *messages.Message)
+	go func() {
+		defer close(ch)
+		for seq := range perKey {
+			for key := range keys {
+				ch <- messages.New([]byte{byte(key), byte(seq)})
+			}
+		}
+	}()
+
+	logger := zap.NewNop()
+	quarantiner, err := quarantiners.NewNoOpQuarantiner(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := &orderWriter{got: map[byte][]byte{}}
+
+	p := NewProcessor(logger, 2, readers.NewChannelReader(logger, ch), writer, quarantiner,
+		WithWorkers(3),
+		WithQueueSize(8),
+		WithOrderingKey(func(b []byte) uint64 { return uint64(b[0]) }),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// Start only returns once every queued message is written
+	for key := range byte(keys) {
+		got := writer.got[key]
+		if len(got) != perKey {
+			t.Fatalf("key %d: wrote %d messages; want %d", key, len(got), perKey)
+		}
+		for i, seq := range got {
+			if seq != byte(i) {
+				t.Fatalf("key %d: message %d has sequence %d; want in order", key, i, seq)
+			}
+		}
+	}
+}
+
+// patternReader reads count messages from the pool, each filled with its own
+// sequence number
+type patternReader struct {
+	next, count int
+}
+
+func (r *patternReader) Read(_ context.Context, pool *messages.Pool) (*messages.Message, error) {
+	if r.next == r.count {
+		return nil, io.EOF
+	}
+
+	m := pool.Get()
+	buf := m.Buffer()
+	for i := range buf {
+		buf[i] = byte(r.next)
+	}
+	m.SetLen(len(buf))
+	r.next++
+	return m, nil
+}
+
+// checkWriter fails the test if a message changes while it holds it. With
+// keep set it holds on to messages after Write returns, like a batching
+// writer, and releases them later on another goroutine.
+type checkWriter struct {
+	t    *testing.T
+	keep chan *messages.Message
+}
+
+func (w *checkWriter) Write(_ context.Context, m *messages.Message) (int, error) {
+	if w.keep != nil {
+		w.keep <- m.Retain()
+	}
+	checkPattern(w.t, m)
+	return m.Len(), nil
+}
+
+func (w *checkWriter) Close() error {
+	return nil
+}
+
+func checkPattern(t *testing.T, m *messages.Message) {
+	t.Helper()
+	b := m.Bytes()
+	time.Sleep(time.Duration(rand.Intn(50)) * time.Microsecond)
+	for i := range b {
+		if b[i] != b[0] {
+			t.Errorf("message %d was overwritten while in use: byte %d is %d", b[0], i, b[i])
+			return
+		}
+	}
+}
+
+// TestProcessorBufferOwnership runs pooled messages through two processors
+// joined by a channel, with workers and writers that keep messages after
+// Write returns. Run it with -race: a buffer going back to the pool while
+// anyone still holds it shows up as a data race or an overwritten message.
+func TestProcessorBufferOwnership(t *testing.T) {
+	logger := zap.NewNop()
+	quarantiner, err := quarantiners.NewNoOpQuarantiner(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kept := make(chan *messages.Message, 16)
+	var releasers sync.WaitGroup
+	releasers.Add(1)
+	go func() {
+		defer releasers.Done()
+		for m := range kept {
+			checkPattern(t, m)
+			m.Release()
+		}
+	}()
+
+	ch := make(chan *messages.Message)
+	tee, err := writers.NewTee(logger, writers.TEE_ALL,
+		writers.NewChannelWriter(logger, ch),
+		&checkWriter{t: t, keep: kept},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A small pool keeps buffers cycling quickly
+	upstream := NewProcessor(logger, 64, &patternReader{count: 500}, tee, quarantiner,
+		WithName("upstream"),
+		WithWorkers(4),
+		WithQueueSize(4),
+	)
+	downstream := NewProcessor(logger, 64, readers.NewChannelReader(logger, ch), &checkWriter{t: t}, quarantiner,
+		WithName("downstream"),
+		WithWorkers(4),
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- downstream.Start(ctx)
+	}()
+
+	if err := upstream.Start(ctx); err != nil {
+		t.Fatalf("upstream Start() error = %v", err)
+	}
+	if err := upstream.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("downstream Start() error = %v", err)
+	}
+
+	close(kept)
+	releasers.Wait()
+}
+
+// stoppingReader reads count messages, then cancels Start's context as a
+// shutdown signal would
+type stoppingReader struct {
+	count int
+	stop  context.CancelFunc
+	read  []*messages.Message
+}
+
+func (r *stoppingReader) Read(ctx context.Context, _ *messages.Pool) (*messages.Message, error) {
+	if len(r.read) == r.count {
+		r.stop()
+		<-ctx.Done()
+		return nil, nil
+	}
+
+	m := messages.New([]byte{byte(len(r.read))})
+	r.read = append(r.read, m)
+	return m, nil
+}
+
+// slowWriter takes a while over each write and fails it once ctx is done,
+// like a ChannelWriter with a busy reader
+type slowWriter struct {
+	mu      sync.Mutex
+	written int
+	err     error
+}
+
+func (w *slowWriter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	time.Sleep(100 * time.Microsecond)
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written++
+	return m.Len(), nil
+}
+
+func (w *slowWriter) Close() error {
+	return nil
+}
+
+type countingQuarantiner struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (q *countingQuarantiner) Quarantine(context.Context, *messages.Message, error) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.count++
+	return 0, nil
+}
+
+func released(m *messages.Message) (ok bool) {
+	defer func() {
+		ok = recover() != nil
+	}()
+	m.Retain().Release()
+	return false
+}
+
+func TestProcessorWorkersWriteQueuedAfterStop(t *testing.T) {
+	const count = 200
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	reader := &stoppingReader{count: count, stop: stop}
+	writer := &slowWriter{}
+	quarantiner := &countingQuarantiner{}
+
+	p := NewProcessor(zap.NewNop(), 2, reader, writer, quarantiner,
+		WithWorkers(2),
+		WithQueueSize(count),
+		WithWriteContext(context.Background()),
+	)
+	if err := p.Start(ctx); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	if writer.written != count || quarantiner.count != 0 {
+		t.Errorf("wrote %d and quarantined %d messages; want %d written", writer.written, quarantiner.count, count)
+	}
+	for i, m := range reader.read {
+		if !released(m) {
+			t.Fatalf("message %d was not released", i)
+		}
+	}
+}
+
+func TestProcessorWorkersReleaseQueueOnError(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	reader := &stoppingReader{count: 100, stop: stop}
+	writer := &slowWriter{err: io.ErrClosedPipe}
+
+	p := NewProcessor(zap.NewNop(), 2, reader, writer, &countingQuarantiner{},
+		WithWorkers(2),
+		WithQueueSize(100),
+		WithReturnOnWriterError(),
+	)
+	if err := p.Start(ctx); err == nil {
+		t.Fatal("Start() error = nil")
+	}
+
+	for i, m := range reader.read {
+		if !released(m) {
+			t.Fatalf("message %d of %d was not released", i, len(reader.read))
+		}
+	}
+}
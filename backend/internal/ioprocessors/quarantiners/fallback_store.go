@@ -0,0 +1,95 @@
+package quarantiners
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"go.uber.org/zap"
+)
+
+// FallbackStore appends to a primary store and, when that fails, to a
+// fallback, so a message is not lost when the database that rejected it is
+// also what it would be quarantined in. Reads cover both stores.
+type FallbackStore struct {
+	logger   *zap.Logger
+	primary  Store
+	fallback Store
+}
+
+func NewFallbackStore(
+	logger *zap.Logger,
+	primary Store,
+	fallback Store,
+) (*FallbackStore, error) {
+	var errs error
+	if logger == nil {
+		return nil, errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if primary == nil {
+		errs = errors.Join(errs, errors.New("primary store cannot be nil"))
+	}
+
+	if fallback == nil {
+		errs = errors.Join(errs, errors.New("fallback store cannot be nil"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &FallbackStore{
+		logger:   logger,
+		primary:  primary,
+		fallback: fallback,
+	}, nil
+}
+
+func (s *FallbackStore) Append(ctx context.Context, e Entry) (Entry, error) {
+	appended, err := s.primary.Append(ctx, e)
+	if err == nil {
+		return appended, nil
+	}
+
+	s.logger.Warn("Quarantining to the fallback store", zap.Error(err))
+	appended, fallbackErr := s.fallback.Append(ctx, e)
+	if fallbackErr != nil {
+		return Entry{}, errors.Join(err, fallbackErr)
+	}
+	return appended, nil
+}
+
+// List returns the entries of both stores, oldest first
+func (s *FallbackStore) List(ctx context.Context) ([]Entry, error) {
+	entries, err := s.primary.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fallbackEntries, err := s.fallback.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	entries = append(entries, fallbackEntries...)
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries, nil
+}
+
+func (s *FallbackStore) Get(ctx context.Context, id string) (Entry, error) {
+	e, err := s.primary.Get(ctx, id)
+	if errors.Is(err, ErrEntryNotFound) {
+		return s.fallback.Get(ctx, id)
+	}
+	return e, err
+}
+
+func (s *FallbackStore) MarkReplayed(ctx context.Context, id string) error {
+	err := s.primary.MarkReplayed(ctx, id)
+	if errors.Is(err, ErrEntryNotFound) {
+		return s.fallback.MarkReplayed(ctx, id)
+	}
+	return err
+}
@@ -0,0 +1,63 @@
+package quarantiners
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// failingStore fails every call
+type failingStore struct{}
+
+func (failingStore) Append(context.Context, Entry) (Entry, error) {
+	return Entry{}, errors.New("database down")
+}
+func (failingStore) List(context.Context) ([]Entry, error) { return nil, nil }
+func (failingStore) Get(context.Context, string) (Entry, error) {
+	return Entry{}, ErrEntryNotFound
+}
+func (failingStore) MarkReplayed(context.Context, string) error { return ErrEntryNotFound }
+
+func TestFallbackStore(t *testing.T) {
+	ctx := context.Background()
+
+	fileStore, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileStore.Close()
+
+	store, err := NewFallbackStore(zap.NewNop(), failingStore{}, fileStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := store.Append(ctx, Entry{Time: time.Now(), Source: "sql", Payload: []byte("row")})
+	if err != nil {
+		t.Fatalf("Append() error = %v; want the entry in the fallback", err)
+	}
+
+	if err := store.MarkReplayed(ctx, e.ID); err != nil {
+		t.Fatalf("MarkReplayed() error = %v", err)
+	}
+	got, err := store.Get(ctx, e.ID)
+	if err != nil || string(got.Payload) != "row" || got.ReplayedAt == nil {
+		t.Errorf("Get(%q) = %+v, %v; want the replayed entry", e.ID, got, err)
+	}
+
+	entries, err := store.List(ctx)
+	if err != nil || len(entries) != 1 {
+		t.Errorf("List() = %+v, %v; want the fallback entry", entries, err)
+	}
+
+	both, err := NewFallbackStore(zap.NewNop(), failingStore{}, failingStore{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := both.Append(ctx, Entry{}); err == nil {
+		t.Error("Append() error = nil; want an error when both stores fail")
+	}
+}
@@ -0,0 +1,346 @@
+package quarantiners
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_MAX_SEGMENT_BYTES = 64 << 20
+
+	segmentExt = ".seg"
+)
+
+// FileStore keeps entries in append-only segment files in a directory. Each
+// line of a segment is a JSON record, either an entry or a note that an entry
+// was replayed. Every append is synced to disk before it returns. A segment
+// is closed and a new one started once it reaches its maximum size.
+//
+// A torn line at the end of a segment, from a crash mid-write, is skipped
+// when reading.
+//
+// Where each entry is, and whether it was replayed, is kept in memory so Get
+// and MarkReplayed read a single line rather than every segment.
+type FileStore struct {
+	dir             string
+	maxSegmentBytes int64
+
+	mu      sync.Mutex
+	segment int
+	count   int
+	size    int64
+	f       *os.File
+	index   map[string]*entryLocation
+}
+
+// fileRecord is one line of a segment
+type fileRecord struct {
+	*Entry
+	// Replayed is the ID of an entry that was replayed at ReplayedTime
+	Replayed     string     `json:"replayed,omitempty"`
+	ReplayedTime *time.Time `json:"replayedTime,omitempty"`
+
+	// Where the line is in its segment, set when reading
+	offset int64
+	length int
+}
+
+// entryLocation is where an entry's line is
+type entryLocation struct {
+	segment    int
+	offset     int64
+	length     int
+	replayedAt *time.Time
+}
+
+type FileStoreOption func(*FileStore)
+
+// WithMaxSegmentBytes starts a new segment once the current one reaches n
+// bytes
+func WithMaxSegmentBytes(n int64) FileStoreOption {
+	return func(s *FileStore) {
+		s.maxSegmentBytes = n
+	}
+}
+
+// NewFileStore opens the segments in dir, creating it if needed, and appends
+// to the newest one
+func NewFileStore(dir string, opts ...FileStoreOption) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating quarantine dir: %w", err)
+	}
+
+	s := &FileStore{
+		dir:             dir,
+		maxSegmentBytes: DEFAULT_MAX_SEGMENT_BYTES,
+		index:           map[string]*entryLocation{},
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.maxSegmentBytes <= 0 {
+		return nil, errors.New("max segment bytes must be positive")
+	}
+
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(segments) == 0 {
+		return s, s.openSegment(1)
+	}
+
+	last := segments[len(segments)-1]
+	for _, segment := range segments {
+		records, err := s.readSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range records {
+			switch {
+			case r.Entry != nil:
+				s.index[r.ID] = &entryLocation{segment: segment, offset: r.offset, length: r.length}
+				if segment == last {
+					s.count++
+				}
+			case r.Replayed != "":
+				if loc, ok := s.index[r.Replayed]; ok {
+					loc.replayedAt = r.ReplayedTime
+				}
+			}
+		}
+	}
+	return s, s.openSegment(last)
+}
+
+func (s *FileStore) Append(ctx context.Context, e Entry) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.size >= s.maxSegmentBytes {
+		if err := s.openSegment(s.segment + 1); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	e.ID = fmt.Sprintf("%08d-%06d", s.segment, s.count)
+	e.ReplayedAt = nil
+	offset := s.size
+	if err := s.write(fileRecord{Entry: &e}); err != nil {
+		return Entry{}, err
+	}
+	s.index[e.ID] = &entryLocation{
+		segment: s.segment,
+		offset:  offset,
+		// Without the newline
+		length: int(s.size-offset) - 1,
+	}
+	s.count++
+	return e, nil
+}
+
+func (s *FileStore) List(ctx context.Context) ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	segments, err := s.segments()
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	index := map[string]int{}
+	for _, segment := range segments {
+		records, err := s.readSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range records {
+			switch {
+			case r.Entry != nil:
+				index[r.ID] = len(entries)
+				entries = append(entries, *r.Entry)
+			case r.Replayed != "":
+				if i, ok := index[r.Replayed]; ok {
+					entries[i].ReplayedAt = r.ReplayedTime
+				}
+			}
+		}
+	}
+	return entries, nil
+}
+
+func (s *FileStore) Get(ctx context.Context, id string) (Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loc, ok := s.index[id]
+	if !ok {
+		return Entry{}, fmt.Errorf("%w: %s", ErrEntryNotFound, id)
+	}
+
+	f, err := os.Open(s.segmentPath(loc.segment))
+	if err != nil {
+		return Entry{}, fmt.Errorf("opening quarantine segment: %w", err)
+	}
+	defer f.Close()
+
+	line := make([]byte, loc.length)
+	if _, err := f.ReadAt(line, loc.offset); err != nil {
+		return Entry{}, fmt.Errorf("reading quarantine segment: %w", err)
+	}
+	var r fileRecord
+	if err := json.Unmarshal(line, &r); err != nil || r.Entry == nil || r.ID != id {
+		return Entry{}, fmt.Errorf("reading quarantine entry %s: corrupt record", id)
+	}
+
+	r.Entry.ReplayedAt = loc.replayedAt
+	return *r.Entry, nil
+}
+
+func (s *FileStore) MarkReplayed(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loc, ok := s.index[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrEntryNotFound, id)
+	}
+
+	now := time.Now().UTC()
+	if err := s.write(fileRecord{Replayed: id, ReplayedTime: &now}); err != nil {
+		return err
+	}
+	loc.replayedAt = &now
+	return nil
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.f.Close()
+}
+
+// write appends r to the current segment. The caller must hold s.mu.
+func (s *FileStore) write(r fileRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal quarantine record: %w", err)
+	}
+	b = append(b, '\n')
+
+	n, err := s.f.Write(b)
+	s.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("writing quarantine segment: %w", err)
+	}
+	if err := s.f.Sync(); err != nil {
+		return fmt.Errorf("syncing quarantine segment: %w", err)
+	}
+	return nil
+}
+
+// openSegment makes segment the one appended to. The caller must hold s.mu
+// or be the constructor.
+func (s *FileStore) openSegment(segment int) error {
+	f, err := os.OpenFile(s.segmentPath(segment), os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening quarantine segment: %w", err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("opening quarantine segment: %w", err)
+	}
+
+	// Terminate a torn last line so the next record starts on its own line
+	if info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return fmt.Errorf("repairing quarantine segment: %w", err)
+			}
+		}
+		info, _ = f.Stat()
+	}
+
+	if s.f != nil {
+		s.f.Close()
+		s.count = 0
+	}
+	s.f = f
+	s.segment = segment
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileStore) segmentPath(segment int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%08d%s", segment, segmentExt))
+}
+
+// segments returns the numbers of the segments in the directory, in order
+func (s *FileStore) segments() ([]int, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading quarantine dir: %w", err)
+	}
+
+	var segments []int
+	for _, d := range dirEntries {
+		name, ok := strings.CutSuffix(d.Name(), segmentExt)
+		if d.IsDir() || !ok {
+			continue
+		}
+		n, err := strconv.Atoi(name)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, n)
+	}
+	sort.Ints(segments)
+	return segments, nil
+}
+
+func (s *FileStore) readSegment(segment int) ([]fileRecord, error) {
+	f, err := os.Open(s.segmentPath(segment))
+	if err != nil {
+		return nil, fmt.Errorf("opening quarantine segment: %w", err)
+	}
+	defer f.Close()
+
+	var records []fileRecord
+	var offset int64
+	scanner := bufio.NewScanner(f)
+	// Payloads are small, but leave room for the odd oversized one
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		r := fileRecord{offset: offset, length: len(line)}
+		offset += int64(len(line)) + 1
+		if err := json.Unmarshal(line, &r); err != nil {
+			continue
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading quarantine segment: %w", err)
+	}
+	return records, nil
+}
@@ -0,0 +1,102 @@
+package quarantiners
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"turion-takehome/internal/ioprocessors/messages"
+
+	"go.uber.org/zap"
+)
+
+func TestFileStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir, WithMaxSegmentBytes(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewStoreQuarantiner(zap.NewNop(), store, "sql", "channel")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, payload := range []string{"first", "second", "third"} {
+		if _, err := q.Quarantine(ctx, messages.New([]byte(payload)), errors.New("insert failed")); err != nil {
+			t.Fatalf("Quarantine() error = %v", err)
+		}
+	}
+
+	segments, err := store.segments()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segments) < 2 {
+		t.Errorf("segments = %v; want the store to have rotated", segments)
+	}
+
+	entries, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || string(entries[1].Payload) != "second" || entries[1].Source != "sql" || entries[1].Error != "insert failed" {
+		t.Fatalf("List() = %+v; want the three quarantined payloads", entries)
+	}
+
+	if err := store.MarkReplayed(ctx, entries[0].ID); err != nil {
+		t.Fatalf("MarkReplayed() error = %v", err)
+	}
+	if err := store.MarkReplayed(ctx, "nope"); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("MarkReplayed(unknown) error = %v; want ErrEntryNotFound", err)
+	}
+	if got, err := store.Get(ctx, entries[0].ID); err != nil || string(got.Payload) != "first" || got.ReplayedAt == nil {
+		t.Errorf("Get(%q) = %+v, %v; want the first entry, replayed", entries[0].ID, got, err)
+	}
+	if _, err := store.Get(ctx, "nope"); !errors.Is(err, ErrEntryNotFound) {
+		t.Errorf("Get(unknown) error = %v; want ErrEntryNotFound", err)
+	}
+
+	// Simulate a crash halfway through writing a record
+	f, err := os.OpenFile(store.segmentPath(store.segment), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"id":"torn`)
+	f.Close()
+	store.Close()
+
+	reopened, err := NewFileStore(dir, WithMaxSegmentBytes(200))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+
+	fourth, err := reopened.Append(ctx, Entry{Source: "udp", Payload: []byte("fourth")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err = reopened.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 4 || entries[0].ReplayedAt == nil || entries[1].ReplayedAt != nil {
+		t.Fatalf("List() after reopen = %+v; want four entries with the first replayed", entries)
+	}
+
+	got, err := reopened.Get(ctx, fourth.ID)
+	if err != nil || string(got.Payload) != "fourth" {
+		t.Errorf("Get(%q) = %+v, %v; want the fourth entry", fourth.ID, got, err)
+	}
+	got, err = reopened.Get(ctx, entries[0].ID)
+	if err != nil || string(got.Payload) != "first" || got.ReplayedAt == nil {
+		t.Errorf("Get(%q) after reopen = %+v, %v; want the first entry, replayed", entries[0].ID, got, err)
+	}
+	for _, e := range entries[:3] {
+		if e.ID == fourth.ID {
+			t.Errorf("ID %q was reused", fourth.ID)
+		}
+	}
+}
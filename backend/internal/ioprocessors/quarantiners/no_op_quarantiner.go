@@ -2,6 +2,7 @@ package quarantiners
 
 import (
 	"context"
+	"turion-takehome/internal/ioprocessors/messages"
 
 	"go.uber.org/zap"
 )
@@ -20,11 +21,11 @@ func NewNoOpQuarantiner(logger *zap.Logger) (*NoOpQuarantiner, error) {
 // Quarantine writes a message to a Quarantine Channel for further processing of
 // an io message. It returns the number of bytes written to the Quarantine
 // Channel and any errors that occurred while writing to it.
-func (q *NoOpQuarantiner) Quarantine(ctx context.Context, b []byte, err error) (int, error) {
+func (q *NoOpQuarantiner) Quarantine(ctx context.Context, m *messages.Message, err error) (int, error) {
 	q.logger.Warn(
 		"Quarantining message but performing no action with it",
-		zap.Binary("Bytes", b),
+		zap.Binary("Bytes", m.Bytes()),
 		zap.Error(err))
 
-	return len(b), nil
+	return m.Len(), nil
 }
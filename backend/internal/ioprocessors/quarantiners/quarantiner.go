@@ -1,10 +1,15 @@
 package quarantiners
 
-import "context"
+import (
+	"context"
+	"turion-takehome/internal/ioprocessors/messages"
+)
 
 // Quarantiner is used in the processor encounters an error while reading or
 // writing a message. Rather than kill the whole process, we quarantine the
 // message elsewhere for review
 type Quarantiner interface {
-	Quarantine(context.Context, []byte, error) (int, error)
+	//
+	// Like writers, quarantiners only borrow the message until they return
+	Quarantine(context.Context, *messages.Message, error) (int, error)
 }
@@ -0,0 +1,111 @@
+package quarantiners
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// SQLStore keeps entries in the public.quarantine table
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) Append(ctx context.Context, e Entry) (Entry, error) {
+	const stmt = `
+    INSERT INTO public.quarantine (received_at, source, reader, error, payload)
+    VALUES ($1, $2, $3, $4, $5)
+    RETURNING id`
+	var id int64
+	err := s.db.QueryRowContext(ctx, stmt, e.Time, e.Source, e.Reader, e.Error, e.Payload).Scan(&id)
+	if err != nil {
+		return Entry{}, fmt.Errorf("insert quarantine entry: %w", err)
+	}
+
+	e.ID = strconv.FormatInt(id, 10)
+	e.ReplayedAt = nil
+	return e, nil
+}
+
+func (s *SQLStore) List(ctx context.Context) ([]Entry, error) {
+	const q = `
+    SELECT id, received_at, source, reader, error, payload, replayed_at
+      FROM public.quarantine
+     ORDER BY id ASC`
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("query quarantine: %w", err)
+	}
+	defer rows.Close()
+
+	var out []Entry
+	for rows.Next() {
+		e, err := scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate quarantine rows: %w", err)
+	}
+	return out, nil
+}
+
+func (s *SQLStore) Get(ctx context.Context, id string) (Entry, error) {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return Entry{}, fmt.Errorf("%w: %s", ErrEntryNotFound, id)
+	}
+
+	const q = `
+    SELECT id, received_at, source, reader, error, payload, replayed_at
+      FROM public.quarantine
+     WHERE id = $1`
+	e, err := scanEntry(s.db.QueryRowContext(ctx, q, n))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, fmt.Errorf("%w: %s", ErrEntryNotFound, id)
+	}
+	return e, err
+}
+
+func (s *SQLStore) MarkReplayed(ctx context.Context, id string) error {
+	n, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrEntryNotFound, id)
+	}
+
+	res, err := s.db.ExecContext(ctx,
+		`UPDATE public.quarantine SET replayed_at = NOW() WHERE id = $1`, n)
+	if err != nil {
+		return fmt.Errorf("mark quarantine entry replayed: %w", err)
+	}
+	if affected, err := res.RowsAffected(); err == nil && affected == 0 {
+		return fmt.Errorf("%w: %s", ErrEntryNotFound, id)
+	}
+	return nil
+}
+
+func scanEntry(row interface{ Scan(...any) error }) (Entry, error) {
+	var e Entry
+	var id int64
+	var replayedAt sql.NullTime
+	if err := row.Scan(&id, &e.Time, &e.Source, &e.Reader, &e.Error, &e.Payload, &replayedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return Entry{}, err
+		}
+		return Entry{}, fmt.Errorf("scan quarantine row: %w", err)
+	}
+
+	e.ID = strconv.FormatInt(id, 10)
+	if replayedAt.Valid {
+		e.ReplayedAt = &replayedAt.Time
+	}
+	return e, nil
+}
@@ -0,0 +1,110 @@
+package quarantiners
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+
+	"go.uber.org/zap"
+)
+
+// ErrEntryNotFound is returned when no quarantined entry has the requested ID
+var ErrEntryNotFound = errors.New("quarantine entry not found")
+
+// Entry is one quarantined message and why it was quarantined
+type Entry struct {
+	// ID is assigned by the store when the entry is appended
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// Source is the pipeline stage that rejected the message, e.g. "sql"
+	Source string `json:"source"`
+	// Reader is where the stage read the message from, e.g. "udp"
+	Reader  string `json:"reader"`
+	Error   string `json:"error"`
+	Payload []byte `json:"payload"`
+	// ReplayedAt is set once the entry has been replayed successfully
+	ReplayedAt *time.Time `json:"replayedAt,omitempty"`
+}
+
+// Store persists quarantined entries so they can be inspected and replayed
+// once whatever rejected them is fixed. Entries are never modified, except to
+// record that they were replayed.
+type Store interface {
+	Append(ctx context.Context, e Entry) (Entry, error)
+	// List returns every entry, oldest first
+	List(ctx context.Context) ([]Entry, error)
+	Get(ctx context.Context, id string) (Entry, error)
+	MarkReplayed(ctx context.Context, id string) error
+}
+
+// StoreQuarantiner quarantines messages into a Store, labelled with the stage
+// and reader they came from. Several quarantiners can share one store.
+type StoreQuarantiner struct {
+	logger *zap.Logger
+	store  Store
+	source string
+	reader string
+	now    func() time.Time
+}
+
+func NewStoreQuarantiner(
+	logger *zap.Logger,
+	store Store,
+	source string,
+	reader string,
+) (*StoreQuarantiner, error) {
+	var errs error
+	if logger == nil {
+		return nil, errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if store == nil {
+		errs = errors.Join(errs, errors.New("store cannot be nil"))
+	}
+
+	if source == "" {
+		errs = errors.Join(errs, errors.New("source cannot be empty"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &StoreQuarantiner{
+		logger: logger,
+		store:  store,
+		source: source,
+		reader: reader,
+		now:    time.Now,
+	}, nil
+}
+
+func (q *StoreQuarantiner) Quarantine(ctx context.Context, m *messages.Message, err error) (int, error) {
+	errMsg := ""
+	if err != nil {
+		errMsg = err.Error()
+	}
+
+	// Keep the message even if the stage is shutting down. The payload isn't
+	// copied, both stores have written it by the time Append returns.
+	e, storeErr := q.store.Append(context.WithoutCancel(ctx), Entry{
+		Time:    q.now().UTC(),
+		Source:  q.source,
+		Reader:  q.reader,
+		Error:   errMsg,
+		Payload: m.Bytes(),
+	})
+	if storeErr != nil {
+		return 0, fmt.Errorf("quarantining message: %w", storeErr)
+	}
+
+	q.logger.Warn("Quarantined message",
+		zap.String("ID", e.ID),
+		zap.String("Source", q.source),
+		zap.Int("Bytes", m.Len()),
+		zap.Error(err),
+	)
+	return m.Len(), nil
+}
@@ -0,0 +1,209 @@
+package readers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+	"turion-takehome/internal/ioprocessors/capture"
+	"turion-takehome/internal/ioprocessors/messages"
+
+	"go.uber.org/zap"
+)
+
+// REPLAY_AS_FAST_AS_POSSIBLE is the replay speed that doesn't wait between
+// records
+const REPLAY_AS_FAST_AS_POSSIBLE = 0
+
+// captureReader replays a capture recorded by writers.CaptureWriter, segment
+// by segment. Messages keep the source and receive time they were recorded
+// with.
+type captureReader struct {
+	logger   *zap.Logger
+	dir      string
+	speed    float64
+	segments []int
+
+	f   *os.File
+	dec *capture.Decoder
+
+	// The receive time of the first record and when it was replayed, which
+	// the records after it are paced from
+	first   time.Time
+	started time.Time
+}
+
+type captureoption struct {
+	speed float64
+}
+
+type captureOption func(*captureoption)
+
+// WithReplaySpeed replays records speed times faster than they were received,
+// e.g. 1 to replay at the original speed, which is the default, or 10 to
+// replay a ten minute pass in a minute. REPLAY_AS_FAST_AS_POSSIBLE doesn't
+// wait at all.
+func WithReplaySpeed(speed float64) captureOption {
+	return func(opt *captureoption) {
+		opt.speed = speed
+	}
+}
+
+// NewCaptureReader replays the segments in dir. Read returns io.EOF once the
+// last record has been replayed.
+func NewCaptureReader(logger *zap.Logger, dir string, opts ...captureOption) (*captureReader, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	opt := &captureoption{speed: 1}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	if opt.speed < 0 {
+		errs = errors.Join(errs, errors.New("replay speed cannot be negative"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	segments, err := capture.Segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("no capture segments in %s", dir)
+	}
+
+	return &captureReader{
+		logger:   logger,
+		dir:      dir,
+		speed:    opt.speed,
+		segments: segments,
+	}, nil
+}
+
+// Read replays the next record into a message from pool once it is due. It
+// returns no message and no error if ctx is done first, or for records too
+// big for the pool's buffers.
+func (r *captureReader) Read(ctx context.Context, pool *messages.Pool) (*messages.Message, error) {
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+
+	rec, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.wait(ctx, rec.ReceivedAt) {
+		return nil, nil
+	}
+
+	m := pool.Get()
+	if len(rec.Payload) > len(m.Buffer()) {
+		m.Release()
+		r.logger.Warn("Skipping captured record too big for the buffer",
+			zap.Int("Bytes", len(rec.Payload)),
+			zap.Int("Buffer size", pool.BufferSize()),
+		)
+		return nil, nil
+	}
+
+	m.SetLen(copy(m.Buffer(), rec.Payload))
+	m.SetOrigin(rec.Source, rec.ReceivedAt)
+	return m, nil
+}
+
+// next returns the next record, moving on to the next segment at the end of
+// one. A segment that is corrupt or cut short is replayed up to the problem.
+func (r *captureReader) next() (capture.Record, error) {
+	for {
+		if r.dec == nil {
+			if len(r.segments) == 0 {
+				return capture.Record{}, io.EOF
+			}
+			if err := r.open(r.segments[0]); err != nil {
+				r.logger.Warn("Skipping capture segment", zap.Int("Segment", r.segments[0]), zap.Error(err))
+			}
+			r.segments = r.segments[1:]
+			continue
+		}
+
+		rec, err := r.dec.Next()
+		if err == nil {
+			return rec, nil
+		}
+		if !errors.Is(err, io.EOF) {
+			r.logger.Warn("Skipping the rest of capture segment", zap.String("Path", r.f.Name()), zap.Error(err))
+		}
+		r.closeSegment()
+	}
+}
+
+func (r *captureReader) open(segment int) error {
+	f, err := os.Open(capture.SegmentPath(r.dir, segment))
+	if err != nil {
+		return err
+	}
+
+	dec, err := capture.NewDecoder(f)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	r.logger.Info("Replaying capture segment", zap.String("Path", f.Name()))
+	r.f = f
+	r.dec = dec
+	return nil
+}
+
+func (r *captureReader) closeSegment() {
+	if r.f != nil {
+		r.f.Close()
+	}
+	r.f = nil
+	r.dec = nil
+}
+
+// wait blocks until a record received at receivedAt is due, and reports
+// whether it is. Records received before the ones already replayed are due
+// straight away.
+func (r *captureReader) wait(ctx context.Context, receivedAt time.Time) bool {
+	if r.speed == REPLAY_AS_FAST_AS_POSSIBLE {
+		return true
+	}
+
+	if r.started.IsZero() {
+		r.first = receivedAt
+		r.started = time.Now()
+		return true
+	}
+
+	due := r.started.Add(time.Duration(float64(receivedAt.Sub(r.first)) / r.speed))
+	delay := time.Until(due)
+	if delay <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Close closes the segment being replayed
+func (r *captureReader) Close() error {
+	r.closeSegment()
+	return nil
+}
@@ -0,0 +1,95 @@
+package readers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+	"turion-takehome/internal/ioprocessors/capture"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/ioprocessors/writers"
+
+	"go.uber.org/zap"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	source := netip.MustParseAddrPort("10.1.2.3:5000")
+	start := time.Unix(1700000000, 0)
+	const records = 5
+	const spacing = 50 * time.Millisecond
+
+	// Small segments so the capture rotates
+	w, err := writers.NewCaptureWriter(zap.NewNop(), dir, writers.WithMaxCaptureBytes(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range records {
+		m := messages.New([]byte(fmt.Sprintf("datagram %d", i)))
+		m.SetOrigin(source, start.Add(time.Duration(i)*spacing))
+		if _, err := w.Write(context.Background(), m); err != nil {
+			t.Fatal(err)
+		}
+		m.Release()
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	segments, err := capture.Segments(dir)
+	if err != nil || len(segments) < 2 {
+		t.Fatalf("got segments %v, %v; want the capture to rotate", segments, err)
+	}
+
+	// A recorder that crashed mid-write leaves a torn record behind
+	f, err := os.OpenFile(capture.SegmentPath(dir, segments[len(segments)-1]), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 1, 2})
+	f.Close()
+
+	tests := []struct {
+		name    string
+		speed   float64
+		minTime time.Duration
+	}{
+		{name: "as fast as possible", speed: REPLAY_AS_FAST_AS_POSSIBLE},
+		{name: "twice the original speed", speed: 2, minTime: (records - 1) * spacing / 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewCaptureReader(zap.NewNop(), dir, WithReplaySpeed(tt.speed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			pool := messages.NewPool(64)
+			began := time.Now()
+			for i := range records {
+				m, err := r.Read(context.Background(), pool)
+				if err != nil || m == nil {
+					t.Fatalf("Read() = %v, %v; want record %d", m, err, i)
+				}
+				want := fmt.Sprintf("datagram %d", i)
+				if string(m.Bytes()) != want || m.Source() != source || !m.ReceivedAt().Equal(start.Add(time.Duration(i)*spacing)) {
+					t.Errorf("record %d = %q from %v at %v; want %q from %v", i, m.Bytes(), m.Source(), m.ReceivedAt(), want, source)
+				}
+				m.Release()
+			}
+
+			if _, err := r.Read(context.Background(), pool); !errors.Is(err, io.EOF) {
+				t.Errorf("Read() after the last record error = %v; want io.EOF", err)
+			}
+			if elapsed := time.Since(began); elapsed < tt.minTime {
+				t.Errorf("replay took %v; want at least %v", elapsed, tt.minTime)
+			}
+		})
+	}
+}
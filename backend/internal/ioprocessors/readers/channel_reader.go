@@ -2,44 +2,47 @@ package readers
 
 import (
 	"context"
+	"io"
 	"time"
+	"turion-takehome/internal/ioprocessors/messages"
 
 	"go.uber.org/zap"
 )
 
 type ChannelReader struct {
 	logger  *zap.Logger
-	channel <-chan []byte
+	channel <-chan *messages.Message
 }
 
-func NewChannelReader(logger *zap.Logger, byteChannel <-chan []byte) *ChannelReader {
+func NewChannelReader(logger *zap.Logger, channel <-chan *messages.Message) *ChannelReader {
 	return &ChannelReader{
 		logger:  logger,
-		channel: byteChannel,
+		channel: channel,
 	}
 }
 
 // Read implements the ContextReader interface used on processors.Processors.
 // Use this over other processors to keep state of your internal process.
-func (cr *ChannelReader) Read(ctx context.Context, b []byte) (n int, err error) {
+//
+// Messages are handed over as they were sent, without copying, and pool is
+// unused. The reference the ChannelWriter took now belongs to the caller.
+//
+// Read returns io.EOF once the channel is closed and every message sent
+// before that has been read, so the processor stops after draining it.
+func (cr *ChannelReader) Read(ctx context.Context, _ *messages.Pool) (*messages.Message, error) {
 	readChannelCtx, cancel := context.WithTimeout(ctx, time.Second*5)
 
 	defer cancel()
 	select {
 	case <-readChannelCtx.Done():
-		return 0, nil
-	case data, ok := <-cr.channel:
+		return nil, nil
+	case m, ok := <-cr.channel:
 		if !ok {
-			return 0, nil
+			return nil, io.EOF
 		}
 
-		if len(b) > len(data) {
-			b = b[:len(data)]
-		}
-
-		copy(b, data)
 		cr.logger.Debug("Reading message from channel")
 
-		return len(data), nil
+		return m, nil
 	}
 }
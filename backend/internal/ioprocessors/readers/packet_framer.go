@@ -0,0 +1,83 @@
+package readers
+
+import (
+	"bufio"
+	"io"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+// MAX_SPACE_PACKET_SIZE is the longest packet the 16-bit length field allows
+const MAX_SPACE_PACKET_SIZE = turiondatapacket.PRIMARY_HEADER_SIZE + 1<<16
+
+// packetFramer cuts CCSDS space packets out of a byte stream using the length
+// in each primary header.
+//
+// A stream has no packet boundaries of its own, so one corrupt header would
+// throw off every packet after it. Bytes that don't start a plausible header,
+// a version 1 telemetry packet that fits in the pool's buffers on a known
+// APID, are skipped one at a time until one does.
+type packetFramer struct {
+	br *bufio.Reader
+	// Reports whether packets on apid are expected, nil accepts any APID
+	known func(apid uint16) bool
+
+	// Bytes skipped since the last packet
+	skipped int
+}
+
+func newPacketFramer(r io.Reader, known func(apid uint16) bool) *packetFramer {
+	return &packetFramer{
+		br:    bufio.NewReaderSize(r, MAX_SPACE_PACKET_SIZE),
+		known: known,
+	}
+}
+
+// next returns the next packet, read into a message from pool, and how many
+// bytes were skipped to find it. Idle packets are dropped.
+//
+// Bytes read before an error, a read deadline passing included, are kept, so
+// next can be called again once the stream has more data.
+func (f *packetFramer) next(pool *messages.Pool) (*messages.Message, int, error) {
+	for {
+		hdr, err := f.br.Peek(turiondatapacket.PRIMARY_HEADER_SIZE)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		h, err := turiondatapacket.ParsePrimaryHeader(hdr)
+		if err != nil || !f.plausible(h, pool) {
+			f.br.Discard(1)
+			f.skipped++
+			continue
+		}
+
+		b, err := f.br.Peek(h.TotalLength())
+		if err != nil {
+			return nil, 0, err
+		}
+
+		if h.APID() == turiondatapacket.IDLE_APID {
+			f.br.Discard(len(b))
+			continue
+		}
+
+		skipped := f.skipped
+		f.skipped = 0
+
+		m := pool.Get()
+		m.SetLen(copy(m.Buffer(), b))
+		f.br.Discard(len(b))
+		return m, skipped, nil
+	}
+}
+
+func (f *packetFramer) plausible(h turiondatapacket.CCSDSPrimaryHeader, pool *messages.Pool) bool {
+	if h.Type() != turiondatapacket.PACKET_TYPE || h.TotalLength() > pool.BufferSize() {
+		return false
+	}
+	if h.APID() == turiondatapacket.IDLE_APID {
+		return true
+	}
+	return f.known == nil || f.known(h.APID())
+}
@@ -1,10 +1,17 @@
 package readers
 
-import "context"
+import (
+	"context"
+	"turion-takehome/internal/ioprocessors/messages"
+)
 
 // Reader defines the method needed for an io-reader, aka what comes in
 // Readers should not perform any transformation of data. What comes in is just
 // bytes and what is passed to the writer is just bytes
+//
+// Read returns the next message, read into a buffer from pool unless the
+// reader already has one, and the caller owns it. It returns nil and no error
+// when nothing arrived in time.
 type Reader interface {
-	Read(context.Context, []byte) (int, error)
+	Read(context.Context, *messages.Pool) (*messages.Message, error)
 }
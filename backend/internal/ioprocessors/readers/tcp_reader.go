@@ -0,0 +1,254 @@
+package readers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/metrics"
+
+	"go.uber.org/zap"
+)
+
+// Reconnect backoff of TCP readers created without WithTCPBackoff
+const (
+	DEFAULT_TCP_MIN_BACKOFF = time.Second
+	DEFAULT_TCP_MAX_BACKOFF = 30 * time.Second
+)
+
+// tcpReader reads CCSDS space packets from a TCP stream, as delivered by most
+// ground station modems and SLE gateways. It either dials the modem, or
+// listens and serves one connection at a time. When the connection drops it
+// reconnects, or accepts the next one, with exponential backoff.
+type tcpReader struct {
+	logger   *zap.Logger
+	dialAddr string
+	listener net.Listener
+	known    func(apid uint16) bool
+
+	minBackoff, maxBackoff time.Duration
+	backoff                time.Duration
+	nextAttempt            time.Time
+
+	conn   net.Conn
+	framer *packetFramer
+}
+
+type tcpoption struct {
+	dialAddr   string
+	listenAddr string
+	known      func(apid uint16) bool
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+type tcpOption func(*tcpoption)
+
+// WithTCPDial connects to addr, e.g. a modem's telemetry port
+func WithTCPDial(addr string) tcpOption {
+	return func(opt *tcpoption) {
+		opt.dialAddr = addr
+	}
+}
+
+// WithTCPListen accepts connections on addr, for modems that connect to the
+// gateway
+func WithTCPListen(addr string) tcpOption {
+	return func(opt *tcpoption) {
+		opt.listenAddr = addr
+	}
+}
+
+// WithTCPBackoff sets how long the reader waits before reconnecting, doubling
+// from minBackoff up to maxBackoff while connecting keeps failing
+func WithTCPBackoff(minBackoff, maxBackoff time.Duration) tcpOption {
+	return func(opt *tcpoption) {
+		opt.minBackoff = minBackoff
+		opt.maxBackoff = maxBackoff
+	}
+}
+
+// WithKnownAPIDs only accepts packets on APIDs known reports true for. It
+// makes finding the next packet after corrupt data much more reliable.
+func WithKnownAPIDs(known func(apid uint16) bool) tcpOption {
+	return func(opt *tcpoption) {
+		opt.known = known
+	}
+}
+
+func NewTCPReader(logger *zap.Logger, opts ...tcpOption) (*tcpReader, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	opt := &tcpoption{
+		minBackoff: DEFAULT_TCP_MIN_BACKOFF,
+		maxBackoff: DEFAULT_TCP_MAX_BACKOFF,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+
+	if (opt.dialAddr == "") == (opt.listenAddr == "") {
+		errs = errors.Join(errs, errors.New("tcp reader needs exactly one of a dial or listen address"))
+	}
+
+	if opt.minBackoff <= 0 || opt.maxBackoff < opt.minBackoff {
+		errs = errors.Join(errs, errors.New("tcp backoff must be positive and min must not exceed max"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	r := &tcpReader{
+		logger:     logger,
+		dialAddr:   opt.dialAddr,
+		known:      opt.known,
+		minBackoff: opt.minBackoff,
+		maxBackoff: opt.maxBackoff,
+		backoff:    opt.minBackoff,
+	}
+
+	if opt.listenAddr != "" {
+		listener, err := net.Listen("tcp", opt.listenAddr)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("Listening for TCP telemetry", zap.Stringer("Address", listener.Addr()))
+		r.listener = listener
+	}
+
+	return r, nil
+}
+
+// Read returns the next packet from the stream. Like the UDP reader it
+// returns no message and no error when nothing arrives within
+// UDP_READ_POLL_INTERVAL, while it waits to reconnect, or once ctx is done.
+// Connection errors are logged and retried rather than returned, so the
+// processor keeps running while the modem is away.
+func (r *tcpReader) Read(ctx context.Context, pool *messages.Pool) (*messages.Message, error) {
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+
+	deadline := time.Now().Add(UDP_READ_POLL_INTERVAL)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	if r.conn == nil && !r.connect(ctx, deadline) {
+		return nil, nil
+	}
+
+	if err := r.conn.SetReadDeadline(deadline); err != nil {
+		r.disconnect(err)
+		return nil, nil
+	}
+
+	m, skipped, err := r.framer.next(pool)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return nil, nil
+	}
+	if err != nil {
+		r.disconnect(err)
+		return nil, nil
+	}
+
+	if skipped > 0 {
+		metrics.TCPResyncBytes.Add(float64(skipped))
+		r.logger.Warn("Skipped corrupt bytes in TCP stream",
+			zap.Int("Bytes", skipped),
+			zap.Stringer("Source", r.conn.RemoteAddr()),
+		)
+	}
+	r.backoff = r.minBackoff
+
+	if addr, ok := r.conn.RemoteAddr().(*net.TCPAddr); ok {
+		m.SetOrigin(addr.AddrPort(), time.Now())
+	}
+	return m, nil
+}
+
+// connect dials or accepts a connection, unless it is too soon to try again.
+// It gives up at deadline so Read can return.
+func (r *tcpReader) connect(ctx context.Context, deadline time.Time) bool {
+	if wait := time.Until(r.nextAttempt); wait > 0 {
+		select {
+		case <-ctx.Done():
+		case <-time.After(min(wait, time.Until(deadline))):
+		}
+		return false
+	}
+
+	var conn net.Conn
+	var err error
+	if r.listener != nil {
+		conn, err = r.accept(deadline)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return false
+		}
+	} else {
+		dialCtx, cancel := context.WithDeadline(ctx, deadline)
+		conn, err = (&net.Dialer{}).DialContext(dialCtx, "tcp", r.dialAddr)
+		cancel()
+	}
+
+	if err != nil {
+		r.logger.Warn("Failed to connect to TCP telemetry source, backing off",
+			zap.String("Address", r.dialAddr),
+			zap.Duration("Backoff", r.backoff),
+			zap.Error(err),
+		)
+		r.retryLater()
+		return false
+	}
+
+	metrics.TCPConnections.Inc()
+	r.logger.Info("Connected to TCP telemetry source", zap.Stringer("Remote address", conn.RemoteAddr()))
+	r.conn = conn
+	r.framer = newPacketFramer(conn, r.known)
+	return true
+}
+
+func (r *tcpReader) accept(deadline time.Time) (net.Conn, error) {
+	if l, ok := r.listener.(*net.TCPListener); ok {
+		if err := l.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+	return r.listener.Accept()
+}
+
+// disconnect drops the connection after err, keeping nothing of a packet
+// that was half read
+func (r *tcpReader) disconnect(err error) {
+	r.logger.Warn("TCP telemetry connection lost",
+		zap.Stringer("Remote address", r.conn.RemoteAddr()),
+		zap.Error(err),
+	)
+	r.conn.Close()
+	r.conn = nil
+	r.framer = nil
+	r.retryLater()
+}
+
+func (r *tcpReader) retryLater() {
+	r.nextAttempt = time.Now().Add(r.backoff)
+	r.backoff = min(r.backoff*2, r.maxBackoff)
+}
+
+// Close closes the connection and the listener
+func (r *tcpReader) Close() error {
+	var errs error
+	if r.conn != nil {
+		errs = errors.Join(errs, r.conn.Close())
+	}
+	if r.listener != nil {
+		errs = errors.Join(errs, r.listener.Close())
+	}
+	return errs
+}
@@ -0,0 +1,113 @@
+package readers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// spacePacket encodes a telemetry packet on apid whose data is payload
+func spacePacket(t *testing.T, apid uint16, payload string) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	h := turiondatapacket.NewCCSDSPrimaryHeader(turiondatapacket.PrimaryHeaderFields{
+		Type:                turiondatapacket.PACKET_TYPE,
+		SecondaryHeaderFlag: true,
+		APID:                apid,
+	}, len(payload))
+	if err := binary.Write(buf, binary.BigEndian, h); err != nil {
+		t.Fatal(err)
+	}
+	buf.WriteString(payload)
+	return buf.Bytes()
+}
+
+func TestTCPReaderFramingAndReconnect(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The modem sends one packet per write, one split over several writes,
+	// garbage and an idle packet, then drops the connection and sends the
+	// last packet on a new one
+	third := spacePacket(t, 1, "third")
+	chunks := [][]byte{
+		spacePacket(t, 1, "first"),
+		{0xde, 0xad, 0xbe, 0xef, 0x00},
+		spacePacket(t, 2, "second"),
+		spacePacket(t, turiondatapacket.IDLE_APID, "idle"),
+		third[:4],
+		third[4:],
+	}
+	fourth := spacePacket(t, 2, "fourth")
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		for _, chunk := range chunks {
+			conn.Write(chunk)
+			time.Sleep(10 * time.Millisecond)
+		}
+		conn.Close()
+
+		conn, err = listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(fourth)
+		time.Sleep(time.Second)
+	}()
+
+	r, err := NewTCPReader(zap.NewNop(),
+		WithTCPDial(listener.Addr().String()),
+		WithTCPBackoff(10*time.Millisecond, 50*time.Millisecond),
+		WithKnownAPIDs(func(apid uint16) bool { return apid == 1 || apid == 2 }),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pool := messages.NewPool(64)
+
+	var got []string
+	for len(got) < 4 && ctx.Err() == nil {
+		m, err := r.Read(ctx, pool)
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		if m == nil {
+			continue
+		}
+		got = append(got, string(m.Bytes()[turiondatapacket.PRIMARY_HEADER_SIZE:]))
+		if !m.Source().IsValid() {
+			t.Error("message has no source address")
+		}
+		m.Release()
+	}
+
+	want := []string{"first", "second", "third", "fourth"}
+	if len(got) != len(want) {
+		t.Fatalf("read %q; want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("packet %d = %q; want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,52 @@
+//go:build linux
+
+package readers
+
+import (
+	"net"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Room for the SCM_TIMESTAMPNS control message
+var timestampOOBSize = unix.CmsgSpace(int(unsafe.Sizeof(unix.Timespec{})))
+
+// enableKernelTimestamps asks the kernel to attach the time each datagram was
+// received to it (SO_TIMESTAMPNS)
+func enableKernelTimestamps(conn *net.UDPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	err = raw.Control(func(fd uintptr) {
+		sockErr = unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_TIMESTAMPNS, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// parseKernelTimestamp finds the receive time in a datagram's control messages
+func parseKernelTimestamp(oob []byte) (time.Time, bool) {
+	msgs, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	for _, msg := range msgs {
+		if msg.Header.Level != unix.SOL_SOCKET || msg.Header.Type != unix.SCM_TIMESTAMPNS {
+			continue
+		}
+		if len(msg.Data) < int(unsafe.Sizeof(unix.Timespec{})) {
+			continue
+		}
+		ts := (*unix.Timespec)(unsafe.Pointer(&msg.Data[0]))
+		return time.Unix(ts.Unix()), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+
+package readers
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+const timestampOOBSize = 0
+
+// Kernel receive timestamps are only read on Linux
+func enableKernelTimestamps(*net.UDPConn) error {
+	return errors.ErrUnsupported
+}
+
+func parseKernelTimestamp([]byte) (time.Time, bool) {
+	return time.Time{}, false
+}
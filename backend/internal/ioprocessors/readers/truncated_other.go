@@ -0,0 +1,6 @@
+//go:build !unix
+
+package readers
+
+// Truncated datagrams are only detected on Unix
+const msgTrunc = 0
@@ -0,0 +1,8 @@
+//go:build unix
+
+package readers
+
+import "syscall"
+
+// msgTrunc is the read flag set when a datagram didn't fit in the buffer
+const msgTrunc = syscall.MSG_TRUNC
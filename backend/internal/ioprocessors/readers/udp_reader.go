@@ -3,19 +3,45 @@ package readers
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net"
+	"net/netip"
+	"os"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/metrics"
 
 	"go.uber.org/zap"
 )
 
+// UDP_READ_POLL_INTERVAL bounds how long Read blocks before it checks whether
+// its context is done
+const UDP_READ_POLL_INTERVAL = 500 * time.Millisecond
+
+// UDP_SOURCE_OTHER labels the datagrams of readers without allowed sources
+const UDP_SOURCE_OTHER = "other"
+
+// ErrDatagramTruncated is logged for datagrams bigger than the reader's
+// buffers. They are dropped rather than passed on cut short.
+var ErrDatagramTruncated = errors.New("datagram larger than the read buffer")
+
 type udpReader struct {
-	logger *zap.Logger
-	conn   *net.UDPConn
+	logger  *zap.Logger
+	conn    *net.UDPConn
+	allowed []netip.Prefix
+
+	kernelTimestamps bool
+	// Control messages of the last read. Read is only ever called from the
+	// processor's read loop, so one buffer is enough.
+	oob []byte
 }
 
 type udpoption struct {
-	addr *net.UDPAddr
-	conn *net.UDPConn
+	addr               *net.UDPAddr
+	conn               *net.UDPConn
+	receiveBufferBytes int
+	multicastInterface string
+	allowed            []netip.Prefix
 }
 
 type udpOption func(*udpoption)
@@ -26,12 +52,39 @@ func WithUDPConnection(conn *net.UDPConn) udpOption {
 	}
 }
 
+// WithUDPAddr listens on addr. If it is a multicast address the reader joins
+// that group, see WithMulticastInterface.
 func WithUDPAddr(addr *net.UDPAddr) udpOption {
 	return func(opt *udpoption) {
 		opt.addr = addr
 	}
 }
 
+// WithReceiveBuffer sizes the socket's receive buffer, which holds datagrams
+// that arrive while the processor is busy. The kernel may cap it, on Linux at
+// net.core.rmem_max.
+func WithReceiveBuffer(bytes int) udpOption {
+	return func(opt *udpoption) {
+		opt.receiveBufferBytes = bytes
+	}
+}
+
+// WithMulticastInterface joins the multicast group on the named network
+// interface instead of the one the system picks, which an empty name keeps
+func WithMulticastInterface(name string) udpOption {
+	return func(opt *udpoption) {
+		opt.multicastInterface = name
+	}
+}
+
+// WithAllowedSources drops datagrams from addresses outside of prefixes, e.g.
+// to only accept packets from known ground stations
+func WithAllowedSources(prefixes ...netip.Prefix) udpOption {
+	return func(opt *udpoption) {
+		opt.allowed = append(opt.allowed, prefixes...)
+	}
+}
+
 func NewUDPReader(logger *zap.Logger, opts ...udpOption) (*udpReader, error) {
 	var errs error
 	// Validate the inputs and accumulate errors.
@@ -48,37 +101,157 @@ func NewUDPReader(logger *zap.Logger, opts ...udpOption) (*udpReader, error) {
 		errs = errors.Join(errs, errors.New("bad udp config"))
 	}
 
+	if opt.receiveBufferBytes < 0 {
+		errs = errors.Join(errs, errors.New("receive buffer size cannot be negative"))
+	}
+
+	if opt.multicastInterface != "" && (opt.addr == nil || !opt.addr.IP.IsMulticast()) {
+		errs = errors.Join(errs, errors.New("multicast interface needs a multicast address"))
+	}
+
 	if errs != nil {
 		return nil, errs
 	}
 
-	var conn *net.UDPConn = opt.conn
-	var err error
+	conn, err := listenUDP(logger, opt)
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.receiveBufferBytes > 0 {
+		if err := conn.SetReadBuffer(opt.receiveBufferBytes); err != nil {
+			return nil, fmt.Errorf("setting UDP receive buffer: %w", err)
+		}
+	}
+
+	// Falls back to the time Read returns, which includes however long the
+	// datagram waited in the receive buffer
+	kernelTimestamps := true
+	if err := enableKernelTimestamps(conn); err != nil {
+		logger.Warn("Kernel receive timestamps unavailable, using read time", zap.Error(err))
+		kernelTimestamps = false
+	}
+
+	r := &udpReader{
+		logger:           logger,
+		conn:             conn,
+		allowed:          opt.allowed,
+		kernelTimestamps: kernelTimestamps,
+	}
+	if kernelTimestamps {
+		r.oob = make([]byte, timestampOOBSize)
+	}
+	return r, nil
+}
 
-	if opt.addr != nil {
+func listenUDP(logger *zap.Logger, opt *udpoption) (*net.UDPConn, error) {
+	if opt.addr == nil {
+		return opt.conn, nil
+	}
+
+	if !opt.addr.IP.IsMulticast() {
 		logger.Info("Address provided, creating new UDP Connection", zap.Any("Address", opt.addr))
-		conn, err = net.ListenUDP("udp", opt.addr)
+		return net.ListenUDP("udp", opt.addr)
+	}
+
+	var ifi *net.Interface
+	if opt.multicastInterface != "" {
+		var err error
+		ifi, err = net.InterfaceByName(opt.multicastInterface)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("finding multicast interface: %w", err)
 		}
 	}
 
-	return &udpReader{
-		logger: logger,
-		conn:   conn,
-	}, nil
+	logger.Info("Multicast address provided, joining group",
+		zap.Any("Address", opt.addr),
+		zap.String("Interface", opt.multicastInterface),
+	)
+	return net.ListenMulticastUDP("udp", ifi, opt.addr)
 }
 
-// Read implements the Read method that adheres to io.Reader.
-func (r *udpReader) Read(ctx context.Context, b []byte) (int, error) {
-	n, _, err := r.conn.ReadFromUDP(b)
-	r.logger.Debug("Received message from UDP connection")
+// Read reads one datagram into a message from pool, along with its source
+// address and when the kernel received it. It returns no message and no error
+// if nothing arrives within UDP_READ_POLL_INTERVAL or ctx is done, so the
+// processor gets a chance to notice ctx is done, for datagrams from sources
+// that aren't allowed, and for datagrams too big for the pool's buffers.
+func (r *udpReader) Read(ctx context.Context, pool *messages.Pool) (*messages.Message, error) {
+	if ctx.Err() != nil {
+		return nil, nil
+	}
+
+	deadline := time.Now().Add(UDP_READ_POLL_INTERVAL)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := r.conn.SetReadDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	m := pool.Get()
+	n, oobn, flags, addr, err := r.conn.ReadMsgUDPAddrPort(m.Buffer(), r.oob)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		m.Release()
+		return nil, nil
+	}
+	r.logger.Debug("Received message from UDP connection", zap.Stringer("Source", addr))
 	if err != nil {
+		m.Release()
+		metrics.UDPReadErrors.Inc()
 		r.logger.Error("error reading connection", zap.Error(err))
-		return n, err
+		return nil, err
 	}
 
-	// TODO: record metrics here if you have time
+	source := netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+	allowedBy, ok := r.allowedBy(source.Addr())
+	if !ok {
+		m.Release()
+		metrics.UDPRejected.Inc()
+		r.logger.Debug("Dropped datagram from source that isn't allowed", zap.Stringer("Source", source))
+		return nil, nil
+	}
+	metrics.UDPDatagrams.WithLabelValues(allowedBy).Inc()
+
+	if flags&msgTrunc != 0 {
+		m.Release()
+		metrics.UDPTruncated.Inc()
+		r.logger.Warn("Dropped datagram",
+			zap.Stringer("Source", source),
+			zap.Int("Buffer bytes", n),
+			zap.Error(ErrDatagramTruncated),
+		)
+		return nil, nil
+	}
+
+	receivedAt, ok := time.Time{}, false
+	if r.kernelTimestamps {
+		receivedAt, ok = parseKernelTimestamp(r.oob[:oobn])
+	}
+	if !ok {
+		receivedAt = time.Now()
+	}
+
+	m.SetLen(n)
+	m.SetOrigin(source, receivedAt)
+	return m, nil
+}
+
+// allowedBy returns the allowed prefix addr is in, which labels its metrics
+// so they stay bounded however many hosts send datagrams. Without allowed
+// sources every address is allowed as UDP_SOURCE_OTHER.
+func (r *udpReader) allowedBy(addr netip.Addr) (string, bool) {
+	if len(r.allowed) == 0 {
+		return UDP_SOURCE_OTHER, true
+	}
+	for _, prefix := range r.allowed {
+		if prefix.Contains(addr) {
+			return prefix.String(), true
+		}
+	}
+	return "", false
+}
 
-	return n, nil
+// Close closes the UDP connection
+func (r *udpReader) Close() error {
+	return r.conn.Close()
 }
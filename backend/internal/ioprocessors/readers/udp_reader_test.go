@@ -0,0 +1,125 @@
+package readers
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+
+	"go.uber.org/zap"
+)
+
+func TestUDPReaderOrigin(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  []netip.Prefix
+		wantRead bool
+	}{
+		{name: "any source", wantRead: true},
+		{name: "allowed source", allowed: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}, wantRead: true},
+		{name: "other source", allowed: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewUDPReader(zap.NewNop(),
+				WithUDPAddr(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}),
+				WithReceiveBuffer(1<<16),
+				WithAllowedSources(tt.allowed...),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			sender, err := net.DialUDP("udp", nil, r.conn.LocalAddr().(*net.UDPAddr))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer sender.Close()
+
+			sent := time.Now()
+			if _, err := sender.Write([]byte("packet")); err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			m, err := r.Read(ctx, messages.NewPool(64))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+
+			if !tt.wantRead {
+				if m != nil {
+					t.Errorf("Read() = %q; want datagram dropped", m.Bytes())
+				}
+				return
+			}
+
+			if m == nil {
+				t.Fatal("Read() returned no message")
+			}
+			defer m.Release()
+
+			if got := string(m.Bytes()); got != "packet" {
+				t.Errorf("Bytes() = %q; want %q", got, "packet")
+			}
+			if want := sender.LocalAddr().(*net.UDPAddr).AddrPort(); m.Source() != want {
+				t.Errorf("Source() = %v; want %v", m.Source(), want)
+			}
+			if d := m.ReceivedAt().Sub(sent); d < -time.Second || d > time.Second {
+				t.Errorf("ReceivedAt() is %v after sending; want about now", d)
+			}
+		})
+	}
+}
+
+func TestUDPReaderRespectsContext(t *testing.T) {
+	r, err := NewUDPReader(zap.NewNop(), WithUDPAddr(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// Nothing is sent, Read gives up at the context's deadline rather than
+	// blocking for UDP_READ_POLL_INTERVAL
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	m, err := r.Read(ctx, messages.NewPool(64))
+	if m != nil || err != nil {
+		t.Fatalf("Read() = %v, %v; want nothing", m, err)
+	}
+	if d := time.Since(start); d >= UDP_READ_POLL_INTERVAL {
+		t.Errorf("Read() took %v; want it to stop at the context deadline", d)
+	}
+}
+
+func TestUDPReaderDropsTruncatedDatagrams(t *testing.T) {
+	r, err := NewUDPReader(zap.NewNop(), WithUDPAddr(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	sender, err := net.DialUDP("udp", nil, r.conn.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+
+	if _, err := sender.Write([]byte("oversized packet")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m, err := r.Read(ctx, messages.NewPool(8))
+	if m != nil || err != nil {
+		t.Fatalf("Read() = %v, %v; want the truncated datagram dropped", m, err)
+	}
+}
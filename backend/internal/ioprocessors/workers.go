@@ -0,0 +1,164 @@
+package ioprocessors
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/metrics"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// DEFAULT_QUEUE_SIZE is how many messages a processor with workers reads ahead
+// of them, see WithQueueSize
+const DEFAULT_QUEUE_SIZE = 1024
+
+// KeyFunc returns the ordering key of a message, see WithOrderingKey
+type KeyFunc func([]byte) uint64
+
+// APIDKey keys space packets by APID, so each APID's packets are written in
+// the order they were received. Messages without a valid primary header all
+// share one key.
+func APIDKey(b []byte) uint64 {
+	h, err := turiondatapacket.DecodePrimaryHeader(b)
+	if err != nil {
+		return 0
+	}
+	return uint64(h.APID())
+}
+
+// FrameKey keys transfer frames by their first two bytes, which hold the
+// version and the spacecraft and virtual channel IDs of both TM and AOS
+// frames, so each virtual channel is demultiplexed in order
+func FrameKey(b []byte) uint64 {
+	if len(b) < 2 {
+		return 0
+	}
+	return uint64(binary.BigEndian.Uint16(b))
+}
+
+// startWorkers reads on the calling goroutine and writes on the workers. Once
+// reading stops, whatever is still queued is written before it returns, with
+// the write context (see WithWriteContext), so those messages are only
+// quarantined if that is done too.
+func (p *Processor) startWorkers(ctx context.Context) error {
+	writeCtx := p.writeContext(ctx)
+
+	// A worker that stops stops the reader too
+	readCtx, stopReading := context.WithCancel(ctx)
+	defer stopReading()
+	var eg errgroup.Group
+
+	// Without an ordering key every worker takes from one queue, so a slow
+	// write only holds up its own worker
+	queues := []chan *messages.Message{make(chan *messages.Message, p.option.queueSize)}
+	if p.option.orderingKey != nil {
+		queues = make([]chan *messages.Message, p.option.workers)
+		for i := range queues {
+			queues[i] = make(chan *messages.Message, max(p.option.queueSize/p.option.workers, 1))
+		}
+	}
+
+	for i := range p.option.workers {
+		q := queues[i%len(queues)]
+		eg.Go(func() error {
+			err := p.work(writeCtx, q)
+			if err != nil {
+				stopReading()
+			}
+			return err
+		})
+	}
+
+	err := p.readInto(readCtx, writeCtx, queues)
+	for _, q := range queues {
+		close(q)
+	}
+	return errors.Join(err, eg.Wait())
+}
+
+// readInto reads messages and queues them for the workers until the reader
+// runs out of messages or ctx is done. A message read while the queue is full
+// waits for room until writeCtx is done.
+func (p *Processor) readInto(ctx, writeCtx context.Context, queues []chan *messages.Message) error {
+	depth := metrics.QueueDepth.WithLabelValues(p.option.name)
+	queueFull := metrics.QueueFull.WithLabelValues(p.option.name)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return p.stopped(ctx)
+		default:
+		}
+
+		m, err := p.read(ctx)
+		if errors.Is(err, io.EOF) {
+			p.logger.Info("Reader has no more messages, processor stopped", zap.String("Processor", p.option.name))
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if m == nil {
+			continue
+		}
+
+		q := queues[0]
+		if p.option.orderingKey != nil {
+			q = queues[p.option.orderingKey(m.Bytes())%uint64(len(queues))]
+		}
+
+		// The worker that takes m releases it
+		depth.Inc()
+		select {
+		case q <- m:
+			continue
+		default:
+		}
+
+		queueFull.Inc()
+		select {
+		case q <- m:
+		case <-writeCtx.Done():
+			depth.Dec()
+			if err := p.handleWriteErrorToQuarantine(writeCtx, m, writeCtx.Err()); err != nil {
+				p.logger.Error("Failed to quarantine queued message", zap.Error(err))
+			}
+			m.Release()
+			return p.stopped(ctx)
+		}
+	}
+}
+
+// work writes queued messages until the queue is closed and empty. After an
+// error that stops the processor it keeps taking messages off the queue, so
+// the reader isn't left waiting, but releases them unwritten.
+func (p *Processor) work(ctx context.Context, q <-chan *messages.Message) error {
+	depth := metrics.QueueDepth.WithLabelValues(p.option.name)
+	var err error
+	dropped := 0
+	for m := range q {
+		depth.Dec()
+		if err != nil {
+			dropped++
+			m.Release()
+			continue
+		}
+
+		err = p.write(ctx, m)
+		m.Release()
+	}
+
+	if dropped > 0 {
+		p.logger.Error("Processor stopped, dropped queued messages",
+			zap.String("Processor", p.option.name),
+			zap.Int("Dropped", dropped),
+			zap.Error(err))
+	}
+	return err
+}
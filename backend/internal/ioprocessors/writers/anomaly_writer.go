@@ -4,6 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/notifier"
+	"turion-takehome/internal/pubsub"
+	"turion-takehome/internal/store"
 	"turion-takehome/internal/turiondatapacket"
 
 	"go.uber.org/zap"
@@ -11,24 +15,54 @@ import (
 )
 
 // AnomalyWriter shall inspect telemetry messages and check for any values that
-// meet or exceed "safety thresholds". These thresholds are defined in the TDP
-// package /internal/turiondatapacket/anomaly.go
+// meet or exceed "safety thresholds". These thresholds are the yellow and red
+// limits of the limit set loaded by the gateway, see
+// /internal/turiondatapacket/limits.go
 //
-// If a threshold is exceeded, an alert is created to notify users of the
-// occurance. Due to time constraints, this will come in the form of an HTTP
-// POST request. The route will simply contain a loud logger for the alert.
-// Were this a production application, this alert would go to something like
-// PagerDuty or Grafana instead.
+// Anomalies arrive as events from TelemetryMessageWriter, and every update to
+// an event updates the same row in public.anomaly_events.
+//
+// Once an event is stored it is handed to the notifier, which POSTs it to the
+// configured webhooks (the telemetry API's /api/v1/anomaly/new by default),
+// and, with live updates, the stored event is published to the telemetry
+// API's subscribers. A failed notification is logged and does not fail the
+// write, the database is the record of truth. Were this a production
+// application, one of the webhooks would be something like PagerDuty or
+// Grafana.
 type AnomalyWriter struct {
-	logger *zap.Logger
-	db     *sql.DB // TODO: Create an interface with mocks to handle this
+	logger      *zap.Logger
+	db          *sql.DB
+	store       store.DataPacketStore
+	notifier    notifier.Notifier
+	liveUpdates bool
+}
+
+type anomalyWriterOptions struct {
+	notifier    notifier.Notifier
+	liveUpdates bool
+}
+
+type AnomalyWriterOption func(*anomalyWriterOptions)
 
+// WithNotifier sends every stored anomaly event to n
+func WithNotifier(n notifier.Notifier) AnomalyWriterOption {
+	return func(o *anomalyWriterOptions) {
+		o.notifier = n
+	}
+}
+
+// WithAnomalyLiveUpdates publishes every stored anomaly event to the telemetry
+// API's subscribers over Postgres NOTIFY, see pubsub.PGListener
+func WithAnomalyLiveUpdates() AnomalyWriterOption {
+	return func(o *anomalyWriterOptions) {
+		o.liveUpdates = true
+	}
 }
 
 func NewAnomalyWriter(
 	logger *zap.Logger,
 	db *sql.DB,
-
+	opts ...AnomalyWriterOption,
 ) (*AnomalyWriter, error) {
 	var errs error
 	if logger == nil {
@@ -42,48 +76,76 @@ func NewAnomalyWriter(
 		return nil, errs
 	}
 
+	options := &anomalyWriterOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
 	return &AnomalyWriter{
-		logger: logger,
-		db:     db,
+		logger:      logger,
+		db:          db,
+		store:       store.NewSQLDataPacketStore(db, logger),
+		notifier:    options.notifier,
+		liveUpdates: options.liveUpdates,
 	}, nil
 }
 
-// I wanted to use protobufs but I am now stuck because I'm trying to POST protobufs
-// instead of JSON. So let's just convert it to JSON for now. Hate this, it's
-// due to my lack of foresight and time constraints
-//
-// I should have done gRPC but I don't know how to do it with ECHO
-// Or I should've just set up opentelemetry from the getgo
-func (w *AnomalyWriter) Write(ctx context.Context, b []byte) (int, error) {
+// Write expects a marshalled turiondatapacket.Anomaly event. Notifications are
+// sent as JSON with protojson, the receivers don't have to speak protobuf.
+func (w *AnomalyWriter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	b := m.Bytes()
 
 	var a turiondatapacket.Anomaly
 	if err := proto.Unmarshal(b, &a); err != nil {
 		return 0, err
 	}
 
-	// 2) Insert into SQL
-	const stmt = `
-    INSERT INTO public.anomalies (field, value, timestamp)
-    VALUES ($1, $2, $3)`
-	_, err := w.db.ExecContext(ctx, stmt,
-		a.Field.String(),
-		a.Value,
-		a.Timestamp,
-	)
+	stored, err := w.store.UpsertAnomaly(ctx, &a)
 	if err != nil {
-		w.logger.Error("failed to insert anomaly", zap.Error(err))
+		w.logger.Error("failed to upsert anomaly event", zap.Error(err))
 		return 0, err
 	}
 
-	w.logger.Debug("wrote anomaly to database",
-		zap.String("field", a.Field.String()),
+	w.logger.Debug("wrote anomaly event to database",
+		zap.String("event id", a.EventId),
+		zap.String("state", a.State.String()),
+		zap.String("parameter", a.Parameter),
+		zap.String("severity", a.Severity.String()),
 		zap.Float32("value", a.Value),
 		zap.Uint64("ts", a.Timestamp),
 	)
 
+	// Subscribers see the event as stored, e.g. still acknowledged. An update
+	// older than the stored event changed nothing.
+	if w.liveUpdates && stored != nil {
+		if err := w.publish(ctx, stored); err != nil {
+			w.logger.Error("failed to publish anomaly event",
+				zap.String("event id", a.EventId),
+				zap.Error(err),
+			)
+		}
+	}
+
+	if w.notifier != nil {
+		if err := w.notifier.Notify(ctx, &a); err != nil {
+			w.logger.Error("failed to notify anomaly event",
+				zap.String("event id", a.EventId),
+				zap.Error(err),
+			)
+		}
+	}
+
 	return len(b), nil
 }
 
+func (w *AnomalyWriter) publish(ctx context.Context, a *turiondatapacket.Anomaly) error {
+	e, err := pubsub.NewAnomalyEvent(a)
+	if err != nil {
+		return err
+	}
+	return pubsub.Notify(ctx, w.db, e)
+}
+
 // TODO
 func (w *AnomalyWriter) Close() error {
 	return nil
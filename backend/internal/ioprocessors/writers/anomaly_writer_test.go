@@ -0,0 +1,90 @@
+package writers
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/pubsub"
+	"turion-takehome/internal/store"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// anomalyStore stores events as stored, or ignores them when stored is nil
+type anomalyStore struct {
+	store.DataPacketStore
+	stored *turiondatapacket.Anomaly
+}
+
+func (s anomalyStore) UpsertAnomaly(context.Context, *turiondatapacket.Anomaly) (*turiondatapacket.Anomaly, error) {
+	return s.stored, nil
+}
+
+func TestAnomalyWriterPublishesStoredEvent(t *testing.T) {
+	update := &turiondatapacket.Anomaly{
+		EventId:     "event-1",
+		Apid:        1,
+		Parameter:   "temperature",
+		State:       turiondatapacket.AnomalyState_OPEN,
+		SampleCount: 3,
+	}
+	acknowledged := proto.Clone(update).(*turiondatapacket.Anomaly)
+	acknowledged.State = turiondatapacket.AnomalyState_ACKNOWLEDGED
+
+	tests := []struct {
+		name      string
+		stored    *turiondatapacket.Anomaly
+		wantState turiondatapacket.AnomalyState
+	}{
+		{name: "stored state is published", stored: acknowledged, wantState: turiondatapacket.AnomalyState_ACKNOWLEDGED},
+		{name: "ignored update isn't published", stored: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeDB{}
+			db := fake.open()
+			defer db.Close()
+
+			w, err := NewAnomalyWriter(zap.NewNop(), db, WithAnomalyLiveUpdates())
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.store = anomalyStore{stored: tt.stored}
+
+			b, err := proto.Marshal(update)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := w.Write(context.Background(), messages.New(b)); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+
+			_, execs := fake.rows()
+			if tt.stored == nil {
+				if execs != 0 {
+					t.Errorf("published %d events; want none", execs)
+				}
+				return
+			}
+
+			if execs != 1 || len(fake.args) != 2 {
+				t.Fatalf("published %d events with %v; want one NOTIFY", execs, fake.args)
+			}
+			var e pubsub.Event
+			if err := json.Unmarshal([]byte(fake.args[1].Value.(string)), &e); err != nil {
+				t.Fatal(err)
+			}
+			var a turiondatapacket.Anomaly
+			if err := json.Unmarshal(e.Data, &a); err != nil {
+				t.Fatal(err)
+			}
+			if e.Topic != pubsub.TOPIC_ANOMALIES || e.Parameter != "temperature" || a.State != tt.wantState {
+				t.Errorf("published %s; want the %s event on %s", e.Data, tt.wantState, pubsub.TOPIC_ANOMALIES)
+			}
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package writers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"turion-takehome/internal/ioprocessors/capture"
+	"turion-takehome/internal/ioprocessors/messages"
+
+	"go.uber.org/zap"
+)
+
+// DEFAULT_MAX_CAPTURE_BYTES is the size at which a CaptureWriter starts a new
+// segment
+const DEFAULT_MAX_CAPTURE_BYTES = 64 << 20
+
+// CaptureWriter records every message, with the time it was received and its
+// source, to a capture directory that readers.NewCaptureReader can replay. It
+// starts a new segment after the ones already in the directory, and another
+// whenever the current one reaches its maximum size. It is safe for concurrent
+// use, though messages written concurrently may be recorded in either order.
+//
+// Records aren't synced to disk, so a crash can lose the last few. The torn
+// record it may leave behind is skipped on replay.
+type CaptureWriter struct {
+	logger          *zap.Logger
+	dir             string
+	maxSegmentBytes int64
+
+	mu      sync.Mutex
+	segment int
+	size    int64
+	f       *os.File
+	buf     []byte
+}
+
+type captureWriterOptions struct {
+	maxSegmentBytes int64
+}
+
+type CaptureWriterOption func(*captureWriterOptions)
+
+// WithMaxCaptureBytes starts a new segment once the current one reaches n
+// bytes
+func WithMaxCaptureBytes(n int64) CaptureWriterOption {
+	return func(o *captureWriterOptions) {
+		o.maxSegmentBytes = n
+	}
+}
+
+func NewCaptureWriter(logger *zap.Logger, dir string, opts ...CaptureWriterOption) (*CaptureWriter, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if dir == "" {
+		errs = errors.Join(errs, errors.New("capture dir cannot be empty"))
+	}
+
+	options := &captureWriterOptions{maxSegmentBytes: DEFAULT_MAX_CAPTURE_BYTES}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.maxSegmentBytes <= 0 {
+		errs = errors.Join(errs, errors.New("max capture bytes must be positive"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating capture dir: %w", err)
+	}
+
+	segments, err := capture.Segments(dir)
+	if err != nil {
+		return nil, err
+	}
+	last := 0
+	if len(segments) > 0 {
+		last = segments[len(segments)-1]
+	}
+
+	w := &CaptureWriter{
+		logger:          logger,
+		dir:             dir,
+		maxSegmentBytes: options.maxSegmentBytes,
+	}
+	if err := w.openSegment(last + 1); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *CaptureWriter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	receivedAt := m.ReceivedAt()
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return 0, fmt.Errorf("%w: capture %s", ErrWriterClosed, w.dir)
+	}
+
+	if w.size >= w.maxSegmentBytes {
+		if err := w.openSegment(w.segment + 1); err != nil {
+			return 0, err
+		}
+	}
+
+	w.buf = capture.AppendRecord(w.buf[:0], capture.Record{
+		ReceivedAt: receivedAt,
+		Source:     m.Source(),
+		Payload:    m.Bytes(),
+	})
+	n, err := w.f.Write(w.buf)
+	w.size += int64(n)
+	if err != nil {
+		return 0, fmt.Errorf("writing capture segment: %w", err)
+	}
+	return m.Len(), nil
+}
+
+// openSegment creates segment and makes it the one written to. The caller
+// must hold w.mu or be the constructor.
+func (w *CaptureWriter) openSegment(segment int) error {
+	path := capture.SegmentPath(w.dir, segment)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("creating capture segment: %w", err)
+	}
+
+	header := capture.AppendHeader(nil)
+	if _, err := f.Write(header); err != nil {
+		f.Close()
+		return fmt.Errorf("writing capture segment header: %w", err)
+	}
+
+	if w.f != nil {
+		if err := w.f.Close(); err != nil {
+			w.logger.Error("Failed to close capture segment", zap.Int("Segment", w.segment), zap.Error(err))
+		}
+	}
+	w.logger.Info("Started capture segment", zap.String("Path", path))
+	w.f = f
+	w.segment = segment
+	w.size = int64(len(header))
+	return nil
+}
+
+// Close closes the current segment. Writes after Close fail with
+// ErrWriterClosed.
+func (w *CaptureWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
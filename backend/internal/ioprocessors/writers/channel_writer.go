@@ -2,45 +2,69 @@ package writers
 
 import (
 	"context"
+	"errors"
 	"sync"
-	"sync/atomic"
+	"turion-takehome/internal/ioprocessors/messages"
 
 	"go.uber.org/zap"
 )
 
+// ErrWriterClosed is returned when writing to a writer that has been closed
+var ErrWriterClosed = errors.New("writer is closed")
+
+// ChannelWriter sends messages to a channel read by another processor's
+// ChannelReader. Close closes the channel, which tells that processor to stop
+// once it has read everything already sent.
+//
+// Messages aren't copied. Each one sent is retained, and the reference passes
+// to whoever reads it from the channel.
 type ChannelWriter struct {
 	logger *zap.Logger
 
-	close   atomic.Bool
-	mu      *sync.Mutex
-	channel chan<- []byte
+	// Writes hold the read lock while sending so Close can't close the channel
+	// under them
+	mu      sync.RWMutex
+	closed  bool
+	channel chan<- *messages.Message
 }
 
-func NewChannelWriter(logger *zap.Logger, channel chan<- []byte) *ChannelWriter {
+func NewChannelWriter(logger *zap.Logger, channel chan<- *messages.Message) *ChannelWriter {
 	return &ChannelWriter{
 		logger:  logger,
 		channel: channel,
-		mu:      &sync.Mutex{},
 	}
 }
 
-// Writer the bytes to a channel.
-func (w *ChannelWriter) Write(ctx context.Context, b []byte) (int, error) {
-	w.channel <- b
+// Writer the message to a channel.
+func (w *ChannelWriter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
 
-	return len(b), nil
+	m.Retain()
+	select {
+	case w.channel <- m:
+		return m.Len(), nil
+	case <-ctx.Done():
+		m.Release()
+		return 0, ctx.Err()
+	}
 }
 
+// Close closes the channel. It is safe to call more than once, and the
+// channel must not be closed by anyone else.
 func (w *ChannelWriter) Close() error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
 
-	if w.close.Load() {
+	if w.closed {
 		return nil
 	}
 
-	w.close.Store(true)
-
+	w.closed = true
 	close(w.channel)
 	return nil
 }
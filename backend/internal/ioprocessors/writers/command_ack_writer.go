@@ -0,0 +1,106 @@
+package writers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// CommandAckWriter records the spacecraft's command acknowledgements in the
+// command history, marking the command acknowledged or failed.
+// Acknowledgements only carry the APID and sequence count of their command,
+// which are matched against the latest command sent with them that is still
+// waiting for one.
+type CommandAckWriter struct {
+	logger *zap.Logger
+	db     *sql.DB
+}
+
+func NewCommandAckWriter(
+	logger *zap.Logger,
+	db *sql.DB,
+) (*CommandAckWriter, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if db == nil {
+		errs = errors.Join(errs, errors.New("db cannot be nil"))
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &CommandAckWriter{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// Write expects a whole turiondatapacket.CommandAck packet
+func (w *CommandAckWriter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	ack, err := turiondatapacket.DecodeCommandAck(m.Bytes())
+	if err != nil {
+		return 0, err
+	}
+	p := ack.CommandAckPayload
+
+	state := turiondatapacket.COMMAND_ACKNOWLEDGED
+	reason := ""
+	if p.Status != turiondatapacket.ACK_STATUS_OK {
+		state = turiondatapacket.COMMAND_FAILED
+		reason = turiondatapacket.AckStatusText(p.Status)
+	}
+
+	// The command may still be queued if the acknowledgement beat the API's
+	// own update
+	const stmt = `
+    UPDATE public.commands
+       SET state           = $3,
+           error           = NULLIF($4, ''),
+           acknowledged_at = NOW(),
+           ack_status      = $5
+     WHERE id = (
+       SELECT id
+         FROM public.commands
+        WHERE apid = $1
+          AND seq_count = $2
+          AND state IN ('queued', 'sent')
+        ORDER BY id DESC
+        LIMIT 1
+     )`
+	res, err := w.db.ExecContext(ctx, stmt,
+		p.CommandAPID, p.CommandSeqCount, state, reason, p.Status,
+	)
+	if err != nil {
+		w.logger.Error("failed to record command acknowledgement", zap.Error(err))
+		return 0, err
+	}
+
+	// Acknowledgements for commands sent by someone else, or repeated ones,
+	// have nothing to update
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		w.logger.Warn("Received acknowledgement for no pending command",
+			zap.Uint16("Command APID", p.CommandAPID),
+			zap.Uint16("Command seq count", p.CommandSeqCount),
+			zap.Uint8("Status", p.Status),
+		)
+		return m.Len(), nil
+	}
+
+	w.logger.Info("Command acknowledged",
+		zap.Uint16("Command APID", p.CommandAPID),
+		zap.Uint16("Command seq count", p.CommandSeqCount),
+		zap.String("State", state),
+	)
+	return m.Len(), nil
+}
+
+func (w *CommandAckWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package writers
+
+import (
+	"context"
+	"errors"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// Filter only forwards the messages keep returns true for. Dropped messages are
+// reported as written so they aren't quarantined.
+type Filter struct {
+	logger *zap.Logger
+	writer Writer
+	keep   func([]byte) bool
+}
+
+func NewFilter(logger *zap.Logger, writer Writer, keep func([]byte) bool) (*Filter, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if writer == nil {
+		errs = errors.Join(errs, errors.New("writer cannot be nil"))
+	}
+
+	if keep == nil {
+		errs = errors.Join(errs, errors.New("filter predicate cannot be nil"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &Filter{
+		logger: logger,
+		writer: writer,
+		keep:   keep,
+	}, nil
+}
+
+func (f *Filter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	if !f.keep(m.Bytes()) {
+		f.logger.Debug("Filtered out message", zap.Int("Bytes", m.Len()))
+		return m.Len(), nil
+	}
+	return f.writer.Write(ctx, m)
+}
+
+func (f *Filter) Close() error {
+	return f.writer.Close()
+}
+
+// KeepAPIDs returns a Filter predicate that keeps the space packets sent on
+// one of apids, or when exclude is set, every packet sent on any other APID.
+// Messages too short to hold a primary header are dropped.
+func KeepAPIDs(exclude bool, apids ...uint16) func([]byte) bool {
+	set := make(map[uint16]struct{}, len(apids))
+	for _, apid := range apids {
+		set[apid] = struct{}{}
+	}
+
+	return func(b []byte) bool {
+		h, err := turiondatapacket.DecodePrimaryHeader(b)
+		if err != nil {
+			return false
+		}
+		_, ok := set[h.APID()]
+		return ok != exclude
+	}
+}
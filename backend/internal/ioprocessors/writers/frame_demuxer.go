@@ -0,0 +1,211 @@
+package writers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/ioprocessors/quarantiners"
+	"turion-takehome/internal/metrics"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// FrameDemuxer takes one TM or AOS transfer frame per message, extracts the
+// space packets of each virtual channel, including packets that span frames,
+// and writes them to writer one at a time, usually a TelemetryMessageWriter.
+//
+// Frames with a bad header or FECF fail Write, so the processor quarantines
+// them. A packet writer fails is quarantined on its own instead, because
+// replaying the whole frame would write the frame's other packets again.
+//
+// A gap in a virtual channel's frame count drops the packet in progress and
+// resumes at the next packet that starts in a frame. Frames of one virtual
+// channel must be written in order, e.g. with ioprocessors.FrameKey.
+type FrameDemuxer struct {
+	logger      *zap.Logger
+	format      turiondatapacket.FrameFormat
+	writer      Writer
+	quarantiner quarantiners.Quarantiner
+
+	mu       sync.Mutex
+	channels map[virtualChannelID]*virtualChannel
+}
+
+type virtualChannelID struct {
+	spacecraft uint16
+	channel    uint8
+}
+
+func (id virtualChannelID) String() string {
+	return fmt.Sprintf("%d/%d", id.spacecraft, id.channel)
+}
+
+// virtualChannel is the reassembly state of one virtual channel
+type virtualChannel struct {
+	seen      bool
+	lastCount uint32
+	// synced is set once a packet start has been found, and cleared when a
+	// frame is lost mid-packet
+	synced bool
+	// pending holds the bytes of a packet that continues in the next frame
+	pending []byte
+}
+
+func NewFrameDemuxer(
+	logger *zap.Logger,
+	format turiondatapacket.FrameFormat,
+	writer Writer,
+	quarantiner quarantiners.Quarantiner,
+) (*FrameDemuxer, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if err := format.Validate(); err != nil {
+		errs = errors.Join(errs, err)
+	}
+
+	if writer == nil {
+		errs = errors.Join(errs, errors.New("writer cannot be nil"))
+	}
+
+	if quarantiner == nil {
+		errs = errors.Join(errs, errors.New("quarantiner cannot be nil"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &FrameDemuxer{
+		logger:      logger,
+		format:      format,
+		writer:      writer,
+		quarantiner: quarantiner,
+		channels:    map[virtualChannelID]*virtualChannel{},
+	}, nil
+}
+
+func (d *FrameDemuxer) Write(ctx context.Context, m *messages.Message) (int, error) {
+	frame, err := d.format.Decode(m.Bytes())
+	if err != nil {
+		metrics.FramesRejected.Inc()
+		return 0, err
+	}
+
+	for _, packet := range d.demux(frame) {
+		p := messages.New(packet)
+		p.SetOrigin(m.Source(), m.ReceivedAt())
+		if _, err := d.writer.Write(ctx, p); err != nil {
+			if _, qErr := d.quarantiner.Quarantine(ctx, p, err); qErr != nil {
+				d.logger.Error("Failed to quarantine packet from frame",
+					zap.Binary("Bytes", packet),
+					zap.Error(errors.Join(err, qErr)),
+				)
+			}
+		}
+		p.Release()
+	}
+
+	return m.Len(), nil
+}
+
+// demux returns copies of the packets that end in frame, dropping idle packets
+func (d *FrameDemuxer) demux(frame turiondatapacket.Frame) [][]byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := virtualChannelID{frame.SpacecraftID, frame.VirtualChannelID}
+	vc, ok := d.channels[id]
+	if !ok {
+		vc = &virtualChannel{}
+		d.channels[id] = vc
+	}
+	metrics.Frames.WithLabelValues(id.String()).Inc()
+
+	if want := (vc.lastCount + 1) % frame.FrameCountModulus; vc.seen && frame.FrameCount != want {
+		metrics.FrameCountGaps.WithLabelValues(id.String()).Inc()
+		d.logger.Warn("Virtual channel frame count gap",
+			zap.Stringer("Virtual channel", id),
+			zap.Uint32("Expected", want),
+			zap.Uint32("Received", frame.FrameCount),
+		)
+		d.drop(id, vc, "frame count gap")
+	}
+	vc.seen = true
+	vc.lastCount = frame.FrameCount
+
+	if frame.Idle {
+		return nil
+	}
+
+	if frame.FirstHeaderPointer == turiondatapacket.FHP_NO_PACKET_START {
+		if !vc.synced {
+			return nil
+		}
+		vc.pending = append(vc.pending, frame.Data...)
+		return d.extract(id, vc)
+	}
+
+	var packets [][]byte
+	fhp := int(frame.FirstHeaderPointer)
+	if vc.synced {
+		vc.pending = append(vc.pending, frame.Data[:fhp]...)
+		packets = d.extract(id, vc)
+		if len(vc.pending) > 0 {
+			// The packet in progress should end exactly where the next starts
+			d.drop(id, vc, "packet overruns first header pointer")
+		}
+	}
+
+	vc.synced = true
+	vc.pending = append(vc.pending[:0], frame.Data[fhp:]...)
+	return append(packets, d.extract(id, vc)...)
+}
+
+// extract takes every complete packet off the front of vc.pending
+func (d *FrameDemuxer) extract(id virtualChannelID, vc *virtualChannel) [][]byte {
+	var packets [][]byte
+	for len(vc.pending) >= turiondatapacket.PRIMARY_HEADER_SIZE {
+		header, err := turiondatapacket.ParsePrimaryHeader(vc.pending)
+		if err != nil {
+			d.logger.Warn("Bad packet header in frame data", zap.Stringer("Virtual channel", id), zap.Error(err))
+			d.drop(id, vc, "bad packet header")
+			return packets
+		}
+
+		size := header.TotalLength()
+		if len(vc.pending) < size {
+			break
+		}
+
+		if header.APID() != turiondatapacket.IDLE_APID {
+			packets = append(packets, bytes.Clone(vc.pending[:size]))
+		}
+		vc.pending = vc.pending[size:]
+	}
+	return packets
+}
+
+// drop discards the packet in progress, and waits for the next packet start
+func (d *FrameDemuxer) drop(id virtualChannelID, vc *virtualChannel, reason string) {
+	if vc.synced && len(vc.pending) > 0 {
+		metrics.FramePacketsDropped.WithLabelValues(id.String()).Inc()
+		d.logger.Debug("Dropped partial packet",
+			zap.Stringer("Virtual channel", id),
+			zap.Int("Bytes", len(vc.pending)),
+			zap.String("Reason", reason),
+		)
+	}
+	vc.synced = false
+	vc.pending = vc.pending[:0]
+}
+
+func (d *FrameDemuxer) Close() error {
+	return d.writer.Close()
+}
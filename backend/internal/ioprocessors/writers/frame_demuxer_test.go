@@ -0,0 +1,153 @@
+package writers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"testing"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// packetOfSize returns a packet sent on apid that is size bytes long
+func packetOfSize(t *testing.T, apid uint16, size int) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	dataLength := size - turiondatapacket.PRIMARY_HEADER_SIZE
+	h := turiondatapacket.NewCCSDSPrimaryHeader(turiondatapacket.PrimaryHeaderFields{APID: apid}, dataLength)
+	if err := binary.Write(buf, binary.BigEndian, h); err != nil {
+		t.Fatal(err)
+	}
+	return append(buf.Bytes(), bytes.Repeat([]byte{byte(apid)}, dataLength)...)
+}
+
+// tmFrames packs packets into TM frames with dataSize byte packet zones and a
+// FECF, filling the last frame with an idle packet
+func tmFrames(t *testing.T, dataSize int, packets ...[]byte) [][]byte {
+	t.Helper()
+
+	var stream []byte
+	var starts []int
+	for _, p := range packets {
+		starts = append(starts, len(stream))
+		stream = append(stream, p...)
+	}
+	if fill := dataSize - len(stream)%dataSize; fill != dataSize {
+		// An idle packet needs at least a header and one byte of data
+		if fill <= turiondatapacket.PRIMARY_HEADER_SIZE {
+			fill += dataSize
+		}
+		starts = append(starts, len(stream))
+		stream = append(stream, packetOfSize(t, turiondatapacket.IDLE_APID, fill)...)
+	}
+
+	var frames [][]byte
+	for i := 0; i*dataSize < len(stream); i++ {
+		fhp := uint16(turiondatapacket.FHP_NO_PACKET_START)
+		for _, s := range starts {
+			if s >= i*dataSize && s < (i+1)*dataSize {
+				fhp = uint16(s - i*dataSize)
+				break
+			}
+		}
+
+		b := binary.BigEndian.AppendUint16(nil, 42<<4|1<<1)
+		b = append(b, 0, byte(i))
+		b = binary.BigEndian.AppendUint16(b, fhp)
+		b = append(b, stream[i*dataSize:(i+1)*dataSize]...)
+		frames = append(frames, binary.BigEndian.AppendUint16(b, turiondatapacket.CRC16CCITT(b)))
+	}
+	return frames
+}
+
+type recordingQuarantiner struct {
+	got [][]byte
+}
+
+func (q *recordingQuarantiner) Quarantine(_ context.Context, m *messages.Message, _ error) (int, error) {
+	q.got = append(q.got, bytes.Clone(m.Bytes()))
+	return m.Len(), nil
+}
+
+func TestFrameDemuxer(t *testing.T) {
+	small := packetOfSize(t, 1, 10)
+	spanning := packetOfSize(t, 2, 45)
+	last := packetOfSize(t, 3, 12)
+	frames := tmFrames(t, 16, small, spanning, last)
+
+	corrupt := bytes.Clone(frames[1])
+	corrupt[10] ^= 0xFF
+
+	tests := []struct {
+		name    string
+		frames  [][]byte
+		want    [][]byte
+		wantErr int
+	}{
+		{name: "in order", frames: frames, want: [][]byte{small, spanning, last}},
+		{
+			name:   "lost frame drops the packet in progress",
+			frames: [][]byte{frames[0], frames[2], frames[3], frames[4]},
+			want:   [][]byte{small, last},
+		},
+		{
+			name:    "bad FECF",
+			frames:  [][]byte{frames[0], corrupt, frames[2], frames[3], frames[4]},
+			want:    [][]byte{small, last},
+			wantErr: 1,
+		},
+		{name: "join mid packet", frames: frames[1:], want: [][]byte{last}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &recordingWriter{}
+			d, err := NewFrameDemuxer(zap.NewNop(), turiondatapacket.FrameFormat{Type: turiondatapacket.FRAME_TYPE_TM, HasFECF: true}, w, &recordingQuarantiner{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			errs := 0
+			for _, f := range tt.frames {
+				if _, err := d.Write(context.Background(), messages.New(f)); err != nil {
+					errs++
+				}
+			}
+
+			if errs != tt.wantErr {
+				t.Errorf("got %d errors; want %d", errs, tt.wantErr)
+			}
+			if len(w.got) != len(tt.want) {
+				t.Fatalf("got %d packets; want %d", len(w.got), len(tt.want))
+			}
+			for i := range tt.want {
+				if !bytes.Equal(w.got[i], tt.want[i]) {
+					t.Errorf("packet %d = %x; want %x", i, w.got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFrameDemuxerQuarantinesPackets(t *testing.T) {
+	a, b := packetOfSize(t, 1, 10), packetOfSize(t, 2, 30)
+	q := &recordingQuarantiner{}
+	d, err := NewFrameDemuxer(zap.NewNop(), turiondatapacket.FrameFormat{Type: turiondatapacket.FRAME_TYPE_TM, HasFECF: true},
+		&recordingWriter{err: context.Canceled}, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range tmFrames(t, 16, a, b) {
+		if _, err := d.Write(context.Background(), messages.New(f)); err != nil {
+			t.Fatalf("Write() error = %v; want packet errors quarantined", err)
+		}
+	}
+
+	if len(q.got) != 2 || !bytes.Equal(q.got[0], a) || !bytes.Equal(q.got[1], b) {
+		t.Errorf("quarantined %x; want %x and %x", q.got, a, b)
+	}
+}
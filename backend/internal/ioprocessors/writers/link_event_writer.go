@@ -0,0 +1,80 @@
+package writers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// LinkEventWriter persists link quality events, the sequence count gaps,
+// duplicates, reordering and rollovers found by TelemetryMessageWriter, so
+// operators can see how much of a pass was lost on the way down.
+type LinkEventWriter struct {
+	logger *zap.Logger
+	db     *sql.DB
+}
+
+func NewLinkEventWriter(
+	logger *zap.Logger,
+	db *sql.DB,
+) (*LinkEventWriter, error) {
+	var errs error
+	if logger == nil {
+		return nil, errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if db == nil {
+		errs = errors.Join(errs, errors.New("db cannot be nil"))
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &LinkEventWriter{
+		logger: logger,
+		db:     db,
+	}, nil
+}
+
+// Write expects a marshalled turiondatapacket.LinkEvent
+func (w *LinkEventWriter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	b := m.Bytes()
+	var e turiondatapacket.LinkEvent
+	if err := proto.Unmarshal(b, &e); err != nil {
+		return 0, err
+	}
+
+	const stmt = `
+    INSERT INTO public.link_events
+      (apid, kind, expected_seq_count, received_seq_count, missing_count, timestamp)
+    VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := w.db.ExecContext(ctx, stmt,
+		e.Apid,
+		e.Kind.String(),
+		e.ExpectedSeqCount,
+		e.ReceivedSeqCount,
+		e.MissingCount,
+		e.Timestamp,
+	)
+	if err != nil {
+		w.logger.Error("failed to insert link event", zap.Error(err))
+		return 0, err
+	}
+
+	w.logger.Debug("wrote link event to database",
+		zap.String("kind", e.Kind.String()),
+		zap.Uint32("apid", e.Apid),
+		zap.Uint64("ts", e.Timestamp),
+	)
+
+	return len(b), nil
+}
+
+func (w *LinkEventWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,66 @@
+package writers
+
+import (
+	"context"
+	"errors"
+	"turion-takehome/internal/ioprocessors/messages"
+
+	"go.uber.org/zap"
+)
+
+// MapFunc transforms a message before it is written. Returning an error fails
+// the write. The result may share memory with b only if the transformation is
+// done in place.
+type MapFunc func(context.Context, []byte) ([]byte, error)
+
+// Map transforms every message with fn and writes the result to writer
+type Map struct {
+	logger *zap.Logger
+	writer Writer
+	fn     MapFunc
+}
+
+func NewMap(logger *zap.Logger, writer Writer, fn MapFunc) (*Map, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if writer == nil {
+		errs = errors.Join(errs, errors.New("writer cannot be nil"))
+	}
+
+	if fn == nil {
+		errs = errors.Join(errs, errors.New("map function cannot be nil"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &Map{
+		logger: logger,
+		writer: writer,
+		fn:     fn,
+	}, nil
+}
+
+// Write reports the length of msg as written rather than the length of the
+// transformed message, since the caller only knows about msg
+func (m *Map) Write(ctx context.Context, msg *messages.Message) (int, error) {
+	b, err := m.fn(ctx, msg.Bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	out := messages.New(b)
+	defer out.Release()
+	if _, err := m.writer.Write(ctx, out); err != nil {
+		return 0, err
+	}
+	return msg.Len(), nil
+}
+
+func (m *Map) Close() error {
+	return m.writer.Close()
+}
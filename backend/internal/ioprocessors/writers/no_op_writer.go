@@ -3,6 +3,7 @@ package writers
 import (
 	"context"
 	"errors"
+	"turion-takehome/internal/ioprocessors/messages"
 
 	"go.uber.org/zap"
 )
@@ -28,10 +29,10 @@ func NewNoOpWriter(
 	}, nil
 }
 
-func (w *NoOpWriter) Write(ctx context.Context, b []byte) (int, error) {
+func (w *NoOpWriter) Write(ctx context.Context, m *messages.Message) (int, error) {
 	w.logger.Info("No write occurred, but processor is working")
 
-	return len(b), nil
+	return m.Len(), nil
 }
 
 func (w *NoOpWriter) Close() error {
@@ -0,0 +1,108 @@
+package writers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// ErrNoRoute is returned when a packet's APID has no route and the router has
+// no default
+var ErrNoRoute = errors.New("no route for APID")
+
+// APIDRouter writes each space packet to the writer routed to its APID
+type APIDRouter struct {
+	logger       *zap.Logger
+	routes       map[uint16]Writer
+	defaultRoute Writer
+}
+
+type apidRouterOptions struct {
+	defaultRoute Writer
+}
+
+type APIDRouterOption func(*apidRouterOptions)
+
+// WithDefaultRoute writes packets sent on an APID without a route to w
+// instead of failing them with ErrNoRoute
+func WithDefaultRoute(w Writer) APIDRouterOption {
+	return func(o *apidRouterOptions) {
+		o.defaultRoute = w
+	}
+}
+
+func NewAPIDRouter(
+	logger *zap.Logger,
+	routes map[uint16]Writer,
+	opts ...APIDRouterOption,
+) (*APIDRouter, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	for apid, w := range routes {
+		if w == nil {
+			errs = errors.Join(errs, fmt.Errorf("writer for APID %#x cannot be nil", apid))
+		}
+	}
+
+	options := &apidRouterOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if len(routes) == 0 && options.defaultRoute == nil {
+		errs = errors.Join(errs, errors.New("router needs at least one route"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &APIDRouter{
+		logger:       logger,
+		routes:       routes,
+		defaultRoute: options.defaultRoute,
+	}, nil
+}
+
+func (r *APIDRouter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	h, err := turiondatapacket.DecodePrimaryHeader(m.Bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	w, ok := r.routes[h.APID()]
+	if !ok {
+		w = r.defaultRoute
+	}
+	if w == nil {
+		return 0, fmt.Errorf("%w %#x", ErrNoRoute, h.APID())
+	}
+
+	return w.Write(ctx, m)
+}
+
+// Close closes every route once, even when a writer is used by several
+// routes
+func (r *APIDRouter) Close() error {
+	closed := map[Writer]struct{}{}
+	var errs error
+	for _, w := range r.routes {
+		if _, ok := closed[w]; ok {
+			continue
+		}
+		closed[w] = struct{}{}
+		errs = errors.Join(errs, w.Close())
+	}
+
+	if _, ok := closed[r.defaultRoute]; r.defaultRoute != nil && !ok {
+		errs = errors.Join(errs, r.defaultRoute.Close())
+	}
+	return errs
+}
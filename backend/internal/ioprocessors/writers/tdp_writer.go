@@ -1,32 +1,90 @@
 package writers
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
 	"errors"
+	"strconv"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/metrics"
 	"turion-takehome/internal/turiondatapacket"
 
 	"go.uber.org/zap"
 	"google.golang.org/protobuf/proto"
 )
 
+// TelemetryMessageWriter decodes raw space packets using the definition
+// registered for their APID, forwards valid packets to the SQL writer and
+// forwards changes to anomaly events, parameters going out of limits, getting
+// worse or coming back, to the anomaly writer.
+//
+// A packet is still checked for anomalies and link events when the SQL writer
+// fails it. Write returns every error once all writers have been tried. To
+// send packets or events to several places, pass a Tee or a writer built from
+// a Topology.
 type TelemetryMessageWriter struct {
-	logger        *zap.Logger
-	sqlWriter     Writer
-	anomalyWriter Writer
+	logger         *zap.Logger
+	registry       *turiondatapacket.Registry
+	limits         *turiondatapacket.LimitChecker
+	anomalyTracker *turiondatapacket.AnomalyTracker
+	sqlWriter      Writer
+	anomalyWriter  Writer
+
+	sequenceTracker *turiondatapacket.SequenceTracker
+	linkEventWriter Writer
+}
+
+type telemetryMessageWriterOptions struct {
+	sequenceTracker *turiondatapacket.SequenceTracker
+	linkEventWriter Writer
+	anomalyTracker  *turiondatapacket.AnomalyTracker
+}
+
+type TelemetryMessageWriterOption func(*telemetryMessageWriterOptions)
+
+// WithLinkQuality checks the sequence count of every decoded packet and writes
+// any gaps, duplicates, reordering or rollovers to linkEventWriter as
+// marshalled turiondatapacket.LinkEvent protobufs.
+func WithLinkQuality(
+	tracker *turiondatapacket.SequenceTracker,
+	linkEventWriter Writer,
+) TelemetryMessageWriterOption {
+	return func(o *telemetryMessageWriterOptions) {
+		o.sequenceTracker = tracker
+		o.linkEventWriter = linkEventWriter
+	}
+}
+
+// WithAnomalyTracker groups anomalies into events with tracker instead of a
+// new, empty one, e.g. a tracker restored with the events still open before a
+// restart
+func WithAnomalyTracker(tracker *turiondatapacket.AnomalyTracker) TelemetryMessageWriterOption {
+	return func(o *telemetryMessageWriterOptions) {
+		o.anomalyTracker = tracker
+	}
 }
 
 func NewTelemetryMessageWriter(
 	logger *zap.Logger,
+	registry *turiondatapacket.Registry,
+	limits *turiondatapacket.LimitChecker,
 	sqlWriter Writer,
 	anomalyWriter Writer,
+	opts ...TelemetryMessageWriterOption,
 ) (*TelemetryMessageWriter, error) {
 	var errs error
 	if logger == nil {
 		errs = errors.Join(errors.New("logger cannot be nil"))
 	}
 
+	if registry == nil {
+		errs = errors.Join(errs, errors.New("packet registry cannot be nil"))
+	}
+
+	if limits == nil {
+		errs = errors.Join(errs, errors.New("limit checker cannot be nil"))
+	}
+
 	if sqlWriter == nil {
 		errs = errors.Join(errors.New("sql writer cannot be nil"))
 	}
@@ -35,54 +93,172 @@ func NewTelemetryMessageWriter(
 		errs = errors.Join(errors.New("anomaly writer cannot be nil"))
 	}
 
+	options := &telemetryMessageWriterOptions{}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if (options.sequenceTracker == nil) != (options.linkEventWriter == nil) {
+		errs = errors.Join(errs, errors.New("link quality needs both a sequence tracker and a writer"))
+	}
+
 	if errs != nil {
 		return nil, errs
 	}
 
+	if options.anomalyTracker == nil {
+		options.anomalyTracker = turiondatapacket.NewAnomalyTracker()
+	}
+
 	return &TelemetryMessageWriter{
-		logger:        logger,
-		anomalyWriter: anomalyWriter,
-		sqlWriter:     sqlWriter,
+		logger:          logger,
+		registry:        registry,
+		limits:          limits,
+		anomalyTracker:  options.anomalyTracker,
+		anomalyWriter:   anomalyWriter,
+		sqlWriter:       sqlWriter,
+		sequenceTracker: options.sequenceTracker,
+		linkEventWriter: options.linkEventWriter,
 	}, nil
 }
 
-func (w *TelemetryMessageWriter) Write(ctx context.Context, b []byte) (int, error) {
-	tdp := &turiondatapacket.TurionDataPacket{}
-	reader := bytes.NewReader(b)
-	if err := binary.Read(reader, binary.BigEndian, tdp); err != nil {
-		return 0, err
+func (w *TelemetryMessageWriter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	pkt, def, err := w.registry.Decode(m.Bytes())
+	if errors.Is(err, turiondatapacket.ErrChecksum) {
+		return 0, errors.Join(err, w.writeChecksumEvent(ctx, m))
 	}
-	w.logger.Debug("Parsed new telemetry message", zap.Any("Message contents", tdp))
-
-	writtenByteCount, err := w.sqlWriter.Write(ctx, b)
 	if err != nil {
 		return 0, err
 	}
+	w.logger.Debug(
+		"Parsed new telemetry message",
+		zap.String("Packet type", def.Name),
+		zap.Stringer("Source", m.Source()),
+		zap.Any("Primary header", pkt.PrimaryHeader().Fields()),
+		zap.Any("Message contents", pkt),
+	)
+
+	var errs error
+	writtenByteCount, err := w.writeLinkEvents(ctx, pkt)
+	errs = errors.Join(errs, err)
+
+	swb, err := w.sqlWriter.Write(ctx, m)
+	errs = errors.Join(errs, err)
+	writtenByteCount += swb
 
-	anomalies := tdp.DetectAnomalies()
+	anomalies := w.limits.DetectAnomalies(pkt)
+	events := w.anomalyTracker.Update(pkt, anomalies)
 
-	// Can't use _, anomaly := range anomalies because Anomaly is a protoc message
-	// Can't copy mutex
-	for i := range anomalies {
-		anomaly := &anomalies[i]
-		a, err := proto.Marshal(anomaly)
+	for _, event := range events {
+		a, err := proto.Marshal(event)
 		if err != nil {
 			w.logger.Error(
-				"Failed to marshal anomaly to JSON",
-				zap.String("Anomaly", anomaly.Field.String()),
+				"Failed to marshal anomaly event",
+				zap.String("Anomaly", event.Parameter),
 				zap.Error(err),
 			)
+			continue
 		}
-		awb, err := w.anomalyWriter.Write(ctx, a)
-		if err != nil {
-			return 0, err
-		}
+		awb, err := w.writeNew(ctx, w.anomalyWriter, a)
+		errs = errors.Join(errs, err)
 		writtenByteCount += awb
 	}
 
+	if errs != nil {
+		return 0, errs
+	}
 	return (writtenByteCount), nil
 }
 
+// writeLinkEvents records the packet's sequence count and forwards any link
+// events it reveals
+func (w *TelemetryMessageWriter) writeLinkEvents(
+	ctx context.Context,
+	pkt turiondatapacket.Packet,
+) (int, error) {
+	if w.sequenceTracker == nil {
+		return 0, nil
+	}
+
+	header := pkt.PrimaryHeader()
+	events := w.sequenceTracker.Observe(header.APID(), header.SequenceCount(), pkt.Timestamp())
+
+	writtenByteCount := 0
+	var errs error
+	for _, event := range events {
+		w.logger.Warn(
+			"Detected link quality event",
+			zap.String("Kind", event.Kind.String()),
+			zap.Uint32("APID", event.Apid),
+			zap.Uint32("Expected", event.ExpectedSeqCount),
+			zap.Uint32("Received", event.ReceivedSeqCount),
+		)
+
+		e, err := proto.Marshal(event)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		lwb, err := w.writeNew(ctx, w.linkEventWriter, e)
+		errs = errors.Join(errs, err)
+		writtenByteCount += lwb
+	}
+
+	return writtenByteCount, errs
+}
+
+// writeChecksumEvent counts a packet that failed its packet error control check
+// and forwards a CHECKSUM link event for it. Only the primary header is
+// trusted, enough to know which APID is corrupting packets.
+func (w *TelemetryMessageWriter) writeChecksumEvent(ctx context.Context, m *messages.Message) error {
+	header, err := turiondatapacket.ParsePrimaryHeader(m.Bytes())
+	if err != nil {
+		return nil
+	}
+	metrics.CorruptedPackets.WithLabelValues(strconv.Itoa(int(header.APID()))).Inc()
+
+	if w.linkEventWriter == nil {
+		return nil
+	}
+
+	receivedAt := m.ReceivedAt()
+	if receivedAt.IsZero() {
+		receivedAt = time.Now()
+	}
+	event := &turiondatapacket.LinkEvent{
+		Apid:             uint32(header.APID()),
+		Kind:             turiondatapacket.LinkEventKind_CHECKSUM,
+		ReceivedSeqCount: uint32(header.SequenceCount()),
+		Timestamp:        uint64(receivedAt.Unix()),
+	}
+	w.logger.Warn(
+		"Packet failed its error control check",
+		zap.Uint32("APID", event.Apid),
+		zap.Uint32("Received", event.ReceivedSeqCount),
+		zap.Stringer("Source", m.Source()),
+	)
+
+	e, err := proto.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = w.writeNew(ctx, w.linkEventWriter, e)
+	return err
+}
+
+// writeNew writes b, a message built from the packet being written, to writer
+func (w *TelemetryMessageWriter) writeNew(ctx context.Context, writer Writer, b []byte) (int, error) {
+	m := messages.New(b)
+	defer m.Release()
+	return writer.Write(ctx, m)
+}
+
+// Close closes the downstream writers. When they are channel writers this lets
+// the processors reading those channels drain them and stop.
 func (w *TelemetryMessageWriter) Close() error {
-	return nil
+	errs := errors.Join(w.sqlWriter.Close(), w.anomalyWriter.Close())
+	if w.linkEventWriter != nil {
+		errs = errors.Join(errs, w.linkEventWriter.Close())
+	}
+	return errs
 }
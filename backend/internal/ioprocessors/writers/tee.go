@@ -0,0 +1,110 @@
+package writers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"turion-takehome/internal/ioprocessors/messages"
+
+	"go.uber.org/zap"
+)
+
+// TeePolicy decides whether a Tee's write succeeded
+type TeePolicy string
+
+const (
+	// TEE_ALL fails the write if any of the writers fail
+	TEE_ALL TeePolicy = "all"
+	// TEE_ANY only fails the write if every writer fails. The other failures
+	// are logged.
+	TEE_ANY TeePolicy = "any"
+	// TEE_FIRST only fails the write if the first writer fails. The others
+	// mirror it on a best effort basis and their failures are logged, e.g. a
+	// capture that shouldn't get packets quarantined when the disk fills up.
+	TEE_FIRST TeePolicy = "first"
+)
+
+// Tee writes every message to each of its writers in turn. A writer failing
+// doesn't stop the message from being written to the writers after it.
+type Tee struct {
+	logger  *zap.Logger
+	policy  TeePolicy
+	writers []Writer
+}
+
+func NewTee(logger *zap.Logger, policy TeePolicy, writers ...Writer) (*Tee, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if policy != TEE_ALL && policy != TEE_ANY && policy != TEE_FIRST {
+		errs = errors.Join(errs, fmt.Errorf("unknown tee policy %q", policy))
+	}
+
+	if len(writers) == 0 {
+		errs = errors.Join(errs, errors.New("tee needs at least one writer"))
+	}
+
+	for i, w := range writers {
+		if w == nil {
+			errs = errors.Join(errs, fmt.Errorf("writer %d cannot be nil", i))
+		}
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &Tee{
+		logger:  logger,
+		policy:  policy,
+		writers: writers,
+	}, nil
+}
+
+func (t *Tee) Write(ctx context.Context, m *messages.Message) (int, error) {
+	var errs, firstErr error
+	failed := 0
+	for i, w := range t.writers {
+		if _, err := w.Write(ctx, m); err != nil {
+			errs = errors.Join(errs, err)
+			failed++
+			if i == 0 {
+				firstErr = err
+			}
+		}
+	}
+
+	if failed == 0 {
+		return m.Len(), nil
+	}
+
+	if t.policy == TEE_FIRST {
+		if firstErr != nil {
+			return 0, firstErr
+		}
+		t.logger.Warn("Tee mirror writers failed", zap.Int("Failed", failed), zap.Error(errs))
+		return m.Len(), nil
+	}
+
+	if t.policy == TEE_ANY && failed < len(t.writers) {
+		t.logger.Warn("Some tee writers failed",
+			zap.Int("Failed", failed),
+			zap.Int("Writers", len(t.writers)),
+			zap.Error(errs),
+		)
+		return m.Len(), nil
+	}
+
+	return 0, errs
+}
+
+// Close closes every writer
+func (t *Tee) Close() error {
+	var errs error
+	for _, w := range t.writers {
+		errs = errors.Join(errs, w.Close())
+	}
+	return errs
+}
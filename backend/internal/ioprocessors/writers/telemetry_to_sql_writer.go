@@ -1,24 +1,106 @@
 package writers
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
-	"encoding/binary"
 	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/ioprocessors/quarantiners"
+	"turion-takehome/internal/pubsub"
 	"turion-takehome/internal/turiondatapacket"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"go.uber.org/zap"
 )
 
+const (
+	DEFAULT_BATCH_SIZE     = 500
+	DEFAULT_FLUSH_INTERVAL = 250 * time.Millisecond
+
+	// Postgres allows at most 65535 parameters per statement
+	maxStatementParams = 65535
+	// How long Close waits for the last batch to be written
+	closeFlushTimeout = 10 * time.Second
+)
+
+// TelemetryToSQLWriter persists packets to the table named by the definition
+// registered for their APID.
+//
+// By default every packet is inserted as it is written. WithBatching buffers
+// packets instead and writes them in one transaction with multi-row inserts
+// once the batch is full or the flush interval passes.
 type TelemetryToSQLWriter struct {
-	logger *zap.Logger
-	db     *sql.DB // TODO: Create an interface with mocks to handle this
+	logger      *zap.Logger
+	db          *sql.DB
+	registry    *turiondatapacket.Registry
+	liveUpdates bool
+
+	batchSize   int
+	quarantiner quarantiners.Quarantiner
+
+	mu      sync.Mutex
+	pending []pendingPacket
+	// flushMu makes sure batches are written in the order they were filled
+	flushMu sync.Mutex
+
+	stop    chan struct{}
+	stopped chan struct{}
+	once    sync.Once
+}
+
+// pendingPacket is a decoded packet waiting to be flushed. The message is kept
+// so the packet can be quarantined as it was received if it fails to insert.
+type pendingPacket struct {
+	msg *messages.Message
+	def turiondatapacket.PacketDefinition
+	pkt turiondatapacket.Packet
+}
+
+type telemetryToSQLWriterOptions struct {
+	liveUpdates   bool
+	batchSize     int
+	flushInterval time.Duration
+	quarantiner   quarantiners.Quarantiner
+}
+
+type TelemetryToSQLWriterOption func(*telemetryToSQLWriterOptions)
+
+// WithLiveUpdates publishes every decoded packet to the telemetry API's
+// subscribers over Postgres NOTIFY, see pubsub.PGListener
+func WithLiveUpdates() TelemetryToSQLWriterOption {
+	return func(o *telemetryToSQLWriterOptions) {
+		o.liveUpdates = true
+	}
+}
+
+// WithBatching buffers up to batchSize packets and flushes them when the
+// batch is full, every flushInterval and on Close. Write can't report a
+// failed insert once the packet is buffered, so failed packets are sent to
+// quarantiner. A batch that fails because of the data in some of its rows is
+// split until those rows are found, and only they are quarantined.
+func WithBatching(
+	batchSize int,
+	flushInterval time.Duration,
+	quarantiner quarantiners.Quarantiner,
+) TelemetryToSQLWriterOption {
+	return func(o *telemetryToSQLWriterOptions) {
+		o.batchSize = batchSize
+		o.flushInterval = flushInterval
+		o.quarantiner = quarantiner
+	}
 }
 
 func NewTelemetryToSQLWriter(
 	logger *zap.Logger,
 	db *sql.DB,
+	registry *turiondatapacket.Registry,
+	opts ...TelemetryToSQLWriterOption,
 ) (*TelemetryToSQLWriter, error) {
 	var errs error
 	if logger == nil {
@@ -29,62 +111,329 @@ func NewTelemetryToSQLWriter(
 		errs = errors.Join(errs, errors.New("db cannot be nil"))
 	}
 
+	if registry == nil {
+		errs = errors.Join(errs, errors.New("packet registry cannot be nil"))
+	}
+
+	options := &telemetryToSQLWriterOptions{batchSize: 1}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	batching := options.batchSize > 1
+	if options.batchSize < 1 {
+		errs = errors.Join(errs, errors.New("batch size must be at least 1"))
+	}
+
+	if batching && options.flushInterval <= 0 {
+		errs = errors.Join(errs, errors.New("flush interval must be positive"))
+	}
+
+	if batching && options.quarantiner == nil {
+		errs = errors.Join(errs, errors.New("batching requires a quarantiner"))
+	}
+
 	if errs != nil {
 		return nil, errs
 	}
 
-	return &TelemetryToSQLWriter{
-		logger: logger,
-		db:     db,
-	}, nil
-}
+	w := &TelemetryToSQLWriter{
+		logger:      logger,
+		db:          db,
+		registry:    registry,
+		liveUpdates: options.liveUpdates,
+		batchSize:   options.batchSize,
+		quarantiner: options.quarantiner,
+		stop:        make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
 
-func (w *TelemetryToSQLWriter) Write(ctx context.Context, b []byte) (int, error) {
-	tdp := &turiondatapacket.TurionDataPacket{}
-	reader := bytes.NewReader(b)
-	if err := binary.Read(reader, binary.BigEndian, tdp); err != nil {
-		return 0, err
+	if batching {
+		go w.flushEvery(options.flushInterval)
+	} else {
+		close(w.stopped)
 	}
-	w.logger.Debug("Parsed new telemetry message", zap.Any("Message contents", tdp))
 
-	err := insertDataPacket(ctx, w.db, tdp)
+	return w, nil
+}
+
+func (w *TelemetryToSQLWriter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	pkt, def, err := w.registry.Decode(m.Bytes())
 	if err != nil {
 		return 0, err
 	}
+	w.logger.Debug(
+		"Parsed new telemetry message",
+		zap.String("Packet type", def.Name),
+		zap.Any("Message contents", pkt),
+	)
+
+	if w.batchSize == 1 {
+		if err := w.writeBatch(ctx, []pendingPacket{{msg: m, def: def, pkt: pkt}}); err != nil {
+			return 0, err
+		}
+		return m.Len(), nil
+	}
+
+	// The caller releases m when Write returns, keep it until it's flushed
+	w.mu.Lock()
+	w.pending = append(w.pending, pendingPacket{msg: m.Retain(), def: def, pkt: pkt})
+	full := len(w.pending) >= w.batchSize
+	w.mu.Unlock()
 
-	w.logger.Debug("Successfully inserted TDP to DB", zap.Any("TDP contents", tdp))
+	// Packets already buffered shouldn't fail because this caller gave up
+	if full {
+		w.flush(context.WithoutCancel(ctx))
+	}
 
-	return len(b), nil
+	return m.Len(), nil
 }
 
+// Close stops the flush timer and writes any buffered packets
 func (w *TelemetryToSQLWriter) Close() error {
+	w.once.Do(func() {
+		close(w.stop)
+	})
+	<-w.stopped
+
+	ctx, cancel := context.WithTimeout(context.Background(), closeFlushTimeout)
+	defer cancel()
+	w.flush(ctx)
+	return nil
+}
+
+func (w *TelemetryToSQLWriter) flushEvery(interval time.Duration) {
+	defer close(w.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.flush(context.Background())
+		}
+	}
+}
+
+// flush writes every buffered packet, quarantining the ones that can't be
+// inserted
+func (w *TelemetryToSQLWriter) flush(ctx context.Context) {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	batch := w.pending
+	w.pending = nil
+	w.mu.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	defer func() {
+		for _, p := range batch {
+			p.msg.Release()
+		}
+	}()
+
+	start := time.Now()
+	w.writeOrSplit(ctx, batch)
+	w.logger.Debug("Flushed packet batch",
+		zap.Int("Packets", len(batch)),
+		zap.Duration("Duration", time.Since(start)),
+	)
+}
+
+// writeOrSplit writes batch in one transaction. If that fails because of some
+// of its rows, each half is retried on its own until the failing packets are
+// isolated and quarantined, so one bad row costs about log2(len(batch)) extra
+// transactions instead of losing the whole batch. Any other error would fail
+// every half as well, so the whole batch is quarantined straight away.
+func (w *TelemetryToSQLWriter) writeOrSplit(ctx context.Context, batch []pendingPacket) {
+	err := w.writeBatch(ctx, batch)
+	if err == nil {
+		return
+	}
+
+	if len(batch) > 1 && rowError(err) {
+		mid := len(batch) / 2
+		w.writeOrSplit(ctx, batch[:mid])
+		w.writeOrSplit(ctx, batch[mid:])
+		return
+	}
+
+	w.logger.Warn("Failed to write packet batch, quarantining it",
+		zap.Int("Packets", len(batch)),
+		zap.Error(err),
+	)
+	for _, p := range batch {
+		if _, qErr := w.quarantiner.Quarantine(ctx, p.msg, err); qErr != nil {
+			w.logger.Error("Failed to quarantine packet",
+				zap.String("Packet type", p.def.Name),
+				zap.Error(errors.Join(err, qErr)),
+			)
+		}
+	}
+}
+
+// rowError reports whether err was caused by the values of some row, a data
+// exception or a constraint violation. Lost connections, cancelled contexts
+// or a missing table fail every row alike.
+func rowError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || len(pgErr.Code) < 2 {
+		return false
+	}
+
+	switch pgErr.Code[:2] {
+	case "22", "23":
+		return true
+	default:
+		return false
+	}
+}
+
+// writeBatch inserts every packet of batch, and publishes them if live updates
+// are on, in a single transaction. Subscribers are only notified once the
+// rows are committed.
+func (w *TelemetryToSQLWriter) writeBatch(ctx context.Context, batch []pendingPacket) error {
+	// Group rows by table to insert each table's rows in as few statements as
+	// possible
+	tables := map[string]*tableRows{}
+	var order []string
+	for _, p := range batch {
+		if p.def.Table == "" {
+			continue
+		}
+		t, ok := tables[p.def.Table]
+		if !ok {
+			t = &tableRows{table: p.def.Table, columns: p.def.Columns}
+			if p.def.RecordOrigin {
+				t.columns = append(slices.Clip(t.columns), "source", "received_at")
+			}
+			tables[p.def.Table] = t
+			order = append(order, p.def.Table)
+		}
+
+		rows := p.def.Rows(p.pkt)
+		if p.def.RecordOrigin {
+			source, receivedAt := origin(p.msg)
+			for i := range rows {
+				rows[i] = append(rows[i], source, receivedAt)
+			}
+		}
+		t.rows = append(t.rows, rows...)
+	}
+
+	if len(order) == 0 && !w.liveUpdates {
+		return nil
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin batch: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range order {
+		if err := insertRows(ctx, tx, tables[table]); err != nil {
+			return err
+		}
+	}
+
+	if w.liveUpdates {
+		for _, p := range batch {
+			e, err := pubsub.NewTelemetryEvent(p.def, p.pkt,
+				pubsub.WithOrigin(p.msg.Source(), p.msg.ReceivedAt()),
+			)
+			if err != nil {
+				return err
+			}
+			if err := pubsub.Notify(ctx, tx, e); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit batch: %w", err)
+	}
 	return nil
 }
 
+// origin returns the source and received_at values of m, NULL when the reader
+// didn't know them
+func origin(m *messages.Message) (source, receivedAt any) {
+	if m.Source().IsValid() {
+		source = m.Source().String()
+	}
+	if !m.ReceivedAt().IsZero() {
+		receivedAt = m.ReceivedAt()
+	}
+	return source, receivedAt
+}
+
+type tableRows struct {
+	table   string
+	columns []string
+	rows    [][]any
+}
+
 // I haven't used pure SQL in a really long time... I've been cheating and using
 // Hasura as my ORM, so this probably looks stupid as hell
-// insertDataPacket does a single INSERT … VALUES (…) with all 9 columns.
-func insertDataPacket(
-	ctx context.Context,
-	db *sql.DB,
-	dp *turiondatapacket.TurionDataPacket,
-) error {
-	const stmt = `
-    INSERT INTO turion_data_packets
-      (packet_id, packet_seq_ctrl, packet_length,
-       ts,     subsystem_id,
-       temperature, battery, altitude, signal)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
-	_, err := db.ExecContext(ctx, stmt,
-		dp.CCSDSPrimaryHeader.PacketID,
-		dp.CCSDSPrimaryHeader.PacketSeqCtrl,
-		dp.CCSDSPrimaryHeader.PacketLength,
-		dp.CCSDSSecondaryHeader.Timestamp,
-		dp.CCSDSSecondaryHeader.SubsystemID,
-		dp.TelemetryPayload.Temperature,
-		dp.TelemetryPayload.Battery,
-		dp.TelemetryPayload.Altitude,
-		dp.TelemetryPayload.Signal,
+// insertRows does one INSERT … VALUES (…), (…) per chunk of rows, keeping each
+// statement under the Postgres parameter limit.
+func insertRows(ctx context.Context, tx *sql.Tx, t *tableRows) error {
+	chunk := maxStatementParams / len(t.columns)
+	for start := 0; start < len(t.rows); start += chunk {
+		end := min(start+chunk, len(t.rows))
+
+		args := make([]any, 0, (end-start)*len(t.columns))
+		for _, row := range t.rows[start:end] {
+			args = append(args, row...)
+		}
+
+		stmt := insertStatement(t.table, t.columns, end-start)
+		if _, err := tx.ExecContext(ctx, stmt, args...); err != nil {
+			return fmt.Errorf("insert into %s: %w", t.table, err)
+		}
+	}
+	return nil
+}
+
+// insertStatement builds an INSERT for rowCount rows of columns into table.
+// Table and column names come from packet definitions, never from packets, but
+// are quoted anyway.
+func insertStatement(table string, columns []string, rowCount int) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = pgx.Identifier{c}.Sanitize()
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "INSERT INTO %s (%s) VALUES ",
+		pgx.Identifier(strings.Split(table, ".")).Sanitize(),
+		strings.Join(quoted, ", "),
 	)
-	return err
+
+	param := 1
+	for r := 0; r < rowCount; r++ {
+		if r > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString("(")
+		for c := range columns {
+			if c > 0 {
+				sb.WriteString(", ")
+			}
+			fmt.Fprintf(&sb, "$%d", param)
+			param++
+		}
+		sb.WriteString(")")
+	}
+
+	return sb.String()
 }
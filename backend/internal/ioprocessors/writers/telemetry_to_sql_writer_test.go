@@ -0,0 +1,205 @@
+package writers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/turiondatapacket"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.uber.org/zap"
+)
+
+// POISON is a temperature fakeDB refuses to insert
+const POISON = -999
+
+// fakeDB is a database/sql driver that counts the rows committed by multi-row
+// INSERTs. A statement fails with a Postgres data exception if any of its
+// arguments is POISON, or with err if it is set.
+type fakeDB struct {
+	mu        sync.Mutex
+	err       error
+	execs     int
+	committed int
+	// Arguments of the last statement
+	args []driver.NamedValue
+}
+
+func (db *fakeDB) open() *sql.DB {
+	return sql.OpenDB(db)
+}
+
+func (db *fakeDB) rows() (committed, execs int) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.committed, db.execs
+}
+
+func (db *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: db}, nil }
+func (db *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct {
+	db      *fakeDB
+	pending int
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("fakeDB only supports Exec")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	c.db.execs++
+	c.db.args = args
+	if c.db.err != nil {
+		return nil, c.db.err
+	}
+	for _, a := range args {
+		if a.Value == float64(POISON) {
+			return nil, &pgconn.PgError{Code: "22003", Message: "value out of range"}
+		}
+	}
+
+	// One "(" for the column list and one per row
+	c.pending += strings.Count(query, "(") - 1
+	return driver.RowsAffected(0), nil
+}
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	c.db.committed += c.pending
+	c.pending = 0
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.pending = 0
+	return nil
+}
+
+// writePackets writes one main bus packet per temperature
+func writePackets(t *testing.T, w Writer, temperatures ...float64) {
+	t.Helper()
+	for i, temp := range temperatures {
+		b, err := turiondatapacket.MainBusPacketSpec.Encode(uint16(i), uint64(1700000000+i), map[string]float64{"temperature": temp})
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := messages.New(b)
+		if _, err := w.Write(context.Background(), m); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		m.Release()
+	}
+}
+
+func TestTelemetryToSQLWriterBatching(t *testing.T) {
+	tests := []struct {
+		name          string
+		batchSize     int
+		flushInterval time.Duration
+		temperatures  []float64
+		// close closes the writer before checking, otherwise it waits up to
+		// a second for the rows to be committed
+		close           bool
+		dbErr           error
+		wantCommitted   int
+		wantQuarantined int
+		// wantExecs is the most INSERTs the batch may take, 0 to not check
+		wantExecs int
+	}{
+		{name: "flush when full", batchSize: 4, flushInterval: time.Hour,
+			temperatures: []float64{20, 21, 22, 23, 24}, wantCommitted: 4},
+		{name: "flush on interval", batchSize: 100, flushInterval: 10 * time.Millisecond,
+			temperatures: []float64{20, 21, 22}, wantCommitted: 3},
+		{name: "flush on close", batchSize: 100, flushInterval: time.Hour,
+			temperatures: []float64{20, 21, 22}, close: true, wantCommitted: 3},
+		{name: "quarantine only bad rows", batchSize: 100, flushInterval: time.Hour,
+			temperatures: []float64{20, 21, POISON, 23, 24, 25, POISON, 27}, close: true,
+			wantCommitted: 6, wantQuarantined: 2},
+		{name: "don't split on connection errors", batchSize: 100, flushInterval: time.Hour,
+			temperatures: []float64{20, 21, 22, 23, 24, 25, 26, 27}, close: true,
+			dbErr: errors.New("connection reset by peer"), wantQuarantined: 8, wantExecs: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeDB{err: tt.dbErr}
+			db := fake.open()
+			defer db.Close()
+
+			q := &recordingQuarantiner{}
+			w, err := NewTelemetryToSQLWriter(zap.NewNop(), db, turiondatapacket.DefaultRegistry(),
+				WithBatching(tt.batchSize, tt.flushInterval, q))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer w.Close()
+
+			writePackets(t, w, tt.temperatures...)
+			if tt.close {
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			committed, execs := fake.rows()
+			for deadline := time.Now().Add(time.Second); committed < tt.wantCommitted && time.Now().Before(deadline); {
+				time.Sleep(time.Millisecond)
+				committed, execs = fake.rows()
+			}
+
+			if committed != tt.wantCommitted {
+				t.Errorf("committed %d rows; want %d", committed, tt.wantCommitted)
+			}
+			if len(q.got) != tt.wantQuarantined {
+				t.Errorf("quarantined %d packets; want %d", len(q.got), tt.wantQuarantined)
+			}
+			if tt.wantExecs != 0 && execs > tt.wantExecs {
+				t.Errorf("ran %d INSERTs; want at most %d", execs, tt.wantExecs)
+			}
+		})
+	}
+}
+
+func TestTelemetryToSQLWriterRecordsOrigin(t *testing.T) {
+	fake := &fakeDB{}
+	db := fake.open()
+	defer db.Close()
+
+	w, err := NewTelemetryToSQLWriter(zap.NewNop(), db, turiondatapacket.DefaultRegistry())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	b, err := turiondatapacket.MainBusPacketSpec.Encode(0, 1700000000, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := netip.MustParseAddrPort("10.0.0.7:5000")
+	receivedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := messages.New(b)
+	m.SetOrigin(source, receivedAt)
+	defer m.Release()
+	if _, err := w.Write(context.Background(), m); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	n := len(fake.args)
+	if n < 2 || fake.args[n-2].Value != source.String() || fake.args[n-1].Value != receivedAt {
+		t.Errorf("row ends with %v; want source %s and receive time %s", fake.args[max(n-2, 0):], source, receivedAt)
+	}
+}
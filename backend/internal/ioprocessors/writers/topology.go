@@ -0,0 +1,212 @@
+package writers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"turion-takehome/internal/ioprocessors/messages"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// DISCARD_SINK is a sink every topology can use that drops whatever it is
+// written
+const DISCARD_SINK = "discard"
+
+// Topology maps each output of a service to the tree of writers its messages
+// go through. It is loaded from YAML, see /dictionary/topology.yaml for an
+// example.
+type Topology map[string]WriterSpec
+
+// WriterSpec describes one writer of a topology. Exactly one field is set.
+// Filters and routers read the APID of space packets, so they only work on
+// outputs that carry packets.
+type WriterSpec struct {
+	// Sink names one of the writers the service provides, or DISCARD_SINK
+	Sink   string      `yaml:"sink"`
+	Tee    *TeeSpec    `yaml:"tee"`
+	Filter *FilterSpec `yaml:"filter"`
+	Router *RouterSpec `yaml:"router"`
+}
+
+type TeeSpec struct {
+	Policy  TeePolicy    `yaml:"policy"`
+	Writers []WriterSpec `yaml:"writers"`
+}
+
+// FilterSpec keeps the packets sent on APIDs, or every other packet when
+// Exclude is set
+type FilterSpec struct {
+	APIDs   []uint16   `yaml:"apids"`
+	Exclude bool       `yaml:"exclude"`
+	Writer  WriterSpec `yaml:"writer"`
+}
+
+type RouterSpec struct {
+	Routes  map[uint16]WriterSpec `yaml:"routes"`
+	Default *WriterSpec           `yaml:"default"`
+}
+
+// LoadTopology reads and validates a YAML writer topology
+func LoadTopology(path string) (Topology, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading writer topology: %w", err)
+	}
+
+	return ParseTopology(b)
+}
+
+// ParseTopology parses and validates a YAML writer topology
+func ParseTopology(b []byte) (Topology, error) {
+	t := Topology{}
+	if err := yaml.Unmarshal(b, &t); err != nil {
+		return nil, fmt.Errorf("parsing writer topology: %w", err)
+	}
+
+	var errs error
+	for output, spec := range t {
+		if err := spec.validate(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("output %q: %w", output, err))
+		}
+	}
+	if errs != nil {
+		return nil, errs
+	}
+
+	return t, nil
+}
+
+func (s WriterSpec) validate() error {
+	set := 0
+	var errs error
+	if s.Sink != "" {
+		set++
+	}
+	if s.Tee != nil {
+		set++
+		if s.Tee.Policy != TEE_ALL && s.Tee.Policy != TEE_ANY && s.Tee.Policy != TEE_FIRST {
+			errs = errors.Join(errs, fmt.Errorf("unknown tee policy %q", s.Tee.Policy))
+		}
+		if len(s.Tee.Writers) == 0 {
+			errs = errors.Join(errs, errors.New("tee needs at least one writer"))
+		}
+		for _, w := range s.Tee.Writers {
+			errs = errors.Join(errs, w.validate())
+		}
+	}
+	if s.Filter != nil {
+		set++
+		if len(s.Filter.APIDs) == 0 {
+			errs = errors.Join(errs, errors.New("filter needs at least one APID"))
+		}
+		errs = errors.Join(errs, s.Filter.Writer.validate())
+	}
+	if s.Router != nil {
+		set++
+		if len(s.Router.Routes) == 0 && s.Router.Default == nil {
+			errs = errors.Join(errs, errors.New("router needs at least one route"))
+		}
+		for _, w := range s.Router.Routes {
+			errs = errors.Join(errs, w.validate())
+		}
+		if s.Router.Default != nil {
+			errs = errors.Join(errs, s.Router.Default.validate())
+		}
+	}
+
+	if set != 1 {
+		errs = errors.Join(errs, errors.New("writer needs exactly one of sink, tee, filter or router"))
+	}
+	return errs
+}
+
+// Build assembles the writer for output, whose sinks are looked up in sinks.
+// Sinks are usually shared between outputs, so the writers built here never
+// close them. The caller closes every sink once all outputs are closed.
+func (t Topology) Build(logger *zap.Logger, output string, sinks map[string]Writer) (Writer, error) {
+	spec, ok := t[output]
+	if !ok {
+		return nil, fmt.Errorf("topology has no output %q", output)
+	}
+
+	w, err := buildWriter(logger, spec, sinks)
+	if err != nil {
+		return nil, fmt.Errorf("output %q: %w", output, err)
+	}
+	return w, nil
+}
+
+func buildWriter(logger *zap.Logger, spec WriterSpec, sinks map[string]Writer) (Writer, error) {
+	switch {
+	case spec.Sink == DISCARD_SINK:
+		return discardWriter{}, nil
+
+	case spec.Sink != "":
+		sink, ok := sinks[spec.Sink]
+		if !ok {
+			return nil, fmt.Errorf("unknown sink %q", spec.Sink)
+		}
+		return sharedSink{sink}, nil
+
+	case spec.Tee != nil:
+		writers := make([]Writer, 0, len(spec.Tee.Writers))
+		for _, s := range spec.Tee.Writers {
+			w, err := buildWriter(logger, s, sinks)
+			if err != nil {
+				return nil, err
+			}
+			writers = append(writers, w)
+		}
+		return NewTee(logger, spec.Tee.Policy, writers...)
+
+	case spec.Filter != nil:
+		w, err := buildWriter(logger, spec.Filter.Writer, sinks)
+		if err != nil {
+			return nil, err
+		}
+		return NewFilter(logger, w, KeepAPIDs(spec.Filter.Exclude, spec.Filter.APIDs...))
+
+	case spec.Router != nil:
+		routes := make(map[uint16]Writer, len(spec.Router.Routes))
+		for apid, s := range spec.Router.Routes {
+			w, err := buildWriter(logger, s, sinks)
+			if err != nil {
+				return nil, err
+			}
+			routes[apid] = w
+		}
+		var opts []APIDRouterOption
+		if spec.Router.Default != nil {
+			w, err := buildWriter(logger, *spec.Router.Default, sinks)
+			if err != nil {
+				return nil, err
+			}
+			opts = append(opts, WithDefaultRoute(w))
+		}
+		return NewAPIDRouter(logger, routes, opts...)
+	}
+
+	return nil, errors.New("writer needs exactly one of sink, tee, filter or router")
+}
+
+// sharedSink leaves closing the sink to its owner
+type sharedSink struct {
+	Writer
+}
+
+func (sharedSink) Close() error {
+	return nil
+}
+
+type discardWriter struct{}
+
+func (discardWriter) Write(_ context.Context, m *messages.Message) (int, error) {
+	return m.Len(), nil
+}
+
+func (discardWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,195 @@
+package writers
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"turion-takehome/internal/ioprocessors/messages"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// recordingWriter keeps every message it is written, or fails them all
+type recordingWriter struct {
+	got    [][]byte
+	err    error
+	closed int
+}
+
+func (w *recordingWriter) Write(_ context.Context, m *messages.Message) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.got = append(w.got, m.Bytes())
+	return m.Len(), nil
+}
+
+func (w *recordingWriter) Close() error {
+	w.closed++
+	return nil
+}
+
+// packetOn returns a packet sent on apid with a one byte data field
+func packetOn(t *testing.T, apid uint16) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	h := turiondatapacket.NewCCSDSPrimaryHeader(turiondatapacket.PrimaryHeaderFields{APID: apid}, 1)
+	if err := binary.Write(buf, binary.BigEndian, h); err != nil {
+		t.Fatal(err)
+	}
+	return append(buf.Bytes(), 0)
+}
+
+func TestTeePolicy(t *testing.T) {
+	failure := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		policy  TeePolicy
+		failing int
+		wantErr bool
+	}{
+		{name: "all succeed", policy: TEE_ALL},
+		{name: "all, one fails", policy: TEE_ALL, failing: 1, wantErr: true},
+		{name: "any, one fails", policy: TEE_ANY, failing: 1},
+		{name: "any, every one fails", policy: TEE_ANY, failing: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ws []Writer
+			var recs []*recordingWriter
+			for i := 0; i < 3; i++ {
+				rec := &recordingWriter{}
+				if i < tt.failing {
+					rec.err = failure
+				}
+				recs = append(recs, rec)
+				ws = append(ws, rec)
+			}
+
+			tee, err := NewTee(zap.NewNop(), tt.policy, ws...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = tee.Write(context.Background(), messages.New([]byte("msg")))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Write() error = %v; want error %v", err, tt.wantErr)
+			}
+
+			// A failing writer never stops the ones after it
+			for i, rec := range recs[tt.failing:] {
+				if len(rec.got) != 1 {
+					t.Errorf("writer %d got %d messages; want 1", tt.failing+i, len(rec.got))
+				}
+			}
+		})
+	}
+}
+
+func TestTeeFirst(t *testing.T) {
+	failure := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		failing []bool
+		wantErr bool
+	}{
+		{name: "mirror fails", failing: []bool{false, true}},
+		{name: "first fails", failing: []bool{true, false}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ws []Writer
+			for _, failing := range tt.failing {
+				rec := &recordingWriter{}
+				if failing {
+					rec.err = failure
+				}
+				ws = append(ws, rec)
+			}
+
+			tee, err := NewTee(zap.NewNop(), TEE_FIRST, ws...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = tee.Write(context.Background(), messages.New([]byte("msg")))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Write() error = %v; want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTopologyBuild(t *testing.T) {
+	topology, err := ParseTopology([]byte(`
+packets:
+  tee:
+    policy: all
+    writers:
+      - router:
+          routes:
+            0x01: { sink: a }
+            0x02: { sink: b }
+          default: { sink: discard }
+      - filter: { apids: [0x01], exclude: true, writer: { sink: b } }
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, b := &recordingWriter{}, &recordingWriter{}
+	w, err := topology.Build(zap.NewNop(), "packets", map[string]Writer{"a": a, "b": b})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, apid := range []uint16{0x01, 0x02, 0x03} {
+		if _, err := w.Write(context.Background(), messages.New(packetOn(t, apid))); err != nil {
+			t.Fatalf("Write(APID %#x) error = %v", apid, err)
+		}
+	}
+
+	if len(a.got) != 1 {
+		t.Errorf("sink a got %d packets; want 1", len(a.got))
+	}
+	// 0x02 through the router, 0x02 and 0x03 through the filter
+	if len(b.got) != 3 {
+		t.Errorf("sink b got %d packets; want 3", len(b.got))
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if a.closed != 0 || b.closed != 0 {
+		t.Error("closing the topology closed its sinks")
+	}
+}
+
+func TestParseTopologyInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{name: "two kinds", yaml: `packets: { sink: a, tee: { policy: all, writers: [{ sink: b }] } }`},
+		{name: "no kind", yaml: `packets: {}`},
+		{name: "bad policy", yaml: `packets: { tee: { policy: most, writers: [{ sink: a }] } }`},
+		{name: "nested error", yaml: `packets: { router: { routes: { 1: {} } } }`},
+		{name: "empty filter", yaml: `packets: { filter: { writer: { sink: a } } }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseTopology([]byte(tt.yaml)); err == nil {
+				t.Error("ParseTopology() succeeded; want error")
+			}
+		})
+	}
+}
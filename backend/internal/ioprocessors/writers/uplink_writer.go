@@ -0,0 +1,137 @@
+package writers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+	"turion-takehome/internal/ioprocessors/messages"
+
+	"go.uber.org/zap"
+)
+
+// DEFAULT_UPLINK_TIMEOUT bounds dialing and each write of uplink writers
+// created without WithUplinkTimeout
+const DEFAULT_UPLINK_TIMEOUT = 5 * time.Second
+
+// UplinkWriter sends telecommand packets to the ground station, one datagram
+// per packet over UDP or back to back on a TCP stream. It connects on the
+// first write and, after a write fails, on the next one, so a ground station
+// that restarts only fails the commands sent while it was away.
+type UplinkWriter struct {
+	logger  *zap.Logger
+	network string
+	address string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+type uplinkOptions struct {
+	timeout time.Duration
+}
+
+type UplinkWriterOption func(*uplinkOptions)
+
+// WithUplinkTimeout sets how long dialing and each write may take
+func WithUplinkTimeout(d time.Duration) UplinkWriterOption {
+	return func(o *uplinkOptions) {
+		o.timeout = d
+	}
+}
+
+// NewUplinkWriter sends packets to address over network, "udp" or "tcp"
+func NewUplinkWriter(
+	logger *zap.Logger,
+	network, address string,
+	opts ...UplinkWriterOption,
+) (*UplinkWriter, error) {
+	var errs error
+	if logger == nil {
+		errs = errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if network != "udp" && network != "tcp" {
+		errs = errors.Join(errs, fmt.Errorf("uplink network must be udp or tcp, got %q", network))
+	}
+
+	if address == "" {
+		errs = errors.Join(errs, errors.New("uplink address cannot be empty"))
+	}
+
+	options := &uplinkOptions{timeout: DEFAULT_UPLINK_TIMEOUT}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.timeout <= 0 {
+		errs = errors.Join(errs, errors.New("uplink timeout must be positive"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &UplinkWriter{
+		logger:  logger,
+		network: network,
+		address: address,
+		timeout: options.timeout,
+	}, nil
+}
+
+// Write sends m, a whole telecommand packet
+func (w *UplinkWriter) Write(ctx context.Context, m *messages.Message) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, ErrWriterClosed
+	}
+
+	if w.conn == nil {
+		dialer := net.Dialer{Timeout: w.timeout}
+		conn, err := dialer.DialContext(ctx, w.network, w.address)
+		if err != nil {
+			return 0, fmt.Errorf("connecting uplink: %w", err)
+		}
+		w.logger.Info("Connected uplink",
+			zap.String("Network", w.network),
+			zap.String("Address", w.address),
+		)
+		w.conn = conn
+	}
+
+	deadline := time.Now().Add(w.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	w.conn.SetWriteDeadline(deadline)
+
+	n, err := w.conn.Write(m.Bytes())
+	if err != nil {
+		// A partial write leaves a TCP stream out of step, start over on a
+		// new connection
+		w.conn.Close()
+		w.conn = nil
+		return n, fmt.Errorf("writing uplink: %w", err)
+	}
+	return n, nil
+}
+
+func (w *UplinkWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -3,12 +3,16 @@ package writers
 import (
 	"context"
 	"io"
+	"turion-takehome/internal/ioprocessors/messages"
 )
 
 // Writer defines the methods needed for an io-writer, aka what goes out
 // Writers are where you will perform any data transformation e.g. bytes to
 // tleemetry message and telemetry message to SQL
+//
+// Write borrows the message until it returns. A writer that keeps it longer
+// must Retain it, see package messages.
 type Writer interface {
-	Write(context.Context, []byte) (int, error)
+	Write(context.Context, *messages.Message) (int, error)
 	io.Closer
 }
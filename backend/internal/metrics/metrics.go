@@ -0,0 +1,233 @@
+// Package metrics holds the Prometheus collectors shared by the gateway and
+// the API, and the handler that serves them.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "turion"
+
+// Registry holds every collector in this package plus the Go runtime and
+// process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	MessagesRead = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "processor",
+		Name:      "messages_read_total",
+		Help:      "Messages read by a processor.",
+	}, []string{"processor"})
+
+	BytesRead = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "processor",
+		Name:      "bytes_read_total",
+		Help:      "Bytes read by a processor.",
+	}, []string{"processor"})
+
+	WriteDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "processor",
+		Name:      "write_duration_seconds",
+		Help:      "Time a processor's writer took to write one message.",
+		// 100µs to ~3s
+		Buckets: prometheus.ExponentialBuckets(0.0001, 4, 9),
+	}, []string{"processor"})
+
+	WriteErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "processor",
+		Name:      "write_errors_total",
+		Help:      "Messages a processor's writer failed to write.",
+	}, []string{"processor"})
+
+	Quarantined = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "processor",
+		Name:      "quarantined_total",
+		Help:      "Messages a processor quarantined.",
+	}, []string{"processor"})
+
+	QuarantineErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "processor",
+		Name:      "quarantine_errors_total",
+		Help:      "Messages a processor failed to quarantine, these are lost.",
+	}, []string{"processor"})
+
+	BufferAllocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "processor",
+		Name:      "buffer_allocations_total",
+		Help:      "Read buffers a processor had to allocate because its pool was empty.",
+	}, []string{"processor"})
+
+	QueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "processor",
+		Name:      "queue_depth",
+		Help:      "Messages read by a processor with workers and waiting to be written.",
+	}, []string{"processor"})
+
+	QueueFull = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "processor",
+		Name:      "queue_full_total",
+		Help:      "Times a processor's reader had to wait because its workers' queue was full.",
+	}, []string{"processor"})
+
+	UDPDatagrams = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "udp",
+		Name:      "datagrams_received_total",
+		Help:      "Datagrams received by UDP readers, by the allowed source prefix they matched.",
+	}, []string{"source"})
+
+	UDPRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "udp",
+		Name:      "datagrams_rejected_total",
+		Help:      "Datagrams dropped by UDP readers because their source isn't allowed.",
+	})
+
+	UDPTruncated = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "udp",
+		Name:      "datagrams_truncated_total",
+		Help:      "Datagrams dropped by UDP readers because they didn't fit in a read buffer.",
+	})
+
+	UDPReadErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "udp",
+		Name:      "read_errors_total",
+		Help:      "Errors reading from UDP connections.",
+	})
+
+	TCPConnections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "tcp",
+		Name:      "connections_total",
+		Help:      "Connections made or accepted by TCP readers.",
+	})
+
+	TCPResyncBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "tcp",
+		Name:      "resync_bytes_total",
+		Help:      "Bytes TCP readers skipped because they didn't start a valid packet.",
+	})
+
+	CorruptedPackets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "packets",
+		Name:      "corrupted_total",
+		Help:      "Packets that failed their packet error control check, by APID.",
+	}, []string{"apid"})
+
+	Frames = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "frames",
+		Name:      "received_total",
+		Help:      "Transfer frames demultiplexed, by spacecraft/virtual channel.",
+	}, []string{"virtual_channel"})
+
+	FramesRejected = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "frames",
+		Name:      "rejected_total",
+		Help:      "Transfer frames rejected for a bad header, length or FECF.",
+	})
+
+	FrameCountGaps = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "frames",
+		Name:      "count_gaps_total",
+		Help:      "Virtual channel frame count discontinuities, by spacecraft/virtual channel.",
+	}, []string{"virtual_channel"})
+
+	FramePacketsDropped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "frames",
+		Name:      "packets_dropped_total",
+		Help:      "Partial packets dropped after a frame gap or a bad packet header, by spacecraft/virtual channel.",
+	}, []string{"virtual_channel"})
+
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "HTTP requests served, by route template and status code.",
+	}, []string{"method", "route", "code"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time taken to serve HTTP requests, by route template.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		MessagesRead,
+		BytesRead,
+		WriteDuration,
+		WriteErrors,
+		Quarantined,
+		QuarantineErrors,
+		BufferAllocations,
+		QueueDepth,
+		QueueFull,
+		UDPDatagrams,
+		UDPRejected,
+		UDPTruncated,
+		TCPConnections,
+		TCPResyncBytes,
+		UDPReadErrors,
+		CorruptedPackets,
+		Frames,
+		FramesRejected,
+		FrameCountGaps,
+		FramePacketsDropped,
+		HTTPRequests,
+		HTTPRequestDuration,
+	)
+}
+
+// WatchChannel reports how many messages are waiting in ch, and how many it
+// can hold. A channel that stays full means the processor reading it can't
+// keep up.
+func WatchChannel[T any](name string, ch chan T) {
+	labels := prometheus.Labels{"channel": name}
+	Registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "channel",
+			Name:        "depth",
+			Help:        "Messages waiting in a channel between processors.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(len(ch)) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "channel",
+			Name:        "capacity",
+			Help:        "Buffer size of a channel between processors.",
+			ConstLabels: labels,
+		}, func() float64 { return float64(cap(ch)) }),
+	)
+}
+
+// Handler serves Registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,33 @@
+package notifier
+
+import (
+	"context"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+// LogNotifier is a local sink that logs every event it is given. Use it in
+// development to see alerts without standing up a webhook.
+type LogNotifier struct {
+	logger *zap.Logger
+}
+
+func NewLogNotifier(logger *zap.Logger) *LogNotifier {
+	return &LogNotifier{logger: logger}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, a *turiondatapacket.Anomaly) error {
+	n.logger.Warn("🚨 Anomaly",
+		zap.String("Event ID", a.EventId),
+		zap.String("State", a.State.String()),
+		zap.String("Severity", a.Severity.String()),
+		zap.Uint32("APID", a.Apid),
+		zap.String("Parameter", a.Parameter),
+		zap.Float32("Value", a.Value),
+		zap.Float32("Peak", a.PeakValue),
+		zap.Float32("Threshold", a.Threshold),
+		zap.String("Limit set", a.LimitSet),
+	)
+	return nil
+}
@@ -0,0 +1,325 @@
+// Package notifier delivers anomaly events from the gateway to the people and
+// services that need to hear about them, e.g. the telemetry API or a chat
+// webhook.
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+const (
+	DEFAULT_QUEUE_SIZE   = 256
+	DEFAULT_RATE_LIMIT   = 10 // alerts per second
+	DEFAULT_DEDUP_WINDOW = 5 * time.Minute
+)
+
+// ErrQueueFull is returned by Dispatcher.Notify when alerts are being raised
+// faster than they can be delivered
+var ErrQueueFull = errors.New("notification queue is full")
+
+// Notifier delivers one anomaly event
+type Notifier interface {
+	Notify(ctx context.Context, a *turiondatapacket.Anomaly) error
+}
+
+// Dispatcher queues anomaly events and delivers them to every notifier in the
+// background, so a slow webhook never holds up packet processing. Each
+// notifier has its own queue, delivered in order and rate limited separately,
+// so one that is down or retrying doesn't hold up the others. Events that
+// were already queued for a notifier within the dedup window are dropped for
+// that notifier.
+type Dispatcher struct {
+	logger      *zap.Logger
+	deliveries  []*delivery
+	dedupWindow time.Duration
+	now         func() time.Time
+}
+
+type dispatcherOptions struct {
+	queueSize   int
+	rateLimit   float64
+	burst       int
+	dedupWindow time.Duration
+}
+
+type DispatcherOption func(*dispatcherOptions)
+
+// delivery is one notifier's queue and the updates it has taken, by
+// UpdateKey
+type delivery struct {
+	notifier Notifier
+	queue    chan *turiondatapacket.Anomaly
+	limiter  *rateLimiter
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// WithQueueSize sets how many events can wait for delivery to each notifier
+// before Notify starts returning ErrQueueFull
+func WithQueueSize(n int) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.queueSize = n
+	}
+}
+
+// WithRateLimit allows perSecond deliveries per second to each notifier on
+// average, with bursts of up to burst deliveries
+func WithRateLimit(perSecond float64, burst int) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.rateLimit = perSecond
+		o.burst = burst
+	}
+}
+
+// WithDedupWindow sets how long an identical event is suppressed after it is
+// first seen
+func WithDedupWindow(d time.Duration) DispatcherOption {
+	return func(o *dispatcherOptions) {
+		o.dedupWindow = d
+	}
+}
+
+func NewDispatcher(
+	logger *zap.Logger,
+	notifiers []Notifier,
+	opts ...DispatcherOption,
+) (*Dispatcher, error) {
+	var errs error
+	if logger == nil {
+		return nil, errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if len(notifiers) == 0 {
+		errs = errors.Join(errs, errors.New("at least one notifier is required"))
+	}
+
+	options := &dispatcherOptions{
+		queueSize:   DEFAULT_QUEUE_SIZE,
+		rateLimit:   DEFAULT_RATE_LIMIT,
+		burst:       DEFAULT_RATE_LIMIT,
+		dedupWindow: DEFAULT_DEDUP_WINDOW,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.queueSize <= 0 {
+		errs = errors.Join(errs, errors.New("queue size must be positive"))
+	}
+
+	if options.rateLimit <= 0 || options.burst <= 0 {
+		errs = errors.Join(errs, errors.New("rate limit and burst must be positive"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	deliveries := make([]*delivery, len(notifiers))
+	for i, n := range notifiers {
+		deliveries[i] = &delivery{
+			notifier: n,
+			queue:    make(chan *turiondatapacket.Anomaly, options.queueSize),
+			limiter:  newRateLimiter(options.rateLimit, options.burst),
+			seen:     map[string]time.Time{},
+		}
+	}
+
+	return &Dispatcher{
+		logger:      logger,
+		deliveries:  deliveries,
+		dedupWindow: options.dedupWindow,
+		now:         time.Now,
+	}, nil
+}
+
+// Notify queues a for delivery to every notifier and returns immediately. It
+// does not wait for the event to be delivered. If a notifier's queue is full
+// the event is dropped for that notifier only, and the same update can be
+// retried.
+func (d *Dispatcher) Notify(ctx context.Context, a *turiondatapacket.Anomaly) error {
+	key := UpdateKey(a)
+	now := d.now()
+
+	var errs error
+	for i, dl := range d.deliveries {
+		duplicate, err := d.enqueue(ctx, dl, a, key, now)
+		switch {
+		case errors.Is(err, ErrQueueFull):
+			errs = errors.Join(errs, fmt.Errorf("%w: dropping event %s for notifier %d", err, a.EventId, i))
+		case err != nil:
+			return err
+		case duplicate:
+			d.logger.Debug("Dropping duplicate anomaly notification",
+				zap.String("Event ID", a.EventId),
+				zap.Int("Notifier", i),
+			)
+		}
+	}
+	return errs
+}
+
+// enqueue queues a for dl unless the same update was queued for it within the
+// dedup window. The update is only remembered once the queue has taken it.
+func (d *Dispatcher) enqueue(
+	ctx context.Context,
+	dl *delivery,
+	a *turiondatapacket.Anomaly,
+	key string,
+	now time.Time,
+) (duplicate bool, err error) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	for k, seenAt := range dl.seen {
+		if now.Sub(seenAt) >= d.dedupWindow {
+			delete(dl.seen, k)
+		}
+	}
+	if _, ok := dl.seen[key]; ok {
+		return true, nil
+	}
+
+	select {
+	case dl.queue <- a:
+		dl.seen[key] = now
+		return false, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	default:
+		return false, ErrQueueFull
+	}
+}
+
+// Run delivers queued events, each notifier on its own goroutine, until ctx
+// is done
+func (d *Dispatcher) Run(ctx context.Context) error {
+	var wg sync.WaitGroup
+	for _, dl := range d.deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.run(ctx, dl)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+func (d *Dispatcher) run(ctx context.Context, dl *delivery) {
+	for {
+		var a *turiondatapacket.Anomaly
+		select {
+		case <-ctx.Done():
+			return
+		case a = <-dl.queue:
+		}
+
+		if err := dl.limiter.wait(ctx); err != nil {
+			// Put it back for Flush if there's room
+			select {
+			case dl.queue <- a:
+			default:
+			}
+			return
+		}
+		d.deliver(ctx, dl, a)
+	}
+}
+
+// deliver sends a to dl's notifier. When that fails the update is forgotten,
+// so it can be redelivered if it is raised again.
+func (d *Dispatcher) deliver(ctx context.Context, dl *delivery, a *turiondatapacket.Anomaly) {
+	if err := dl.notifier.Notify(ctx, a); err != nil {
+		d.logger.Error("Failed to deliver anomaly notification",
+			zap.String("Event ID", a.EventId),
+			zap.String("Parameter", a.Parameter),
+			zap.Error(err),
+		)
+
+		dl.mu.Lock()
+		delete(dl.seen, UpdateKey(a))
+		dl.mu.Unlock()
+	}
+}
+
+// Flush delivers every event still queued, or gives up when ctx is done. Call
+// it after Run has returned and nothing else calls Notify, e.g. at shutdown.
+func (d *Dispatcher) Flush(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, dl := range d.deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.flush(ctx, dl)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) flush(ctx context.Context, dl *delivery) {
+	for {
+		select {
+		case a := <-dl.queue:
+			if err := dl.limiter.wait(ctx); err != nil {
+				return
+			}
+			d.deliver(ctx, dl, a)
+		default:
+			return
+		}
+	}
+}
+
+// UpdateKey identifies one update of an anomaly event: the event, its state,
+// severity and peak. Redeliveries of an update share it, while an event that
+// got worse or resolved gets a new one, so it is never a duplicate of an
+// earlier update.
+func UpdateKey(a *turiondatapacket.Anomaly) string {
+	return fmt.Sprintf("%s/%s/%s/%g", a.EventId, a.State, a.Severity, a.PeakValue)
+}
+
+// rateLimiter is a token bucket holding up to burst tokens that refills at
+// rate tokens per second
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait blocks until a token is available and takes it. Each limiter is only
+// used by its notifier's goroutine so it doesn't need a lock.
+func (l *rateLimiter) wait(ctx context.Context) error {
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens < 1 {
+		delay := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		l.tokens = 1
+		l.last = time.Now()
+	}
+
+	l.tokens--
+	return nil
+}
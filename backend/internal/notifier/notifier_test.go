@@ -0,0 +1,256 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+)
+
+func TestWebhookNotifierRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int32
+		wantErr      bool
+		wantRejected bool
+	}{
+		{name: "delivered", statuses: []int{http.StatusOK}, wantAttempts: 1},
+		{name: "retried until delivered", statuses: []int{503, 429, 202}, wantAttempts: 3},
+		{name: "rejected", statuses: []int{http.StatusBadRequest}, wantAttempts: 1, wantErr: true, wantRejected: true},
+		{name: "gives up", statuses: []int{500, 500, 500, 500}, wantAttempts: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &turiondatapacket.Anomaly{EventId: "abc", State: turiondatapacket.AnomalyState_OPEN}
+			var attempts atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := attempts.Add(1) - 1
+				if got := r.Header.Get("Idempotency-Key"); got != UpdateKey(a) {
+					t.Errorf("Idempotency-Key = %q; want %q", got, UpdateKey(a))
+				}
+				w.WriteHeader(tt.statuses[i])
+			}))
+			defer srv.Close()
+
+			n, err := NewWebhookNotifier(zap.NewNop(), srv.URL, WithRetries(3, time.Millisecond, time.Millisecond))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = n.Notify(context.Background(), a)
+			if (err != nil) != tt.wantErr || errors.Is(err, ErrWebhookRejected) != tt.wantRejected {
+				t.Errorf("Notify() error = %v; want error %v, rejected %v", err, tt.wantErr, tt.wantRejected)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d; want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestUpdateKey(t *testing.T) {
+	open := &turiondatapacket.Anomaly{EventId: "a", State: turiondatapacket.AnomalyState_OPEN, Severity: turiondatapacket.Severity_YELLOW, PeakValue: 55}
+	updates := []*turiondatapacket.Anomaly{
+		open,
+		{EventId: "a", State: turiondatapacket.AnomalyState_OPEN, Severity: turiondatapacket.Severity_YELLOW, PeakValue: 50},
+		{EventId: "a", State: turiondatapacket.AnomalyState_OPEN, Severity: turiondatapacket.Severity_RED, PeakValue: 35},
+		{EventId: "a", State: turiondatapacket.AnomalyState_RESOLVED, Severity: turiondatapacket.Severity_RED, PeakValue: 35},
+		{EventId: "b", State: turiondatapacket.AnomalyState_OPEN, Severity: turiondatapacket.Severity_YELLOW, PeakValue: 55},
+	}
+
+	seen := map[string]int{}
+	for i, a := range updates {
+		key := UpdateKey(a)
+		if j, ok := seen[key]; ok {
+			t.Errorf("updates %d and %d share the key %q", j, i, key)
+		}
+		seen[key] = i
+	}
+
+	// Only the changes that matter are in the key
+	again := &turiondatapacket.Anomaly{EventId: "a", State: turiondatapacket.AnomalyState_OPEN, Severity: turiondatapacket.Severity_YELLOW, PeakValue: 55, Value: 57, SampleCount: 3}
+	if UpdateKey(again) != UpdateKey(open) {
+		t.Errorf("UpdateKey() = %q for a redelivery of %q", UpdateKey(again), UpdateKey(open))
+	}
+}
+
+type recordingNotifier struct {
+	got chan *turiondatapacket.Anomaly
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, a *turiondatapacket.Anomaly) error {
+	n.got <- a
+	return nil
+}
+
+func TestDispatcherDedup(t *testing.T) {
+	rec := &recordingNotifier{got: make(chan *turiondatapacket.Anomaly, 10)}
+	d, err := NewDispatcher(zap.NewNop(), []Notifier{rec}, WithDedupWindow(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	open := &turiondatapacket.Anomaly{EventId: "a", State: turiondatapacket.AnomalyState_OPEN, PeakValue: 55}
+	worse := &turiondatapacket.Anomaly{EventId: "a", State: turiondatapacket.AnomalyState_OPEN, PeakValue: 50}
+	resolved := &turiondatapacket.Anomaly{EventId: "a", State: turiondatapacket.AnomalyState_RESOLVED, PeakValue: 50}
+
+	for _, a := range []*turiondatapacket.Anomaly{open, open, worse, worse, resolved} {
+		if err := d.Notify(ctx, a); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	for _, want := range []*turiondatapacket.Anomaly{open, worse, resolved} {
+		select {
+		case got := <-rec.got:
+			if got != want {
+				t.Errorf("delivered %v; want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %v", want)
+		}
+	}
+
+	select {
+	case got := <-rec.got:
+		t.Errorf("delivered duplicate %v", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDispatcherFlush(t *testing.T) {
+	rec := &recordingNotifier{got: make(chan *turiondatapacket.Anomaly, 10)}
+	d, err := NewDispatcher(zap.NewNop(), []Notifier{rec})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Queued while nothing is running, like events left over at shutdown
+	for _, id := range []string{"a", "b", "c"} {
+		if err := d.Notify(context.Background(), &turiondatapacket.Anomaly{EventId: id}); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+	}
+
+	d.Flush(context.Background())
+
+	if got := len(rec.got); got != 3 {
+		t.Errorf("Flush() delivered %d events; want 3", got)
+	}
+}
+
+// blockedNotifier never finishes a delivery until ctx is done
+type blockedNotifier struct{}
+
+func (blockedNotifier) Notify(ctx context.Context, _ *turiondatapacket.Anomaly) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestDispatcherNotifiersIndependent(t *testing.T) {
+	rec := &recordingNotifier{got: make(chan *turiondatapacket.Anomaly, 10)}
+	d, err := NewDispatcher(zap.NewNop(), []Notifier{blockedNotifier{}, rec}, WithQueueSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go d.Run(ctx)
+
+	// The blocked notifier's queue fills up, the other keeps delivering
+	var dropped int
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		err := d.Notify(ctx, &turiondatapacket.Anomaly{EventId: id})
+		if errors.Is(err, ErrQueueFull) {
+			dropped++
+		} else if err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+
+		select {
+		case got := <-rec.got:
+			if got.EventId != id {
+				t.Errorf("delivered %s; want %s", got.EventId, id)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("event %s held up by a blocked notifier", id)
+		}
+	}
+	if dropped == 0 {
+		t.Error("Notify() never reported the blocked notifier's queue full")
+	}
+}
+
+// failingNotifier counts deliveries and fails every one
+type failingNotifier struct {
+	attempts int
+}
+
+func (n *failingNotifier) Notify(context.Context, *turiondatapacket.Anomaly) error {
+	n.attempts++
+	return errors.New("webhook down")
+}
+
+func TestDispatcherRetriesDroppedUpdates(t *testing.T) {
+	ctx := context.Background()
+	a := &turiondatapacket.Anomaly{EventId: "a", State: turiondatapacket.AnomalyState_OPEN}
+	b := &turiondatapacket.Anomaly{EventId: "b", State: turiondatapacket.AnomalyState_OPEN}
+
+	t.Run("queue full", func(t *testing.T) {
+		rec := &recordingNotifier{got: make(chan *turiondatapacket.Anomaly, 10)}
+		d, err := NewDispatcher(zap.NewNop(), []Notifier{rec}, WithQueueSize(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := d.Notify(ctx, a); err != nil {
+			t.Fatalf("Notify() error = %v", err)
+		}
+		if err := d.Notify(ctx, b); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("Notify() error = %v; want ErrQueueFull", err)
+		}
+		d.Flush(ctx)
+
+		// The dropped update wasn't delivered, so it isn't a duplicate
+		if err := d.Notify(ctx, b); err != nil {
+			t.Fatalf("Notify() retry error = %v", err)
+		}
+		d.Flush(ctx)
+
+		if got := len(rec.got); got != 2 {
+			t.Errorf("delivered %d events; want 2", got)
+		}
+	})
+
+	t.Run("delivery failed", func(t *testing.T) {
+		failing := &failingNotifier{}
+		d, err := NewDispatcher(zap.NewNop(), []Notifier{failing})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for range 2 {
+			if err := d.Notify(ctx, a); err != nil {
+				t.Fatalf("Notify() error = %v", err)
+			}
+			d.Flush(ctx)
+		}
+
+		if failing.attempts != 2 {
+			t.Errorf("attempts = %d; want the failed update delivered again", failing.attempts)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+	"turion-takehome/internal/turiondatapacket"
+
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	DEFAULT_WEBHOOK_TIMEOUT = 5 * time.Second
+	DEFAULT_MAX_ATTEMPTS    = 5
+	DEFAULT_INITIAL_BACKOFF = 500 * time.Millisecond
+	DEFAULT_MAX_BACKOFF     = 30 * time.Second
+)
+
+// ErrWebhookRejected is returned when the webhook answers with a client error
+// that retrying won't fix
+var ErrWebhookRejected = errors.New("webhook rejected notification")
+
+// WebhookNotifier POSTs anomaly events as JSON to a URL. Network errors, 5xx
+// and 429 responses are retried with exponential backoff and jitter.
+type WebhookNotifier struct {
+	logger         *zap.Logger
+	url            string
+	client         *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+type webhookOptions struct {
+	client         *http.Client
+	maxAttempts    int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+type WebhookOption func(*webhookOptions)
+
+func WithHTTPClient(client *http.Client) WebhookOption {
+	return func(o *webhookOptions) {
+		o.client = client
+	}
+}
+
+// WithRetries makes up to maxAttempts attempts, waiting initialBackoff after
+// the first failure and doubling the wait up to maxBackoff
+func WithRetries(maxAttempts int, initialBackoff, maxBackoff time.Duration) WebhookOption {
+	return func(o *webhookOptions) {
+		o.maxAttempts = maxAttempts
+		o.initialBackoff = initialBackoff
+		o.maxBackoff = maxBackoff
+	}
+}
+
+func NewWebhookNotifier(
+	logger *zap.Logger,
+	url string,
+	opts ...WebhookOption,
+) (*WebhookNotifier, error) {
+	var errs error
+	if logger == nil {
+		return nil, errors.Join(errs, errors.New("logger cannot be nil"))
+	}
+
+	if url == "" {
+		errs = errors.Join(errs, errors.New("webhook url cannot be empty"))
+	}
+
+	options := &webhookOptions{
+		client:         &http.Client{Timeout: DEFAULT_WEBHOOK_TIMEOUT},
+		maxAttempts:    DEFAULT_MAX_ATTEMPTS,
+		initialBackoff: DEFAULT_INITIAL_BACKOFF,
+		maxBackoff:     DEFAULT_MAX_BACKOFF,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.maxAttempts < 1 {
+		errs = errors.Join(errs, errors.New("max attempts must be at least 1"))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &WebhookNotifier{
+		logger:         logger,
+		url:            url,
+		client:         options.client,
+		maxAttempts:    options.maxAttempts,
+		initialBackoff: options.initialBackoff,
+		maxBackoff:     options.maxBackoff,
+	}, nil
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, a *turiondatapacket.Anomaly) error {
+	body, err := protojson.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("marshal anomaly: %w", err)
+	}
+
+	backoff := n.initialBackoff
+	for attempt := 1; ; attempt++ {
+		err = n.post(ctx, UpdateKey(a), body)
+		if err == nil || errors.Is(err, ErrWebhookRejected) || attempt == n.maxAttempts {
+			return err
+		}
+
+		// Full jitter so a burst of failed alerts doesn't retry in lockstep
+		wait := time.Duration(rand.Int64N(int64(backoff) + 1))
+		n.logger.Warn("Webhook delivery failed, retrying",
+			zap.String("URL", n.url),
+			zap.Int("Attempt", attempt),
+			zap.Duration("Backoff", wait),
+			zap.Error(err),
+		)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, n.maxBackoff)
+	}
+}
+
+func (n *WebhookNotifier) post(ctx context.Context, key string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrWebhookRejected, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	// Receivers can use this to drop redeliveries of the same update, while
+	// still getting every update of an event
+	req.Header.Set("Idempotency-Key", key)
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return fmt.Errorf("%w: %s", ErrWebhookRejected, resp.Status)
+	}
+}
@@ -0,0 +1,22 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+// NewAnomalyEvent builds the anomalies event for a, encoded the same way as
+// the API's anomaly routes
+func NewAnomalyEvent(a *turiondatapacket.Anomaly) (Event, error) {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return Event{}, fmt.Errorf("marshal anomaly event: %w", err)
+	}
+	return Event{
+		Topic:     TOPIC_ANOMALIES,
+		APID:      a.Apid,
+		Parameter: a.Parameter,
+		Data:      data,
+	}, nil
+}
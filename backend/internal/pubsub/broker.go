@@ -0,0 +1,100 @@
+// Package pubsub fans out events received by the telemetry API to every
+// client subscribed to them.
+package pubsub
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+const (
+	TOPIC_TELEMETRY = "telemetry"
+	TOPIC_ANOMALIES = "anomalies"
+
+	DEFAULT_SUBSCRIPTION_BUFFER = 64
+)
+
+// Event is one message published to a topic. Data is JSON so it can be passed
+// to clients as is.
+type Event struct {
+	Topic string `json:"topic"`
+	APID  uint32 `json:"apid"`
+	// Parameter is the parameter an anomaly is about. Telemetry events carry
+	// every parameter of the packet in Data instead.
+	Parameter string          `json:"parameter,omitempty"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Broker is an in-memory publisher. Publish never blocks: a subscriber that
+// can't keep up misses events instead of slowing everyone else down. It is
+// safe for concurrent use.
+type Broker struct {
+	logger *zap.Logger
+
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+func NewBroker(logger *zap.Logger) *Broker {
+	return &Broker{logger: logger, subs: map[*Subscription]struct{}{}}
+}
+
+// Subscription receives events on C until Close is called
+type Subscription struct {
+	C <-chan Event
+
+	c       chan Event
+	broker  *Broker
+	once    sync.Once
+	dropped atomic.Uint64
+}
+
+// Subscribe returns a subscription that buffers up to buffer events
+func (b *Broker) Subscribe(buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DEFAULT_SUBSCRIPTION_BUFFER
+	}
+
+	c := make(chan Event, buffer)
+	s := &Subscription{C: c, c: c, broker: b}
+
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Publish sends e to every subscriber
+func (b *Broker) Publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for s := range b.subs {
+		select {
+		case s.c <- e:
+		default:
+			if s.dropped.Add(1) == 1 {
+				b.logger.Warn("Subscriber is falling behind, dropping events", zap.String("Topic", e.Topic))
+			}
+		}
+	}
+}
+
+// Dropped returns how many events this subscriber missed because its buffer
+// was full
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes C. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.broker.mu.Lock()
+		delete(s.broker.subs, s)
+		s.broker.mu.Unlock()
+		close(s.c)
+	})
+}
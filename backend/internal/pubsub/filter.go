@@ -0,0 +1,64 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Filter selects the events a client is interested in. An empty set matches
+// everything.
+type Filter struct {
+	Topics     map[string]bool
+	APIDs      map[uint32]bool
+	Parameters map[string]bool
+}
+
+// Apply returns the JSON to send to the client for e, or false if the client
+// isn't interested in it. Telemetry events are trimmed down to the parameters
+// in the filter.
+func (f Filter) Apply(e Event) ([]byte, bool, error) {
+	if len(f.Topics) > 0 && !f.Topics[e.Topic] {
+		return nil, false, nil
+	}
+	if len(f.APIDs) > 0 && !f.APIDs[e.APID] {
+		return nil, false, nil
+	}
+
+	if len(f.Parameters) > 0 {
+		switch e.Topic {
+		case TOPIC_ANOMALIES:
+			if !f.Parameters[e.Parameter] {
+				return nil, false, nil
+			}
+
+		case TOPIC_TELEMETRY:
+			var msg TelemetryMessage
+			if err := json.Unmarshal(e.Data, &msg); err != nil {
+				return nil, false, fmt.Errorf("unmarshal telemetry message: %w", err)
+			}
+
+			kept := msg.Parameters[:0]
+			for _, v := range msg.Parameters {
+				if f.Parameters[v.Name] {
+					kept = append(kept, v)
+				}
+			}
+			if len(kept) == 0 {
+				return nil, false, nil
+			}
+			msg.Parameters = kept
+
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return nil, false, fmt.Errorf("marshal telemetry message: %w", err)
+			}
+			e.Data = data
+		}
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, false, fmt.Errorf("marshal event: %w", err)
+	}
+	return b, true, nil
+}
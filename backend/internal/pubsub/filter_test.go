@@ -0,0 +1,73 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"testing"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+func TestFilterApply(t *testing.T) {
+	telemetry, err := json.Marshal(TelemetryMessage{
+		APID: 1,
+		Parameters: []turiondatapacket.ParameterValue{
+			{Name: "battery", Value: 80},
+			{Name: "temperature", Value: 25},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	packet := Event{Topic: TOPIC_TELEMETRY, APID: 1, Data: telemetry}
+	anomaly := Event{Topic: TOPIC_ANOMALIES, APID: 2, Parameter: "battery", Data: []byte(`{}`)}
+
+	tests := []struct {
+		name           string
+		filter         Filter
+		event          Event
+		wantSend       bool
+		wantParameters []string
+	}{
+		{name: "no filter", event: packet, wantSend: true, wantParameters: []string{"battery", "temperature"}},
+		{name: "other topic", filter: Filter{Topics: map[string]bool{TOPIC_ANOMALIES: true}}, event: packet},
+		{name: "other apid", filter: Filter{APIDs: map[uint32]bool{1: true}}, event: anomaly},
+		{name: "anomaly parameter", filter: Filter{Parameters: map[string]bool{"battery": true}}, event: anomaly, wantSend: true},
+		{name: "trims parameters", filter: Filter{Parameters: map[string]bool{"battery": true}}, event: packet, wantSend: true, wantParameters: []string{"battery"}},
+		{name: "no matching parameters", filter: Filter{Parameters: map[string]bool{"signal": true}}, event: packet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, send, err := tt.filter.Apply(tt.event)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if send != tt.wantSend {
+				t.Fatalf("Apply() send = %v; want %v", send, tt.wantSend)
+			}
+			if !send || tt.event.Topic != TOPIC_TELEMETRY {
+				return
+			}
+
+			var e Event
+			var msg TelemetryMessage
+			if err := json.Unmarshal(b, &e); err != nil {
+				t.Fatal(err)
+			}
+			if err := json.Unmarshal(e.Data, &msg); err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, v := range msg.Parameters {
+				got = append(got, v.Name)
+			}
+			if len(got) != len(tt.wantParameters) {
+				t.Fatalf("parameters = %v; want %v", got, tt.wantParameters)
+			}
+			for i := range got {
+				if got[i] != tt.wantParameters[i] {
+					t.Errorf("parameters = %v; want %v", got, tt.wantParameters)
+				}
+			}
+		})
+	}
+}
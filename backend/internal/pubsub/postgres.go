@@ -0,0 +1,109 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"go.uber.org/zap"
+)
+
+// PG_CHANNEL is the Postgres NOTIFY channel events are sent on between the
+// gateway and the API
+const PG_CHANNEL = "turion_live"
+
+const (
+	minReconnectBackoff = time.Second
+	maxReconnectBackoff = 30 * time.Second
+)
+
+// execer is implemented by *sql.DB, *sql.Conn and *sql.Tx
+type execer interface {
+	ExecContext(context.Context, string, ...any) (sql.Result, error)
+}
+
+// Notify sends e to every PGListener. Postgres limits payloads to 8000 bytes,
+// which is plenty for one packet. If db is a transaction, the event is only
+// delivered once it commits.
+func Notify(ctx context.Context, db execer, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal event: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, "SELECT pg_notify($1, $2)", PG_CHANNEL, string(payload)); err != nil {
+		return fmt.Errorf("notify %s: %w", PG_CHANNEL, err)
+	}
+	return nil
+}
+
+// PGListener LISTENs for events sent with Notify and publishes them to a
+// Broker. It holds its own connection, separate from the database/sql pool,
+// and reconnects with backoff if the connection drops.
+type PGListener struct {
+	logger  *zap.Logger
+	connURL string
+	broker  *Broker
+}
+
+func NewPGListener(logger *zap.Logger, connURL string, broker *Broker) *PGListener {
+	return &PGListener{logger: logger, connURL: connURL, broker: broker}
+}
+
+// Run listens until ctx is done
+func (l *PGListener) Run(ctx context.Context) error {
+	backoff := minReconnectBackoff
+	for {
+		start := time.Now()
+		err := l.listen(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Only back off further if the connection keeps failing quickly
+		if time.Since(start) > maxReconnectBackoff {
+			backoff = minReconnectBackoff
+		}
+
+		l.logger.Error("Lost Postgres notification connection, reconnecting",
+			zap.Duration("Backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxReconnectBackoff)
+	}
+}
+
+func (l *PGListener) listen(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, l.connURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{PG_CHANNEL}.Sanitize()); err != nil {
+		return err
+	}
+	l.logger.Info("Listening for live updates", zap.String("Channel", PG_CHANNEL))
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var e Event
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			l.logger.Error("Dropping malformed live update", zap.Error(err))
+			continue
+		}
+		l.broker.Publish(e)
+	}
+}
@@ -0,0 +1,64 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"time"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+// TelemetryMessage is the Data of a telemetry event, one decoded packet
+type TelemetryMessage struct {
+	APID       uint16                            `json:"apid"`
+	Packet     string                            `json:"packet"`
+	SeqCount   uint16                            `json:"seqCount"`
+	Timestamp  uint64                            `json:"timestamp"`
+	Parameters []turiondatapacket.ParameterValue `json:"parameters"`
+	// Where the gateway received the packet from and when, if known
+	Source     string     `json:"source,omitempty"`
+	ReceivedAt *time.Time `json:"receivedAt,omitempty"`
+}
+
+type TelemetryEventOption func(*TelemetryMessage)
+
+// WithOrigin adds the address the packet was received from and when, see
+// messages.Message
+func WithOrigin(source netip.AddrPort, receivedAt time.Time) TelemetryEventOption {
+	return func(m *TelemetryMessage) {
+		if source.IsValid() {
+			m.Source = source.String()
+		}
+		if !receivedAt.IsZero() {
+			t := receivedAt.UTC()
+			m.ReceivedAt = &t
+		}
+	}
+}
+
+// NewTelemetryEvent builds the telemetry event for pkt, decoded with def
+func NewTelemetryEvent(
+	def turiondatapacket.PacketDefinition,
+	pkt turiondatapacket.Packet,
+	opts ...TelemetryEventOption,
+) (Event, error) {
+	h := pkt.PrimaryHeader()
+	msg := TelemetryMessage{
+		APID:      h.APID(),
+		Packet:    def.Name,
+		SeqCount:  h.SequenceCount(),
+		Timestamp: pkt.Timestamp(),
+	}
+	for _, opt := range opts {
+		opt(&msg)
+	}
+	if pp, ok := pkt.(turiondatapacket.Parameters); ok {
+		msg.Parameters = pp.Values()
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return Event{}, fmt.Errorf("marshal telemetry message: %w", err)
+	}
+	return Event{Topic: TOPIC_TELEMETRY, APID: uint32(msg.APID), Data: data}, nil
+}
@@ -0,0 +1,346 @@
+package scenario
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+// never is the offset of a stream that won't send again
+const never = time.Duration(math.MaxInt64)
+
+// Emission is what the link delivers once the packet due at At is sent: no
+// datagrams if it was dropped or held back, several if it was duplicated or a
+// held back packet follows it
+type Emission struct {
+	At        time.Duration
+	Datagrams [][]byte
+}
+
+// Runner plays a scenario. Given the same scenario, dictionary and start
+// time it produces the same datagrams, so Next can be used to check what a
+// scenario sends without waiting for it. It is not safe for concurrent use.
+type Runner struct {
+	scenario *Scenario
+	start    time.Time
+	rng      *rand.Rand
+	streams  []*stream
+	link     *link
+	// last is when the last packet was sent
+	last time.Duration
+	done bool
+}
+
+type stream struct {
+	spec      turiondatapacket.PacketSpec
+	rate      float64
+	changes   []RateChange
+	waveforms []*waveform
+	faults    []FaultSpec
+	next      time.Duration
+	seqCount  uint16
+	// last is the last value sent of each parameter, for freeze faults
+	last map[string]float64
+}
+
+// NewRunner checks that every stream, parameter and fault of s exists in
+// dictionary and prepares a run starting at start. Packet timestamps are
+// start plus the packet's offset.
+func NewRunner(s *Scenario, dictionary *turiondatapacket.Dictionary, start time.Time) (*Runner, error) {
+	if s == nil || dictionary == nil {
+		return nil, errors.New("scenario and dictionary cannot be nil")
+	}
+
+	specs := map[uint16]turiondatapacket.PacketSpec{}
+	for _, p := range dictionary.Packets {
+		specs[p.APID] = p
+	}
+
+	var errs error
+	r := &Runner{
+		scenario: s,
+		start:    start,
+		rng:      rand.New(rand.NewSource(s.Seed)),
+		link:     &link{impairments: s.Link},
+	}
+	for _, ss := range s.Streams {
+		spec, ok := specs[ss.APID]
+		if !ok {
+			errs = errors.Join(errs, fmt.Errorf("stream %#x: no packet in the telemetry dictionary", ss.APID))
+			continue
+		}
+
+		st := &stream{
+			spec:    spec,
+			rate:    ss.Rate,
+			changes: ss.RateChanges,
+			last:    map[string]float64{},
+		}
+		// Waveforms in packet order, so random values are drawn in the same
+		// order every run
+		known := map[string]bool{}
+		for _, param := range spec.Parameters {
+			known[param.Name] = true
+			if w, ok := ss.Parameters[param.Name]; ok {
+				st.waveforms = append(st.waveforms, &waveform{name: param.Name, spec: w, value: w.Value})
+			}
+		}
+		for name := range ss.Parameters {
+			if !known[name] {
+				errs = errors.Join(errs, fmt.Errorf("stream %#x: %s has no parameter %q", ss.APID, spec.Name, name))
+			}
+		}
+		for _, f := range s.Faults {
+			if f.APID != ss.APID {
+				continue
+			}
+			if f.Kind != FAULT_SILENCE && !known[f.Parameter] {
+				errs = errors.Join(errs, fmt.Errorf("fault on %#x: %s has no parameter %q", ss.APID, spec.Name, f.Parameter))
+			}
+			st.faults = append(st.faults, f)
+		}
+
+		// Check up front that the packet can be encoded
+		if _, err := st.spec.Encode(0, 0, map[string]float64{}); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stream %#x: %w", ss.APID, err))
+		}
+		for _, w := range st.waveforms {
+			if _, err := st.spec.Encode(0, 0, map[string]float64{w.name: w.value}); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("stream %#x: %w", ss.APID, err))
+			}
+		}
+
+		st.next = st.after(0, 0)
+		r.streams = append(r.streams, st)
+	}
+	r.link.rng = r.rng
+
+	if errs != nil {
+		return nil, errs
+	}
+	return r, nil
+}
+
+// Next returns the next emission, or false once the scenario is over
+func (r *Runner) Next() (Emission, bool) {
+	for !r.done {
+		var st *stream
+		for _, candidate := range r.streams {
+			if st == nil || candidate.next < st.next {
+				st = candidate
+			}
+		}
+
+		at := st.next
+		if at == never || (r.scenario.Duration > 0 && at >= r.scenario.Duration) {
+			r.done = true
+			// A packet held back for reordering still gets delivered
+			if held := r.link.flush(); held != nil {
+				return Emission{At: r.last, Datagrams: [][]byte{held}}, true
+			}
+			return Emission{}, false
+		}
+		st.next = st.after(at, 1)
+		r.last = at
+
+		pkt, ok := r.packet(st, at)
+		if !ok {
+			continue
+		}
+		return Emission{At: at, Datagrams: r.link.transmit(at, pkt)}, true
+	}
+	return Emission{}, false
+}
+
+// Run sends every emission on time, until the scenario is over or ctx is done
+func (r *Runner) Run(ctx context.Context, send func([]byte)) error {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	for {
+		e, ok := r.Next()
+		if !ok {
+			return nil
+		}
+
+		timer.Reset(time.Until(r.start.Add(e.At)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		for _, d := range e.Datagrams {
+			send(d)
+		}
+	}
+}
+
+// packet samples the stream's parameters at t, applies the faults in effect
+// and encodes the packet. It returns false while the stream is silenced.
+func (r *Runner) packet(st *stream, t time.Duration) ([]byte, bool) {
+	values := make(map[string]float64, len(st.waveforms))
+	for _, w := range st.waveforms {
+		values[w.name] = w.sample(t, r.rng)
+	}
+
+	for _, f := range st.faults {
+		if !active(f.At, f.Duration, t) {
+			continue
+		}
+		switch f.Kind {
+		case FAULT_SILENCE:
+			return nil, false
+		case FAULT_SET:
+			values[f.Parameter] = f.Value
+		case FAULT_OFFSET:
+			values[f.Parameter] += f.Value
+		case FAULT_FREEZE:
+			values[f.Parameter] = st.last[f.Parameter]
+		}
+	}
+	for name, v := range values {
+		st.last[name] = v
+	}
+
+	timestamp := uint64(r.start.Add(t).Unix())
+	// Validated by NewRunner, only linear calibrations are inverted
+	pkt, _ := st.spec.Encode(st.seqCount, timestamp, values)
+	st.seqCount = (st.seqCount + 1) & turiondatapacket.MAX_SEQUENCE_COUNT
+	return pkt, true
+}
+
+// after returns when the stream sends next after sending at t, or when it
+// first sends if packets is zero
+func (st *stream) after(t time.Duration, packets float64) time.Duration {
+	rate := st.rateAt(t)
+	if rate > 0 {
+		next := t + time.Duration(packets*float64(time.Second)/rate)
+		// A rate change before the next packet takes over from there
+		for _, c := range st.changes {
+			if c.At > t && c.At < next {
+				return st.after(c.At, 0)
+			}
+		}
+		return next
+	}
+
+	// Paused, wait for a change that restarts it
+	for _, c := range st.changes {
+		if c.At > t && c.Rate > 0 {
+			return c.At
+		}
+	}
+	return never
+}
+
+func (st *stream) rateAt(t time.Duration) float64 {
+	rate := st.rate
+	for _, c := range st.changes {
+		if c.At <= t {
+			rate = c.Rate
+		}
+	}
+	return rate
+}
+
+// waveform is a parameter's WaveformSpec and, for random walks, where it has
+// got to
+type waveform struct {
+	name  string
+	spec  WaveformSpec
+	value float64
+}
+
+func (w *waveform) sample(t time.Duration, rng *rand.Rand) float64 {
+	s := w.spec
+	var v float64
+	switch s.Type {
+	case WAVEFORM_CONSTANT:
+		v = s.Value
+	case WAVEFORM_SINE:
+		v = s.Value + s.Amplitude*math.Sin(2*math.Pi*t.Seconds()/s.Period.Seconds())
+	case WAVEFORM_RAMP:
+		v = s.Value + s.Slope*t.Seconds()
+	case WAVEFORM_RANDOM_WALK:
+		w.value = clamp(w.value+rng.NormFloat64()*s.Step, s.Min, s.Max)
+		v = w.value
+	case WAVEFORM_STEP:
+		v = s.Value
+		for _, step := range s.Steps {
+			if t >= step.At {
+				v = step.Value
+			}
+		}
+	}
+
+	if s.Noise > 0 {
+		v += rng.NormFloat64() * s.Noise
+	}
+	return clamp(v, s.Min, s.Max)
+}
+
+func clamp(v float64, lo, hi *float64) float64 {
+	if lo != nil && v < *lo {
+		v = *lo
+	}
+	if hi != nil && v > *hi {
+		v = *hi
+	}
+	return v
+}
+
+// link applies the scenario's impairments to each packet sent
+type link struct {
+	rng         *rand.Rand
+	impairments []ImpairmentSpec
+	// held is a packet being delivered late, after the next one
+	held []byte
+}
+
+// transmit returns the datagrams delivered when pkt is sent at t
+func (l *link) transmit(t time.Duration, pkt []byte) [][]byte {
+	var imp ImpairmentSpec
+	for _, i := range l.impairments {
+		if active(i.At, i.Duration, t) {
+			imp = i
+		}
+	}
+
+	var out [][]byte
+	switch {
+	case l.chance(imp.Drop):
+	case l.held == nil && l.chance(imp.Reorder):
+		l.held = pkt
+		return nil
+	default:
+		if l.chance(imp.Corrupt) {
+			pkt = append([]byte{}, pkt...)
+			pkt[l.rng.Intn(len(pkt))] ^= 1 << l.rng.Intn(8)
+		}
+		out = append(out, pkt)
+		if l.chance(imp.Duplicate) {
+			out = append(out, pkt)
+		}
+	}
+
+	if held := l.flush(); held != nil {
+		out = append(out, held)
+	}
+	return out
+}
+
+func (l *link) flush() []byte {
+	held := l.held
+	l.held = nil
+	return held
+}
+
+func (l *link) chance(p float64) bool {
+	return p > 0 && l.rng.Float64() < p
+}
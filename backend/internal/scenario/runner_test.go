@@ -0,0 +1,234 @@
+package scenario
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+var start = time.Unix(1700000000, 0)
+
+func loadTestDictionary(t *testing.T) (*turiondatapacket.Dictionary, *turiondatapacket.Registry) {
+	t.Helper()
+
+	d, err := turiondatapacket.LoadDictionary("../../../dictionary/telemetry.yaml")
+	if err != nil {
+		t.Fatalf("LoadDictionary() error = %v", err)
+	}
+	r := turiondatapacket.NewRegistry()
+	if err := d.Register(r); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	return d, r
+}
+
+func runAll(t *testing.T, s *Scenario, d *turiondatapacket.Dictionary) []Emission {
+	t.Helper()
+
+	r, err := NewRunner(s, d, start)
+	if err != nil {
+		t.Fatalf("NewRunner() error = %v", err)
+	}
+	var out []Emission
+	for {
+		e, ok := r.Next()
+		if !ok {
+			return out
+		}
+		out = append(out, e)
+	}
+}
+
+func TestScenarioIsRepeatable(t *testing.T) {
+	d, _ := loadTestDictionary(t)
+	s, err := Parse([]byte(`
+seed: 7
+duration: 30s
+streams:
+  - apid: 0x01
+    rate: 2
+    parameters:
+      temperature: { type: sine, value: 25, amplitude: 5, period: 10s, noise: 0.5 }
+      battery: { type: randomWalk, value: 80, step: 1, min: 0, max: 100 }
+  - apid: 0x02
+    rate: 1
+    parameters:
+      bus_voltage: { type: ramp, value: 28, slope: 0.1 }
+link:
+  - { drop: 0.1, duplicate: 0.1, reorder: 0.1, corrupt: 0.1 }
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	if s.Duration != 30*time.Second || s.Streams[0].Parameters["temperature"].Period != 10*time.Second {
+		t.Fatalf("Parse() durations = %s, %s", s.Duration, s.Streams[0].Parameters["temperature"].Period)
+	}
+
+	first := runAll(t, s, d)
+	if len(first) != 90 {
+		t.Errorf("len(emissions) = %d; want 90", len(first))
+	}
+	if second := runAll(t, s, d); !reflect.DeepEqual(first, second) {
+		t.Error("same scenario and seed sent different packets")
+	}
+
+	s.Seed = 8
+	if other := runAll(t, s, d); reflect.DeepEqual(first, other) {
+		t.Error("different seeds sent the same packets")
+	}
+}
+
+func TestScenarioFaultsAndRates(t *testing.T) {
+	d, registry := loadTestDictionary(t)
+	s := &Scenario{
+		Duration: 8 * time.Second,
+		Streams: []StreamSpec{{
+			APID:        0x02,
+			Rate:        1,
+			RateChanges: []RateChange{{At: 5 * time.Second, Rate: 2}},
+			Parameters: map[string]WaveformSpec{
+				"bus_voltage": {Type: WAVEFORM_STEP, Value: 28, Steps: []StepSpec{{At: 3 * time.Second, Value: 30}}},
+			},
+		}},
+		Faults: []FaultSpec{
+			{At: 1 * time.Second, Duration: 2 * time.Second, APID: 0x02, Kind: FAULT_SILENCE},
+			{At: 6 * time.Second, Duration: time.Second, APID: 0x02, Kind: FAULT_SET, Parameter: "bus_voltage", Value: 40},
+			{At: 4 * time.Second, APID: 0x02, Kind: FAULT_OFFSET, Parameter: "bus_current", Value: 1.5},
+		},
+	}
+	if err := s.Validate(); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	type sent struct {
+		at       time.Duration
+		seqCount uint16
+		voltage  float64
+		current  float64
+	}
+	var got []sent
+	for _, e := range runAll(t, s, d) {
+		if len(e.Datagrams) != 1 {
+			t.Fatalf("emission at %s has %d datagrams", e.At, len(e.Datagrams))
+		}
+		pkt, _, err := registry.Decode(e.Datagrams[0])
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+		dp := pkt.(*turiondatapacket.DictionaryPacket)
+		if want := uint64(start.Add(e.At).Unix()); dp.Timestamp() != want {
+			t.Errorf("timestamp at %s = %d; want %d", e.At, dp.Timestamp(), want)
+		}
+		voltage, _ := dp.Parameter("bus_voltage")
+		current, _ := dp.Parameter("bus_current")
+		got = append(got, sent{
+			at:       e.At,
+			seqCount: dp.PrimaryHeader().SequenceCount(),
+			voltage:  math.Round(voltage.Value*1000) / 1000,
+			current:  math.Round(current.Value*1000) / 1000,
+		})
+	}
+
+	// Silenced at 1s and 2s without using sequence counts, stepped up at 3s,
+	// offset from 4s, twice as fast from 5s and forced to 40 V during 6s
+	want := []sent{
+		{0, 0, 28, 0},
+		{3 * time.Second, 1, 30, 0},
+		{4 * time.Second, 2, 30, 1.5},
+		{5 * time.Second, 3, 30, 1.5},
+		{5500 * time.Millisecond, 4, 30, 1.5},
+		{6 * time.Second, 5, 40, 1.5},
+		{6500 * time.Millisecond, 6, 40, 1.5},
+		{7 * time.Second, 7, 30, 1.5},
+		{7500 * time.Millisecond, 8, 30, 1.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %+v\nwant %+v", got, want)
+	}
+}
+
+func TestScenarioLinkImpairments(t *testing.T) {
+	d, _ := loadTestDictionary(t)
+	stream := StreamSpec{
+		APID: 0x02,
+		Rate: 1,
+		Parameters: map[string]WaveformSpec{
+			"bus_voltage": {Type: WAVEFORM_RAMP, Value: 28, Slope: 0.1},
+		},
+	}
+	clean := runAll(t, &Scenario{Duration: 4 * time.Second, Streams: []StreamSpec{stream}}, d)
+
+	tests := []struct {
+		name string
+		link ImpairmentSpec
+		// want maps each clean packet to the datagrams sent instead, -1 is a
+		// corrupted copy of the packet
+		want [][]int
+	}{
+		{"drop", ImpairmentSpec{Drop: 1}, [][]int{{}, {}, {}, {}}},
+		{"duplicate", ImpairmentSpec{Duplicate: 1}, [][]int{{0, 0}, {1, 1}, {2, 2}, {3, 3}}},
+		{"reorder", ImpairmentSpec{Reorder: 1}, [][]int{{}, {1, 0}, {}, {3, 2}}},
+		{"corrupt", ImpairmentSpec{Corrupt: 1}, [][]int{{-1}, {-1}, {-1}, {-1}}},
+		{"window", ImpairmentSpec{At: time.Second, Duration: 2 * time.Second, Drop: 1}, [][]int{{0}, {}, {}, {3}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scenario{Duration: 4 * time.Second, Streams: []StreamSpec{stream}, Link: []ImpairmentSpec{tt.link}}
+			got := runAll(t, s, d)
+
+			var flat, want int
+			for _, e := range got {
+				flat += len(e.Datagrams)
+			}
+			for _, w := range tt.want {
+				want += len(w)
+			}
+			if flat != want {
+				t.Fatalf("sent %d datagrams; want %d", flat, want)
+			}
+
+			for i, e := range got {
+				for j, datagram := range e.Datagrams {
+					idx := tt.want[i][j]
+					if idx == -1 {
+						if bytes.Equal(datagram, clean[i].Datagrams[0]) || len(datagram) != len(clean[i].Datagrams[0]) {
+							t.Errorf("packet %d was not corrupted", i)
+						}
+						continue
+					}
+					if !bytes.Equal(datagram, clean[idx].Datagrams[0]) {
+						t.Errorf("emission %d datagram %d is not packet %d", i, j, idx)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestNewRunnerErrors(t *testing.T) {
+	d, _ := loadTestDictionary(t)
+	s := &Scenario{
+		Streams: []StreamSpec{
+			{APID: 0x7ff, Rate: 1},
+			{APID: 0x02, Rate: 1, Parameters: map[string]WaveformSpec{"nope": {Type: WAVEFORM_CONSTANT}}},
+		},
+		Faults: []FaultSpec{{APID: 0x02, Kind: FAULT_FREEZE, Parameter: "missing"}},
+	}
+	if _, err := NewRunner(s, d, start); err == nil {
+		t.Fatal("NewRunner() error = nil")
+	}
+}
+
+func TestExampleScenario(t *testing.T) {
+	d, _ := loadTestDictionary(t)
+	s, err := Load("../../../dictionary/scenario.yaml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, err := NewRunner(s, d, start); err != nil {
+		t.Fatalf("NewRunner() error = %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Waveforms a parameter can follow
+const (
+	WAVEFORM_CONSTANT    = "constant"
+	WAVEFORM_SINE        = "sine"
+	WAVEFORM_RAMP        = "ramp"
+	WAVEFORM_RANDOM_WALK = "randomWalk"
+	WAVEFORM_STEP        = "step"
+)
+
+// Faults that can be injected into a stream
+const (
+	// FAULT_SET replaces the parameter with Value
+	FAULT_SET = "set"
+	// FAULT_OFFSET adds Value to the parameter
+	FAULT_OFFSET = "offset"
+	// FAULT_FREEZE holds the parameter at its last value
+	FAULT_FREEZE = "freeze"
+	// FAULT_SILENCE stops the stream sending, without using up sequence
+	// counts, as if the subsystem was off
+	FAULT_SILENCE = "silence"
+)
+
+// Scenario scripts what the generator sends: which packets, at what rate, how
+// each parameter evolves, the faults to inject and how the link mangles
+// packets on the way down. It is loaded from YAML, see
+// /dictionary/scenario.yaml for an example. Times are offsets from the start
+// of the run.
+type Scenario struct {
+	Name string `yaml:"name"`
+	// Seed seeds every random choice, so a scenario sends the same packets
+	// every run
+	Seed int64 `yaml:"seed"`
+	// Duration is how long the scenario runs, forever when zero
+	Duration time.Duration    `yaml:"duration"`
+	Streams  []StreamSpec     `yaml:"streams"`
+	Faults   []FaultSpec      `yaml:"faults"`
+	Link     []ImpairmentSpec `yaml:"link"`
+}
+
+// StreamSpec sends the telemetry dictionary packet on APID
+type StreamSpec struct {
+	APID uint16 `yaml:"apid"`
+	// Rate is in packets per second. RateChanges switch to another rate at
+	// set times, 0 pauses the stream.
+	Rate        float64      `yaml:"rate"`
+	RateChanges []RateChange `yaml:"rateChanges"`
+	// Parameters maps parameter names to the waveform they follow.
+	// Parameters left out are sent as zero.
+	Parameters map[string]WaveformSpec `yaml:"parameters"`
+}
+
+type RateChange struct {
+	At   time.Duration `yaml:"at"`
+	Rate float64       `yaml:"rate"`
+}
+
+// WaveformSpec describes how a parameter evolves, in engineering units
+type WaveformSpec struct {
+	Type string `yaml:"type"`
+	// Value is the constant value, the middle of a sine, where ramps and
+	// random walks start and the value before a step's first step
+	Value float64 `yaml:"value"`
+	// Sine
+	Amplitude float64       `yaml:"amplitude"`
+	Period    time.Duration `yaml:"period"`
+	// Ramp, change per second
+	Slope float64 `yaml:"slope"`
+	// Random walk, standard deviation of each packet's step
+	Step float64 `yaml:"step"`
+	// Step
+	Steps []StepSpec `yaml:"steps"`
+	// Noise is the standard deviation of gaussian noise added to any waveform
+	Noise float64 `yaml:"noise"`
+	// Optional bounds the value is clamped to
+	Min *float64 `yaml:"min"`
+	Max *float64 `yaml:"max"`
+}
+
+type StepSpec struct {
+	At    time.Duration `yaml:"at"`
+	Value float64       `yaml:"value"`
+}
+
+// FaultSpec injects a fault into the stream on APID from At, for Duration or
+// until the end when Duration is zero
+type FaultSpec struct {
+	At        time.Duration `yaml:"at"`
+	Duration  time.Duration `yaml:"duration"`
+	APID      uint16        `yaml:"apid"`
+	Kind      string        `yaml:"kind"`
+	Parameter string        `yaml:"parameter"`
+	Value     float64       `yaml:"value"`
+}
+
+// ImpairmentSpec is the chance of each packet being dropped, duplicated,
+// delivered after the next one or having a bit flipped, from At for Duration.
+// Without a duration it lasts until the end. When several overlap, the last
+// one listed applies.
+type ImpairmentSpec struct {
+	At        time.Duration `yaml:"at"`
+	Duration  time.Duration `yaml:"duration"`
+	Drop      float64       `yaml:"drop"`
+	Duplicate float64       `yaml:"duplicate"`
+	Reorder   float64       `yaml:"reorder"`
+	Corrupt   float64       `yaml:"corrupt"`
+}
+
+// Load reads and validates a YAML scenario
+func Load(path string) (*Scenario, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading scenario: %w", err)
+	}
+
+	return Parse(b)
+}
+
+// Parse parses and validates a YAML scenario
+func Parse(b []byte) (*Scenario, error) {
+	s := &Scenario{}
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("parsing scenario: %w", err)
+	}
+
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Validate checks the scenario on its own and returns all problems found.
+// Whether its APIDs and parameters exist is checked by NewRunner against the
+// telemetry dictionary.
+func (s *Scenario) Validate() error {
+	var errs error
+	if s.Duration < 0 {
+		errs = errors.Join(errs, errors.New("duration cannot be negative"))
+	}
+
+	if len(s.Streams) == 0 {
+		errs = errors.Join(errs, errors.New("scenario needs at least one stream"))
+	}
+
+	apids := map[uint16]bool{}
+	for _, stream := range s.Streams {
+		if apids[stream.APID] {
+			errs = errors.Join(errs, fmt.Errorf("stream %#x is defined twice", stream.APID))
+		}
+		apids[stream.APID] = true
+
+		if err := stream.validate(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("stream %#x: %w", stream.APID, err))
+		}
+	}
+
+	for i, f := range s.Faults {
+		if err := f.validate(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("fault %d: %w", i, err))
+		}
+		if !apids[f.APID] {
+			errs = errors.Join(errs, fmt.Errorf("fault %d: no stream on APID %#x", i, f.APID))
+		}
+	}
+
+	for i, l := range s.Link {
+		if err := l.validate(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("link impairment %d: %w", i, err))
+		}
+	}
+
+	return errs
+}
+
+func (s StreamSpec) validate() error {
+	var errs error
+	if s.Rate < 0 {
+		errs = errors.Join(errs, errors.New("rate cannot be negative"))
+	}
+
+	var last time.Duration
+	for _, c := range s.RateChanges {
+		if c.Rate < 0 {
+			errs = errors.Join(errs, fmt.Errorf("rate change at %s: rate cannot be negative", c.At))
+		}
+		if c.At < last {
+			errs = errors.Join(errs, fmt.Errorf("rate change at %s is out of order", c.At))
+		}
+		last = c.At
+	}
+
+	for name, w := range s.Parameters {
+		if err := w.validate(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("parameter %q: %w", name, err))
+		}
+	}
+	return errs
+}
+
+func (w WaveformSpec) validate() error {
+	var errs error
+	switch w.Type {
+	case WAVEFORM_CONSTANT, WAVEFORM_RAMP:
+	case WAVEFORM_SINE:
+		if w.Period <= 0 {
+			errs = errors.Join(errs, errors.New("sine needs a positive period"))
+		}
+	case WAVEFORM_RANDOM_WALK:
+		if w.Step < 0 {
+			errs = errors.Join(errs, errors.New("random walk step cannot be negative"))
+		}
+	case WAVEFORM_STEP:
+		var last time.Duration
+		for _, step := range w.Steps {
+			if step.At < last {
+				errs = errors.Join(errs, fmt.Errorf("step at %s is out of order", step.At))
+			}
+			last = step.At
+		}
+	default:
+		errs = errors.Join(errs, fmt.Errorf("unknown waveform %q", w.Type))
+	}
+
+	if w.Noise < 0 {
+		errs = errors.Join(errs, errors.New("noise cannot be negative"))
+	}
+
+	if w.Min != nil && w.Max != nil && *w.Min > *w.Max {
+		errs = errors.Join(errs, fmt.Errorf("min %v is above max %v", *w.Min, *w.Max))
+	}
+	return errs
+}
+
+func (f FaultSpec) validate() error {
+	var errs error
+	switch f.Kind {
+	case FAULT_SET, FAULT_OFFSET, FAULT_FREEZE:
+		if f.Parameter == "" {
+			errs = errors.Join(errs, fmt.Errorf("%s fault needs a parameter", f.Kind))
+		}
+	case FAULT_SILENCE:
+	default:
+		errs = errors.Join(errs, fmt.Errorf("unknown fault %q", f.Kind))
+	}
+
+	if f.At < 0 || f.Duration < 0 {
+		errs = errors.Join(errs, errors.New("at and duration cannot be negative"))
+	}
+	return errs
+}
+
+func (l ImpairmentSpec) validate() error {
+	var errs error
+	probabilities := []struct {
+		name string
+		p    float64
+	}{{"drop", l.Drop}, {"duplicate", l.Duplicate}, {"reorder", l.Reorder}, {"corrupt", l.Corrupt}}
+	for _, p := range probabilities {
+		if p.p < 0 || p.p > 1 {
+			errs = errors.Join(errs, fmt.Errorf("%s must be a probability from 0 to 1, got %v", p.name, p.p))
+		}
+	}
+
+	if l.At < 0 || l.Duration < 0 {
+		errs = errors.Join(errs, errors.New("at and duration cannot be negative"))
+	}
+	return errs
+}
+
+// active reports whether something starting at at and lasting duration, or
+// forever when zero, is in effect at t
+func active(at, duration, t time.Duration) bool {
+	return t >= at && (duration == 0 || t < at+duration)
+}
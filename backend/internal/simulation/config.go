@@ -0,0 +1,228 @@
+package simulation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config describes the simulated spacecraft, its orbit and the ground station
+// it talks to. It is loaded from YAML on top of DefaultConfig, see
+// /dictionary/simulation.yaml for an example. Angles are in degrees,
+// distances in km, power in W and energy in Wh.
+type Config struct {
+	// Seed seeds the noise added to the telemetry
+	Seed int64 `yaml:"seed"`
+	// Epoch is the simulated time at the start of the run, the start of the
+	// run when zero
+	Epoch time.Time `yaml:"epoch"`
+	// TimeScale is how many simulated seconds pass per second of the run,
+	// to see orbits go by faster than every 95 minutes
+	TimeScale float64 `yaml:"timeScale"`
+	// Interval is the time between packets, in run time
+	Interval      time.Duration       `yaml:"interval"`
+	Orbit         OrbitConfig         `yaml:"orbit"`
+	GroundStation GroundStationConfig `yaml:"groundStation"`
+	Power         PowerConfig         `yaml:"power"`
+	Thermal       ThermalConfig       `yaml:"thermal"`
+	// Noise is the standard deviation of gaussian noise added to each
+	// parameter, see Simulator.Values for the names
+	Noise map[string]float64 `yaml:"noise"`
+}
+
+// OrbitConfig holds the orbital elements at the epoch
+type OrbitConfig struct {
+	// Altitude of the semi-major axis above the mean Earth radius
+	Altitude     float64 `yaml:"altitude"`
+	Eccentricity float64 `yaml:"eccentricity"`
+	Inclination  float64 `yaml:"inclination"`
+	// The ascending node is either given as a right ascension, or as the
+	// local time (hours) at which the orbit crosses the equator northbound,
+	// like sun-synchronous orbits are. LTAN defaults to 10.5 when neither is
+	// set.
+	RAAN              *float64 `yaml:"raan"`
+	LTAN              *float64 `yaml:"ltan"`
+	ArgumentOfPerigee float64  `yaml:"argumentOfPerigee"`
+	MeanAnomaly       float64  `yaml:"meanAnomaly"`
+	// Decay is how fast the semi-major axis shrinks from drag, in km/day
+	Decay float64 `yaml:"decay"`
+}
+
+type GroundStationConfig struct {
+	Latitude  float64 `yaml:"latitude"`
+	Longitude float64 `yaml:"longitude"`
+	Altitude  float64 `yaml:"altitude"`
+	// MinElevation is the elevation above which the station is in view and
+	// the transmitter is on
+	MinElevation float64 `yaml:"minElevation"`
+	// Signal strength is ReferenceSignal (dB) at ReferenceRange and follows
+	// free space loss from there
+	ReferenceSignal float64 `yaml:"referenceSignal"`
+	ReferenceRange  float64 `yaml:"referenceRange"`
+	// NoiseFloor (dB) is what the station receives when it is out of view
+	NoiseFloor float64 `yaml:"noiseFloor"`
+}
+
+type PowerConfig struct {
+	// Battery capacity and its initial state of charge in %
+	Capacity      float64 `yaml:"capacity"`
+	InitialCharge float64 `yaml:"initialCharge"`
+	// SolarArrayPower is produced in sunlight
+	SolarArrayPower float64 `yaml:"solarArrayPower"`
+	// ChargeEfficiency is the fraction of surplus power stored
+	ChargeEfficiency float64 `yaml:"chargeEfficiency"`
+	// Load is drawn all the time, TransmitLoad on top while the ground
+	// station is in view
+	Load         float64 `yaml:"load"`
+	TransmitLoad float64 `yaml:"transmitLoad"`
+	// The bus voltage goes linearly from empty to full with the charge
+	BusVoltageEmpty float64 `yaml:"busVoltageEmpty"`
+	BusVoltageFull  float64 `yaml:"busVoltageFull"`
+}
+
+// ThermalConfig is a first order thermal model: the temperature (°C) heads
+// for Sunlit or Eclipse, getting 63% of the way there every TimeConstant
+type ThermalConfig struct {
+	Initial      float64       `yaml:"initial"`
+	Sunlit       float64       `yaml:"sunlit"`
+	Eclipse      float64       `yaml:"eclipse"`
+	TimeConstant time.Duration `yaml:"timeConstant"`
+}
+
+// DefaultConfig is a small satellite in a 520 km sun-synchronous orbit,
+// which sees about 35 minutes of eclipse every orbit, talking to a station
+// in Svalbard
+var DefaultConfig = Config{
+	TimeScale: 1,
+	Interval:  time.Second,
+	Orbit: OrbitConfig{
+		Altitude:     520,
+		Eccentricity: 0.001,
+		Inclination:  97.5,
+		Decay:        0.05,
+	},
+	GroundStation: GroundStationConfig{
+		Latitude:        78.23,
+		Longitude:       15.39,
+		MinElevation:    5,
+		ReferenceSignal: -45,
+		ReferenceRange:  1000,
+		NoiseFloor:      -110,
+	},
+	Power: PowerConfig{
+		Capacity:         100,
+		InitialCharge:    90,
+		SolarArrayPower:  70,
+		ChargeEfficiency: 0.9,
+		Load:             30,
+		TransmitLoad:     10,
+		BusVoltageEmpty:  27,
+		BusVoltageFull:   33,
+	},
+	Thermal: ThermalConfig{
+		Initial:      22,
+		Sunlit:       30,
+		Eclipse:      10,
+		TimeConstant: 15 * time.Minute,
+	},
+}
+
+// Load reads and validates a YAML simulation config
+func Load(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading simulation config: %w", err)
+	}
+
+	return Parse(b)
+}
+
+// Parse parses a YAML simulation config over DefaultConfig and validates it
+func Parse(b []byte) (*Config, error) {
+	c := DefaultConfig
+	c.Noise = map[string]float64{}
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("parsing simulation config: %w", err)
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Validate returns all problems found with the config
+func (c *Config) Validate() error {
+	var errs error
+	if c.TimeScale <= 0 {
+		errs = errors.Join(errs, errors.New("timeScale must be positive"))
+	}
+	if c.Interval <= 0 {
+		errs = errors.Join(errs, errors.New("interval must be positive"))
+	}
+
+	o := c.Orbit
+	if o.Eccentricity < 0 || o.Eccentricity >= 1 {
+		errs = errors.Join(errs, fmt.Errorf("orbit: eccentricity must be from 0 to 1, got %v", o.Eccentricity))
+	} else if perigee := (EARTH_RADIUS+o.Altitude)*(1-o.Eccentricity) - EARTH_RADIUS; perigee < MIN_ALTITUDE {
+		errs = errors.Join(errs, fmt.Errorf("orbit: perigee at %.0f km is below %v km", perigee, MIN_ALTITUDE))
+	}
+	if o.Inclination < 0 || o.Inclination > 180 {
+		errs = errors.Join(errs, fmt.Errorf("orbit: inclination must be from 0 to 180, got %v", o.Inclination))
+	}
+	if o.RAAN != nil && o.LTAN != nil {
+		errs = errors.Join(errs, errors.New("orbit: set raan or ltan, not both"))
+	}
+	if o.LTAN != nil && (*o.LTAN < 0 || *o.LTAN >= 24) {
+		errs = errors.Join(errs, fmt.Errorf("orbit: ltan must be from 0 to 24 hours, got %v", *o.LTAN))
+	}
+	if o.Decay < 0 {
+		errs = errors.Join(errs, errors.New("orbit: decay cannot be negative"))
+	}
+
+	g := c.GroundStation
+	if g.Latitude < -90 || g.Latitude > 90 {
+		errs = errors.Join(errs, fmt.Errorf("groundStation: latitude must be from -90 to 90, got %v", g.Latitude))
+	}
+	if g.ReferenceRange <= 0 {
+		errs = errors.Join(errs, errors.New("groundStation: referenceRange must be positive"))
+	}
+	if g.NoiseFloor >= g.ReferenceSignal {
+		errs = errors.Join(errs, errors.New("groundStation: noiseFloor must be below referenceSignal"))
+	}
+
+	p := c.Power
+	if p.Capacity <= 0 {
+		errs = errors.Join(errs, errors.New("power: capacity must be positive"))
+	}
+	if p.InitialCharge < 0 || p.InitialCharge > 100 {
+		errs = errors.Join(errs, fmt.Errorf("power: initialCharge must be from 0 to 100, got %v", p.InitialCharge))
+	}
+	if p.ChargeEfficiency <= 0 || p.ChargeEfficiency > 1 {
+		errs = errors.Join(errs, fmt.Errorf("power: chargeEfficiency must be above 0 and at most 1, got %v", p.ChargeEfficiency))
+	}
+	if p.SolarArrayPower < 0 || p.Load < 0 || p.TransmitLoad < 0 {
+		errs = errors.Join(errs, errors.New("power: solarArrayPower, load and transmitLoad cannot be negative"))
+	}
+	if p.BusVoltageFull < p.BusVoltageEmpty {
+		errs = errors.Join(errs, errors.New("power: busVoltageFull is below busVoltageEmpty"))
+	}
+
+	if c.Thermal.TimeConstant <= 0 {
+		errs = errors.Join(errs, errors.New("thermal: timeConstant must be positive"))
+	}
+
+	for name, sigma := range c.Noise {
+		if !knownParameters[name] {
+			errs = errors.Join(errs, fmt.Errorf("noise: unknown parameter %q", name))
+		}
+		if sigma < 0 {
+			errs = errors.Join(errs, fmt.Errorf("noise: %s cannot be negative", name))
+		}
+	}
+	return errs
+}
@@ -0,0 +1,124 @@
+package simulation
+
+import (
+	"math"
+	"time"
+)
+
+// Low precision models, good to a fraction of a degree, which is plenty for
+// telemetry that has to look right rather than be right
+const (
+	// Mean Earth radius in km, the Earth is a sphere here
+	EARTH_RADIUS = 6371.0
+	// Earth's gravitational parameter in km³/s²
+	EARTH_MU = 398600.4418
+	// Earth's oblateness, which makes the orbit plane precess
+	EARTH_J2 = 1.08263e-3
+	// Lowest altitude the orbit decays to, in km
+	MIN_ALTITUDE = 150.0
+)
+
+var j2000 = time.Date(2000, 1, 1, 12, 0, 0, 0, time.UTC)
+
+type vec3 [3]float64
+
+func (a vec3) sub(b vec3) vec3 {
+	return vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func (a vec3) dot(b vec3) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func (a vec3) scale(k float64) vec3 {
+	return vec3{a[0] * k, a[1] * k, a[2] * k}
+}
+
+func (a vec3) norm() float64 {
+	return math.Sqrt(a.dot(a))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func degrees(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func daysSinceJ2000(t time.Time) float64 {
+	return t.Sub(j2000).Hours() / 24
+}
+
+// sunDirection returns the unit vector to the sun in Earth-centred inertial
+// coordinates and its right ascension in radians
+func sunDirection(t time.Time) (vec3, float64) {
+	n := daysSinceJ2000(t)
+	meanLongitude := radians(280.460 + 0.9856474*n)
+	meanAnomaly := radians(357.528 + 0.9856003*n)
+	longitude := meanLongitude + radians(1.915)*math.Sin(meanAnomaly) + radians(0.020)*math.Sin(2*meanAnomaly)
+	obliquity := radians(23.439 - 0.0000004*n)
+
+	dir := vec3{
+		math.Cos(longitude),
+		math.Cos(obliquity) * math.Sin(longitude),
+		math.Sin(obliquity) * math.Sin(longitude),
+	}
+	return dir, math.Atan2(dir[1], dir[0])
+}
+
+// siderealAngle is how far the Earth has turned under the inertial frame, in
+// radians
+func siderealAngle(t time.Time) float64 {
+	return radians(math.Mod(280.46061837+360.98564736629*daysSinceJ2000(t), 360))
+}
+
+// stationPosition returns where a point on the ground is at t in inertial
+// coordinates
+func stationPosition(g GroundStationConfig, t time.Time) vec3 {
+	lat, lon := radians(g.Latitude), radians(g.Longitude)+siderealAngle(t)
+	r := EARTH_RADIUS + g.Altitude
+	return vec3{
+		r * math.Cos(lat) * math.Cos(lon),
+		r * math.Cos(lat) * math.Sin(lon),
+		r * math.Sin(lat),
+	}
+}
+
+// solveKepler returns the eccentric anomaly for a mean anomaly
+func solveKepler(meanAnomaly, e float64) float64 {
+	E := meanAnomaly
+	for range 10 {
+		step := (E - e*math.Sin(E) - meanAnomaly) / (1 - e*math.Cos(E))
+		E -= step
+		if math.Abs(step) < 1e-12 {
+			break
+		}
+	}
+	return E
+}
+
+// orbitPosition returns the position in inertial coordinates of a body on
+// the orbit with these elements, angles in radians
+func orbitPosition(a, e, inclination, raan, argumentOfPerigee, meanAnomaly float64) vec3 {
+	E := solveKepler(meanAnomaly, e)
+	trueAnomaly := 2 * math.Atan2(math.Sqrt(1+e)*math.Sin(E/2), math.Sqrt(1-e)*math.Cos(E/2))
+	r := a * (1 - e*math.Cos(E))
+	u := argumentOfPerigee + trueAnomaly
+
+	cosU, sinU := math.Cos(u), math.Sin(u)
+	cosO, sinO := math.Cos(raan), math.Sin(raan)
+	cosI, sinI := math.Cos(inclination), math.Sin(inclination)
+	return vec3{
+		r * (cosU*cosO - sinU*sinO*cosI),
+		r * (cosU*sinO + sinU*cosO*cosI),
+		r * sinU * sinI,
+	}
+}
+
+// inEclipse reports whether position is in the Earth's shadow, taken as a
+// cylinder behind the Earth
+func inEclipse(position, sun vec3) bool {
+	s := position.dot(sun)
+	return s < 0 && position.sub(sun.scale(s)).norm() < EARTH_RADIUS
+}
@@ -0,0 +1,228 @@
+package simulation
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// MAX_STEP is the longest step the battery and thermal models are integrated
+// over, in simulated time
+const MAX_STEP = 10 * time.Second
+
+// Parameters the simulator produces, see Simulator.Values
+var parameterNames = []string{
+	"temperature", "battery", "altitude", "signal",
+	"bus_voltage", "bus_current", "solar_array_power", "battery_soc",
+}
+
+var knownParameters = func() map[string]bool {
+	known := map[string]bool{}
+	for _, name := range parameterNames {
+		known[name] = true
+	}
+	return known
+}()
+
+// State is the simulated spacecraft at a point in time
+type State struct {
+	Time time.Time
+	// Altitude above the mean Earth radius, in km
+	Altitude float64
+	Eclipse  bool
+	// Range (km) and elevation (degrees) seen from the ground station
+	Range         float64
+	Elevation     float64
+	StationInView bool
+	// Signal strength in dB
+	Signal float64
+	// SolarPower is what the array produces, Load what the spacecraft draws
+	SolarPower float64
+	Load       float64
+	// Charge is the battery's state of charge in %
+	Charge      float64
+	BusVoltage  float64
+	BusCurrent  float64
+	Temperature float64
+}
+
+// Simulator propagates a spacecraft on a Keplerian orbit with drag decay and
+// J2 precession of its orbit plane. The battery charges from the solar array
+// in sunlight and drains into the load, and the spacecraft warms up in
+// sunlight and cools down in eclipse, so the telemetry it produces moves
+// together the way a real spacecraft's does. It is not safe for concurrent
+// use.
+type Simulator struct {
+	config Config
+	rng    *rand.Rand
+
+	time time.Time
+	// Orbital elements, angles in radians
+	semiMajorAxis     float64
+	eccentricity      float64
+	inclination       float64
+	raan              float64
+	argumentOfPerigee float64
+	meanAnomaly       float64
+
+	// Battery energy in Wh
+	energy      float64
+	temperature float64
+	state       State
+}
+
+// NewSimulator starts the simulation at c.Epoch, or at start when c.Epoch is
+// zero
+func NewSimulator(c *Config, start time.Time) (*Simulator, error) {
+	if c == nil {
+		return nil, errors.New("config cannot be nil")
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	epoch := c.Epoch
+	if epoch.IsZero() {
+		epoch = start
+	}
+
+	o := c.Orbit
+	var raan float64
+	switch {
+	case o.RAAN != nil:
+		raan = radians(*o.RAAN)
+	default:
+		ltan := 10.5
+		if o.LTAN != nil {
+			ltan = *o.LTAN
+		}
+		// The node is at noon local time when it points at the sun
+		_, sunRightAscension := sunDirection(epoch)
+		raan = sunRightAscension + radians((ltan-12)*15)
+	}
+
+	s := &Simulator{
+		config:            *c,
+		rng:               rand.New(rand.NewSource(c.Seed)),
+		time:              epoch,
+		semiMajorAxis:     EARTH_RADIUS + o.Altitude,
+		eccentricity:      o.Eccentricity,
+		inclination:       radians(o.Inclination),
+		raan:              raan,
+		argumentOfPerigee: radians(o.ArgumentOfPerigee),
+		meanAnomaly:       radians(o.MeanAnomaly),
+		energy:            c.Power.Capacity * c.Power.InitialCharge / 100,
+		temperature:       c.Thermal.Initial,
+	}
+	s.update()
+	return s, nil
+}
+
+// Advance moves the simulation d of simulated time forward
+func (s *Simulator) Advance(d time.Duration) State {
+	for d > 0 {
+		step := min(d, MAX_STEP)
+		s.step(step.Seconds())
+		s.time = s.time.Add(step)
+		s.update()
+		d -= step
+	}
+	return s.state
+}
+
+// State returns the spacecraft's current state
+func (s *Simulator) State() State {
+	return s.state
+}
+
+// Values returns the current state as telemetry parameters, with noise:
+// temperature (°C), battery and battery_soc (%), altitude (km), signal (dB),
+// bus_voltage (V), bus_current (A) and solar_array_power (W). The names match
+// the main bus and power packets of the telemetry dictionary.
+func (s *Simulator) Values() map[string]float64 {
+	st := s.state
+	values := map[string]float64{
+		"temperature":       st.Temperature,
+		"battery":           st.Charge,
+		"altitude":          st.Altitude,
+		"signal":            st.Signal,
+		"bus_voltage":       st.BusVoltage,
+		"bus_current":       st.BusCurrent,
+		"solar_array_power": st.SolarPower,
+		"battery_soc":       st.Charge,
+	}
+
+	// In a fixed order, so a seed always gives the same noise
+	for _, name := range parameterNames {
+		if sigma := s.config.Noise[name]; sigma > 0 {
+			values[name] += s.rng.NormFloat64() * sigma
+		}
+	}
+	for _, name := range []string{"battery", "battery_soc"} {
+		values[name] = math.Max(0, math.Min(100, values[name]))
+	}
+	return values
+}
+
+// step integrates the orbit, battery and temperature over dt seconds, using
+// the state at the start of the step
+func (s *Simulator) step(dt float64) {
+	o, p, th := s.config.Orbit, s.config.Power, s.config.Thermal
+
+	s.semiMajorAxis = math.Max(EARTH_RADIUS+MIN_ALTITUDE, s.semiMajorAxis-o.Decay*dt/86400)
+	a, e := s.semiMajorAxis, s.eccentricity
+	meanMotion := math.Sqrt(EARTH_MU / (a * a * a))
+	s.meanAnomaly = math.Mod(s.meanAnomaly+meanMotion*dt, 2*math.Pi)
+	semiLatusRectum := a * (1 - e*e)
+	s.raan += -1.5 * meanMotion * EARTH_J2 * math.Pow(EARTH_RADIUS/semiLatusRectum, 2) * math.Cos(s.inclination) * dt
+
+	net := s.state.SolarPower - s.state.Load
+	if net > 0 {
+		net *= p.ChargeEfficiency
+	}
+	s.energy = math.Max(0, math.Min(p.Capacity, s.energy+net*dt/3600))
+
+	target := th.Sunlit
+	if s.state.Eclipse {
+		target = th.Eclipse
+	}
+	s.temperature += (target - s.temperature) * (1 - math.Exp(-dt/th.TimeConstant.Seconds()))
+}
+
+// update works out the state from the orbit, battery and temperature
+func (s *Simulator) update() {
+	c := s.config
+	position := orbitPosition(s.semiMajorAxis, s.eccentricity, s.inclination, s.raan, s.argumentOfPerigee, s.meanAnomaly)
+	sun, _ := sunDirection(s.time)
+
+	station := stationPosition(c.GroundStation, s.time)
+	lineOfSight := position.sub(station)
+	rng := lineOfSight.norm()
+	elevation := degrees(math.Asin(lineOfSight.dot(station) / (rng * station.norm())))
+
+	st := State{
+		Time:          s.time,
+		Altitude:      position.norm() - EARTH_RADIUS,
+		Eclipse:       inEclipse(position, sun),
+		Range:         rng,
+		Elevation:     elevation,
+		StationInView: elevation >= c.GroundStation.MinElevation,
+		Signal:        c.GroundStation.NoiseFloor,
+		Load:          c.Power.Load,
+		Charge:        100 * s.energy / c.Power.Capacity,
+		Temperature:   s.temperature,
+	}
+	if !st.Eclipse {
+		st.SolarPower = c.Power.SolarArrayPower
+	}
+	if st.StationInView {
+		st.Signal = c.GroundStation.ReferenceSignal - 20*math.Log10(rng/c.GroundStation.ReferenceRange)
+		st.Load += c.Power.TransmitLoad
+	}
+	st.BusVoltage = c.Power.BusVoltageEmpty + (c.Power.BusVoltageFull-c.Power.BusVoltageEmpty)*st.Charge/100
+	if st.BusVoltage > 0 {
+		st.BusCurrent = st.Load / st.BusVoltage
+	}
+	s.state = st
+}
@@ -0,0 +1,142 @@
+package simulation
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+
+func TestSimulatorOrbit(t *testing.T) {
+	s, err := NewSimulator(&DefaultConfig, epoch)
+	if err != nil {
+		t.Fatalf("NewSimulator() error = %v", err)
+	}
+
+	a := EARTH_RADIUS + DefaultConfig.Orbit.Altitude
+	period := time.Duration(2 * math.Pi * math.Sqrt(a*a*a/EARTH_MU) * float64(time.Second))
+	if period < 94*time.Minute || period > 96*time.Minute {
+		t.Fatalf("period = %s", period)
+	}
+
+	const step = 10 * time.Second
+	var eclipses, inView int
+	var eclipsed time.Duration
+	prev := s.State()
+	for elapsed := time.Duration(0); elapsed < 3*period; elapsed += step {
+		st := s.Advance(step)
+
+		if st.Altitude < 510 || st.Altitude > 530 {
+			t.Fatalf("altitude at %s = %.1f km", elapsed, st.Altitude)
+		}
+		if st.Eclipse {
+			eclipsed += step
+			if !prev.Eclipse {
+				eclipses++
+			}
+		}
+
+		// Charge and temperature follow the sun
+		switch {
+		case prev.Eclipse && st.Charge >= prev.Charge:
+			t.Fatalf("battery charging in eclipse at %s: %.2f -> %.2f", elapsed, prev.Charge, st.Charge)
+		case !prev.Eclipse && st.Charge < prev.Charge:
+			t.Fatalf("battery draining in sunlight at %s: %.2f -> %.2f", elapsed, prev.Charge, st.Charge)
+		case prev.Eclipse && st.Temperature >= prev.Temperature:
+			t.Fatalf("warming up in eclipse at %s: %.2f -> %.2f", elapsed, prev.Temperature, st.Temperature)
+		case !prev.Eclipse && st.Temperature <= prev.Temperature:
+			t.Fatalf("cooling down in sunlight at %s: %.2f -> %.2f", elapsed, prev.Temperature, st.Temperature)
+		}
+
+		// In view means close, and the signal is strongest when the station
+		// is closest. Out of view there is only the noise floor.
+		switch {
+		case !st.StationInView:
+			if st.Signal != DefaultConfig.GroundStation.NoiseFloor {
+				t.Fatalf("signal %.1f dB out of view at %s", st.Signal, elapsed)
+			}
+		case st.Range > 2500:
+			t.Fatalf("in view at %s with range %.0f km and signal %.1f dB", elapsed, st.Range, st.Signal)
+		case prev.StationInView && (st.Range < prev.Range) != (st.Signal > prev.Signal):
+			t.Fatalf("signal %.2f -> %.2f dB while range %.0f -> %.0f km", prev.Signal, st.Signal, prev.Range, st.Range)
+		}
+		if st.StationInView {
+			inView++
+		}
+		prev = st
+	}
+
+	if eclipses != 3 {
+		t.Errorf("entered eclipse %d times in 3 orbits", eclipses)
+	}
+	if fraction := eclipsed.Seconds() / (3 * period).Seconds(); fraction < 0.3 || fraction > 0.4 {
+		t.Errorf("in eclipse %.0f%% of the time", fraction*100)
+	}
+	if inView == 0 {
+		t.Error("ground station never in view")
+	}
+}
+
+func TestSimulatorValuesAreRepeatable(t *testing.T) {
+	c, err := Parse([]byte(`
+seed: 3
+noise: { temperature: 0.5, signal: 1, battery: 5 }
+power: { initialCharge: 100 }
+`))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	run := func() []map[string]float64 {
+		s, err := NewSimulator(c, epoch)
+		if err != nil {
+			t.Fatalf("NewSimulator() error = %v", err)
+		}
+		var out []map[string]float64
+		for range 50 {
+			s.Advance(time.Minute)
+			out = append(out, s.Values())
+		}
+		return out
+	}
+
+	first := run()
+	if !reflect.DeepEqual(first, run()) {
+		t.Error("same seed gave different values")
+	}
+	for _, v := range first {
+		if v["battery"] > 100 || v["battery_soc"] > 100 {
+			t.Fatalf("battery above 100%%: %v", v)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{"bad yaml", "orbit: ["},
+		{"perigee too low", "orbit: { altitude: 300, eccentricity: 0.1 }"},
+		{"raan and ltan", "orbit: { raan: 10, ltan: 6 }"},
+		{"inclination", "orbit: { inclination: 200 }"},
+		{"time scale", "timeScale: 0"},
+		{"charge", "power: { initialCharge: 120 }"},
+		{"voltages", "power: { busVoltageEmpty: 34 }"},
+		{"unknown noise", "noise: { humidity: 1 }"},
+		{"latitude", "groundStation: { latitude: 91 }"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.yaml)); err == nil {
+				t.Error("Parse() error = nil")
+			}
+		})
+	}
+
+	if _, err := Load("../../../dictionary/simulation.yaml"); err != nil {
+		t.Errorf("Load(example) error = %v", err)
+	}
+}
@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+// ErrAnomalyNotFound is returned when no anomaly event has the requested ID
+var ErrAnomalyNotFound = errors.New("anomaly event not found")
+
+const anomalyEventColumns = `
+      event_id, apid, parameter, field,
+      severity, limit_set, bound, threshold,
+      state, start_time, COALESCE(end_time, 0),
+      last_value, last_timestamp, peak_value, sample_count,
+      COALESCE(ack_operator, ''), COALESCE(ack_note, ''), COALESCE(ack_time, 0)`
+
+func (s *sqlDataPacketStore) FetchAnomaliesByTimeRange(
+	ctx context.Context,
+	startTS, endTS uint64,
+) ([]*turiondatapacket.Anomaly, error) {
+	q := `
+    SELECT ` + anomalyEventColumns + `
+      FROM public.anomaly_events
+     WHERE start_time <= $2
+       AND (end_time IS NULL OR end_time >= $1)
+     ORDER BY start_time ASC`
+	rows, err := s.db.QueryContext(ctx, q, startTS, endTS)
+	if err != nil {
+		return nil, fmt.Errorf("query anomalies: %w", err)
+	}
+	return scanAnomalyEvents(rows)
+}
+
+func (s *sqlDataPacketStore) FetchOpenAnomalies(ctx context.Context) ([]*turiondatapacket.Anomaly, error) {
+	q := `
+    SELECT ` + anomalyEventColumns + `
+      FROM public.anomaly_events
+     WHERE state <> 'RESOLVED'
+     ORDER BY start_time ASC`
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("query open anomalies: %w", err)
+	}
+	return scanAnomalyEvents(rows)
+}
+
+func (s *sqlDataPacketStore) UpsertAnomaly(
+	ctx context.Context,
+	a *turiondatapacket.Anomaly,
+) (*turiondatapacket.Anomaly, error) {
+	// The gateway and the API both upsert events, so the same update may
+	// arrive twice or out of order. sample_count only grows while an event is
+	// open, so it tells which update is newer.
+	stmt := `
+    INSERT INTO public.anomaly_events
+      (event_id, apid, parameter, field,
+       severity, limit_set, bound, threshold,
+       state, start_time, end_time,
+       last_value, last_timestamp, peak_value, sample_count)
+    VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NULLIF($11::BIGINT, 0), $12, $13, $14, $15)
+    ON CONFLICT (event_id) DO UPDATE SET
+      severity       = EXCLUDED.severity,
+      limit_set      = EXCLUDED.limit_set,
+      threshold      = EXCLUDED.threshold,
+      state          = CASE
+                         WHEN anomaly_events.state = 'ACKNOWLEDGED' AND EXCLUDED.state = 'OPEN'
+                         THEN anomaly_events.state
+                         ELSE EXCLUDED.state
+                       END,
+      end_time       = EXCLUDED.end_time,
+      last_value     = EXCLUDED.last_value,
+      last_timestamp = EXCLUDED.last_timestamp,
+      peak_value     = EXCLUDED.peak_value,
+      sample_count   = EXCLUDED.sample_count,
+      updated_at     = NOW()
+    WHERE anomaly_events.state <> 'RESOLVED'
+      AND EXCLUDED.sample_count >= anomaly_events.sample_count
+    RETURNING ` + anomalyEventColumns
+	rows, err := s.db.QueryContext(ctx, stmt,
+		a.EventId,
+		a.Apid,
+		a.Parameter,
+		a.Field.String(),
+		a.Severity.String(),
+		a.LimitSet,
+		a.Bound.String(),
+		a.Threshold,
+		a.State.String(),
+		a.StartTime,
+		a.EndTime,
+		a.Value,
+		a.Timestamp,
+		a.PeakValue,
+		a.SampleCount,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("upsert anomaly event: %w", err)
+	}
+
+	// No row when the update was ignored
+	events, err := scanAnomalyEvents(rows)
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+	return events[0], nil
+}
+
+func (s *sqlDataPacketStore) AcknowledgeAnomaly(
+	ctx context.Context,
+	eventID, operator, note string,
+) (*turiondatapacket.Anomaly, error) {
+	// A resolved event can still be acknowledged, it just stays resolved
+	q := `
+    UPDATE public.anomaly_events
+       SET state        = CASE WHEN state = 'OPEN' THEN 'ACKNOWLEDGED' ELSE state END,
+           ack_operator = $2,
+           ack_note     = $3,
+           ack_time     = EXTRACT(EPOCH FROM NOW())::BIGINT,
+           updated_at   = NOW()
+     WHERE event_id = $1
+    RETURNING ` + anomalyEventColumns
+	rows, err := s.db.QueryContext(ctx, q, eventID, operator, note)
+	if err != nil {
+		return nil, fmt.Errorf("acknowledge anomaly: %w", err)
+	}
+
+	events, err := scanAnomalyEvents(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrAnomalyNotFound, eventID)
+	}
+	return events[0], nil
+}
+
+// scanAnomalyEvents reads rows selected with anomalyEventColumns and closes
+// them
+func scanAnomalyEvents(rows *sql.Rows) ([]*turiondatapacket.Anomaly, error) {
+	defer rows.Close()
+
+	var out []*turiondatapacket.Anomaly
+	for rows.Next() {
+		var field, severity, bound, state string
+		a := &turiondatapacket.Anomaly{}
+
+		if err := rows.Scan(
+			&a.EventId, &a.Apid, &a.Parameter, &field,
+			&severity, &a.LimitSet, &bound, &a.Threshold,
+			&state, &a.StartTime, &a.EndTime,
+			&a.Value, &a.Timestamp, &a.PeakValue, &a.SampleCount,
+			&a.AckOperator, &a.AckNote, &a.AckTime,
+		); err != nil {
+			return nil, fmt.Errorf("scan anomaly row: %w", err)
+		}
+
+		a.Field = turiondatapacket.Field(turiondatapacket.Field_value[field])
+		a.Severity = turiondatapacket.Severity(turiondatapacket.Severity_value[severity])
+		a.Bound = turiondatapacket.LimitBound(turiondatapacket.LimitBound_value[bound])
+		a.State = turiondatapacket.AnomalyState(turiondatapacket.AnomalyState_value[state])
+		out = append(out, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate anomaly rows: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,194 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+// ErrCommandNotFound is returned when no command has the requested ID
+var ErrCommandNotFound = errors.New("command not found")
+
+const commandColumns = `
+      id, name, apid, opcode, seq_count, arguments, state,
+      COALESCE(error, ''), created_at, sent_at, acknowledged_at, ack_status`
+
+func (s *sqlDataPacketStore) InsertCommand(
+	ctx context.Context,
+	cmd *turiondatapacket.Command,
+	packet []byte,
+) error {
+	args, err := json.Marshal(cmd.Arguments)
+	if err != nil {
+		return fmt.Errorf("marshal command arguments: %w", err)
+	}
+
+	const q = `
+    INSERT INTO public.commands
+      (name, apid, opcode, seq_count, arguments, packet, state)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)
+    RETURNING id, created_at`
+	err = s.db.QueryRowContext(ctx, q,
+		cmd.Name, cmd.APID, cmd.Opcode, cmd.SeqCount, args, packet, cmd.State,
+	).Scan(&cmd.ID, &cmd.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("insert command: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlDataPacketStore) UpdateCommandState(
+	ctx context.Context,
+	id int64,
+	state, reason string,
+) (*turiondatapacket.Command, error) {
+	// The acknowledgement can come down before the uplink write returns, it
+	// wins over sent
+	q := `
+    UPDATE public.commands
+       SET state   = CASE WHEN state = 'queued' THEN $2 ELSE state END,
+           error   = CASE WHEN state = 'queued' THEN NULLIF($3, '') ELSE error END,
+           sent_at = CASE WHEN $2 = 'sent' THEN NOW() ELSE sent_at END
+     WHERE id = $1
+    RETURNING ` + commandColumns
+	rows, err := s.db.QueryContext(ctx, q, id, state, reason)
+	if err != nil {
+		return nil, fmt.Errorf("update command state: %w", err)
+	}
+
+	commands, err := scanCommands(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrCommandNotFound, id)
+	}
+	return commands[0], nil
+}
+
+func (s *sqlDataPacketStore) FetchLastSeqCounts(ctx context.Context) (map[uint16]uint16, error) {
+	// Counts roll over, so the latest command's count is the one to carry on
+	// from rather than the highest
+	const q = `
+    SELECT DISTINCT ON (apid) apid, seq_count
+      FROM public.commands
+     ORDER BY apid, id DESC`
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("query last sequence counts: %w", err)
+	}
+	defer rows.Close()
+
+	last := map[uint16]uint16{}
+	for rows.Next() {
+		var apid, seqCount uint16
+		if err := rows.Scan(&apid, &seqCount); err != nil {
+			return nil, fmt.Errorf("scan sequence count row: %w", err)
+		}
+		last[apid] = seqCount
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate sequence count rows: %w", err)
+	}
+	return last, nil
+}
+
+func (s *sqlDataPacketStore) ExpireCommands(
+	ctx context.Context,
+	before time.Time,
+	reason string,
+) ([]*turiondatapacket.Command, error) {
+	// A command is left queued if the API stopped before writing it to the
+	// uplink or recording that it did
+	q := `
+    UPDATE public.commands
+       SET state = 'failed',
+           error = $2
+     WHERE (state = 'sent' AND sent_at < $1)
+        OR (state = 'queued' AND created_at < $1)
+    RETURNING ` + commandColumns
+	rows, err := s.db.QueryContext(ctx, q, before, reason)
+	if err != nil {
+		return nil, fmt.Errorf("expire commands: %w", err)
+	}
+	return scanCommands(rows)
+}
+
+func (s *sqlDataPacketStore) FetchCommands(ctx context.Context, limit int) ([]*turiondatapacket.Command, error) {
+	q := `
+    SELECT ` + commandColumns + `
+      FROM public.commands
+     ORDER BY id DESC
+     LIMIT $1`
+	rows, err := s.db.QueryContext(ctx, q, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query commands: %w", err)
+	}
+	return scanCommands(rows)
+}
+
+func (s *sqlDataPacketStore) FetchCommand(ctx context.Context, id int64) (*turiondatapacket.Command, error) {
+	q := `
+    SELECT ` + commandColumns + `
+      FROM public.commands
+     WHERE id = $1`
+	rows, err := s.db.QueryContext(ctx, q, id)
+	if err != nil {
+		return nil, fmt.Errorf("query command: %w", err)
+	}
+
+	commands, err := scanCommands(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrCommandNotFound, id)
+	}
+	return commands[0], nil
+}
+
+// scanCommands reads rows selected with commandColumns and closes them
+func scanCommands(rows *sql.Rows) ([]*turiondatapacket.Command, error) {
+	defer rows.Close()
+
+	var out []*turiondatapacket.Command
+	for rows.Next() {
+		var args []byte
+		var sentAt, acknowledgedAt sql.NullTime
+		var ackStatus sql.NullInt16
+		c := &turiondatapacket.Command{}
+
+		if err := rows.Scan(
+			&c.ID, &c.Name, &c.APID, &c.Opcode, &c.SeqCount, &args, &c.State,
+			&c.Error, &c.CreatedAt, &sentAt, &acknowledgedAt, &ackStatus,
+		); err != nil {
+			return nil, fmt.Errorf("scan command row: %w", err)
+		}
+
+		if err := json.Unmarshal(args, &c.Arguments); err != nil {
+			return nil, fmt.Errorf("unmarshal command arguments: %w", err)
+		}
+		c.SentAt = nullTimePtr(sentAt)
+		c.AcknowledgedAt = nullTimePtr(acknowledgedAt)
+		if ackStatus.Valid {
+			status := uint8(ackStatus.Int16)
+			c.AckStatus = &status
+		}
+		out = append(out, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate command rows: %w", err)
+	}
+	return out, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
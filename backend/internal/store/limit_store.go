@@ -0,0 +1,91 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+// ErrNoActiveLimitSet is returned by the SQL limit source when no row in
+// limit_sets is marked active
+var ErrNoActiveLimitSet = errors.New("no active limit set")
+
+// sqlLimitSource is a Postgres implementation of turiondatapacket.LimitSource
+type sqlLimitSource struct {
+	db *sql.DB
+}
+
+// NewSQLLimitSource loads the active limit set from the limit_sets and
+// limit_definitions tables
+func NewSQLLimitSource(db *sql.DB) turiondatapacket.LimitSource {
+	return &sqlLimitSource{db: db}
+}
+
+func (s *sqlLimitSource) LoadLimits(ctx context.Context) (*turiondatapacket.LimitSet, error) {
+	const q = `
+      SELECT s.name, d.parameter, d.apid,
+             d.yellow_low, d.yellow_high, d.red_low, d.red_high
+        FROM public.limit_sets s
+        LEFT JOIN public.limit_definitions d ON d.set_name = s.name
+       WHERE s.active
+       ORDER BY d.id ASC`
+	rows, err := s.db.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("query limit definitions: %w", err)
+	}
+	defer rows.Close()
+
+	var set *turiondatapacket.LimitSet
+	for rows.Next() {
+		var name string
+		var parameter sql.NullString
+		var apid sql.NullInt32
+		var yellowLow, yellowHigh, redLow, redHigh sql.NullFloat64
+
+		if err := rows.Scan(
+			&name, &parameter, &apid,
+			&yellowLow, &yellowHigh, &redLow, &redHigh,
+		); err != nil {
+			return nil, fmt.Errorf("scan limit definition row: %w", err)
+		}
+
+		if set == nil {
+			set = &turiondatapacket.LimitSet{Name: name}
+		}
+
+		// The active set has no definitions yet
+		if !parameter.Valid {
+			continue
+		}
+
+		limit := turiondatapacket.Limit{
+			Parameter:  parameter.String,
+			YellowLow:  nullFloat64Ptr(yellowLow),
+			YellowHigh: nullFloat64Ptr(yellowHigh),
+			RedLow:     nullFloat64Ptr(redLow),
+			RedHigh:    nullFloat64Ptr(redHigh),
+		}
+		if apid.Valid {
+			a := uint16(apid.Int32)
+			limit.APID = &a
+		}
+		set.Limits = append(set.Limits, limit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate limit definition rows: %w", err)
+	}
+
+	if set == nil {
+		return nil, ErrNoActiveLimitSet
+	}
+	return set, set.Validate()
+}
+
+func nullFloat64Ptr(f sql.NullFloat64) *float64 {
+	if !f.Valid {
+		return nil
+	}
+	return &f.Float64
+}
@@ -0,0 +1,207 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+var ErrUnknownParameter = errors.New("unknown payload parameter")
+
+// checkParameters makes sure only column names end up in the queries below
+func checkParameters(parameters []string) error {
+	if len(parameters) == 0 {
+		return errors.New("no payload parameters asked for")
+	}
+	for _, p := range parameters {
+		if !slices.Contains(turiondatapacket.PayloadParameters, p) {
+			return fmt.Errorf("%w: %q", ErrUnknownParameter, p)
+		}
+	}
+	return nil
+}
+
+// PercentileKey names percentile p (0 to 100) in ParameterStats.Percentiles
+func PercentileKey(p float64) string {
+	return "p" + strconv.FormatFloat(p, 'f', -1, 64)
+}
+
+func (s *sqlDataPacketStore) FetchPayloadBuckets(
+	ctx context.Context,
+	startTS, endTS, bucketSeconds uint64,
+	parameters []string,
+	percentiles []float64,
+) ([]turiondatapacket.PayloadBucket, error) {
+	if err := checkParameters(parameters); err != nil {
+		return nil, err
+	}
+	if bucketSeconds == 0 {
+		return nil, errors.New("bucket size must be positive")
+	}
+
+	args := []any{startTS, endTS, bucketSeconds}
+	for _, p := range percentiles {
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("percentile %v is not from 0 to 100", p)
+		}
+		args = append(args, p/100)
+	}
+
+	columns := []string{"(ts / $3) * $3", "COUNT(*)"}
+	for _, param := range parameters {
+		columns = append(columns, fmt.Sprintf(
+			"MIN(%[1]s), MAX(%[1]s), AVG(%[1]s), STDDEV_POP(%[1]s)", param,
+		))
+		for i := range percentiles {
+			columns = append(columns, fmt.Sprintf(
+				"PERCENTILE_CONT($%d::FLOAT8) WITHIN GROUP (ORDER BY %s)", 4+i, param,
+			))
+		}
+	}
+	query := `
+      SELECT ` + strings.Join(columns, ",\n             ") + `
+        FROM turion_data_packets
+       WHERE ts BETWEEN $1 AND $2
+       GROUP BY 1
+       ORDER BY 1 ASC`
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query payload buckets: %w", err)
+	}
+	defer rows.Close()
+
+	out := []turiondatapacket.PayloadBucket{}
+	for rows.Next() {
+		b := turiondatapacket.PayloadBucket{
+			Parameters: make(map[string]turiondatapacket.ParameterStats, len(parameters)),
+		}
+		stats := make([]turiondatapacket.ParameterStats, len(parameters))
+		values := make([][]float64, len(parameters))
+
+		dest := []any{&b.Start, &b.Count}
+		for i := range parameters {
+			values[i] = make([]float64, len(percentiles))
+			dest = append(dest, &stats[i].Min, &stats[i].Max, &stats[i].Avg, &stats[i].Stddev)
+			for j := range percentiles {
+				dest = append(dest, &values[i][j])
+			}
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("scan payload bucket row: %w", err)
+		}
+
+		for i, param := range parameters {
+			if len(percentiles) > 0 {
+				stats[i].Percentiles = make(map[string]float64, len(percentiles))
+				for j, p := range percentiles {
+					stats[i].Percentiles[PercentileKey(p)] = values[i][j]
+				}
+			}
+			b.Parameters[param] = stats[i]
+		}
+		out = append(out, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate payload bucket rows: %w", err)
+	}
+	return out, nil
+}
+
+func (s *sqlDataPacketStore) FetchPayloadSeries(
+	ctx context.Context,
+	startTS, endTS uint64,
+	parameters []string,
+	maxPoints int,
+) (*turiondatapacket.PayloadSeries, error) {
+	if err := checkParameters(parameters); err != nil {
+		return nil, err
+	}
+	if maxPoints < 2 {
+		return nil, errors.New("max points must be at least 2")
+	}
+
+	series := &turiondatapacket.PayloadSeries{
+		Parameters: make(map[string]turiondatapacket.ParameterSeries, len(parameters)),
+	}
+	const countQuery = `
+      SELECT COUNT(*)
+        FROM turion_data_packets
+       WHERE ts BETWEEN $1 AND $2`
+	if err := s.db.QueryRowContext(ctx, countQuery, startTS, endTS).Scan(&series.Total); err != nil {
+		return nil, fmt.Errorf("count payload series: %w", err)
+	}
+
+	if series.Total <= maxPoints {
+		query := `
+      SELECT ts, ` + strings.Join(parameters, ", ") + `
+        FROM turion_data_packets
+       WHERE ts BETWEEN $1 AND $2
+       ORDER BY ts ASC, id ASC`
+		return series, s.scanPayloadSeries(ctx, series, parameters, query, startTS, endTS)
+	}
+
+	// Too many packets to hold them all. The lowest and highest value of each
+	// bucket keep the spikes and the envelope of the plot, the rest is left
+	// to the caller's downsampling.
+	width := (endTS-startTS)/uint64(maxPoints/2) + 1
+	for _, param := range parameters {
+		query := fmt.Sprintf(`
+      SELECT ts, %[1]s
+        FROM (
+          SELECT ts, id, %[1]s,
+                 ROW_NUMBER() OVER (PARTITION BY (ts - $1) / $3 ORDER BY %[1]s ASC, ts, id) AS lowest,
+                 ROW_NUMBER() OVER (PARTITION BY (ts - $1) / $3 ORDER BY %[1]s DESC, ts, id) AS highest
+            FROM turion_data_packets
+           WHERE ts BETWEEN $1 AND $2
+        ) b
+       WHERE lowest = 1 OR highest = 1
+       ORDER BY ts ASC, id ASC`, param)
+		if err := s.scanPayloadSeries(ctx, series, []string{param}, query, startTS, endTS, width); err != nil {
+			return nil, err
+		}
+	}
+	return series, nil
+}
+
+// scanPayloadSeries runs query, which selects ts and then parameters, and
+// appends its rows to series
+func (s *sqlDataPacketStore) scanPayloadSeries(
+	ctx context.Context,
+	series *turiondatapacket.PayloadSeries,
+	parameters []string,
+	query string,
+	args ...any,
+) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("query payload series: %w", err)
+	}
+	defer rows.Close()
+
+	var ts uint64
+	values := make([]float64, len(parameters))
+	dest := []any{&ts}
+	for i := range values {
+		dest = append(dest, &values[i])
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("scan payload series row: %w", err)
+		}
+		for i, param := range parameters {
+			p := series.Parameters[param]
+			p.Timestamps = append(p.Timestamps, ts)
+			p.Values = append(p.Values, values[i])
+			series.Parameters[param] = p
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterate payload series rows: %w", err)
+	}
+	return nil
+}
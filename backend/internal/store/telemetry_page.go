@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"turion-takehome/internal/turiondatapacket"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// TelemetryCursor is the position of a packet in the (ts, id) order pages
+// are read in
+type TelemetryCursor struct {
+	Timestamp uint64
+	ID        int64
+}
+
+// String encodes the cursor for clients, who should treat it as opaque
+func (c TelemetryCursor) String() string {
+	return base64.RawURLEncoding.EncodeToString(
+		[]byte(strconv.FormatUint(c.Timestamp, 10) + ":" + strconv.FormatInt(c.ID, 10)),
+	)
+}
+
+// ParseTelemetryCursor decodes a cursor made by TelemetryCursor.String
+func ParseTelemetryCursor(s string) (TelemetryCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return TelemetryCursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	ts, id, ok := strings.Cut(string(b), ":")
+	if !ok {
+		return TelemetryCursor{}, ErrInvalidCursor
+	}
+	var c TelemetryCursor
+	if c.Timestamp, err = strconv.ParseUint(ts, 10, 64); err != nil {
+		return TelemetryCursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	if c.ID, err = strconv.ParseInt(id, 10, 64); err != nil {
+		return TelemetryCursor{}, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return c, nil
+}
+
+// TelemetryQuery selects a page of packets for FetchTelemetryPage
+type TelemetryQuery struct {
+	// Packets whose ts lies in [StartTS, EndTS], inclusive
+	StartTS, EndTS uint64
+	// Limit is the most packets on the page
+	Limit int
+	// Descending pages newest first
+	Descending bool
+	// After continues from the last packet of the previous page, nil for the
+	// first page
+	After *TelemetryCursor
+	// Optional filters
+	SubsystemID *uint16
+	APID        *uint16
+}
+
+// TelemetryPage is a page of packets, and where the next page starts if
+// there is one
+type TelemetryPage struct {
+	Packets []*turiondatapacket.TurionDataPacket
+	Next    *TelemetryCursor
+}
+
+func (s *sqlDataPacketStore) FetchTelemetryPage(
+	ctx context.Context,
+	q TelemetryQuery,
+) (*TelemetryPage, error) {
+	if q.Limit <= 0 {
+		return nil, errors.New("limit must be positive")
+	}
+
+	// Both come from here, never from the request
+	direction, after := "ASC", ">"
+	if q.Descending {
+		direction, after = "DESC", "<"
+	}
+	query := fmt.Sprintf(`
+      SELECT id, packet_id, packet_seq_ctrl, packet_length,
+             ts, subsystem_id,
+             temperature, battery, altitude, signal
+        FROM turion_data_packets
+       WHERE ts BETWEEN $1 AND $2
+         AND ($3::BIGINT IS NULL OR (ts, id) %[2]s ($3, $4))
+         AND ($5::INTEGER IS NULL OR subsystem_id = $5)
+         AND ($6::INTEGER IS NULL OR apid = $6)
+       ORDER BY ts %[1]s, id %[1]s
+       LIMIT $7`, direction, after)
+
+	var afterTS *uint64
+	var afterID *int64
+	if q.After != nil {
+		afterTS, afterID = &q.After.Timestamp, &q.After.ID
+	}
+
+	// One more than asked for tells whether there is a next page
+	rows, err := s.db.QueryContext(ctx, query,
+		q.StartTS, q.EndTS, afterTS, afterID, q.SubsystemID, q.APID, q.Limit+1,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("query telemetry page: %w", err)
+	}
+	defer rows.Close()
+
+	page := &TelemetryPage{Packets: []*turiondatapacket.TurionDataPacket{}}
+	var last TelemetryCursor
+	for rows.Next() {
+		if len(page.Packets) == q.Limit {
+			page.Next = &last
+			break
+		}
+
+		pkt := &turiondatapacket.TurionDataPacket{}
+		ph, sh, tp := &pkt.CCSDSPrimaryHeader, &pkt.CCSDSSecondaryHeader, &pkt.TelemetryPayload
+		if err := rows.Scan(
+			&last.ID,
+			&ph.PacketID, &ph.PacketSeqCtrl, &ph.PacketLength,
+			&sh.Timestamp, &sh.SubsystemID,
+			&tp.Temperature, &tp.Battery, &tp.Altitude, &tp.Signal,
+		); err != nil {
+			return nil, fmt.Errorf("scan packet row: %w", err)
+		}
+		last.Timestamp = sh.Timestamp
+		page.Packets = append(page.Packets, pkt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating packet rows: %w", err)
+	}
+	return page, nil
+}
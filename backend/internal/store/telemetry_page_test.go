@@ -0,0 +1,43 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestTelemetryCursorRoundTrip(t *testing.T) {
+	for _, c := range []TelemetryCursor{
+		{},
+		{Timestamp: 1700000000, ID: 42},
+		{Timestamp: math.MaxUint64, ID: math.MaxInt64},
+	} {
+		got, err := ParseTelemetryCursor(c.String())
+		if err != nil {
+			t.Fatalf("ParseTelemetryCursor(%q) error = %v", c.String(), err)
+		}
+		if got != c {
+			t.Errorf("ParseTelemetryCursor(%q) = %+v; want %+v", c.String(), got, c)
+		}
+	}
+}
+
+func TestParseTelemetryCursorInvalid(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	for _, s := range []string{
+		"",
+		"not base64!",
+		encode("1700000000"),
+		encode("1700000000:"),
+		encode("-1:42"),
+		encode("1700000000:id"),
+	} {
+		if _, err := ParseTelemetryCursor(s); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("ParseTelemetryCursor(%q) error = %v; want ErrInvalidCursor", s, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 	"turion-takehome/internal/turiondatapacket"
 
 	"go.uber.org/zap"
@@ -17,10 +18,64 @@ type DataPacketStore interface {
 	// lies in [startTS, endTS], inclusive.
 	FetchByTimeRange(ctx context.Context, startTS, endTS uint64) ([]*turiondatapacket.TurionDataPacket, error)
 
-	// FetchAnomaliesByTimeRange returns all anomalies whose Timestamp (ts)
-	// lies in [startTS, endTS], inclusive.
+	// FetchTelemetryPage returns up to q.Limit packets matching q, in (ts, id)
+	// order, starting after q.After, along with the cursor of the next page.
+	FetchTelemetryPage(ctx context.Context, q TelemetryQuery) (*TelemetryPage, error)
+
+	// FetchAnomaliesByTimeRange returns all anomaly events that were open at
+	// any point in [startTS, endTS], inclusive.
 	FetchAnomaliesByTimeRange(ctx context.Context, startTS, endTS uint64) ([]*turiondatapacket.Anomaly, error)
 
+	// FetchOpenAnomalies returns every anomaly event that has not resolved yet,
+	// acknowledged or not, oldest first.
+	FetchOpenAnomalies(ctx context.Context) ([]*turiondatapacket.Anomaly, error)
+
+	// UpsertAnomaly creates or updates the anomaly event a.EventId and returns
+	// the event as stored. Updates that are older than the stored event are
+	// ignored and return nil, and an acknowledged event stays acknowledged
+	// until it resolves.
+	UpsertAnomaly(ctx context.Context, a *turiondatapacket.Anomaly) (*turiondatapacket.Anomaly, error)
+
+	// AcknowledgeAnomaly records that operator has seen the anomaly event with
+	// eventID and returns the updated event. Returns ErrAnomalyNotFound if
+	// there is no such event.
+	AcknowledgeAnomaly(ctx context.Context, eventID, operator, note string) (*turiondatapacket.Anomaly, error)
+
+	// FetchLinkEventsByTimeRange returns the sequence count gaps, duplicates,
+	// reordering and rollovers whose Timestamp lies in [startTS, endTS],
+	// inclusive. If apid is not nil only events for that APID are returned.
+	FetchLinkEventsByTimeRange(ctx context.Context, startTS, endTS uint64, apid *uint16) ([]*turiondatapacket.LinkEvent, error)
+
+	// FetchCorruptedPacketCounts returns, per APID, how many packets failed
+	// their packet error control check with an arrival time in [startTS,
+	// endTS], inclusive. APIDs without any are left out.
+	FetchCorruptedPacketCounts(ctx context.Context, startTS, endTS uint64) ([]turiondatapacket.CorruptedPacketCount, error)
+
+	// InsertCommand adds cmd to the command history along with the packet it
+	// is sent as, and sets its ID and creation time.
+	InsertCommand(ctx context.Context, cmd *turiondatapacket.Command, packet []byte) error
+
+	// UpdateCommandState moves command id out of the queued state, to state
+	// with reason when it failed, and returns the updated command. A command
+	// already acknowledged keeps its state. Returns ErrCommandNotFound if there
+	// is no such command.
+	UpdateCommandState(ctx context.Context, id int64, state, reason string) (*turiondatapacket.Command, error)
+
+	// FetchLastSeqCounts returns the sequence count of the most recent
+	// command on each APID.
+	FetchLastSeqCounts(ctx context.Context) (map[uint16]uint16, error)
+
+	// ExpireCommands fails, with reason, every command sent before before that
+	// was never acknowledged and every command queued before before that was
+	// never sent, and returns them.
+	ExpireCommands(ctx context.Context, before time.Time, reason string) ([]*turiondatapacket.Command, error)
+
+	// FetchCommands returns the limit most recent commands, newest first.
+	FetchCommands(ctx context.Context, limit int) ([]*turiondatapacket.Command, error)
+
+	// FetchCommand returns command id, or ErrCommandNotFound if there is none.
+	FetchCommand(ctx context.Context, id int64) (*turiondatapacket.Command, error)
+
 	// Returns the single most‐recent packet (highest ts), or ErrNoRows if none.
 	FetchLatest(ctx context.Context) (turiondatapacket.TurionDataPacket, error)
 
@@ -30,6 +85,21 @@ type DataPacketStore interface {
 	// FetchPayloadStatsByTimeRange computes min, max, avg of each TelemetryPayload
 	// column for packets whose ts is between startTS and endTS.
 	FetchPayloadStatsByTimeRange(ctx context.Context, startTS, endTS uint64) (turiondatapacket.PayloadStats, error)
+
+	// FetchPayloadBuckets splits [startTS, endTS] into buckets of
+	// bucketSeconds, aligned on multiples of it, and summarises parameters
+	// over the packets in each, with percentiles (0 to 100) of each.
+	// Buckets without packets are left out. Returns ErrUnknownParameter for
+	// names not in turiondatapacket.PayloadParameters.
+	FetchPayloadBuckets(ctx context.Context, startTS, endTS, bucketSeconds uint64, parameters []string, percentiles []float64) ([]turiondatapacket.PayloadBucket, error)
+
+	// FetchPayloadSeries samples parameters over the packets whose ts lies in
+	// [startTS, endTS], returning at most maxPoints samples of each. When the
+	// range has no more than maxPoints packets every one is returned,
+	// otherwise the range is split into maxPoints/2 buckets and only the
+	// lowest and highest value of each bucket are. Returns
+	// ErrUnknownParameter for names not in turiondatapacket.PayloadParameters.
+	FetchPayloadSeries(ctx context.Context, startTS, endTS uint64, parameters []string, maxPoints int) (*turiondatapacket.PayloadSeries, error)
 }
 
 // sqlDataPacketStore is a Postgres implementation of DataPacketStore.
@@ -117,46 +187,70 @@ func (s *sqlDataPacketStore) FetchByTimeRange(
 	return packets, nil
 }
 
-func (s *sqlDataPacketStore) FetchAnomaliesByTimeRange(
+func (s *sqlDataPacketStore) FetchLinkEventsByTimeRange(
 	ctx context.Context,
 	startTS, endTS uint64,
-) ([]*turiondatapacket.Anomaly, error) {
+	apid *uint16,
+) ([]*turiondatapacket.LinkEvent, error) {
 	const q = `
-      SELECT field, value, timestamp
-        FROM public.anomalies
+      SELECT apid, kind, expected_seq_count, received_seq_count, missing_count, timestamp
+        FROM public.link_events
        WHERE timestamp BETWEEN $1 AND $2
-       ORDER BY timestamp ASC`
-	rows, err := s.db.QueryContext(ctx, q, startTS, endTS)
+         AND ($3::INTEGER IS NULL OR apid = $3)
+       ORDER BY timestamp ASC, id ASC`
+	rows, err := s.db.QueryContext(ctx, q, startTS, endTS, apid)
 	if err != nil {
-		return nil, fmt.Errorf("query anomalies: %w", err)
+		return nil, fmt.Errorf("query link events: %w", err)
 	}
 	defer rows.Close()
 
-	var out []*turiondatapacket.Anomaly
+	var out []*turiondatapacket.LinkEvent
 	for rows.Next() {
-		var fieldName string
-		var value float32
-		var ts uint64
-
-		if err := rows.Scan(&fieldName, &value, &ts); err != nil {
-			return nil, fmt.Errorf("scan anomaly row: %w", err)
+		var kind string
+		e := &turiondatapacket.LinkEvent{}
+		if err := rows.Scan(
+			&e.Apid, &kind,
+			&e.ExpectedSeqCount, &e.ReceivedSeqCount, &e.MissingCount,
+			&e.Timestamp,
+		); err != nil {
+			return nil, fmt.Errorf("scan link event row: %w", err)
 		}
+		e.Kind = turiondatapacket.LinkEventKind(turiondatapacket.LinkEventKind_value[kind])
+		out = append(out, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate link event rows: %w", err)
+	}
+	return out, nil
+}
 
-		enumVal, found := turiondatapacket.Field_value[fieldName]
-		f := turiondatapacket.Field_FIELD_UNSPECIFIED
-		if found {
-			f = turiondatapacket.Field(enumVal)
-		}
+func (s *sqlDataPacketStore) FetchCorruptedPacketCounts(
+	ctx context.Context,
+	startTS, endTS uint64,
+) ([]turiondatapacket.CorruptedPacketCount, error) {
+	const q = `
+      SELECT apid, COUNT(*), MAX(timestamp)
+        FROM public.link_events
+       WHERE kind = 'CHECKSUM'
+         AND timestamp BETWEEN $1 AND $2
+       GROUP BY apid
+       ORDER BY apid ASC`
+	rows, err := s.db.QueryContext(ctx, q, startTS, endTS)
+	if err != nil {
+		return nil, fmt.Errorf("query corrupted packet counts: %w", err)
+	}
+	defer rows.Close()
 
-		a := &turiondatapacket.Anomaly{
-			Field:     f,
-			Value:     value,
-			Timestamp: ts,
+	out := []turiondatapacket.CorruptedPacketCount{}
+	for rows.Next() {
+		var c turiondatapacket.CorruptedPacketCount
+		if err := rows.Scan(&c.APID, &c.Count, &c.LastSeen); err != nil {
+			return nil, fmt.Errorf("scan corrupted packet count row: %w", err)
 		}
-		out = append(out, a)
+		out = append(out, c)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("iterate anomaly rows: %w", err)
+		return nil, fmt.Errorf("iterate corrupted packet count rows: %w", err)
 	}
 	return out, nil
 }
@@ -166,8 +260,9 @@ func (s *sqlDataPacketStore) Insert(ctx context.Context, pkt *turiondatapacket.T
       INSERT INTO turion_data_packets
         (packet_id, packet_seq_ctrl, packet_length,
          ts, subsystem_id,
-         temperature, battery, altitude, signal)
-      VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)`
+         temperature, battery, altitude, signal,
+         apid, seq_count)
+      VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)`
 	_, err := s.db.ExecContext(ctx, stmt,
 		pkt.CCSDSPrimaryHeader.PacketID,
 		pkt.CCSDSPrimaryHeader.PacketSeqCtrl,
@@ -178,6 +273,8 @@ func (s *sqlDataPacketStore) Insert(ctx context.Context, pkt *turiondatapacket.T
 		pkt.TelemetryPayload.Battery,
 		pkt.TelemetryPayload.Altitude,
 		pkt.TelemetryPayload.Signal,
+		pkt.CCSDSPrimaryHeader.APID(),
+		pkt.CCSDSPrimaryHeader.SequenceCount(),
 	)
 	if err != nil {
 		return fmt.Errorf("insert packet: %w", err)
@@ -77,7 +77,170 @@ func (Field) EnumDescriptor() ([]byte, []int) {
 	return file_protobufs_anomaly_proto_rawDescGZIP(), []int{0}
 }
 
-// Anomaly represents a single out-of-range reading.
+// How far out of limits a reading is.
+type Severity int32
+
+const (
+	// must start at 0
+	Severity_SEVERITY_UNSPECIFIED Severity = 0
+	// Outside the yellow (warning) limits but inside the red limits
+	Severity_YELLOW Severity = 1
+	// Outside the red (critical) limits
+	Severity_RED Severity = 2
+)
+
+// Enum value maps for Severity.
+var (
+	Severity_name = map[int32]string{
+		0: "SEVERITY_UNSPECIFIED",
+		1: "YELLOW",
+		2: "RED",
+	}
+	Severity_value = map[string]int32{
+		"SEVERITY_UNSPECIFIED": 0,
+		"YELLOW":               1,
+		"RED":                  2,
+	}
+)
+
+func (x Severity) Enum() *Severity {
+	p := new(Severity)
+	*p = x
+	return p
+}
+
+func (x Severity) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Severity) Descriptor() protoreflect.EnumDescriptor {
+	return file_protobufs_anomaly_proto_enumTypes[1].Descriptor()
+}
+
+func (Severity) Type() protoreflect.EnumType {
+	return &file_protobufs_anomaly_proto_enumTypes[1]
+}
+
+func (x Severity) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Severity.Descriptor instead.
+func (Severity) EnumDescriptor() ([]byte, []int) {
+	return file_protobufs_anomaly_proto_rawDescGZIP(), []int{1}
+}
+
+// Which side of its limits a reading fell on.
+type LimitBound int32
+
+const (
+	// must start at 0
+	LimitBound_LIMIT_BOUND_UNSPECIFIED LimitBound = 0
+	LimitBound_LOW                     LimitBound = 1
+	LimitBound_HIGH                    LimitBound = 2
+)
+
+// Enum value maps for LimitBound.
+var (
+	LimitBound_name = map[int32]string{
+		0: "LIMIT_BOUND_UNSPECIFIED",
+		1: "LOW",
+		2: "HIGH",
+	}
+	LimitBound_value = map[string]int32{
+		"LIMIT_BOUND_UNSPECIFIED": 0,
+		"LOW":                     1,
+		"HIGH":                    2,
+	}
+)
+
+func (x LimitBound) Enum() *LimitBound {
+	p := new(LimitBound)
+	*p = x
+	return p
+}
+
+func (x LimitBound) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LimitBound) Descriptor() protoreflect.EnumDescriptor {
+	return file_protobufs_anomaly_proto_enumTypes[2].Descriptor()
+}
+
+func (LimitBound) Type() protoreflect.EnumType {
+	return &file_protobufs_anomaly_proto_enumTypes[2]
+}
+
+func (x LimitBound) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LimitBound.Descriptor instead.
+func (LimitBound) EnumDescriptor() ([]byte, []int) {
+	return file_protobufs_anomaly_proto_rawDescGZIP(), []int{2}
+}
+
+// Where an anomaly event is in its lifecycle.
+type AnomalyState int32
+
+const (
+	// must start at 0
+	AnomalyState_ANOMALY_STATE_UNSPECIFIED AnomalyState = 0
+	// The parameter is still out of limits and nobody has looked at it
+	AnomalyState_OPEN AnomalyState = 1
+	// An operator has seen the anomaly. It stays acknowledged until resolved
+	AnomalyState_ACKNOWLEDGED AnomalyState = 2
+	// The parameter is back within limits
+	AnomalyState_RESOLVED AnomalyState = 3
+)
+
+// Enum value maps for AnomalyState.
+var (
+	AnomalyState_name = map[int32]string{
+		0: "ANOMALY_STATE_UNSPECIFIED",
+		1: "OPEN",
+		2: "ACKNOWLEDGED",
+		3: "RESOLVED",
+	}
+	AnomalyState_value = map[string]int32{
+		"ANOMALY_STATE_UNSPECIFIED": 0,
+		"OPEN":                      1,
+		"ACKNOWLEDGED":              2,
+		"RESOLVED":                  3,
+	}
+)
+
+func (x AnomalyState) Enum() *AnomalyState {
+	p := new(AnomalyState)
+	*p = x
+	return p
+}
+
+func (x AnomalyState) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (AnomalyState) Descriptor() protoreflect.EnumDescriptor {
+	return file_protobufs_anomaly_proto_enumTypes[3].Descriptor()
+}
+
+func (AnomalyState) Type() protoreflect.EnumType {
+	return &file_protobufs_anomaly_proto_enumTypes[3]
+}
+
+func (x AnomalyState) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use AnomalyState.Descriptor instead.
+func (AnomalyState) EnumDescriptor() ([]byte, []int) {
+	return file_protobufs_anomaly_proto_rawDescGZIP(), []int{3}
+}
+
+// Anomaly represents a period during which a parameter was out of limits.
+// value and timestamp are the most recent out-of-limit reading. Every update
+// to the same event carries the same event_id.
 type Anomaly struct {
 	state         protoimpl.MessageState
 	sizeCache     protoimpl.SizeCache
@@ -86,6 +249,29 @@ type Anomaly struct {
 	Value     float32 `protobuf:"fixed32,1,opt,name=value,proto3" json:"value,omitempty"`
 	Timestamp uint64  `protobuf:"varint,2,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
 	Field     Field   `protobuf:"varint,3,opt,name=field,proto3,enum=turiondatapacket.Field" json:"field,omitempty"`
+	// Name of the telemetry dictionary parameter. Field is only set when the
+	// parameter is one of the original main bus fields.
+	Parameter string   `protobuf:"bytes,4,opt,name=parameter,proto3" json:"parameter,omitempty"`
+	Apid      uint32   `protobuf:"varint,5,opt,name=apid,proto3" json:"apid,omitempty"`
+	Severity  Severity `protobuf:"varint,6,opt,name=severity,proto3,enum=turiondatapacket.Severity" json:"severity,omitempty"`
+	// Name of the limit set, e.g. the mission phase, that was in effect
+	LimitSet string     `protobuf:"bytes,7,opt,name=limit_set,json=limitSet,proto3" json:"limit_set,omitempty"`
+	Bound    LimitBound `protobuf:"varint,8,opt,name=bound,proto3,enum=turiondatapacket.LimitBound" json:"bound,omitempty"`
+	// The limit that was crossed
+	Threshold float32      `protobuf:"fixed32,9,opt,name=threshold,proto3" json:"threshold,omitempty"`
+	EventId   string       `protobuf:"bytes,10,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	State     AnomalyState `protobuf:"varint,11,opt,name=state,proto3,enum=turiondatapacket.AnomalyState" json:"state,omitempty"`
+	// Timestamps of the first out-of-limit reading and of the first reading back
+	// within limits. end_time is 0 until the event is resolved
+	StartTime uint64 `protobuf:"varint,12,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	EndTime   uint64 `protobuf:"varint,13,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	// The reading furthest past the crossed bound
+	PeakValue   float32 `protobuf:"fixed32,14,opt,name=peak_value,json=peakValue,proto3" json:"peak_value,omitempty"`
+	SampleCount uint32  `protobuf:"varint,15,opt,name=sample_count,json=sampleCount,proto3" json:"sample_count,omitempty"`
+	AckOperator string  `protobuf:"bytes,16,opt,name=ack_operator,json=ackOperator,proto3" json:"ack_operator,omitempty"`
+	AckNote     string  `protobuf:"bytes,17,opt,name=ack_note,json=ackNote,proto3" json:"ack_note,omitempty"`
+	// Unix seconds
+	AckTime uint64 `protobuf:"varint,18,opt,name=ack_time,json=ackTime,proto3" json:"ack_time,omitempty"`
 }
 
 func (x *Anomaly) Reset() {
@@ -139,28 +325,179 @@ func (x *Anomaly) GetField() Field {
 	return Field_FIELD_UNSPECIFIED
 }
 
+func (x *Anomaly) GetParameter() string {
+	if x != nil {
+		return x.Parameter
+	}
+	return ""
+}
+
+func (x *Anomaly) GetApid() uint32 {
+	if x != nil {
+		return x.Apid
+	}
+	return 0
+}
+
+func (x *Anomaly) GetSeverity() Severity {
+	if x != nil {
+		return x.Severity
+	}
+	return Severity_SEVERITY_UNSPECIFIED
+}
+
+func (x *Anomaly) GetLimitSet() string {
+	if x != nil {
+		return x.LimitSet
+	}
+	return ""
+}
+
+func (x *Anomaly) GetBound() LimitBound {
+	if x != nil {
+		return x.Bound
+	}
+	return LimitBound_LIMIT_BOUND_UNSPECIFIED
+}
+
+func (x *Anomaly) GetThreshold() float32 {
+	if x != nil {
+		return x.Threshold
+	}
+	return 0
+}
+
+func (x *Anomaly) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *Anomaly) GetState() AnomalyState {
+	if x != nil {
+		return x.State
+	}
+	return AnomalyState_ANOMALY_STATE_UNSPECIFIED
+}
+
+func (x *Anomaly) GetStartTime() uint64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+func (x *Anomaly) GetEndTime() uint64 {
+	if x != nil {
+		return x.EndTime
+	}
+	return 0
+}
+
+func (x *Anomaly) GetPeakValue() float32 {
+	if x != nil {
+		return x.PeakValue
+	}
+	return 0
+}
+
+func (x *Anomaly) GetSampleCount() uint32 {
+	if x != nil {
+		return x.SampleCount
+	}
+	return 0
+}
+
+func (x *Anomaly) GetAckOperator() string {
+	if x != nil {
+		return x.AckOperator
+	}
+	return ""
+}
+
+func (x *Anomaly) GetAckNote() string {
+	if x != nil {
+		return x.AckNote
+	}
+	return ""
+}
+
+func (x *Anomaly) GetAckTime() uint64 {
+	if x != nil {
+		return x.AckTime
+	}
+	return 0
+}
+
 var File_protobufs_anomaly_proto protoreflect.FileDescriptor
 
 var file_protobufs_anomaly_proto_rawDesc = []byte{
 	0x0a, 0x17, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x73, 0x2f, 0x61, 0x6e, 0x6f, 0x6d,
 	0x61, 0x6c, 0x79, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x10, 0x74, 0x75, 0x72, 0x69, 0x6f,
-	0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x22, 0x6c, 0x0a, 0x07, 0x41,
-	0x6e, 0x6f, 0x6d, 0x61, 0x6c, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18,
-	0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1c, 0x0a, 0x09,
-	0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04, 0x52,
-	0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x2d, 0x0a, 0x05, 0x66, 0x69,
-	0x65, 0x6c, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x74, 0x75, 0x72, 0x69,
-	0x6f, 0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x2e, 0x46, 0x69, 0x65,
-	0x6c, 0x64, 0x52, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x2a, 0x56, 0x0a, 0x05, 0x46, 0x69, 0x65,
+	0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x22, 0xeb, 0x04, 0x0a, 0x07,
+	0x41, 0x6e, 0x6f, 0x6d, 0x61, 0x6c, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x02, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x1c, 0x0a,
+	0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x18, 0x02, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x12, 0x2d, 0x0a, 0x05, 0x66,
+	0x69, 0x65, 0x6c, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x17, 0x2e, 0x74, 0x75, 0x72,
+	0x69, 0x6f, 0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x2e, 0x46, 0x69,
+	0x65, 0x6c, 0x64, 0x52, 0x05, 0x66, 0x69, 0x65, 0x6c, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x70, 0x61,
+	0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70,
+	0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x12, 0x12, 0x0a, 0x04, 0x61, 0x70, 0x69, 0x64,
+	0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x61, 0x70, 0x69, 0x64, 0x12, 0x36, 0x0a, 0x08,
+	0x73, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1a,
+	0x2e, 0x74, 0x75, 0x72, 0x69, 0x6f, 0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65,
+	0x74, 0x2e, 0x53, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x79, 0x52, 0x08, 0x73, 0x65, 0x76, 0x65,
+	0x72, 0x69, 0x74, 0x79, 0x12, 0x1b, 0x0a, 0x09, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x5f, 0x73, 0x65,
+	0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x6c, 0x69, 0x6d, 0x69, 0x74, 0x53, 0x65,
+	0x74, 0x12, 0x32, 0x0a, 0x05, 0x62, 0x6f, 0x75, 0x6e, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0e,
+	0x32, 0x1c, 0x2e, 0x74, 0x75, 0x72, 0x69, 0x6f, 0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63,
+	0x6b, 0x65, 0x74, 0x2e, 0x4c, 0x69, 0x6d, 0x69, 0x74, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x52, 0x05,
+	0x62, 0x6f, 0x75, 0x6e, 0x64, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f,
+	0x6c, 0x64, 0x18, 0x09, 0x20, 0x01, 0x28, 0x02, 0x52, 0x09, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68,
+	0x6f, 0x6c, 0x64, 0x12, 0x19, 0x0a, 0x08, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18,
+	0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x34,
+	0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1e, 0x2e,
+	0x74, 0x75, 0x72, 0x69, 0x6f, 0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74,
+	0x2e, 0x41, 0x6e, 0x6f, 0x6d, 0x61, 0x6c, 0x79, 0x53, 0x74, 0x61, 0x74, 0x65, 0x52, 0x05, 0x73,
+	0x74, 0x61, 0x74, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x74, 0x61, 0x72, 0x74, 0x5f, 0x74, 0x69,
+	0x6d, 0x65, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x73, 0x74, 0x61, 0x72, 0x74, 0x54,
+	0x69, 0x6d, 0x65, 0x12, 0x19, 0x0a, 0x08, 0x65, 0x6e, 0x64, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18,
+	0x0d, 0x20, 0x01, 0x28, 0x04, 0x52, 0x07, 0x65, 0x6e, 0x64, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x1d,
+	0x0a, 0x0a, 0x70, 0x65, 0x61, 0x6b, 0x5f, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x0e, 0x20, 0x01,
+	0x28, 0x02, 0x52, 0x09, 0x70, 0x65, 0x61, 0x6b, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12, 0x21, 0x0a,
+	0x0c, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x0f, 0x20,
+	0x01, 0x28, 0x0d, 0x52, 0x0b, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74,
+	0x12, 0x21, 0x0a, 0x0c, 0x61, 0x63, 0x6b, 0x5f, 0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x6f, 0x72,
+	0x18, 0x10, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x61, 0x63, 0x6b, 0x4f, 0x70, 0x65, 0x72, 0x61,
+	0x74, 0x6f, 0x72, 0x12, 0x19, 0x0a, 0x08, 0x61, 0x63, 0x6b, 0x5f, 0x6e, 0x6f, 0x74, 0x65, 0x18,
+	0x11, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x61, 0x63, 0x6b, 0x4e, 0x6f, 0x74, 0x65, 0x12, 0x19,
+	0x0a, 0x08, 0x61, 0x63, 0x6b, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x12, 0x20, 0x01, 0x28, 0x04,
+	0x52, 0x07, 0x61, 0x63, 0x6b, 0x54, 0x69, 0x6d, 0x65, 0x2a, 0x56, 0x0a, 0x05, 0x46, 0x69, 0x65,
 	0x6c, 0x64, 0x12, 0x15, 0x0a, 0x11, 0x46, 0x49, 0x45, 0x4c, 0x44, 0x5f, 0x55, 0x4e, 0x53, 0x50,
 	0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0f, 0x0a, 0x0b, 0x54, 0x45, 0x4d,
 	0x50, 0x45, 0x52, 0x41, 0x54, 0x55, 0x52, 0x45, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x42, 0x41,
 	0x54, 0x54, 0x45, 0x52, 0x59, 0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x41, 0x4c, 0x54, 0x49, 0x54,
 	0x55, 0x44, 0x45, 0x10, 0x03, 0x12, 0x0a, 0x0a, 0x06, 0x53, 0x49, 0x47, 0x4e, 0x41, 0x4c, 0x10,
-	0x04, 0x42, 0x34, 0x5a, 0x32, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x2f, 0x69, 0x6e, 0x74,
-	0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x74, 0x75, 0x72, 0x69, 0x6f, 0x6e, 0x64, 0x61, 0x74, 0x61,
-	0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x3b, 0x74, 0x75, 0x72, 0x69, 0x6f, 0x6e, 0x64, 0x61, 0x74,
-	0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x04, 0x2a, 0x39, 0x0a, 0x08, 0x53, 0x65, 0x76, 0x65, 0x72, 0x69, 0x74, 0x79, 0x12, 0x18, 0x0a,
+	0x14, 0x53, 0x45, 0x56, 0x45, 0x52, 0x49, 0x54, 0x59, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43,
+	0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x0a, 0x0a, 0x06, 0x59, 0x45, 0x4c, 0x4c, 0x4f,
+	0x57, 0x10, 0x01, 0x12, 0x07, 0x0a, 0x03, 0x52, 0x45, 0x44, 0x10, 0x02, 0x2a, 0x3c, 0x0a, 0x0a,
+	0x4c, 0x69, 0x6d, 0x69, 0x74, 0x42, 0x6f, 0x75, 0x6e, 0x64, 0x12, 0x1b, 0x0a, 0x17, 0x4c, 0x49,
+	0x4d, 0x49, 0x54, 0x5f, 0x42, 0x4f, 0x55, 0x4e, 0x44, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43,
+	0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x4c, 0x4f, 0x57, 0x10, 0x01,
+	0x12, 0x08, 0x0a, 0x04, 0x48, 0x49, 0x47, 0x48, 0x10, 0x02, 0x2a, 0x57, 0x0a, 0x0c, 0x41, 0x6e,
+	0x6f, 0x6d, 0x61, 0x6c, 0x79, 0x53, 0x74, 0x61, 0x74, 0x65, 0x12, 0x1d, 0x0a, 0x19, 0x41, 0x4e,
+	0x4f, 0x4d, 0x41, 0x4c, 0x59, 0x5f, 0x53, 0x54, 0x41, 0x54, 0x45, 0x5f, 0x55, 0x4e, 0x53, 0x50,
+	0x45, 0x43, 0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x08, 0x0a, 0x04, 0x4f, 0x50, 0x45,
+	0x4e, 0x10, 0x01, 0x12, 0x10, 0x0a, 0x0c, 0x41, 0x43, 0x4b, 0x4e, 0x4f, 0x57, 0x4c, 0x45, 0x44,
+	0x47, 0x45, 0x44, 0x10, 0x02, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x45, 0x53, 0x4f, 0x4c, 0x56, 0x45,
+	0x44, 0x10, 0x03, 0x42, 0x34, 0x5a, 0x32, 0x62, 0x61, 0x63, 0x6b, 0x65, 0x6e, 0x64, 0x2f, 0x69,
+	0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x74, 0x75, 0x72, 0x69, 0x6f, 0x6e, 0x64, 0x61,
+	0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x3b, 0x74, 0x75, 0x72, 0x69, 0x6f, 0x6e, 0x64,
+	0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
 }
 
 var (
@@ -175,19 +512,25 @@ func file_protobufs_anomaly_proto_rawDescGZIP() []byte {
 	return file_protobufs_anomaly_proto_rawDescData
 }
 
-var file_protobufs_anomaly_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_protobufs_anomaly_proto_enumTypes = make([]protoimpl.EnumInfo, 4)
 var file_protobufs_anomaly_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
 var file_protobufs_anomaly_proto_goTypes = []any{
-	(Field)(0),      // 0: turiondatapacket.Field
-	(*Anomaly)(nil), // 1: turiondatapacket.Anomaly
+	(Field)(0),        // 0: turiondatapacket.Field
+	(Severity)(0),     // 1: turiondatapacket.Severity
+	(LimitBound)(0),   // 2: turiondatapacket.LimitBound
+	(AnomalyState)(0), // 3: turiondatapacket.AnomalyState
+	(*Anomaly)(nil),   // 4: turiondatapacket.Anomaly
 }
 var file_protobufs_anomaly_proto_depIdxs = []int32{
 	0, // 0: turiondatapacket.Anomaly.field:type_name -> turiondatapacket.Field
-	1, // [1:1] is the sub-list for method output_type
-	1, // [1:1] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	1, // 1: turiondatapacket.Anomaly.severity:type_name -> turiondatapacket.Severity
+	2, // 2: turiondatapacket.Anomaly.bound:type_name -> turiondatapacket.LimitBound
+	3, // 3: turiondatapacket.Anomaly.state:type_name -> turiondatapacket.AnomalyState
+	4, // [4:4] is the sub-list for method output_type
+	4, // [4:4] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_protobufs_anomaly_proto_init() }
@@ -200,7 +543,7 @@ func file_protobufs_anomaly_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_protobufs_anomaly_proto_rawDesc,
-			NumEnums:      1,
+			NumEnums:      4,
 			NumMessages:   1,
 			NumExtensions: 0,
 			NumServices:   0,
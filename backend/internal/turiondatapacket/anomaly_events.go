@@ -0,0 +1,156 @@
+package turiondatapacket
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// AnomalyTracker groups the per-packet anomalies found by a LimitChecker into
+// anomaly events, so a parameter that stays out of limits for an hour is one
+// event instead of one anomaly per packet. It is safe for concurrent use.
+type AnomalyTracker struct {
+	mu   sync.Mutex
+	open map[anomalyKey]*Anomaly
+}
+
+type anomalyKey struct {
+	apid      uint32
+	parameter string
+}
+
+func NewAnomalyTracker() *AnomalyTracker {
+	return &AnomalyTracker{open: map[anomalyKey]*Anomaly{}}
+}
+
+// Update folds the anomalies detected in p into the open events and returns
+// every event that changed:
+//   - a new OPEN event for each parameter that just went out of limits
+//   - the event again whenever its peak or severity gets worse
+//   - a RESOLVED event for each parameter of p that is back within limits
+//
+// The returned anomalies are copies and can be handed to other goroutines.
+func (t *AnomalyTracker) Update(p Packet, anomalies []Anomaly) []*Anomaly {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	apid := uint32(p.PrimaryHeader().APID())
+	var changed []*Anomaly
+
+	outOfLimits := map[string]bool{}
+	for i := range anomalies {
+		a := &anomalies[i]
+		outOfLimits[a.Parameter] = true
+		key := anomalyKey{apid: apid, parameter: a.Parameter}
+
+		event, ok := t.open[key]
+		if ok && event.Bound != a.Bound {
+			// Went straight from one side of its limits to the other. Treat
+			// that as two separate events.
+			changed = append(changed, t.resolve(key, a.Timestamp))
+			ok = false
+		}
+
+		if !ok {
+			event = proto.Clone(a).(*Anomaly)
+			event.Apid = apid
+			event.EventId = newEventID()
+			event.State = AnomalyState_OPEN
+			event.StartTime = a.Timestamp
+			event.PeakValue = a.Value
+			event.SampleCount = 1
+			t.open[key] = event
+			changed = append(changed, proto.Clone(event).(*Anomaly))
+			continue
+		}
+
+		event.Value = a.Value
+		event.Timestamp = a.Timestamp
+		event.SampleCount++
+
+		worse := false
+		if a.Severity > event.Severity {
+			event.Severity = a.Severity
+			event.Threshold = a.Threshold
+			event.LimitSet = a.LimitSet
+			worse = true
+		}
+		if (a.Bound == LimitBound_HIGH && a.Value > event.PeakValue) ||
+			(a.Bound == LimitBound_LOW && a.Value < event.PeakValue) {
+			event.PeakValue = a.Value
+			worse = true
+		}
+
+		if worse {
+			changed = append(changed, proto.Clone(event).(*Anomaly))
+		}
+	}
+
+	pp, ok := p.(Parameters)
+	if !ok {
+		return changed
+	}
+
+	for _, v := range pp.Values() {
+		key := anomalyKey{apid: apid, parameter: v.Name}
+		if _, open := t.open[key]; open && !outOfLimits[v.Name] {
+			changed = append(changed, t.resolve(key, p.Timestamp()))
+		}
+	}
+
+	return changed
+}
+
+// Restore carries on the events that were still open when the tracker was
+// last stopped, typically the unresolved rows of anomaly_events, so a restart
+// neither opens a second event for a parameter that is still out of limits
+// nor leaves the old one open forever. It replaces any open event for the same
+// APID and parameter.
+//
+// Only one event can be open per parameter. If events holds several, the one
+// that started last is kept and the others are returned RESOLVED at the time
+// it started, ready to be written back.
+func (t *AnomalyTracker) Restore(events []*Anomaly) []*Anomaly {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var resolved []*Anomaly
+	for _, e := range events {
+		if e.State == AnomalyState_RESOLVED {
+			continue
+		}
+
+		key := anomalyKey{apid: e.Apid, parameter: e.Parameter}
+		event := proto.Clone(e).(*Anomaly)
+		if older, ok := t.open[key]; ok {
+			if older.StartTime > event.StartTime {
+				older, event = event, older
+			}
+			older.State = AnomalyState_RESOLVED
+			older.EndTime = event.StartTime
+			resolved = append(resolved, older)
+		}
+		t.open[key] = event
+	}
+
+	return resolved
+}
+
+// resolve closes the open event for key and returns a copy of it
+func (t *AnomalyTracker) resolve(key anomalyKey, ts uint64) *Anomaly {
+	event := t.open[key]
+	delete(t.open, key)
+
+	event.State = AnomalyState_RESOLVED
+	event.EndTime = ts
+	return event
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	// crypto/rand.Read never returns an error
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,104 @@
+package turiondatapacket
+
+import "testing"
+
+func TestAnomalyTrackerLifecycle(t *testing.T) {
+	tracker := NewAnomalyTracker()
+
+	type change struct {
+		state    AnomalyState
+		severity Severity
+		peak     float32
+	}
+
+	steps := []struct {
+		name    string
+		battery float32
+		want    []change
+	}{
+		{name: "nominal", battery: 80},
+		{name: "goes yellow", battery: 55, want: []change{{AnomalyState_OPEN, Severity_YELLOW, 55}}},
+		{name: "recovers a little", battery: 58},
+		{name: "goes red", battery: 35, want: []change{{AnomalyState_OPEN, Severity_RED, 35}}},
+		{name: "gets worse", battery: 30, want: []change{{AnomalyState_OPEN, Severity_RED, 30}}},
+		{name: "stays bad", battery: 32},
+		{name: "back within limits", battery: 75, want: []change{{AnomalyState_RESOLVED, Severity_RED, 30}}},
+		{name: "nominal again", battery: 80},
+	}
+
+	var eventID string
+	for i, step := range steps {
+		pkt := makePacket(25, step.battery, 525, -50, uint64(1700000000+i))
+		got := tracker.Update(&pkt, defaultLimitChecker.DetectAnomalies(&pkt))
+
+		if len(got) != len(step.want) {
+			t.Fatalf("%s: Update() returned %d events; want %d", step.name, len(got), len(step.want))
+		}
+		for j, w := range step.want {
+			e := got[j]
+			if e.State != w.state || e.Severity != w.severity || e.PeakValue != w.peak {
+				t.Errorf("%s: event = %v %v peak %v; want %v %v peak %v",
+					step.name, e.State, e.Severity, e.PeakValue, w.state, w.severity, w.peak)
+			}
+			if eventID == "" {
+				eventID = e.EventId
+			}
+			if e.EventId != eventID {
+				t.Errorf("%s: EventId = %q; want %q", step.name, e.EventId, eventID)
+			}
+			if e.StartTime != 1700000001 {
+				t.Errorf("%s: StartTime = %d; want %d", step.name, e.StartTime, 1700000001)
+			}
+		}
+	}
+
+	if got := tracker.Update(&TurionDataPacket{}, nil); len(got) != 0 {
+		t.Errorf("Update() after resolve = %v; want no events", got)
+	}
+}
+
+func TestAnomalyTrackerRestore(t *testing.T) {
+	before := NewAnomalyTracker()
+	pkt := makePacket(40, 35, 525, -50, 1700000000)
+	open := before.Update(&pkt, defaultLimitChecker.DetectAnomalies(&pkt))
+	if len(open) != 2 {
+		t.Fatalf("Update() returned %d events; want 2", len(open))
+	}
+	battery, temperature := open[0], open[1]
+	if battery.Parameter != "battery" {
+		battery, temperature = temperature, battery
+	}
+
+	// A leftover from an earlier run that never got resolved
+	stale := &Anomaly{
+		EventId:   "stale",
+		Apid:      battery.Apid,
+		Parameter: "battery",
+		State:     AnomalyState_ACKNOWLEDGED,
+		StartTime: 1600000000,
+	}
+
+	after := NewAnomalyTracker()
+	resolved := after.Restore([]*Anomaly{battery, stale, temperature})
+	if len(resolved) != 1 || resolved[0].EventId != "stale" ||
+		resolved[0].State != AnomalyState_RESOLVED || resolved[0].EndTime != battery.StartTime {
+		t.Fatalf("Restore() = %v; want the stale event resolved at %d", resolved, battery.StartTime)
+	}
+
+	// Temperature is still out of limits and battery is back
+	pkt = makePacket(40, 80, 525, -50, 1700000010)
+	got := after.Update(&pkt, defaultLimitChecker.DetectAnomalies(&pkt))
+	if len(got) != 1 {
+		t.Fatalf("Update() after Restore() returned %d events; want 1", len(got))
+	}
+	if e := got[0]; e.EventId != battery.EventId || e.State != AnomalyState_RESOLVED ||
+		e.StartTime != 1700000000 || e.EndTime != 1700000010 {
+		t.Errorf("Update() after Restore() = %v; want event %s resolved at %d", e, battery.EventId, 1700000010)
+	}
+
+	pkt = makePacket(30, 80, 525, -50, 1700000020)
+	got = after.Update(&pkt, defaultLimitChecker.DetectAnomalies(&pkt))
+	if len(got) != 1 || got[0].EventId != temperature.EventId || got[0].SampleCount != 2 {
+		t.Errorf("Update() = %v; want event %s resolved after 2 samples", got, temperature.EventId)
+	}
+}
@@ -0,0 +1,255 @@
+package turiondatapacket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Sizes of the fixed parts of a Turion data packet, in bytes
+const (
+	PRIMARY_HEADER_SIZE   = 6
+	SECONDARY_HEADER_SIZE = 10
+	// PEC_SIZE is the size of the optional packet error control field that
+	// ends a packet, a CRC-16-CCITT of everything before it
+	PEC_SIZE = 2
+)
+
+// Bit layout of the two packed primary header words. See CCSDS 133.0-B-2 §4.1.3
+const (
+	versionShift    = 13
+	versionMask     = 0x7
+	typeShift       = 12
+	typeMask        = 0x1
+	secHdrFlagShift = 11
+	secHdrFlagMask  = 0x1
+	apidMask        = 0x7FF
+	seqFlagsShift   = 14
+	seqFlagsMask    = 0x3
+	seqCountMask    = 0x3FFF
+)
+
+// IDLE_APID is reserved for idle packets, fill sent to keep a stream or frame
+// busy that carries no data. See CCSDS 133.0-B-2 §4.1.3.3.4.4
+const IDLE_APID = apidMask
+
+// MAX_SEQUENCE_COUNT is the largest value of the 14-bit sequence count before
+// it rolls over to zero
+const MAX_SEQUENCE_COUNT = seqCountMask
+
+// Errors returned while decoding a packet. Callers should use errors.Is, since
+// the returned errors are wrapped with the offending values.
+var (
+	ErrPacketTooShort           = errors.New("packet is shorter than the CCSDS primary header")
+	ErrUnsupportedVersion       = errors.New("unsupported CCSDS packet version")
+	ErrUnexpectedPacketType     = errors.New("unexpected CCSDS packet type")
+	ErrMissingSecondaryHeader   = errors.New("CCSDS secondary header flag is not set")
+	ErrUnexpectedAPID           = errors.New("unexpected APID")
+	ErrUnsupportedSequenceFlags = errors.New("unsupported CCSDS sequence flags")
+	ErrLengthMismatch           = errors.New("CCSDS packet length does not match bytes received")
+	ErrChecksum                 = errors.New("packet error control does not match")
+)
+
+// PrimaryHeaderFields is the decoded form of CCSDSPrimaryHeader
+type PrimaryHeaderFields struct {
+	Version             uint8  `json:"version"`
+	Type                uint8  `json:"type"`
+	SecondaryHeaderFlag bool   `json:"secondaryHeaderFlag"`
+	APID                uint16 `json:"apid"`
+	SequenceFlags       uint8  `json:"sequenceFlags"`
+	SequenceCount       uint16 `json:"sequenceCount"`
+}
+
+// NewCCSDSPrimaryHeader packs the decoded fields into a primary header.
+// dataLength is the number of bytes following the primary header.
+func NewCCSDSPrimaryHeader(f PrimaryHeaderFields, dataLength int) CCSDSPrimaryHeader {
+	var secHdrFlag uint16
+	if f.SecondaryHeaderFlag {
+		secHdrFlag = 1
+	}
+
+	return CCSDSPrimaryHeader{
+		PacketID: uint16(f.Version&versionMask)<<versionShift |
+			uint16(f.Type&typeMask)<<typeShift |
+			secHdrFlag<<secHdrFlagShift |
+			f.APID&apidMask,
+		PacketSeqCtrl: uint16(f.SequenceFlags&seqFlagsMask)<<seqFlagsShift |
+			f.SequenceCount&seqCountMask,
+		PacketLength: uint16(dataLength - 1),
+	}
+}
+
+func (h CCSDSPrimaryHeader) Version() uint8 {
+	return uint8(h.PacketID >> versionShift & versionMask)
+}
+
+func (h CCSDSPrimaryHeader) Type() uint8 {
+	return uint8(h.PacketID >> typeShift & typeMask)
+}
+
+func (h CCSDSPrimaryHeader) HasSecondaryHeader() bool {
+	return h.PacketID>>secHdrFlagShift&secHdrFlagMask == 1
+}
+
+func (h CCSDSPrimaryHeader) APID() uint16 {
+	return h.PacketID & apidMask
+}
+
+func (h CCSDSPrimaryHeader) SequenceFlags() uint8 {
+	return uint8(h.PacketSeqCtrl >> seqFlagsShift & seqFlagsMask)
+}
+
+func (h CCSDSPrimaryHeader) SequenceCount() uint16 {
+	return h.PacketSeqCtrl & seqCountMask
+}
+
+// DataLength is the number of bytes following the primary header. The packet
+// length field stores this value minus one.
+func (h CCSDSPrimaryHeader) DataLength() int {
+	return int(h.PacketLength) + 1
+}
+
+// TotalLength is the number of bytes in the whole packet, primary header included
+func (h CCSDSPrimaryHeader) TotalLength() int {
+	return PRIMARY_HEADER_SIZE + h.DataLength()
+}
+
+// Fields returns the decoded bitfields of the header
+func (h CCSDSPrimaryHeader) Fields() PrimaryHeaderFields {
+	return PrimaryHeaderFields{
+		Version:             h.Version(),
+		Type:                h.Type(),
+		SecondaryHeaderFlag: h.HasSecondaryHeader(),
+		APID:                h.APID(),
+		SequenceFlags:       h.SequenceFlags(),
+		SequenceCount:       h.SequenceCount(),
+	}
+}
+
+// MarshalJSON keeps the raw header words for existing clients and adds the
+// decoded bitfields alongside them.
+func (h CCSDSPrimaryHeader) MarshalJSON() ([]byte, error) {
+	type raw CCSDSPrimaryHeader
+	return json.Marshal(struct {
+		raw
+		PrimaryHeaderFields
+	}{raw(h), h.Fields()})
+}
+
+// DecodePrimaryHeader reads the primary header from the start of b and checks
+// that it is a version 1 (binary 000) packet whose length field matches len(b).
+func DecodePrimaryHeader(b []byte) (CCSDSPrimaryHeader, error) {
+	h, err := ParsePrimaryHeader(b)
+	if err != nil {
+		return h, err
+	}
+
+	if h.TotalLength() != len(b) {
+		return h, fmt.Errorf(
+			"%w: header declares %d bytes, received %d",
+			ErrLengthMismatch, h.TotalLength(), len(b),
+		)
+	}
+
+	return h, nil
+}
+
+// ParsePrimaryHeader reads the primary header from the start of b and checks
+// that it is a version 1 (binary 000) packet. Unlike DecodePrimaryHeader, b may
+// hold less or more than the whole packet, e.g. when cutting packets out of a
+// stream.
+func ParsePrimaryHeader(b []byte) (CCSDSPrimaryHeader, error) {
+	var h CCSDSPrimaryHeader
+	if len(b) < PRIMARY_HEADER_SIZE {
+		return h, fmt.Errorf("%w: got %d bytes", ErrPacketTooShort, len(b))
+	}
+
+	h.PacketID = binary.BigEndian.Uint16(b[0:2])
+	h.PacketSeqCtrl = binary.BigEndian.Uint16(b[2:4])
+	h.PacketLength = binary.BigEndian.Uint16(b[4:6])
+
+	if h.Version() != PACKET_VERSION {
+		return h, fmt.Errorf("%w: got %d, want %d", ErrUnsupportedVersion, h.Version(), PACKET_VERSION)
+	}
+
+	return h, nil
+}
+
+// AppendErrorControl appends the packet error control field to b, a whole
+// packet whose length field already counts it
+func AppendErrorControl(b []byte) []byte {
+	return binary.BigEndian.AppendUint16(b, CRC16CCITT(b))
+}
+
+// CheckErrorControl checks the packet error control field at the end of b and
+// returns the packet without it
+func CheckErrorControl(b []byte) ([]byte, error) {
+	if len(b) < PRIMARY_HEADER_SIZE+PEC_SIZE {
+		return nil, fmt.Errorf("%w: got %d bytes", ErrPacketTooShort, len(b))
+	}
+
+	body := b[:len(b)-PEC_SIZE]
+	want := binary.BigEndian.Uint16(b[len(body):])
+	if got := CRC16CCITT(body); got != want {
+		return nil, fmt.Errorf("%w: computed %#04x, packet has %#04x", ErrChecksum, got, want)
+	}
+	return body, nil
+}
+
+// DecodeTurionDataPacket validates the primary header of b and decodes the
+// secondary header and payload. Anything other than a standalone telemetry
+// packet on APID with a secondary header is rejected.
+func DecodeTurionDataPacket(b []byte) (*TurionDataPacket, error) {
+	h, err := DecodePrimaryHeader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.APID() != APID {
+		return nil, fmt.Errorf("%w: got %#x, want %#x", ErrUnexpectedAPID, h.APID(), APID)
+	}
+
+	return decodeTurionDataPacket(h, b)
+}
+
+// decodeTurionDataPacket is the PacketDecoder registered for APID
+func decodeTurionDataPacket(h CCSDSPrimaryHeader, b []byte) (*TurionDataPacket, error) {
+	if err := validateStandaloneTelemetry(h); err != nil {
+		return nil, err
+	}
+
+	wantLength := binary.Size(TurionDataPacket{})
+	if len(b) != wantLength {
+		return nil, fmt.Errorf(
+			"%w: Turion data packets are %d bytes, received %d",
+			ErrLengthMismatch, wantLength, len(b),
+		)
+	}
+
+	tdp := &TurionDataPacket{}
+	if err := binary.Read(bytes.NewReader(b), binary.BigEndian, tdp); err != nil {
+		return nil, err
+	}
+
+	return tdp, nil
+}
+
+// validateStandaloneTelemetry checks the header bits every telemetry packet we
+// decode must share: TM type, secondary header present and unsegmented.
+func validateStandaloneTelemetry(h CCSDSPrimaryHeader) error {
+	if h.Type() != PACKET_TYPE {
+		return fmt.Errorf("%w: got %d, want %d", ErrUnexpectedPacketType, h.Type(), PACKET_TYPE)
+	}
+
+	if !h.HasSecondaryHeader() {
+		return ErrMissingSecondaryHeader
+	}
+
+	if h.SequenceFlags() != SEQ_FLAGS {
+		return fmt.Errorf("%w: got %#b", ErrUnsupportedSequenceFlags, h.SequenceFlags())
+	}
+
+	return nil
+}
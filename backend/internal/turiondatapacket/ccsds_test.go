@@ -0,0 +1,139 @@
+package turiondatapacket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// helper to encode a packet with the given header fields
+func encodePacket(t *testing.T, fields PrimaryHeaderFields, dataLength int) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	pkt := makePacket(25, 85, 525, -50, 1700000000)
+	pkt.CCSDSPrimaryHeader = NewCCSDSPrimaryHeader(fields, dataLength)
+	if err := binary.Write(buf, binary.BigEndian, pkt); err != nil {
+		t.Fatalf("encoding packet: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func validFields() PrimaryHeaderFields {
+	return PrimaryHeaderFields{
+		Version:             PACKET_VERSION,
+		Type:                PACKET_TYPE,
+		SecondaryHeaderFlag: true,
+		APID:                APID,
+		SequenceFlags:       SEQ_FLAGS,
+		SequenceCount:       42,
+	}
+}
+
+func TestPrimaryHeaderRoundTrip(t *testing.T) {
+	want := PrimaryHeaderFields{
+		Version:             0,
+		Type:                1,
+		SecondaryHeaderFlag: true,
+		APID:                0x7FF,
+		SequenceFlags:       0x1,
+		SequenceCount:       MAX_SEQUENCE_COUNT,
+	}
+	h := NewCCSDSPrimaryHeader(want, 26)
+
+	if got := h.Fields(); got != want {
+		t.Errorf("Fields() = %+v; want %+v", got, want)
+	}
+	if h.PacketLength != 25 {
+		t.Errorf("PacketLength = %d; want 25", h.PacketLength)
+	}
+	if h.TotalLength() != 32 {
+		t.Errorf("TotalLength() = %d; want 32", h.TotalLength())
+	}
+}
+
+func TestDecodeTurionDataPacket(t *testing.T) {
+	dataLength := SECONDARY_HEADER_SIZE + binary.Size(TelemetryPayload{})
+	valid := encodePacket(t, validFields(), dataLength)
+
+	withFields := func(mod func(*PrimaryHeaderFields)) []byte {
+		f := validFields()
+		mod(&f)
+		return encodePacket(t, f, dataLength)
+	}
+
+	tests := []struct {
+		name    string
+		b       []byte
+		wantErr error
+	}{
+		{
+			name: "valid packet",
+			b:    valid,
+		},
+		{
+			name:    "shorter than primary header",
+			b:       valid[:4],
+			wantErr: ErrPacketTooShort,
+		},
+		{
+			name:    "truncated datagram",
+			b:       valid[:len(valid)-3],
+			wantErr: ErrLengthMismatch,
+		},
+		{
+			name:    "oversized datagram",
+			b:       append(append([]byte{}, valid...), 0xDE, 0xAD),
+			wantErr: ErrLengthMismatch,
+		},
+		{
+			name:    "header length disagrees with payload layout",
+			b:       encodePacket(t, validFields(), dataLength-4)[:dataLength-4+PRIMARY_HEADER_SIZE],
+			wantErr: ErrLengthMismatch,
+		},
+		{
+			name:    "wrong version",
+			b:       withFields(func(f *PrimaryHeaderFields) { f.Version = 1 }),
+			wantErr: ErrUnsupportedVersion,
+		},
+		{
+			name:    "telecommand packet type",
+			b:       withFields(func(f *PrimaryHeaderFields) { f.Type = 1 }),
+			wantErr: ErrUnexpectedPacketType,
+		},
+		{
+			name:    "no secondary header",
+			b:       withFields(func(f *PrimaryHeaderFields) { f.SecondaryHeaderFlag = false }),
+			wantErr: ErrMissingSecondaryHeader,
+		},
+		{
+			name:    "unknown APID",
+			b:       withFields(func(f *PrimaryHeaderFields) { f.APID = 0x42 }),
+			wantErr: ErrUnexpectedAPID,
+		},
+		{
+			name:    "segmented packet",
+			b:       withFields(func(f *PrimaryHeaderFields) { f.SequenceFlags = 0x1 }),
+			wantErr: ErrUnsupportedSequenceFlags,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeTurionDataPacket(tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeTurionDataPacket() error = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if got.CCSDSPrimaryHeader.SequenceCount() != 42 {
+				t.Errorf("SequenceCount() = %d; want 42", got.CCSDSPrimaryHeader.SequenceCount())
+			}
+			if got.TelemetryPayload.Battery != 85 {
+				t.Errorf("Battery = %v; want 85", got.TelemetryPayload.Battery)
+			}
+		})
+	}
+}
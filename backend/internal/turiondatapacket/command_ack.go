@@ -0,0 +1,151 @@
+package turiondatapacket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+const (
+	// COMMAND_ACK_APID is the telemetry APID the spacecraft acknowledges
+	// commands on
+	COMMAND_ACK_APID         = 0x10
+	COMMAND_ACK_SUBSYSTEM_ID = 0x0010
+)
+
+// Status the spacecraft acknowledges a command with. Anything other than
+// ACK_STATUS_OK fails the command.
+const (
+	ACK_STATUS_OK                = 0
+	ACK_STATUS_UNKNOWN_COMMAND   = 1
+	ACK_STATUS_INVALID_ARGUMENTS = 2
+	ACK_STATUS_CHECKSUM          = 3
+)
+
+var ackStatusText = map[uint8]string{
+	ACK_STATUS_OK:                "ok",
+	ACK_STATUS_UNKNOWN_COMMAND:   "rejected by spacecraft: unknown command",
+	ACK_STATUS_INVALID_ARGUMENTS: "rejected by spacecraft: invalid arguments",
+	ACK_STATUS_CHECKSUM:          "rejected by spacecraft: packet error control does not match",
+}
+
+// AckStatusText describes an acknowledgement status
+func AckStatusText(status uint8) string {
+	if s, ok := ackStatusText[status]; ok {
+		return s
+	}
+	return fmt.Sprintf("rejected by spacecraft: status %d", status)
+}
+
+// CommandAckPayload identifies the command being acknowledged by the APID and
+// sequence count it was sent with
+type CommandAckPayload struct {
+	CommandAPID     uint16 `json:"commandApid"`
+	CommandSeqCount uint16 `json:"commandSeqCount"`
+	Opcode          uint16 `json:"opcode"`
+	Status          uint8  `json:"status"`
+}
+
+// CommandAck is the telemetry packet the spacecraft sends for every command it
+// receives. It always ends with packet error control.
+type CommandAck struct {
+	CCSDSPrimaryHeader   CCSDSPrimaryHeader   `json:"ccsdsPrimaryHeader"`
+	CCSDSSecondaryHeader CCSDSSecondaryHeader `json:"ccsdsSecondaryHeader"`
+	CommandAckPayload    CommandAckPayload    `json:"commandAckPayload"`
+}
+
+// PrimaryHeader implements Packet
+func (a *CommandAck) PrimaryHeader() CCSDSPrimaryHeader {
+	return a.CCSDSPrimaryHeader
+}
+
+// Timestamp implements Packet
+func (a *CommandAck) Timestamp() uint64 {
+	return a.CCSDSSecondaryHeader.Timestamp
+}
+
+// Values implements Parameters so acknowledgements show up on live streams
+func (a *CommandAck) Values() []ParameterValue {
+	p := a.CommandAckPayload
+	return []ParameterValue{
+		{Name: "command_apid", Raw: float64(p.CommandAPID), Value: float64(p.CommandAPID)},
+		{Name: "command_seq_count", Raw: float64(p.CommandSeqCount), Value: float64(p.CommandSeqCount)},
+		{Name: "opcode", Raw: float64(p.Opcode), Value: float64(p.Opcode)},
+		{Name: "status", Raw: float64(p.Status), Value: float64(p.Status)},
+	}
+}
+
+// CommandAckDefinition decodes acknowledgements. It has no table, the
+// gateway updates the command history from them instead.
+var CommandAckDefinition = PacketDefinition{
+	APID:         COMMAND_ACK_APID,
+	Name:         "command ack",
+	ErrorControl: true,
+	Decode: func(h CCSDSPrimaryHeader, b []byte) (Packet, error) {
+		return decodeCommandAck(h, b)
+	},
+}
+
+// EncodeCommandAck builds the acknowledgement packet for a command
+func EncodeCommandAck(seqCount uint16, ack CommandAckPayload, sentAt time.Time) []byte {
+	dataLength := binary.Size(CCSDSSecondaryHeader{}) + binary.Size(CommandAckPayload{}) + PEC_SIZE
+	pkt := CommandAck{
+		CCSDSPrimaryHeader: NewCCSDSPrimaryHeader(PrimaryHeaderFields{
+			Version:             PACKET_VERSION,
+			Type:                PACKET_TYPE,
+			SecondaryHeaderFlag: true,
+			APID:                COMMAND_ACK_APID,
+			SequenceFlags:       SEQ_FLAGS,
+			SequenceCount:       seqCount,
+		}, dataLength),
+		CCSDSSecondaryHeader: CCSDSSecondaryHeader{
+			Timestamp:   uint64(sentAt.Unix()),
+			SubsystemID: COMMAND_ACK_SUBSYSTEM_ID,
+		},
+		CommandAckPayload: ack,
+	}
+
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.BigEndian, pkt)
+	return AppendErrorControl(buf.Bytes())
+}
+
+// DecodeCommandAck validates b, a whole acknowledgement packet with its
+// packet error control, and decodes it
+func DecodeCommandAck(b []byte) (*CommandAck, error) {
+	h, err := DecodePrimaryHeader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.APID() != COMMAND_ACK_APID {
+		return nil, fmt.Errorf("%w: got %#x, want %#x", ErrUnexpectedAPID, h.APID(), COMMAND_ACK_APID)
+	}
+
+	body, err := CheckErrorControl(b)
+	if err != nil {
+		return nil, err
+	}
+	return decodeCommandAck(h, body)
+}
+
+func decodeCommandAck(h CCSDSPrimaryHeader, b []byte) (*CommandAck, error) {
+	if err := validateStandaloneTelemetry(h); err != nil {
+		return nil, err
+	}
+
+	wantLength := binary.Size(CommandAck{})
+	if len(b) != wantLength {
+		return nil, fmt.Errorf(
+			"%w: command acks are %d bytes, received %d",
+			ErrLengthMismatch, wantLength, len(b),
+		)
+	}
+
+	ack := &CommandAck{}
+	if err := binary.Read(bytes.NewReader(b), binary.BigEndian, ack); err != nil {
+		return nil, err
+	}
+	return ack, nil
+}
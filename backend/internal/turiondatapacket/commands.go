@@ -0,0 +1,464 @@
+package turiondatapacket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Telecommand packet layout. Every command is a standalone TC packet with a
+// secondary header, followed by its arguments in dictionary order and packet
+// error control.
+const (
+	TC_PACKET_TYPE = 0x1 // 1 = TC (telecommand)
+	// TC_SECONDARY_HEADER_SIZE is the 8 byte unix timestamp the command was
+	// sent at and the 2 byte opcode
+	TC_SECONDARY_HEADER_SIZE = 10
+)
+
+// States a command goes through. Commands are queued when accepted, sent once
+// written to the uplink and then acknowledged or failed by the spacecraft. A
+// command the uplink couldn't send is failed straight away.
+const (
+	COMMAND_QUEUED       = "queued"
+	COMMAND_SENT         = "sent"
+	COMMAND_ACKNOWLEDGED = "acknowledged"
+	COMMAND_FAILED       = "failed"
+)
+
+// Errors returned while validating and decoding commands. Callers should use
+// errors.Is, since the returned errors are wrapped with the offending values.
+var (
+	ErrUnknownCommand  = errors.New("unknown command")
+	ErrInvalidArgument = errors.New("invalid command argument")
+)
+
+// CommandDictionary is every command the spacecraft accepts and the arguments
+// each one takes. It is loaded from YAML, see /dictionary/commands.yaml for an
+// example.
+type CommandDictionary struct {
+	Commands []CommandSpec `yaml:"commands"`
+}
+
+// CommandSpec defines one command
+type CommandSpec struct {
+	Name        string `yaml:"name" json:"name"`
+	Description string `yaml:"description" json:"description,omitempty"`
+	// APID is the APID the command is sent on, usually one per subsystem
+	APID uint16 `yaml:"apid" json:"apid"`
+	// Opcode identifies the command among those sent on its APID
+	Opcode    uint16         `yaml:"opcode" json:"opcode"`
+	Arguments []ArgumentSpec `yaml:"arguments" json:"arguments"`
+}
+
+// ArgumentSpec defines one argument of a command. Arguments are encoded big
+// endian, in the order they are defined.
+type ArgumentSpec struct {
+	Name string `yaml:"name" json:"name"`
+	// Type is one of the parameter types of the telemetry dictionary
+	Type string `yaml:"type" json:"type"`
+	Unit string `yaml:"unit" json:"unit,omitempty"`
+	// Optional inclusive range the value must be in
+	Min *float64 `yaml:"min" json:"min,omitempty"`
+	Max *float64 `yaml:"max" json:"max,omitempty"`
+}
+
+// DefaultCommandDictionary is used when no command dictionary is configured.
+// The generator's spacecraft stand-in accepts these commands.
+var DefaultCommandDictionary = CommandDictionary{
+	Commands: []CommandSpec{
+		{
+			Name:        "NOOP",
+			Description: "Does nothing, used to check the uplink",
+			APID:        0x40,
+			Opcode:      0x01,
+		},
+		{
+			Name:        "SET_HEATER",
+			Description: "Turns a heater on or off",
+			APID:        0x40,
+			Opcode:      0x02,
+			Arguments: []ArgumentSpec{
+				{Name: "heater", Type: "uint8", Min: ptr(0), Max: ptr(3)},
+				{Name: "on", Type: "uint8", Min: ptr(0), Max: ptr(1)},
+			},
+		},
+		{
+			Name:        "SET_TRANSMIT_POWER",
+			Description: "Sets the downlink transmitter power",
+			APID:        0x41,
+			Opcode:      0x01,
+			Arguments: []ArgumentSpec{
+				{Name: "power", Type: "float32", Unit: "dBm", Min: ptr(0), Max: ptr(33)},
+			},
+		},
+	},
+}
+
+func ptr(f float64) *float64 {
+	return &f
+}
+
+// LoadCommandDictionary reads and validates a YAML command dictionary
+func LoadCommandDictionary(path string) (*CommandDictionary, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading command dictionary: %w", err)
+	}
+
+	return ParseCommandDictionary(b)
+}
+
+// ParseCommandDictionary parses and validates a YAML command dictionary
+func ParseCommandDictionary(b []byte) (*CommandDictionary, error) {
+	d := &CommandDictionary{}
+	if err := yaml.Unmarshal(b, d); err != nil {
+		return nil, fmt.Errorf("parsing command dictionary: %w", err)
+	}
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Validate checks every command and argument and returns all problems found
+func (d *CommandDictionary) Validate() error {
+	var errs error
+	names := map[string]bool{}
+	opcodes := map[[2]uint16]string{}
+	for _, c := range d.Commands {
+		if names[c.Name] {
+			errs = errors.Join(errs, fmt.Errorf("command %q is defined twice", c.Name))
+		}
+		names[c.Name] = true
+
+		key := [2]uint16{c.APID, c.Opcode}
+		if other, ok := opcodes[key]; ok {
+			errs = errors.Join(errs, fmt.Errorf("command %q: opcode %#x on APID %#x already used by %q", c.Name, c.Opcode, c.APID, other))
+		}
+		opcodes[key] = c.Name
+
+		if err := c.validate(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("command %q: %w", c.Name, err))
+		}
+	}
+	return errs
+}
+
+func (c CommandSpec) validate() error {
+	var errs error
+	if c.Name == "" {
+		errs = errors.Join(errs, errors.New("name is required"))
+	}
+
+	if c.APID > apidMask {
+		errs = errors.Join(errs, fmt.Errorf("APID %#x does not fit in 11 bits", c.APID))
+	}
+
+	names := map[string]bool{}
+	for _, a := range c.Arguments {
+		if a.Name == "" {
+			errs = errors.Join(errs, errors.New("argument name is required"))
+		}
+		if names[a.Name] {
+			errs = errors.Join(errs, fmt.Errorf("argument %q is defined twice", a.Name))
+		}
+		names[a.Name] = true
+
+		if _, ok := parameterTypeSizes[a.Type]; !ok {
+			errs = errors.Join(errs, fmt.Errorf("argument %q: unknown type %q", a.Name, a.Type))
+		}
+
+		if a.Min != nil && a.Max != nil && *a.Min > *a.Max {
+			errs = errors.Join(errs, fmt.Errorf("argument %q: min %v is above max %v", a.Name, *a.Min, *a.Max))
+		}
+	}
+
+	return errs
+}
+
+// Lookup returns the command called name
+func (d *CommandDictionary) Lookup(name string) (CommandSpec, bool) {
+	for _, c := range d.Commands {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return CommandSpec{}, false
+}
+
+// LookupOpcode returns the command sent on apid with opcode
+func (d *CommandDictionary) LookupOpcode(apid, opcode uint16) (CommandSpec, bool) {
+	for _, c := range d.Commands {
+		if c.APID == apid && c.Opcode == opcode {
+			return c, true
+		}
+	}
+	return CommandSpec{}, false
+}
+
+// EncodeArguments checks that args has a value for every argument of the
+// command and nothing else, and that each value fits its argument's type and
+// range. It returns the encoded arguments.
+func (c CommandSpec) EncodeArguments(args map[string]float64) ([]byte, error) {
+	var errs error
+	known := map[string]bool{}
+	var b []byte
+	for _, a := range c.Arguments {
+		known[a.Name] = true
+		v, ok := args[a.Name]
+		if !ok {
+			errs = errors.Join(errs, fmt.Errorf("%w: %q is required", ErrInvalidArgument, a.Name))
+			continue
+		}
+		if err := a.check(v); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("%w: %q %w", ErrInvalidArgument, a.Name, err))
+			continue
+		}
+		b = a.append(b, v)
+	}
+
+	// Sorted so the same request always reports the same error
+	var unknown []string
+	for name := range args {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	for _, name := range unknown {
+		errs = errors.Join(errs, fmt.Errorf("%w: %s has no argument %q", ErrInvalidArgument, c.Name, name))
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+	return b, nil
+}
+
+// DecodeArguments reads the arguments encoded by EncodeArguments back out of b
+func (c CommandSpec) DecodeArguments(b []byte) (map[string]float64, error) {
+	if want := c.argumentsLength(); len(b) != want {
+		return nil, fmt.Errorf("%w: %s takes %d bytes of arguments, received %d", ErrInvalidArgument, c.Name, want, len(b))
+	}
+
+	args := make(map[string]float64, len(c.Arguments))
+	offset := 0
+	for _, a := range c.Arguments {
+		param := ParameterSpec{Type: a.Type, Offset: offset}
+		v := param.readRaw(b)
+		if err := a.check(v); err != nil {
+			return nil, fmt.Errorf("%w: %q %w", ErrInvalidArgument, a.Name, err)
+		}
+		args[a.Name] = v
+		offset += parameterTypeSizes[a.Type]
+	}
+	return args, nil
+}
+
+func (c CommandSpec) argumentsLength() int {
+	n := 0
+	for _, a := range c.Arguments {
+		n += parameterTypeSizes[a.Type]
+	}
+	return n
+}
+
+// Integer ranges of the supported argument types
+var argumentTypeRanges = map[string][2]float64{
+	"uint8": {0, math.MaxUint8}, "int8": {math.MinInt8, math.MaxInt8},
+	"uint16": {0, math.MaxUint16}, "int16": {math.MinInt16, math.MaxInt16},
+	"uint32": {0, math.MaxUint32}, "int32": {math.MinInt32, math.MaxInt32},
+	"uint64": {0, math.MaxUint64}, "int64": {math.MinInt64, math.MaxInt64},
+}
+
+// check returns why v can't be sent as this argument, if it can't
+func (a ArgumentSpec) check(v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("must be a finite number, got %v", v)
+	}
+
+	if r, ok := argumentTypeRanges[a.Type]; ok {
+		if v != math.Trunc(v) {
+			return fmt.Errorf("must be a whole number, got %v", v)
+		}
+		if v < r[0] || v > r[1] {
+			return fmt.Errorf("does not fit in %s, got %v", a.Type, v)
+		}
+	}
+	if a.Type == "float32" && math.Abs(v) > math.MaxFloat32 {
+		return fmt.Errorf("does not fit in float32, got %v", v)
+	}
+
+	if a.Min != nil && v < *a.Min {
+		return fmt.Errorf("must be at least %v, got %v", *a.Min, v)
+	}
+	if a.Max != nil && v > *a.Max {
+		return fmt.Errorf("must be at most %v, got %v", *a.Max, v)
+	}
+	return nil
+}
+
+// append encodes v, already checked, big endian
+func (a ArgumentSpec) append(b []byte, v float64) []byte {
+	switch a.Type {
+	case "uint8":
+		return append(b, uint8(v))
+	case "int8":
+		return append(b, uint8(int8(v)))
+	case "uint16":
+		return binary.BigEndian.AppendUint16(b, uint16(v))
+	case "int16":
+		return binary.BigEndian.AppendUint16(b, uint16(int16(v)))
+	case "uint32":
+		return binary.BigEndian.AppendUint32(b, uint32(v))
+	case "int32":
+		return binary.BigEndian.AppendUint32(b, uint32(int32(v)))
+	case "uint64":
+		return binary.BigEndian.AppendUint64(b, uint64(v))
+	case "int64":
+		return binary.BigEndian.AppendUint64(b, uint64(int64(v)))
+	case "float32":
+		return binary.BigEndian.AppendUint32(b, math.Float32bits(float32(v)))
+	case "float64":
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(v))
+	}
+	return b
+}
+
+// Telecommand is a decoded TC packet
+type Telecommand struct {
+	CCSDSPrimaryHeader CCSDSPrimaryHeader `json:"ccsdsPrimaryHeader"`
+	// Timestamp is when the command was sent, in unix seconds
+	Timestamp uint64 `json:"timestamp"`
+	Opcode    uint16 `json:"opcode"`
+	Arguments []byte `json:"arguments"`
+}
+
+// EncodeTelecommand builds a standalone TC packet with packet error control
+func EncodeTelecommand(apid, seqCount, opcode uint16, sentAt time.Time, args []byte) []byte {
+	dataLength := TC_SECONDARY_HEADER_SIZE + len(args) + PEC_SIZE
+	header := NewCCSDSPrimaryHeader(PrimaryHeaderFields{
+		Version:             PACKET_VERSION,
+		Type:                TC_PACKET_TYPE,
+		SecondaryHeaderFlag: true,
+		APID:                apid,
+		SequenceFlags:       SEQ_FLAGS,
+		SequenceCount:       seqCount,
+	}, dataLength)
+
+	b := make([]byte, 0, PRIMARY_HEADER_SIZE+dataLength)
+	b = binary.BigEndian.AppendUint16(b, header.PacketID)
+	b = binary.BigEndian.AppendUint16(b, header.PacketSeqCtrl)
+	b = binary.BigEndian.AppendUint16(b, header.PacketLength)
+	b = binary.BigEndian.AppendUint64(b, uint64(sentAt.Unix()))
+	b = binary.BigEndian.AppendUint16(b, opcode)
+	b = append(b, args...)
+	return AppendErrorControl(b)
+}
+
+// DecodeTelecommand validates b as a standalone TC packet, checks its packet
+// error control and decodes it
+func DecodeTelecommand(b []byte) (*Telecommand, error) {
+	h, err := DecodePrimaryHeader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if h.Type() != TC_PACKET_TYPE {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrUnexpectedPacketType, h.Type(), TC_PACKET_TYPE)
+	}
+	if !h.HasSecondaryHeader() {
+		return nil, ErrMissingSecondaryHeader
+	}
+	if h.SequenceFlags() != SEQ_FLAGS {
+		return nil, fmt.Errorf("%w: got %#b", ErrUnsupportedSequenceFlags, h.SequenceFlags())
+	}
+
+	body, err := CheckErrorControl(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < PRIMARY_HEADER_SIZE+TC_SECONDARY_HEADER_SIZE {
+		return nil, fmt.Errorf("%w: telecommands need at least %d bytes, received %d",
+			ErrLengthMismatch, PRIMARY_HEADER_SIZE+TC_SECONDARY_HEADER_SIZE+PEC_SIZE, len(b))
+	}
+
+	return &Telecommand{
+		CCSDSPrimaryHeader: h,
+		Timestamp:          binary.BigEndian.Uint64(body[PRIMARY_HEADER_SIZE:]),
+		Opcode:             binary.BigEndian.Uint16(body[PRIMARY_HEADER_SIZE+8:]),
+		Arguments:          body[PRIMARY_HEADER_SIZE+TC_SECONDARY_HEADER_SIZE:],
+	}, nil
+}
+
+// CommandCounter hands out the sequence count of each APID's next
+// telecommand, rolling over after MAX_SEQUENCE_COUNT. It is safe for
+// concurrent use.
+type CommandCounter struct {
+	mu   sync.Mutex
+	next map[uint16]uint16
+}
+
+func NewCommandCounter() *CommandCounter {
+	return &CommandCounter{next: map[uint16]uint16{}}
+}
+
+// Next returns the sequence count to send apid's next command with
+func (c *CommandCounter) Next(apid uint16) uint16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.next[apid]
+	c.next[apid] = (n + 1) & seqCountMask
+	return n
+}
+
+// Peek returns the sequence count Next will return for apid, without taking
+// it
+func (c *CommandCounter) Peek(apid uint16) uint16 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.next[apid]
+}
+
+// Restore carries on from the last sequence count sent on each APID, so the
+// next command on an APID is sent with the count after it
+func (c *CommandCounter) Restore(last map[uint16]uint16) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for apid, n := range last {
+		c.next[apid] = (n + 1) & seqCountMask
+	}
+}
+
+// Command is a command sent, or being sent, to the spacecraft and what
+// became of it
+type Command struct {
+	ID        int64              `json:"id"`
+	Name      string             `json:"name"`
+	APID      uint16             `json:"apid"`
+	Opcode    uint16             `json:"opcode"`
+	SeqCount  uint16             `json:"seqCount"`
+	Arguments map[string]float64 `json:"arguments"`
+	State     string             `json:"state"`
+	// Error is why the command failed, if it did
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	SentAt    *time.Time `json:"sentAt,omitempty"`
+	// AcknowledgedAt is when the gateway received the spacecraft's
+	// acknowledgement, success or failure
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	AckStatus      *uint8     `json:"ackStatus,omitempty"`
+}
@@ -0,0 +1,134 @@
+package turiondatapacket
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseCommandDictionaryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{name: "duplicate name", yaml: `
+commands:
+  - { name: NOOP, apid: 0x40, opcode: 1 }
+  - { name: NOOP, apid: 0x40, opcode: 2 }`},
+		{name: "duplicate opcode", yaml: `
+commands:
+  - { name: A, apid: 0x40, opcode: 1 }
+  - { name: B, apid: 0x40, opcode: 1 }`},
+		{name: "unknown argument type", yaml: `
+commands:
+  - { name: A, apid: 0x40, opcode: 1, arguments: [{ name: x, type: string }] }`},
+		{name: "min above max", yaml: `
+commands:
+  - { name: A, apid: 0x40, opcode: 1, arguments: [{ name: x, type: uint8, min: 5, max: 1 }] }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCommandDictionary([]byte(tt.yaml)); err == nil {
+				t.Error("ParseCommandDictionary() error = nil; want error")
+			}
+		})
+	}
+
+	if err := DefaultCommandDictionary.Validate(); err != nil {
+		t.Errorf("DefaultCommandDictionary.Validate() = %v", err)
+	}
+}
+
+func TestEncodeArguments(t *testing.T) {
+	heater, _ := DefaultCommandDictionary.Lookup("SET_HEATER")
+
+	tests := []struct {
+		name    string
+		args    map[string]float64
+		want    []byte
+		wantErr error
+	}{
+		{name: "valid", args: map[string]float64{"heater": 2, "on": 1}, want: []byte{2, 1}},
+		{name: "missing", args: map[string]float64{"heater": 2}, wantErr: ErrInvalidArgument},
+		{name: "unknown", args: map[string]float64{"heater": 2, "on": 1, "off": 0}, wantErr: ErrInvalidArgument},
+		{name: "out of range", args: map[string]float64{"heater": 4, "on": 1}, wantErr: ErrInvalidArgument},
+		{name: "not whole", args: map[string]float64{"heater": 1.5, "on": 1}, wantErr: ErrInvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := heater.EncodeArguments(tt.args)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("EncodeArguments() error = %v; want %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("EncodeArguments() = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTelecommandRoundTrip(t *testing.T) {
+	power, _ := DefaultCommandDictionary.Lookup("SET_TRANSMIT_POWER")
+	args, err := power.EncodeArguments(map[string]float64{"power": 27.5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := EncodeTelecommand(power.APID, 42, power.Opcode, time.Unix(1700000000, 0), args)
+	tc, err := DecodeTelecommand(b)
+	if err != nil {
+		t.Fatalf("DecodeTelecommand() error = %v", err)
+	}
+	h := tc.CCSDSPrimaryHeader
+	if h.Type() != TC_PACKET_TYPE || h.APID() != power.APID || h.SequenceCount() != 42 ||
+		tc.Opcode != power.Opcode || tc.Timestamp != 1700000000 {
+		t.Errorf("DecodeTelecommand() = %+v, header %+v", tc, h.Fields())
+	}
+
+	decoded, err := power.DecodeArguments(tc.Arguments)
+	if err != nil || decoded["power"] != 27.5 {
+		t.Errorf("DecodeArguments() = %v, %v; want power 27.5", decoded, err)
+	}
+
+	b[len(b)-3] ^= 0x01
+	if _, err := DecodeTelecommand(b); !errors.Is(err, ErrChecksum) {
+		t.Errorf("DecodeTelecommand(corrupted) error = %v; want %v", err, ErrChecksum)
+	}
+}
+
+func TestCommandAckRegistry(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register(CommandAckDefinition); err != nil {
+		t.Fatal(err)
+	}
+
+	want := CommandAckPayload{CommandAPID: 0x40, CommandSeqCount: 7, Opcode: 2, Status: ACK_STATUS_INVALID_ARGUMENTS}
+	pkt, _, err := r.Decode(EncodeCommandAck(3, want, time.Now()))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	if got := pkt.(*CommandAck).CommandAckPayload; got != want {
+		t.Errorf("Decode() payload = %+v; want %+v", got, want)
+	}
+}
+
+func TestCommandCounterRollsOver(t *testing.T) {
+	c := NewCommandCounter()
+	c.next[0x40] = MAX_SEQUENCE_COUNT
+
+	if got := c.Peek(0x40); got != MAX_SEQUENCE_COUNT {
+		t.Errorf("Peek() = %d; want %d", got, MAX_SEQUENCE_COUNT)
+	}
+	if got := c.Next(0x40); got != MAX_SEQUENCE_COUNT {
+		t.Errorf("Next() = %d; want %d", got, MAX_SEQUENCE_COUNT)
+	}
+	if got := c.Next(0x40); got != 0 {
+		t.Errorf("Next() after rollover = %d; want 0", got)
+	}
+	if got := c.Next(0x41); got != 0 {
+		t.Errorf("Next() on another APID = %d; want 0", got)
+	}
+}
@@ -0,0 +1,10 @@
+package turiondatapacket
+
+// CorruptedPacketCount is how many packets on one APID failed their packet
+// error control check
+type CorruptedPacketCount struct {
+	APID  uint16 `json:"apid"`
+	Count uint64 `json:"count"`
+	// LastSeen is when the most recent one arrived, in unix seconds
+	LastSeen uint64 `json:"lastSeen"`
+}
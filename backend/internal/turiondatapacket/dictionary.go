@@ -0,0 +1,546 @@
+package turiondatapacket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// GENERIC_PARAMETER_TABLE stores one row per parameter per packet for packets
+// that don't map their parameters to the columns of a dedicated table
+const GENERIC_PARAMETER_TABLE = "telemetry_parameters"
+
+// Columns written for every dictionary packet stored in a dedicated table,
+// ahead of the parameter columns
+var dictionaryHeaderColumns = []string{
+	"packet_id", "packet_seq_ctrl", "packet_length",
+	"ts", "subsystem_id",
+	"apid", "seq_count",
+}
+
+var genericParameterColumns = []string{
+	"apid", "seq_count", "ts", "subsystem_id",
+	"parameter", "raw_value", "value", "unit",
+}
+
+// Dictionary is the telemetry dictionary: every packet the spacecraft sends,
+// where each parameter lives in it and how to turn it into engineering units.
+// It is loaded from YAML, see /dictionary/telemetry.yaml for an example.
+type Dictionary struct {
+	Packets []PacketSpec `yaml:"packets"`
+}
+
+// PacketSpec defines the layout of the packets sent on one APID
+type PacketSpec struct {
+	Name string `yaml:"name"`
+	APID uint16 `yaml:"apid"`
+	// Length is the total packet length in bytes. If it is zero, packets only
+	// need to be long enough to hold every parameter.
+	Length int `yaml:"length"`
+	// ErrorControl is set when packets end with a CRC-16 packet error control
+	// field. Length doesn't count it, since it is checked and removed before
+	// the packet is decoded.
+	ErrorControl bool `yaml:"errorControl"`
+	// Table is the table the packet is stored in, with one column per
+	// parameter. Packets without a table are stored in GENERIC_PARAMETER_TABLE.
+	Table string `yaml:"table"`
+	// SubsystemID is only used to encode packets, decoding keeps whatever the
+	// packet carries
+	SubsystemID uint16 `yaml:"subsystemId"`
+	// RecordOrigin stores each packet's source address and receive time in
+	// the source and received_at columns of Table, which must have them.
+	// Packets without a table always have them recorded.
+	RecordOrigin bool            `yaml:"recordOrigin"`
+	Parameters   []ParameterSpec `yaml:"parameters"`
+}
+
+// MainBusPacketSpec describes the built-in main bus packet, TurionDataPacket,
+// in dictionary form so it can be encoded like any dictionary packet
+var MainBusPacketSpec = PacketSpec{
+	Name:        "main bus",
+	APID:        APID,
+	Length:      32,
+	SubsystemID: SUBSYSTEM_ID,
+	Parameters: []ParameterSpec{
+		{Name: "temperature", Offset: 16, Type: "float32", Unit: "°C"},
+		{Name: "battery", Offset: 20, Type: "float32", Unit: "%"},
+		{Name: "altitude", Offset: 24, Type: "float32", Unit: "km"},
+		{Name: "signal", Offset: 28, Type: "float32", Unit: "dB"},
+	},
+}
+
+// ParameterSpec defines a single value inside a packet
+type ParameterSpec struct {
+	Name string `yaml:"name"`
+	// Offset is counted in bytes from the start of the primary header
+	Offset     int    `yaml:"offset"`
+	Type       string `yaml:"type"`
+	Endianness string `yaml:"endianness"`
+	Unit       string `yaml:"unit"`
+	// Column overrides the column name when the packet has a table
+	Column      string       `yaml:"column"`
+	Calibration *Calibration `yaml:"calibration"`
+	Limits      *LimitSpec   `yaml:"limits"`
+}
+
+// Calibration converts a raw value to engineering units
+type Calibration struct {
+	// Polynomial coefficients, lowest order first:
+	// value = c[0] + c[1]*raw + c[2]*raw^2 ...
+	Polynomial []float64 `yaml:"polynomial"`
+}
+
+// LimitSpec is the default range outside of which a parameter is anomalous.
+// Low and High are the red limits. Any bound may be omitted. These limits are
+// only used when no limit set has been loaded, see LimitSource.
+type LimitSpec struct {
+	Low        *float64 `yaml:"low"`
+	High       *float64 `yaml:"high"`
+	YellowLow  *float64 `yaml:"yellowLow"`
+	YellowHigh *float64 `yaml:"yellowHigh"`
+}
+
+// Sizes in bytes of the supported parameter types
+var parameterTypeSizes = map[string]int{
+	"uint8": 1, "int8": 1,
+	"uint16": 2, "int16": 2,
+	"uint32": 4, "int32": 4, "float32": 4,
+	"uint64": 8, "int64": 8, "float64": 8,
+}
+
+// LoadDictionary reads and validates a YAML telemetry dictionary
+func LoadDictionary(path string) (*Dictionary, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading telemetry dictionary: %w", err)
+	}
+
+	return ParseDictionary(b)
+}
+
+// ParseDictionary parses and validates a YAML telemetry dictionary
+func ParseDictionary(b []byte) (*Dictionary, error) {
+	d := &Dictionary{}
+	if err := yaml.Unmarshal(b, d); err != nil {
+		return nil, fmt.Errorf("parsing telemetry dictionary: %w", err)
+	}
+
+	if err := d.Validate(); err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Validate checks every packet and parameter and returns all problems found
+func (d *Dictionary) Validate() error {
+	var errs error
+	apids := map[uint16]string{}
+	for _, p := range d.Packets {
+		if other, ok := apids[p.APID]; ok {
+			errs = errors.Join(errs, fmt.Errorf("packet %q: APID %#x already used by %q", p.Name, p.APID, other))
+		}
+		apids[p.APID] = p.Name
+
+		if err := p.validate(); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("packet %q: %w", p.Name, err))
+		}
+	}
+	return errs
+}
+
+func (p PacketSpec) validate() error {
+	var errs error
+	if p.Name == "" {
+		errs = errors.Join(errs, errors.New("name is required"))
+	}
+
+	if p.APID > apidMask {
+		errs = errors.Join(errs, fmt.Errorf("APID %#x does not fit in 11 bits", p.APID))
+	}
+
+	if p.Length != 0 && p.Length < p.minLength() {
+		errs = errors.Join(errs, fmt.Errorf("length %d is too short for its parameters", p.Length))
+	}
+
+	names := map[string]bool{}
+	for _, param := range p.Parameters {
+		if param.Name == "" {
+			errs = errors.Join(errs, errors.New("parameter name is required"))
+		}
+		if names[param.Name] {
+			errs = errors.Join(errs, fmt.Errorf("parameter %q is defined twice", param.Name))
+		}
+		names[param.Name] = true
+
+		if _, ok := parameterTypeSizes[param.Type]; !ok {
+			errs = errors.Join(errs, fmt.Errorf("parameter %q: unknown type %q", param.Name, param.Type))
+		}
+
+		if param.Offset < PRIMARY_HEADER_SIZE+SECONDARY_HEADER_SIZE {
+			errs = errors.Join(errs, fmt.Errorf("parameter %q: offset %d overlaps the packet headers", param.Name, param.Offset))
+		}
+
+		switch param.Endianness {
+		case "", "big", "little":
+		default:
+			errs = errors.Join(errs, fmt.Errorf("parameter %q: unknown endianness %q", param.Name, param.Endianness))
+		}
+	}
+
+	return errs
+}
+
+// minLength is the number of bytes needed to hold the headers and every parameter
+func (p PacketSpec) minLength() int {
+	n := PRIMARY_HEADER_SIZE + SECONDARY_HEADER_SIZE
+	for _, param := range p.Parameters {
+		if end := param.Offset + parameterTypeSizes[param.Type]; end > n {
+			n = end
+		}
+	}
+	return n
+}
+
+// LimitSet collects the limits defined in the dictionary into a limit set
+func (d *Dictionary) LimitSet() *LimitSet {
+	set := &LimitSet{Name: "dictionary"}
+	for _, p := range d.Packets {
+		for _, param := range p.Parameters {
+			if param.Limits == nil {
+				continue
+			}
+			apid := p.APID
+			set.Limits = append(set.Limits, Limit{
+				Parameter:  param.Name,
+				APID:       &apid,
+				YellowLow:  param.Limits.YellowLow,
+				YellowHigh: param.Limits.YellowHigh,
+				RedLow:     param.Limits.Low,
+				RedHigh:    param.Limits.High,
+			})
+		}
+	}
+	return set
+}
+
+// Register adds a definition for every packet in the dictionary to r
+func (d *Dictionary) Register(r *Registry) error {
+	var errs error
+	for _, p := range d.Packets {
+		if err := r.Register(p.Definition()); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+// Definition builds the registry definition that decodes and stores packets
+// matching this spec.
+func (p PacketSpec) Definition() PacketDefinition {
+	def := PacketDefinition{
+		APID:         p.APID,
+		Name:         p.Name,
+		Decode:       p.decode,
+		ErrorControl: p.ErrorControl,
+	}
+
+	if p.Table == "" {
+		def.Table = GENERIC_PARAMETER_TABLE
+		def.Columns = genericParameterColumns
+		def.Rows = genericParameterRows
+		def.RecordOrigin = true
+		return def
+	}
+
+	def.Table = p.Table
+	def.RecordOrigin = p.RecordOrigin
+	def.Columns = append([]string{}, dictionaryHeaderColumns...)
+	for _, param := range p.Parameters {
+		def.Columns = append(def.Columns, param.column())
+	}
+	def.Rows = dedicatedTableRows
+	return def
+}
+
+func (p PacketSpec) decode(h CCSDSPrimaryHeader, b []byte) (Packet, error) {
+	if err := validateStandaloneTelemetry(h); err != nil {
+		return nil, err
+	}
+
+	if p.Length != 0 && len(b) != p.Length {
+		return nil, fmt.Errorf("%w: %s packets are %d bytes, received %d", ErrLengthMismatch, p.Name, p.Length, len(b))
+	}
+
+	if minLength := p.minLength(); len(b) < minLength {
+		return nil, fmt.Errorf("%w: %s packets need at least %d bytes, received %d", ErrLengthMismatch, p.Name, minLength, len(b))
+	}
+
+	pkt := &DictionaryPacket{
+		CCSDSPrimaryHeader: h,
+		CCSDSSecondaryHeader: CCSDSSecondaryHeader{
+			Timestamp:   binary.BigEndian.Uint64(b[PRIMARY_HEADER_SIZE:]),
+			SubsystemID: binary.BigEndian.Uint16(b[PRIMARY_HEADER_SIZE+8:]),
+		},
+		Parameters: make([]ParameterValue, len(p.Parameters)),
+	}
+
+	for i, param := range p.Parameters {
+		raw := param.readRaw(b)
+		pkt.Parameters[i] = ParameterValue{
+			Name:  param.Name,
+			Raw:   raw,
+			Value: param.Calibration.apply(raw),
+			Unit:  param.Unit,
+		}
+	}
+
+	return pkt, nil
+}
+
+func (param ParameterSpec) column() string {
+	if param.Column != "" {
+		return param.Column
+	}
+	return param.Name
+}
+
+func (param ParameterSpec) byteOrder() binary.ByteOrder {
+	if param.Endianness == "little" {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// readRaw reads the parameter out of b, which must be long enough to hold it
+func (param ParameterSpec) readRaw(b []byte) float64 {
+	order := param.byteOrder()
+	field := b[param.Offset:]
+	switch param.Type {
+	case "uint8":
+		return float64(field[0])
+	case "int8":
+		return float64(int8(field[0]))
+	case "uint16":
+		return float64(order.Uint16(field))
+	case "int16":
+		return float64(int16(order.Uint16(field)))
+	case "uint32":
+		return float64(order.Uint32(field))
+	case "int32":
+		return float64(int32(order.Uint32(field)))
+	case "uint64":
+		return float64(order.Uint64(field))
+	case "int64":
+		return float64(int64(order.Uint64(field)))
+	case "float32":
+		return float64(math.Float32frombits(order.Uint32(field)))
+	case "float64":
+		return math.Float64frombits(order.Uint64(field))
+	}
+	return math.NaN()
+}
+
+// Encode builds a packet holding values, in engineering units, with packet
+// error control if the spec has it. Parameters missing from values are left at
+// zero. Raw values are rounded and clamped to their type, so out of range
+// values saturate like a real sensor would.
+func (p PacketSpec) Encode(seqCount uint16, timestamp uint64, values map[string]float64) ([]byte, error) {
+	length := p.Length
+	if length == 0 {
+		length = p.minLength()
+	}
+	dataLength := length - PRIMARY_HEADER_SIZE
+	if p.ErrorControl {
+		dataLength += PEC_SIZE
+	}
+
+	h := NewCCSDSPrimaryHeader(PrimaryHeaderFields{
+		Version:             PACKET_VERSION,
+		Type:                PACKET_TYPE,
+		SecondaryHeaderFlag: true,
+		APID:                p.APID,
+		SequenceFlags:       SEQ_FLAGS,
+		SequenceCount:       seqCount,
+	}, dataLength)
+
+	b := make([]byte, length, length+PEC_SIZE)
+	binary.BigEndian.PutUint16(b[0:], h.PacketID)
+	binary.BigEndian.PutUint16(b[2:], h.PacketSeqCtrl)
+	binary.BigEndian.PutUint16(b[4:], h.PacketLength)
+	binary.BigEndian.PutUint64(b[PRIMARY_HEADER_SIZE:], timestamp)
+	binary.BigEndian.PutUint16(b[PRIMARY_HEADER_SIZE+8:], p.SubsystemID)
+
+	for _, param := range p.Parameters {
+		v, ok := values[param.Name]
+		if !ok {
+			continue
+		}
+		raw, err := param.Calibration.invert(v)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %q: %w", param.Name, err)
+		}
+		param.writeRaw(b, raw)
+	}
+
+	if p.ErrorControl {
+		b = AppendErrorControl(b)
+	}
+	return b, nil
+}
+
+// writeRaw writes raw into b, which must be long enough to hold it
+func (param ParameterSpec) writeRaw(b []byte, raw float64) {
+	order := param.byteOrder()
+	field := b[param.Offset:]
+	if r, ok := argumentTypeRanges[param.Type]; ok {
+		raw = math.Max(r[0], math.Min(r[1], math.Round(raw)))
+	}
+	switch param.Type {
+	case "uint8":
+		field[0] = uint8(raw)
+	case "int8":
+		field[0] = uint8(int8(raw))
+	case "uint16":
+		order.PutUint16(field, uint16(raw))
+	case "int16":
+		order.PutUint16(field, uint16(int16(raw)))
+	case "uint32":
+		order.PutUint32(field, uint32(raw))
+	case "int32":
+		order.PutUint32(field, uint32(int32(raw)))
+	case "uint64":
+		order.PutUint64(field, uint64(raw))
+	case "int64":
+		order.PutUint64(field, uint64(int64(raw)))
+	case "float32":
+		order.PutUint32(field, math.Float32bits(float32(raw)))
+	case "float64":
+		order.PutUint64(field, math.Float64bits(raw))
+	}
+}
+
+// invert turns an engineering value back into its raw value. Only linear
+// calibrations can be inverted.
+func (c *Calibration) invert(value float64) (float64, error) {
+	if c == nil || len(c.Polynomial) == 0 {
+		return value, nil
+	}
+
+	offset, gain := c.Polynomial[0], 0.0
+	if len(c.Polynomial) > 1 {
+		gain = c.Polynomial[1]
+	}
+	for _, coefficient := range c.Polynomial[min(len(c.Polynomial), 2):] {
+		if coefficient != 0 {
+			return 0, errors.New("only linear calibrations can be inverted")
+		}
+	}
+	if gain == 0 {
+		return 0, errors.New("constant calibrations can't be inverted")
+	}
+	return (value - offset) / gain, nil
+}
+
+func (c *Calibration) apply(raw float64) float64 {
+	if c == nil || len(c.Polynomial) == 0 {
+		return raw
+	}
+
+	// Horner's method
+	value := 0.0
+	for i := len(c.Polynomial) - 1; i >= 0; i-- {
+		value = value*raw + c.Polynomial[i]
+	}
+	return value
+}
+
+// fieldForParameter maps the original main bus parameters onto the Field enum
+// so anomalies from dictionary packets look the same as before to clients.
+func fieldForParameter(name string) Field {
+	if v, ok := Field_value[strings.ToUpper(name)]; ok {
+		return Field(v)
+	}
+	return Field_FIELD_UNSPECIFIED
+}
+
+// ParameterValue is one decoded parameter
+type ParameterValue struct {
+	Name string `json:"name"`
+	// Raw is the value as read from the packet, Value is after calibration
+	Raw   float64 `json:"raw"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit,omitempty"`
+}
+
+// DictionaryPacket is a packet decoded using a PacketSpec
+type DictionaryPacket struct {
+	CCSDSPrimaryHeader   CCSDSPrimaryHeader   `json:"ccsdsPrimaryHeader"`
+	CCSDSSecondaryHeader CCSDSSecondaryHeader `json:"ccsdsSecondaryHeader"`
+	Parameters           []ParameterValue     `json:"parameters"`
+}
+
+// PrimaryHeader implements Packet
+func (dp *DictionaryPacket) PrimaryHeader() CCSDSPrimaryHeader {
+	return dp.CCSDSPrimaryHeader
+}
+
+// Timestamp implements Packet
+func (dp *DictionaryPacket) Timestamp() uint64 {
+	return dp.CCSDSSecondaryHeader.Timestamp
+}
+
+// Values implements Parameters
+func (dp *DictionaryPacket) Values() []ParameterValue {
+	return dp.Parameters
+}
+
+// Parameter returns the decoded parameter with the given name
+func (dp *DictionaryPacket) Parameter(name string) (ParameterValue, bool) {
+	for _, p := range dp.Parameters {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return ParameterValue{}, false
+}
+
+func genericParameterRows(p Packet) [][]any {
+	dp := p.(*DictionaryPacket)
+	rows := make([][]any, 0, len(dp.Parameters))
+	for _, param := range dp.Parameters {
+		rows = append(rows, []any{
+			dp.CCSDSPrimaryHeader.APID(),
+			dp.CCSDSPrimaryHeader.SequenceCount(),
+			dp.CCSDSSecondaryHeader.Timestamp,
+			dp.CCSDSSecondaryHeader.SubsystemID,
+			param.Name,
+			param.Raw,
+			param.Value,
+			param.Unit,
+		})
+	}
+	return rows
+}
+
+func dedicatedTableRows(p Packet) [][]any {
+	dp := p.(*DictionaryPacket)
+	row := []any{
+		dp.CCSDSPrimaryHeader.PacketID,
+		dp.CCSDSPrimaryHeader.PacketSeqCtrl,
+		dp.CCSDSPrimaryHeader.PacketLength,
+		dp.CCSDSSecondaryHeader.Timestamp,
+		dp.CCSDSSecondaryHeader.SubsystemID,
+		dp.CCSDSPrimaryHeader.APID(),
+		dp.CCSDSPrimaryHeader.SequenceCount(),
+	}
+	for _, param := range dp.Parameters {
+		row = append(row, param.Value)
+	}
+	return [][]any{row}
+}
@@ -0,0 +1,225 @@
+package turiondatapacket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func loadTestDictionary(t *testing.T) (*Registry, *LimitChecker) {
+	t.Helper()
+
+	d, err := LoadDictionary("../../../dictionary/telemetry.yaml")
+	if err != nil {
+		t.Fatalf("LoadDictionary() error = %v", err)
+	}
+
+	r := NewRegistry()
+	if err := d.Register(r); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	limits, err := NewLimitChecker(d.LimitSet())
+	if err != nil {
+		t.Fatalf("NewLimitChecker() error = %v", err)
+	}
+	return r, limits
+}
+
+func TestDictionaryMatchesBuiltInMainBus(t *testing.T) {
+	r, limits := loadTestDictionary(t)
+	dataLength := SECONDARY_HEADER_SIZE + binary.Size(TelemetryPayload{})
+
+	for _, tdp := range []TurionDataPacket{
+		makePacket(25, 85, 525, -50, 1700000000),
+		makePacket(50, 20, 350, -90, 1700000000),
+	} {
+		tdp.CCSDSPrimaryHeader = NewCCSDSPrimaryHeader(validFields(), dataLength)
+		buf := new(bytes.Buffer)
+		if err := binary.Write(buf, binary.BigEndian, tdp); err != nil {
+			t.Fatal(err)
+		}
+
+		pkt, def, err := r.Decode(buf.Bytes())
+		if err != nil {
+			t.Fatalf("Decode() error = %v", err)
+		}
+
+		dp := pkt.(*DictionaryPacket)
+		battery, _ := dp.Parameter("battery")
+		if float32(battery.Value) != tdp.TelemetryPayload.Battery {
+			t.Errorf("battery = %v; want %v", battery.Value, tdp.TelemetryPayload.Battery)
+		}
+
+		var gotFields []Field
+		got := limits.DetectAnomalies(pkt)
+		for i := range got {
+			gotFields = append(gotFields, got[i].Field)
+		}
+		var wantFields []Field
+		want := tdp.DetectAnomalies()
+		for i := range want {
+			wantFields = append(wantFields, want[i].Field)
+		}
+		if !reflect.DeepEqual(gotFields, wantFields) {
+			t.Errorf("anomalous fields = %v; want %v", gotFields, wantFields)
+		}
+
+		if got, want := len(def.Columns), len(def.Rows(pkt)[0]); got != want {
+			t.Errorf("len(Columns) = %d, len(row) = %d; want equal", got, want)
+		}
+	}
+}
+
+func TestDictionaryCalibrationAndLimits(t *testing.T) {
+	r, limits := loadTestDictionary(t)
+
+	const powerAPID = 0x02
+	b := make([]byte, 23)
+	h := NewCCSDSPrimaryHeader(PrimaryHeaderFields{
+		SecondaryHeaderFlag: true,
+		APID:                powerAPID,
+		SequenceFlags:       SEQ_FLAGS,
+	}, len(b)-PRIMARY_HEADER_SIZE)
+	binary.BigEndian.PutUint16(b[0:], h.PacketID)
+	binary.BigEndian.PutUint16(b[2:], h.PacketSeqCtrl)
+	binary.BigEndian.PutUint16(b[4:], h.PacketLength)
+	binary.BigEndian.PutUint64(b[6:], 1700000000)
+	binary.BigEndian.PutUint16(b[16:], 25500) // bus_voltage, mV
+	b[22] = 12                                // battery_soc, %
+
+	pkt, def, err := r.Decode(b)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+
+	voltage, _ := pkt.(*DictionaryPacket).Parameter("bus_voltage")
+	if voltage.Raw != 25500 || voltage.Value != 25.5 || voltage.Unit != "V" {
+		t.Errorf("bus_voltage = %+v; want raw 25500, value 25.5 V", voltage)
+	}
+
+	var got []string
+	anomalies := limits.DetectAnomalies(pkt)
+	for i := range anomalies {
+		if anomalies[i].Apid != powerAPID {
+			t.Errorf("anomaly APID = %#x; want %#x", anomalies[i].Apid, powerAPID)
+		}
+		got = append(got, anomalies[i].Parameter)
+	}
+	if want := []string{"bus_voltage", "battery_soc"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("anomalous parameters = %v; want %v", got, want)
+	}
+
+	if rows := def.Rows(pkt); def.Table != GENERIC_PARAMETER_TABLE || len(rows) != 4 {
+		t.Errorf("stored %d rows in %q; want 4 rows in %q", len(rows), def.Table, GENERIC_PARAMETER_TABLE)
+	}
+}
+
+func TestParseDictionaryErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "duplicate APID",
+			yaml: `
+packets:
+  - {name: a, apid: 1}
+  - {name: b, apid: 1}`,
+		},
+		{
+			name: "unknown type",
+			yaml: `
+packets:
+  - name: a
+    apid: 1
+    parameters: [{name: x, offset: 16, type: float16}]`,
+		},
+		{
+			name: "parameter overlaps headers",
+			yaml: `
+packets:
+  - name: a
+    apid: 1
+    parameters: [{name: x, offset: 8, type: uint8}]`,
+		},
+		{
+			name: "length too short",
+			yaml: `
+packets:
+  - name: a
+    apid: 1
+    length: 17
+    parameters: [{name: x, offset: 16, type: uint16}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseDictionary([]byte(tt.yaml)); err == nil {
+				t.Error("ParseDictionary() error = nil; want error")
+			}
+		})
+	}
+}
+
+func TestPacketSpecEncode(t *testing.T) {
+	d, err := LoadDictionary("../../../dictionary/telemetry.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRegistry()
+	if err := d.Register(r); err != nil {
+		t.Fatal(err)
+	}
+
+	power := d.Packets[1]
+	b, err := power.Encode(9, 1700000000, map[string]float64{
+		"bus_voltage": 28.5,
+		"battery_soc": 120, // saturates at the top of uint8
+	})
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	pkt, _, err := r.Decode(b)
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	dp := pkt.(*DictionaryPacket)
+	want := map[string]float64{"bus_voltage": 28.5, "bus_current": 0, "solar_array_power": 0, "battery_soc": 120}
+	for name, v := range want {
+		got, _ := dp.Parameter(name)
+		if math.Abs(got.Value-v) > 1e-9 {
+			t.Errorf("%s = %v; want %v", name, got.Value, v)
+		}
+	}
+	if dp.PrimaryHeader().SequenceCount() != 9 || dp.Timestamp() != 1700000000 {
+		t.Errorf("header = %+v, timestamp %d", dp.PrimaryHeader().Fields(), dp.Timestamp())
+	}
+
+	// The built-in main bus packet, with packet error control
+	spec := MainBusPacketSpec
+	spec.ErrorControl = true
+	def := TurionDataPacketDefinition
+	def.ErrorControl = true
+	r = NewRegistry()
+	if err := r.Register(def); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = spec.Encode(1, 1700000000, map[string]float64{"temperature": 25, "battery": 85})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkt, _, err = r.Decode(b)
+	if err != nil {
+		t.Fatalf("Decode(main bus) error = %v", err)
+	}
+	tdp := pkt.(*TurionDataPacket)
+	if tdp.TelemetryPayload.Temperature != 25 || tdp.TelemetryPayload.Battery != 85 || tdp.CCSDSSecondaryHeader.SubsystemID != SUBSYSTEM_ID {
+		t.Errorf("Decode(main bus) = %+v", tdp)
+	}
+}
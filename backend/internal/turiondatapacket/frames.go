@@ -0,0 +1,217 @@
+package turiondatapacket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// FrameType is the kind of transfer frame a ground station delivers
+type FrameType string
+
+const (
+	// FRAME_TYPE_TM is a TM Transfer Frame, CCSDS 132.0-B-3
+	FRAME_TYPE_TM FrameType = "tm"
+	// FRAME_TYPE_AOS is an AOS Transfer Frame, CCSDS 732.0-B-4
+	FRAME_TYPE_AOS FrameType = "aos"
+)
+
+// Sizes of the fixed parts of transfer frames, in bytes
+const (
+	FRAME_PRIMARY_HEADER_SIZE = 6
+	FECF_SIZE                 = 2
+	OCF_SIZE                  = 4
+	FHEC_SIZE                 = 2
+	MPDU_HEADER_SIZE          = 2
+)
+
+// First header pointer values that don't point at a packet
+const (
+	// FHP_NO_PACKET_START means the frame only continues a packet that
+	// started in an earlier frame
+	FHP_NO_PACKET_START = 0x7FF
+	// FHP_IDLE means the frame only carries idle data
+	FHP_IDLE = 0x7FE
+)
+
+// AOS_IDLE_VCID is the virtual channel AOS idle frames are sent on
+const AOS_IDLE_VCID = 63
+
+// Errors returned while decoding a frame. Like packet errors they are wrapped
+// with the offending values.
+var (
+	ErrFrameTooShort      = errors.New("transfer frame is too short")
+	ErrFrameVersion       = errors.New("unexpected transfer frame version")
+	ErrFrameChecksum      = errors.New("transfer frame FECF does not match")
+	ErrFrameNotPacketData = errors.New("transfer frame does not carry packets")
+	ErrFirstHeaderPointer = errors.New("first header pointer is past the end of the frame")
+)
+
+// FrameFormat describes the optional fields of a mission's frames. They are
+// fixed for a physical channel, so frames don't say whether they have them.
+type FrameFormat struct {
+	Type FrameType
+	// HasFECF is set when frames end with a CRC-16 frame error control field
+	HasFECF bool
+	// HasOCF is set when AOS frames carry an operational control field. TM
+	// frames flag it in their header instead.
+	HasOCF bool
+	// HasFHEC is set when AOS frames carry a frame header error control field
+	HasFHEC bool
+	// InsertZoneLength is the length of the AOS insert zone, if any
+	InsertZoneLength int
+}
+
+// Frame is what the packet demultiplexer needs from a transfer frame
+type Frame struct {
+	SpacecraftID     uint16
+	VirtualChannelID uint8
+	// FrameCount is the virtual channel frame count, which rolls over at
+	// FrameCountModulus
+	FrameCount        uint32
+	FrameCountModulus uint32
+	// FirstHeaderPointer is the offset in Data of the first packet that starts
+	// in this frame, or FHP_NO_PACKET_START
+	FirstHeaderPointer uint16
+	// Idle frames carry no packets
+	Idle bool
+	// Data is the packet zone, a slice of the decoded bytes
+	Data []byte
+}
+
+// Validate checks the format is one Decode understands
+func (f FrameFormat) Validate() error {
+	var errs error
+	if f.Type != FRAME_TYPE_TM && f.Type != FRAME_TYPE_AOS {
+		errs = errors.Join(errs, fmt.Errorf("unknown frame type %q", f.Type))
+	}
+	if f.Type == FRAME_TYPE_TM && (f.HasOCF || f.HasFHEC || f.InsertZoneLength != 0) {
+		errs = errors.Join(errs, errors.New("OCF, FHEC and insert zone options only apply to AOS frames"))
+	}
+	if f.InsertZoneLength < 0 {
+		errs = errors.Join(errs, errors.New("insert zone length cannot be negative"))
+	}
+	return errs
+}
+
+// Decode checks the frame's version and FECF and finds its packet zone
+func (f FrameFormat) Decode(b []byte) (Frame, error) {
+	if f.HasFECF {
+		if len(b) < FRAME_PRIMARY_HEADER_SIZE+FECF_SIZE {
+			return Frame{}, fmt.Errorf("%w: got %d bytes", ErrFrameTooShort, len(b))
+		}
+		body := b[:len(b)-FECF_SIZE]
+		want := binary.BigEndian.Uint16(b[len(body):])
+		if got := CRC16CCITT(body); got != want {
+			return Frame{}, fmt.Errorf("%w: computed %#04x, frame has %#04x", ErrFrameChecksum, got, want)
+		}
+		b = body
+	}
+
+	if f.Type == FRAME_TYPE_AOS {
+		return f.decodeAOS(b)
+	}
+	return decodeTM(b)
+}
+
+// decodeTM decodes a TM frame whose FECF has been removed
+func decodeTM(b []byte) (Frame, error) {
+	if len(b) < FRAME_PRIMARY_HEADER_SIZE {
+		return Frame{}, fmt.Errorf("%w: got %d bytes", ErrFrameTooShort, len(b))
+	}
+
+	id := binary.BigEndian.Uint16(b[0:2])
+	if version := id >> 14; version != 0 {
+		return Frame{}, fmt.Errorf("%w: got %d, want 0 for TM", ErrFrameVersion, version)
+	}
+
+	status := binary.BigEndian.Uint16(b[4:6])
+	if status>>14&0x1 == 1 {
+		return Frame{}, fmt.Errorf("%w: synchronization flag is set", ErrFrameNotPacketData)
+	}
+
+	start := FRAME_PRIMARY_HEADER_SIZE
+	if status>>15 == 1 {
+		if len(b) <= start {
+			return Frame{}, fmt.Errorf("%w: missing secondary header", ErrFrameTooShort)
+		}
+		// The secondary header identification field holds its length minus one
+		start += int(b[start]&0x3F) + 1
+	}
+
+	end := len(b)
+	if id&0x1 == 1 {
+		end -= OCF_SIZE
+	}
+	if end < start {
+		return Frame{}, fmt.Errorf("%w: got %d bytes", ErrFrameTooShort, len(b))
+	}
+
+	fhp := status & 0x7FF
+	return newFrame(Frame{
+		SpacecraftID:       id >> 4 & 0x3FF,
+		VirtualChannelID:   uint8(id >> 1 & 0x7),
+		FrameCount:         uint32(b[3]),
+		FrameCountModulus:  1 << 8,
+		FirstHeaderPointer: fhp,
+		Idle:               fhp == FHP_IDLE,
+		Data:               b[start:end],
+	})
+}
+
+// decodeAOS decodes an AOS frame whose FECF has been removed
+func (f FrameFormat) decodeAOS(b []byte) (Frame, error) {
+	start := FRAME_PRIMARY_HEADER_SIZE + f.InsertZoneLength + MPDU_HEADER_SIZE
+	if f.HasFHEC {
+		start += FHEC_SIZE
+	}
+	end := len(b)
+	if f.HasOCF {
+		end -= OCF_SIZE
+	}
+	if end < start {
+		return Frame{}, fmt.Errorf("%w: got %d bytes", ErrFrameTooShort, len(b))
+	}
+
+	id := binary.BigEndian.Uint16(b[0:2])
+	if version := id >> 14; version != 1 {
+		return Frame{}, fmt.Errorf("%w: got %d, want 1 for AOS", ErrFrameVersion, version)
+	}
+
+	vcid := uint8(id & 0x3F)
+	fhp := binary.BigEndian.Uint16(b[start-MPDU_HEADER_SIZE:start]) & 0x7FF
+	return newFrame(Frame{
+		SpacecraftID:       id >> 6 & 0xFF,
+		VirtualChannelID:   vcid,
+		FrameCount:         uint32(b[2])<<16 | uint32(b[3])<<8 | uint32(b[4]),
+		FrameCountModulus:  1 << 24,
+		FirstHeaderPointer: fhp,
+		Idle:               vcid == AOS_IDLE_VCID || fhp == FHP_IDLE,
+		Data:               b[start:end],
+	})
+}
+
+func newFrame(f Frame) (Frame, error) {
+	if !f.Idle && f.FirstHeaderPointer != FHP_NO_PACKET_START && int(f.FirstHeaderPointer) >= len(f.Data) {
+		return Frame{}, fmt.Errorf("%w: pointer is %d, packet zone is %d bytes",
+			ErrFirstHeaderPointer, f.FirstHeaderPointer, len(f.Data))
+	}
+	return f, nil
+}
+
+// CRC16CCITT is the CRC used by the transfer frame FECF: polynomial 0x1021,
+// initial value 0xFFFF, no reflection and no final XOR
+func CRC16CCITT(b []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, c := range b {
+		crc ^= uint16(c) << 8
+		for range 8 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
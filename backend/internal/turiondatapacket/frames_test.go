@@ -0,0 +1,118 @@
+package turiondatapacket
+
+import (
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// tmFrame builds a TM frame on virtual channel vcid of spacecraft 42 with a
+// FECF, and an OCF when ocf is set
+func tmFrame(vcid uint8, count uint8, fhp uint16, data []byte, ocf bool) []byte {
+	id := uint16(42)<<4 | uint16(vcid)<<1
+	if ocf {
+		id |= 1
+	}
+	b := binary.BigEndian.AppendUint16(nil, id)
+	b = append(b, 0, count)
+	b = binary.BigEndian.AppendUint16(b, fhp)
+	b = append(b, data...)
+	if ocf {
+		b = append(b, 0xDE, 0xAD, 0xBE, 0xEF)
+	}
+	return binary.BigEndian.AppendUint16(b, CRC16CCITT(b))
+}
+
+func TestCRC16CCITT(t *testing.T) {
+	// The standard check value of CRC-16/CCITT-FALSE
+	if got := CRC16CCITT([]byte("123456789")); got != 0x29B1 {
+		t.Errorf("CRC16CCITT = %#04x; want 0x29b1", got)
+	}
+}
+
+func TestDecodeFrame(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	tm := FrameFormat{Type: FRAME_TYPE_TM, HasFECF: true}
+
+	corrupt := tmFrame(3, 7, 0, data, false)
+	corrupt[8] ^= 0xFF
+
+	withSecondaryHeader := binary.BigEndian.AppendUint16(nil, uint16(42)<<4|3<<1)
+	withSecondaryHeader = append(withSecondaryHeader, 0, 7)
+	withSecondaryHeader = binary.BigEndian.AppendUint16(withSecondaryHeader, 1<<15|2)
+	// A two byte secondary header: its ID field holds the length minus one
+	withSecondaryHeader = append(withSecondaryHeader, 1, 0xAA)
+	withSecondaryHeader = append(withSecondaryHeader, data...)
+
+	aos := binary.BigEndian.AppendUint16(nil, 1<<14|uint16(42)<<6|5)
+	aos = append(aos, 0x01, 0x00, 0x02, 0)
+	aos = append(aos, 0xEE, 0xEE) // insert zone
+	aos = binary.BigEndian.AppendUint16(aos, 4)
+	aos = append(aos, data...)
+	aos = append(aos, 0xDE, 0xAD, 0xBE, 0xEF)
+
+	tests := []struct {
+		name    string
+		format  FrameFormat
+		b       []byte
+		want    Frame
+		wantErr error
+	}{
+		{
+			name:   "tm",
+			format: tm,
+			b:      tmFrame(3, 7, 2, data, false),
+			want: Frame{SpacecraftID: 42, VirtualChannelID: 3, FrameCount: 7, FrameCountModulus: 256,
+				FirstHeaderPointer: 2, Data: data},
+		},
+		{
+			name:   "tm with OCF",
+			format: tm,
+			b:      tmFrame(3, 7, FHP_NO_PACKET_START, data, true),
+			want: Frame{SpacecraftID: 42, VirtualChannelID: 3, FrameCount: 7, FrameCountModulus: 256,
+				FirstHeaderPointer: FHP_NO_PACKET_START, Data: data},
+		},
+		{
+			name:   "tm idle",
+			format: tm,
+			b:      tmFrame(7, 0, FHP_IDLE, data, false),
+			want: Frame{SpacecraftID: 42, VirtualChannelID: 7, FrameCountModulus: 256,
+				FirstHeaderPointer: FHP_IDLE, Idle: true, Data: data},
+		},
+		{
+			name:   "tm with secondary header, no FECF",
+			format: FrameFormat{Type: FRAME_TYPE_TM},
+			b:      withSecondaryHeader,
+			want: Frame{SpacecraftID: 42, VirtualChannelID: 3, FrameCount: 7, FrameCountModulus: 256,
+				FirstHeaderPointer: 2, Data: data},
+		},
+		{
+			name:   "aos with insert zone and OCF",
+			format: FrameFormat{Type: FRAME_TYPE_AOS, HasOCF: true, InsertZoneLength: 2},
+			b:      aos,
+			want: Frame{SpacecraftID: 42, VirtualChannelID: 5, FrameCount: 0x010002, FrameCountModulus: 1 << 24,
+				FirstHeaderPointer: 4, Data: data},
+		},
+		{name: "bad FECF", format: tm, b: corrupt, wantErr: ErrFrameChecksum},
+		{name: "too short", format: tm, b: []byte{0, 0, 0}, wantErr: ErrFrameTooShort},
+		{name: "aos read as tm", format: FrameFormat{Type: FRAME_TYPE_TM}, b: aos, wantErr: ErrFrameVersion},
+		{name: "pointer past the data", format: tm, b: tmFrame(3, 7, 8, data, false), wantErr: ErrFirstHeaderPointer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.format.Decode(tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v; want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Decode() = %+v; want %+v", got, tt.want)
+			}
+		})
+	}
+}
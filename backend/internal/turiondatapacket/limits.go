@@ -0,0 +1,251 @@
+package turiondatapacket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Limit is the yellow (warning) and red (critical) bands for one parameter.
+// Any bound may be omitted. A reading outside a red bound is RED, otherwise a
+// reading outside a yellow bound is YELLOW.
+type Limit struct {
+	Parameter string `yaml:"parameter" json:"parameter"`
+	// APID restricts the limit to one packet type. Limits without an APID apply
+	// to the parameter in every packet that doesn't have its own limit.
+	APID       *uint16  `yaml:"apid" json:"apid,omitempty"`
+	YellowLow  *float64 `yaml:"yellowLow" json:"yellowLow,omitempty"`
+	YellowHigh *float64 `yaml:"yellowHigh" json:"yellowHigh,omitempty"`
+	RedLow     *float64 `yaml:"redLow" json:"redLow,omitempty"`
+	RedHigh    *float64 `yaml:"redHigh" json:"redHigh,omitempty"`
+}
+
+// LimitSet is every limit in effect at once, typically for a mission phase
+// like "commissioning" or "nominal"
+type LimitSet struct {
+	Name   string  `yaml:"name" json:"name"`
+	Limits []Limit `yaml:"limits" json:"limits"`
+}
+
+// LimitSource loads the limit set that should currently be in effect
+type LimitSource interface {
+	LoadLimits(context.Context) (*LimitSet, error)
+}
+
+// Parameters are implemented by packets whose values can be limit checked
+type Parameters interface {
+	Packet
+	Values() []ParameterValue
+}
+
+// LimitChecker evaluates packets against the current limit set. The set can be
+// swapped with Reload while packets are being checked.
+type LimitChecker struct {
+	current atomic.Pointer[limitIndex]
+}
+
+type limitKey struct {
+	apid      uint16
+	anyAPID   bool
+	parameter string
+}
+
+type limitIndex struct {
+	name   string
+	limits map[limitKey]Limit
+}
+
+func NewLimitChecker(set *LimitSet) (*LimitChecker, error) {
+	c := &LimitChecker{}
+	if err := c.Reload(set); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Reload validates set and makes it the limit set used for every packet
+// checked afterwards
+func (c *LimitChecker) Reload(set *LimitSet) error {
+	if err := set.Validate(); err != nil {
+		return err
+	}
+
+	idx := &limitIndex{name: set.Name, limits: map[limitKey]Limit{}}
+	for _, l := range set.Limits {
+		idx.limits[l.key()] = l
+	}
+	c.current.Store(idx)
+	return nil
+}
+
+// Name returns the name of the limit set currently in effect
+func (c *LimitChecker) Name() string {
+	return c.current.Load().name
+}
+
+// DetectAnomalies returns one Anomaly per parameter of p that is outside its
+// limits. Packets that don't carry parameters never have anomalies.
+func (c *LimitChecker) DetectAnomalies(p Packet) []Anomaly {
+	pp, ok := p.(Parameters)
+	if !ok {
+		return nil
+	}
+
+	idx := c.current.Load()
+	apid := p.PrimaryHeader().APID()
+
+	var anomalies []Anomaly
+	for _, v := range pp.Values() {
+		limit, ok := idx.limits[limitKey{apid: apid, parameter: v.Name}]
+		if !ok {
+			limit, ok = idx.limits[limitKey{anyAPID: true, parameter: v.Name}]
+		}
+		if !ok {
+			continue
+		}
+
+		severity, bound, threshold := limit.Evaluate(v.Value)
+		if severity == Severity_SEVERITY_UNSPECIFIED {
+			continue
+		}
+
+		anomalies = append(anomalies, Anomaly{
+			Value:     float32(v.Value),
+			Timestamp: p.Timestamp(),
+			Field:     fieldForParameter(v.Name),
+			Parameter: v.Name,
+			Apid:      uint32(apid),
+			Severity:  severity,
+			LimitSet:  idx.name,
+			Bound:     bound,
+			Threshold: float32(threshold),
+		})
+	}
+	return anomalies
+}
+
+// Evaluate returns the severity of v and the bound it crossed. A value inside
+// every bound returns SEVERITY_UNSPECIFIED.
+func (l Limit) Evaluate(v float64) (Severity, LimitBound, float64) {
+	switch {
+	case l.RedLow != nil && v < *l.RedLow:
+		return Severity_RED, LimitBound_LOW, *l.RedLow
+	case l.RedHigh != nil && v > *l.RedHigh:
+		return Severity_RED, LimitBound_HIGH, *l.RedHigh
+	case l.YellowLow != nil && v < *l.YellowLow:
+		return Severity_YELLOW, LimitBound_LOW, *l.YellowLow
+	case l.YellowHigh != nil && v > *l.YellowHigh:
+		return Severity_YELLOW, LimitBound_HIGH, *l.YellowHigh
+	}
+	return Severity_SEVERITY_UNSPECIFIED, LimitBound_LIMIT_BOUND_UNSPECIFIED, 0
+}
+
+func (l Limit) key() limitKey {
+	if l.APID == nil {
+		return limitKey{anyAPID: true, parameter: l.Parameter}
+	}
+	return limitKey{apid: *l.APID, parameter: l.Parameter}
+}
+
+// Validate checks that every limit names a parameter, is only defined once, and
+// has its yellow band inside its red band
+func (s *LimitSet) Validate() error {
+	if s == nil {
+		return errors.New("limit set cannot be nil")
+	}
+
+	var errs error
+	if s.Name == "" {
+		errs = errors.Join(errs, errors.New("limit set name is required"))
+	}
+
+	seen := map[limitKey]bool{}
+	for _, l := range s.Limits {
+		if l.Parameter == "" {
+			errs = errors.Join(errs, errors.New("limit parameter is required"))
+			continue
+		}
+		if seen[l.key()] {
+			errs = errors.Join(errs, fmt.Errorf("limit for %q is defined twice", l.Parameter))
+		}
+		seen[l.key()] = true
+
+		if l.RedLow != nil && l.YellowLow != nil && *l.YellowLow < *l.RedLow {
+			errs = errors.Join(errs, fmt.Errorf("%q: yellow low is below red low", l.Parameter))
+		}
+		if l.RedHigh != nil && l.YellowHigh != nil && *l.YellowHigh > *l.RedHigh {
+			errs = errors.Join(errs, fmt.Errorf("%q: yellow high is above red high", l.Parameter))
+		}
+		if l.YellowLow != nil && l.YellowHigh != nil && *l.YellowLow > *l.YellowHigh {
+			errs = errors.Join(errs, fmt.Errorf("%q: yellow low is above yellow high", l.Parameter))
+		}
+		if l.RedLow != nil && l.RedHigh != nil && *l.RedLow > *l.RedHigh {
+			errs = errors.Join(errs, fmt.Errorf("%q: red low is above red high", l.Parameter))
+		}
+	}
+	return errs
+}
+
+// limitsFile is the layout of a YAML limits file. It may hold the limit sets of
+// several mission phases, Active names the one in effect.
+type limitsFile struct {
+	Active string     `yaml:"active"`
+	Sets   []LimitSet `yaml:"sets"`
+}
+
+// FileLimitSource loads the active limit set from a YAML file every time it is
+// asked, so edits to the file are picked up on the next reload
+type FileLimitSource struct {
+	Path string
+}
+
+func (s FileLimitSource) LoadLimits(ctx context.Context) (*LimitSet, error) {
+	b, err := os.ReadFile(s.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading limits file: %w", err)
+	}
+
+	var f limitsFile
+	if err := yaml.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("parsing limits file: %w", err)
+	}
+
+	for i := range f.Sets {
+		if f.Sets[i].Name == f.Active {
+			return &f.Sets[i], f.Sets[i].Validate()
+		}
+	}
+	return nil, fmt.Errorf("limits file has no set named %q", f.Active)
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+// DefaultLimitSet holds the limits the main bus packet has always been checked
+// against. The red limits are the original anomaly thresholds.
+var DefaultLimitSet = LimitSet{
+	Name: "default",
+	Limits: []Limit{
+		// normal 20–30
+		{Parameter: "temperature", YellowHigh: float64Ptr(32), RedHigh: float64Ptr(35)},
+		// normal 70–100
+		{Parameter: "battery", YellowLow: float64Ptr(60), RedLow: float64Ptr(40)},
+		// normal 500–550
+		{Parameter: "altitude", YellowLow: float64Ptr(450), RedLow: float64Ptr(400)},
+		// normal -60…-40
+		{Parameter: "signal", YellowLow: float64Ptr(-70), RedLow: float64Ptr(-80)},
+	},
+}
+
+var defaultLimitChecker = func() *LimitChecker {
+	c, err := NewLimitChecker(&DefaultLimitSet)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}()
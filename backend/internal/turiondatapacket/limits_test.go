@@ -0,0 +1,106 @@
+package turiondatapacket
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLimitEvaluate(t *testing.T) {
+	limit := Limit{
+		Parameter:  "battery",
+		YellowLow:  float64Ptr(60),
+		YellowHigh: float64Ptr(95),
+		RedLow:     float64Ptr(40),
+		RedHigh:    float64Ptr(99),
+	}
+
+	tests := []struct {
+		value         float64
+		wantSeverity  Severity
+		wantBound     LimitBound
+		wantThreshold float64
+	}{
+		{value: 80, wantSeverity: Severity_SEVERITY_UNSPECIFIED},
+		{value: 60, wantSeverity: Severity_SEVERITY_UNSPECIFIED},
+		{value: 59, wantSeverity: Severity_YELLOW, wantBound: LimitBound_LOW, wantThreshold: 60},
+		{value: 39, wantSeverity: Severity_RED, wantBound: LimitBound_LOW, wantThreshold: 40},
+		{value: 96, wantSeverity: Severity_YELLOW, wantBound: LimitBound_HIGH, wantThreshold: 95},
+		{value: 100, wantSeverity: Severity_RED, wantBound: LimitBound_HIGH, wantThreshold: 99},
+	}
+
+	for _, tt := range tests {
+		severity, bound, threshold := limit.Evaluate(tt.value)
+		if severity != tt.wantSeverity || bound != tt.wantBound || threshold != tt.wantThreshold {
+			t.Errorf("Evaluate(%v) = %v, %v, %v; want %v, %v, %v",
+				tt.value, severity, bound, threshold,
+				tt.wantSeverity, tt.wantBound, tt.wantThreshold)
+		}
+	}
+}
+
+func TestLimitCheckerReload(t *testing.T) {
+	pkt := makePacket(25, 50, 525, -50, 1700000000)
+
+	checker, err := NewLimitChecker(&DefaultLimitSet)
+	if err != nil {
+		t.Fatalf("NewLimitChecker() error = %v", err)
+	}
+	if got := checker.DetectAnomalies(&pkt); len(got) != 1 || got[0].Severity != Severity_YELLOW {
+		t.Fatalf("DetectAnomalies() with default limits = %v; want one yellow battery anomaly", got)
+	}
+
+	// A mission phase where the battery is expected to run low, except on the
+	// main bus which keeps a tighter limit
+	apid := uint16(0)
+	err = checker.Reload(&LimitSet{
+		Name: "eclipse",
+		Limits: []Limit{
+			{Parameter: "battery", RedLow: float64Ptr(20)},
+			{Parameter: "battery", APID: &apid, RedLow: float64Ptr(55)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Reload() error = %v", err)
+	}
+
+	got := checker.DetectAnomalies(&pkt)
+	if len(got) != 1 || got[0].Severity != Severity_RED || got[0].LimitSet != "eclipse" || got[0].Threshold != 55 {
+		t.Errorf("DetectAnomalies() after reload = %v; want one red battery anomaly from the APID limit", got)
+	}
+
+	if err := checker.Reload(&LimitSet{Name: "bad", Limits: []Limit{
+		{Parameter: "battery", YellowLow: float64Ptr(10), RedLow: float64Ptr(20)},
+	}}); err == nil {
+		t.Error("Reload() with yellow outside red error = nil; want error")
+	}
+	if checker.Name() != "eclipse" {
+		t.Errorf("Name() after failed reload = %q; want %q", checker.Name(), "eclipse")
+	}
+}
+
+func TestFileLimitSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "limits.yaml")
+	err := os.WriteFile(path, []byte(`
+active: safe_mode
+sets:
+  - name: nominal
+    limits:
+      - {parameter: temperature, yellowHigh: 32, redHigh: 35}
+  - name: safe_mode
+    limits:
+      - {parameter: temperature, apid: 0x01, yellowHigh: 40, redHigh: 45}
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set, err := FileLimitSource{Path: path}.LoadLimits(context.Background())
+	if err != nil {
+		t.Fatalf("LoadLimits() error = %v", err)
+	}
+	if set.Name != "safe_mode" || len(set.Limits) != 1 || *set.Limits[0].APID != APID {
+		t.Errorf("LoadLimits() = %+v; want the safe_mode set", set)
+	}
+}
@@ -0,0 +1,272 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.35.2
+// 	protoc        v4.25.3
+// source: protobufs/link_event.proto
+
+package turiondatapacket
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Kinds of link quality event detected from packet sequence counts and
+// packet error control.
+type LinkEventKind int32
+
+const (
+	// must start at 0
+	LinkEventKind_LINK_EVENT_KIND_UNSPECIFIED LinkEventKind = 0
+	// One or more packets were never received
+	LinkEventKind_GAP LinkEventKind = 1
+	// A sequence count that was already received arrived again
+	LinkEventKind_DUPLICATE LinkEventKind = 2
+	// A packet arrived after packets with later sequence counts
+	LinkEventKind_OUT_OF_ORDER LinkEventKind = 3
+	// The 14-bit sequence count wrapped back to zero
+	LinkEventKind_ROLLOVER LinkEventKind = 4
+	// The sequence count jumped too far backwards to be a late packet, most
+	// likely because the spacecraft rebooted
+	LinkEventKind_RESET LinkEventKind = 5
+	// A packet failed its packet error control check. Only the APID and the
+	// received sequence count are set, and the timestamp is when it arrived
+	LinkEventKind_CHECKSUM LinkEventKind = 6
+)
+
+// Enum value maps for LinkEventKind.
+var (
+	LinkEventKind_name = map[int32]string{
+		0: "LINK_EVENT_KIND_UNSPECIFIED",
+		1: "GAP",
+		2: "DUPLICATE",
+		3: "OUT_OF_ORDER",
+		4: "ROLLOVER",
+		5: "RESET",
+		6: "CHECKSUM",
+	}
+	LinkEventKind_value = map[string]int32{
+		"LINK_EVENT_KIND_UNSPECIFIED": 0,
+		"GAP":                         1,
+		"DUPLICATE":                   2,
+		"OUT_OF_ORDER":                3,
+		"ROLLOVER":                    4,
+		"RESET":                       5,
+		"CHECKSUM":                    6,
+	}
+)
+
+func (x LinkEventKind) Enum() *LinkEventKind {
+	p := new(LinkEventKind)
+	*p = x
+	return p
+}
+
+func (x LinkEventKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LinkEventKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_protobufs_link_event_proto_enumTypes[0].Descriptor()
+}
+
+func (LinkEventKind) Type() protoreflect.EnumType {
+	return &file_protobufs_link_event_proto_enumTypes[0]
+}
+
+func (x LinkEventKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use LinkEventKind.Descriptor instead.
+func (LinkEventKind) EnumDescriptor() ([]byte, []int) {
+	return file_protobufs_link_event_proto_rawDescGZIP(), []int{0}
+}
+
+// LinkEvent is a single sequence count discontinuity or corrupted packet on
+// one APID.
+type LinkEvent struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Apid uint32        `protobuf:"varint,1,opt,name=apid,proto3" json:"apid,omitempty"`
+	Kind LinkEventKind `protobuf:"varint,2,opt,name=kind,proto3,enum=turiondatapacket.LinkEventKind" json:"kind,omitempty"`
+	// The sequence count that should have come next
+	ExpectedSeqCount uint32 `protobuf:"varint,3,opt,name=expected_seq_count,json=expectedSeqCount,proto3" json:"expected_seq_count,omitempty"`
+	ReceivedSeqCount uint32 `protobuf:"varint,4,opt,name=received_seq_count,json=receivedSeqCount,proto3" json:"received_seq_count,omitempty"`
+	// Number of packets skipped. Only set for gaps
+	MissingCount uint32 `protobuf:"varint,5,opt,name=missing_count,json=missingCount,proto3" json:"missing_count,omitempty"`
+	// Timestamp of the packet that revealed the event
+	Timestamp uint64 `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+}
+
+func (x *LinkEvent) Reset() {
+	*x = LinkEvent{}
+	mi := &file_protobufs_link_event_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *LinkEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LinkEvent) ProtoMessage() {}
+
+func (x *LinkEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_protobufs_link_event_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LinkEvent.ProtoReflect.Descriptor instead.
+func (*LinkEvent) Descriptor() ([]byte, []int) {
+	return file_protobufs_link_event_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LinkEvent) GetApid() uint32 {
+	if x != nil {
+		return x.Apid
+	}
+	return 0
+}
+
+func (x *LinkEvent) GetKind() LinkEventKind {
+	if x != nil {
+		return x.Kind
+	}
+	return LinkEventKind_LINK_EVENT_KIND_UNSPECIFIED
+}
+
+func (x *LinkEvent) GetExpectedSeqCount() uint32 {
+	if x != nil {
+		return x.ExpectedSeqCount
+	}
+	return 0
+}
+
+func (x *LinkEvent) GetReceivedSeqCount() uint32 {
+	if x != nil {
+		return x.ReceivedSeqCount
+	}
+	return 0
+}
+
+func (x *LinkEvent) GetMissingCount() uint32 {
+	if x != nil {
+		return x.MissingCount
+	}
+	return 0
+}
+
+func (x *LinkEvent) GetTimestamp() uint64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+var File_protobufs_link_event_proto protoreflect.FileDescriptor
+
+var file_protobufs_link_event_proto_rawDesc = []byte{
+	0x0a, 0x1a, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x73, 0x2f, 0x6c, 0x69, 0x6e, 0x6b,
+	0x5f, 0x65, 0x76, 0x65, 0x6e, 0x74, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x10, 0x74, 0x75,
+	0x72, 0x69, 0x6f, 0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x22, 0xf3,
+	0x01, 0x0a, 0x09, 0x4c, 0x69, 0x6e, 0x6b, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x12, 0x12, 0x0a, 0x04,
+	0x61, 0x70, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x04, 0x61, 0x70, 0x69, 0x64,
+	0x12, 0x33, 0x0a, 0x04, 0x6b, 0x69, 0x6e, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x1f,
+	0x2e, 0x74, 0x75, 0x72, 0x69, 0x6f, 0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65,
+	0x74, 0x2e, 0x4c, 0x69, 0x6e, 0x6b, 0x45, 0x76, 0x65, 0x6e, 0x74, 0x4b, 0x69, 0x6e, 0x64, 0x52,
+	0x04, 0x6b, 0x69, 0x6e, 0x64, 0x12, 0x2c, 0x0a, 0x12, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65,
+	0x64, 0x5f, 0x73, 0x65, 0x71, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x10, 0x65, 0x78, 0x70, 0x65, 0x63, 0x74, 0x65, 0x64, 0x53, 0x65, 0x71, 0x43, 0x6f,
+	0x75, 0x6e, 0x74, 0x12, 0x2c, 0x0a, 0x12, 0x72, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x5f,
+	0x73, 0x65, 0x71, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0d, 0x52,
+	0x10, 0x72, 0x65, 0x63, 0x65, 0x69, 0x76, 0x65, 0x64, 0x53, 0x65, 0x71, 0x43, 0x6f, 0x75, 0x6e,
+	0x74, 0x12, 0x23, 0x0a, 0x0d, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6e, 0x67, 0x5f, 0x63, 0x6f, 0x75,
+	0x6e, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0c, 0x6d, 0x69, 0x73, 0x73, 0x69, 0x6e,
+	0x67, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x18, 0x06, 0x20, 0x01, 0x28, 0x04, 0x52, 0x09, 0x74, 0x69, 0x6d, 0x65, 0x73,
+	0x74, 0x61, 0x6d, 0x70, 0x2a, 0x81, 0x01, 0x0a, 0x0d, 0x4c, 0x69, 0x6e, 0x6b, 0x45, 0x76, 0x65,
+	0x6e, 0x74, 0x4b, 0x69, 0x6e, 0x64, 0x12, 0x1f, 0x0a, 0x1b, 0x4c, 0x49, 0x4e, 0x4b, 0x5f, 0x45,
+	0x56, 0x45, 0x4e, 0x54, 0x5f, 0x4b, 0x49, 0x4e, 0x44, 0x5f, 0x55, 0x4e, 0x53, 0x50, 0x45, 0x43,
+	0x49, 0x46, 0x49, 0x45, 0x44, 0x10, 0x00, 0x12, 0x07, 0x0a, 0x03, 0x47, 0x41, 0x50, 0x10, 0x01,
+	0x12, 0x0d, 0x0a, 0x09, 0x44, 0x55, 0x50, 0x4c, 0x49, 0x43, 0x41, 0x54, 0x45, 0x10, 0x02, 0x12,
+	0x10, 0x0a, 0x0c, 0x4f, 0x55, 0x54, 0x5f, 0x4f, 0x46, 0x5f, 0x4f, 0x52, 0x44, 0x45, 0x52, 0x10,
+	0x03, 0x12, 0x0c, 0x0a, 0x08, 0x52, 0x4f, 0x4c, 0x4c, 0x4f, 0x56, 0x45, 0x52, 0x10, 0x04, 0x12,
+	0x09, 0x0a, 0x05, 0x52, 0x45, 0x53, 0x45, 0x54, 0x10, 0x05, 0x12, 0x0c, 0x0a, 0x08, 0x43, 0x48,
+	0x45, 0x43, 0x4b, 0x53, 0x55, 0x4d, 0x10, 0x06, 0x42, 0x34, 0x5a, 0x32, 0x62, 0x61, 0x63, 0x6b,
+	0x65, 0x6e, 0x64, 0x2f, 0x69, 0x6e, 0x74, 0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x74, 0x75, 0x72,
+	0x69, 0x6f, 0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x3b, 0x74, 0x75,
+	0x72, 0x69, 0x6f, 0x6e, 0x64, 0x61, 0x74, 0x61, 0x70, 0x61, 0x63, 0x6b, 0x65, 0x74, 0x62, 0x06,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_protobufs_link_event_proto_rawDescOnce sync.Once
+	file_protobufs_link_event_proto_rawDescData = file_protobufs_link_event_proto_rawDesc
+)
+
+func file_protobufs_link_event_proto_rawDescGZIP() []byte {
+	file_protobufs_link_event_proto_rawDescOnce.Do(func() {
+		file_protobufs_link_event_proto_rawDescData = protoimpl.X.CompressGZIP(file_protobufs_link_event_proto_rawDescData)
+	})
+	return file_protobufs_link_event_proto_rawDescData
+}
+
+var file_protobufs_link_event_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
+var file_protobufs_link_event_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_protobufs_link_event_proto_goTypes = []any{
+	(LinkEventKind)(0), // 0: turiondatapacket.LinkEventKind
+	(*LinkEvent)(nil),  // 1: turiondatapacket.LinkEvent
+}
+var file_protobufs_link_event_proto_depIdxs = []int32{
+	0, // 0: turiondatapacket.LinkEvent.kind:type_name -> turiondatapacket.LinkEventKind
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_protobufs_link_event_proto_init() }
+func file_protobufs_link_event_proto_init() {
+	if File_protobufs_link_event_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_protobufs_link_event_proto_rawDesc,
+			NumEnums:      1,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_protobufs_link_event_proto_goTypes,
+		DependencyIndexes: file_protobufs_link_event_proto_depIdxs,
+		EnumInfos:         file_protobufs_link_event_proto_enumTypes,
+		MessageInfos:      file_protobufs_link_event_proto_msgTypes,
+	}.Build()
+	File_protobufs_link_event_proto = out.File
+	file_protobufs_link_event_proto_rawDesc = nil
+	file_protobufs_link_event_proto_goTypes = nil
+	file_protobufs_link_event_proto_depIdxs = nil
+}
@@ -19,3 +19,40 @@ type PayloadStats struct {
 	MaxSignal float32 `json:"maxSignal"`
 	AvgSignal float32 `json:"avgSignal"`
 }
+
+// PayloadParameters are the TelemetryPayload fields, named like their
+// columns in turion_data_packets
+var PayloadParameters = []string{"temperature", "battery", "altitude", "signal"}
+
+// PayloadBucket summarises the packets whose timestamp falls in
+// [Start, Start+bucket size)
+type PayloadBucket struct {
+	Start      uint64                    `json:"start"`
+	Count      int64                     `json:"count"`
+	Parameters map[string]ParameterStats `json:"parameters"`
+}
+
+// ParameterStats summarises one parameter over a bucket. Stddev is the
+// population standard deviation, Percentiles are keyed "p50", "p99.9" etc and
+// interpolated between values.
+type ParameterStats struct {
+	Min         float64            `json:"min"`
+	Max         float64            `json:"max"`
+	Avg         float64            `json:"avg"`
+	Stddev      float64            `json:"stddev"`
+	Percentiles map[string]float64 `json:"percentiles,omitempty"`
+}
+
+// PayloadSeries holds samples of parameters over a range of packets
+type PayloadSeries struct {
+	// Total is the number of packets in the range
+	Total      int
+	Parameters map[string]ParameterSeries
+}
+
+// ParameterSeries is the samples of one parameter, oldest first, Values lined
+// up with Timestamps
+type ParameterSeries struct {
+	Timestamps []uint64
+	Values     []float64
+}
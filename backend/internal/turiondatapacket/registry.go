@@ -0,0 +1,165 @@
+package turiondatapacket
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Packet is a decoded space packet of any registered type
+type Packet interface {
+	PrimaryHeader() CCSDSPrimaryHeader
+	// Timestamp is the packet generation time in unix seconds
+	Timestamp() uint64
+}
+
+// PacketDecoder decodes b, the full packet including the primary header, into
+// a Packet. The primary header has already been validated and is passed along
+// so decoders don't have to parse it again. The packet error control field, if
+// the definition has one, has been checked and removed from b.
+type PacketDecoder func(header CCSDSPrimaryHeader, b []byte) (Packet, error)
+
+// PacketDefinition describes how to handle every packet sent on an APID
+type PacketDefinition struct {
+	APID uint16
+	// Name is a human readable label used in logs, e.g. "power" or "thermal"
+	Name   string
+	Decode PacketDecoder
+	// ErrorControl is set when packets end with a PEC_SIZE packet error
+	// control field
+	ErrorControl bool
+
+	// Table is the SQL table packets are persisted to. Rows returns one or more
+	// rows of values in the same order as Columns.
+	Table   string
+	Columns []string
+	Rows    func(Packet) [][]any
+	// RecordOrigin stores the address each packet came from and when it was
+	// received in the table's source and received_at columns, after Columns
+	RecordOrigin bool
+}
+
+// Registry maps APIDs to the definition used to decode and store them.
+// It is safe for concurrent use.
+type Registry struct {
+	mu   sync.RWMutex
+	defs map[uint16]PacketDefinition
+}
+
+func NewRegistry() *Registry {
+	return &Registry{defs: map[uint16]PacketDefinition{}}
+}
+
+// DefaultRegistry returns a registry containing the Turion data packet on APID
+func DefaultRegistry() *Registry {
+	r := NewRegistry()
+	if err := r.Register(TurionDataPacketDefinition); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Register adds a definition to the registry. Each APID may only be registered
+// once.
+func (r *Registry) Register(def PacketDefinition) error {
+	var errs error
+	if def.APID > apidMask {
+		errs = errors.Join(errs, fmt.Errorf("APID %#x does not fit in 11 bits", def.APID))
+	}
+
+	if def.Decode == nil {
+		errs = errors.Join(errs, fmt.Errorf("APID %#x has no decoder", def.APID))
+	}
+
+	if def.Table != "" && (len(def.Columns) == 0 || def.Rows == nil) {
+		errs = errors.Join(errs, fmt.Errorf("APID %#x has a table but no columns or rows", def.APID))
+	}
+
+	if errs != nil {
+		return errs
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.defs[def.APID]; ok {
+		return fmt.Errorf("APID %#x is already registered to %q", def.APID, existing.Name)
+	}
+
+	r.defs[def.APID] = def
+	return nil
+}
+
+// Lookup returns the definition registered for apid
+func (r *Registry) Lookup(apid uint16) (PacketDefinition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	def, ok := r.defs[apid]
+	return def, ok
+}
+
+// Decode validates the primary header of b, looks up the definition for its
+// APID, checks the packet error control field if the definition has one and
+// decodes the packet with it. Packets that fail the check return ErrChecksum
+// along with their definition.
+func (r *Registry) Decode(b []byte) (Packet, PacketDefinition, error) {
+	h, err := DecodePrimaryHeader(b)
+	if err != nil {
+		return nil, PacketDefinition{}, err
+	}
+
+	def, ok := r.Lookup(h.APID())
+	if !ok {
+		return nil, PacketDefinition{}, fmt.Errorf(
+			"%w: no packet definition registered for %#x",
+			ErrUnexpectedAPID, h.APID(),
+		)
+	}
+
+	if def.ErrorControl {
+		if b, err = CheckErrorControl(b); err != nil {
+			return nil, def, fmt.Errorf("%s packet: %w", def.Name, err)
+		}
+	}
+
+	pkt, err := def.Decode(h, b)
+	if err != nil {
+		return nil, def, fmt.Errorf("decoding %s packet: %w", def.Name, err)
+	}
+
+	return pkt, def, nil
+}
+
+// TurionDataPacketDefinition is the main bus telemetry packet
+var TurionDataPacketDefinition = PacketDefinition{
+	APID: APID,
+	Name: "main bus",
+	Decode: func(h CCSDSPrimaryHeader, b []byte) (Packet, error) {
+		return decodeTurionDataPacket(h, b)
+	},
+	Table: "turion_data_packets",
+	Columns: []string{
+		"packet_id", "packet_seq_ctrl", "packet_length",
+		"ts", "subsystem_id",
+		"temperature", "battery", "altitude", "signal",
+		"apid", "seq_count",
+	},
+	Rows: func(p Packet) [][]any {
+		dp := p.(*TurionDataPacket)
+		return [][]any{{
+			dp.CCSDSPrimaryHeader.PacketID,
+			dp.CCSDSPrimaryHeader.PacketSeqCtrl,
+			dp.CCSDSPrimaryHeader.PacketLength,
+			dp.CCSDSSecondaryHeader.Timestamp,
+			dp.CCSDSSecondaryHeader.SubsystemID,
+			dp.TelemetryPayload.Temperature,
+			dp.TelemetryPayload.Battery,
+			dp.TelemetryPayload.Altitude,
+			dp.TelemetryPayload.Signal,
+			dp.CCSDSPrimaryHeader.APID(),
+			dp.CCSDSPrimaryHeader.SequenceCount(),
+		}}
+	},
+	RecordOrigin: true,
+}
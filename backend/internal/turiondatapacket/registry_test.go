@@ -0,0 +1,123 @@
+package turiondatapacket
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// rawPacket is a minimal Packet used to register a second APID in tests
+type rawPacket struct {
+	header CCSDSPrimaryHeader
+	data   []byte
+}
+
+func (p *rawPacket) PrimaryHeader() CCSDSPrimaryHeader { return p.header }
+func (p *rawPacket) Timestamp() uint64                 { return 0 }
+
+func TestRegistryRoutesByAPID(t *testing.T) {
+	const thermalAPID = 0x42
+
+	r := DefaultRegistry()
+	err := r.Register(PacketDefinition{
+		APID: thermalAPID,
+		Name: "thermal",
+		Decode: func(h CCSDSPrimaryHeader, b []byte) (Packet, error) {
+			return &rawPacket{header: h, data: b[PRIMARY_HEADER_SIZE:]}, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	dataLength := SECONDARY_HEADER_SIZE + binary.Size(TelemetryPayload{})
+
+	pkt, def, err := r.Decode(encodePacket(t, validFields(), dataLength))
+	if err != nil {
+		t.Fatalf("Decode(main bus) error = %v", err)
+	}
+	if _, ok := pkt.(*TurionDataPacket); !ok || def.Name != "main bus" {
+		t.Errorf("Decode(main bus) = %T, %q; want *TurionDataPacket, \"main bus\"", pkt, def.Name)
+	}
+
+	thermal := validFields()
+	thermal.APID = thermalAPID
+	pkt, def, err = r.Decode(encodePacket(t, thermal, dataLength))
+	if err != nil {
+		t.Fatalf("Decode(thermal) error = %v", err)
+	}
+	if _, ok := pkt.(*rawPacket); !ok || def.Name != "thermal" {
+		t.Errorf("Decode(thermal) = %T, %q; want *rawPacket, \"thermal\"", pkt, def.Name)
+	}
+
+	unknown := validFields()
+	unknown.APID = 0x7FE
+	if _, _, err := r.Decode(encodePacket(t, unknown, dataLength)); !errors.Is(err, ErrUnexpectedAPID) {
+		t.Errorf("Decode(unknown APID) error = %v; want %v", err, ErrUnexpectedAPID)
+	}
+}
+
+func TestRegistryRegisterErrors(t *testing.T) {
+	decode := func(h CCSDSPrimaryHeader, b []byte) (Packet, error) { return nil, nil }
+
+	tests := []struct {
+		name string
+		def  PacketDefinition
+	}{
+		{name: "duplicate APID", def: PacketDefinition{APID: APID, Decode: decode}},
+		{name: "APID out of range", def: PacketDefinition{APID: 0x800, Decode: decode}},
+		{name: "missing decoder", def: PacketDefinition{APID: 0x10}},
+		{name: "table without rows", def: PacketDefinition{APID: 0x11, Decode: decode, Table: "t"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := DefaultRegistry().Register(tt.def); err == nil {
+				t.Error("Register() error = nil; want error")
+			}
+		})
+	}
+}
+
+func TestRegistryErrorControl(t *testing.T) {
+	def := TurionDataPacketDefinition
+	def.ErrorControl = true
+	r := NewRegistry()
+	if err := r.Register(def); err != nil {
+		t.Fatal(err)
+	}
+
+	dataLength := SECONDARY_HEADER_SIZE + binary.Size(TelemetryPayload{}) + PEC_SIZE
+	valid := AppendErrorControl(encodePacket(t, validFields(), dataLength))
+
+	corrupt := append([]byte{}, valid...)
+	corrupt[20] ^= 0x01
+
+	badPEC := append([]byte{}, valid...)
+	badPEC[len(badPEC)-1] ^= 0x01
+
+	tests := []struct {
+		name    string
+		b       []byte
+		wantErr error
+	}{
+		{name: "valid", b: valid},
+		{name: "corrupted payload", b: corrupt, wantErr: ErrChecksum},
+		{name: "corrupted PEC", b: badPEC, wantErr: ErrChecksum},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pkt, got, err := r.Decode(tt.b)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v; want %v", err, tt.wantErr)
+			}
+			if got.APID != APID {
+				t.Errorf("Decode() definition APID = %#x; want %#x", got.APID, APID)
+			}
+			if tt.wantErr == nil && pkt.(*TurionDataPacket).TelemetryPayload.Temperature != 25 {
+				t.Errorf("Decode() = %+v; want the encoded payload", pkt)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package turiondatapacket
+
+import "sync"
+
+// SEQUENCE_REORDER_WINDOW is how far behind the newest sequence count a packet
+// may arrive and still be treated as late or duplicated. Anything further back
+// means the spacecraft's counter was reset.
+const SEQUENCE_REORDER_WINDOW = 512
+
+const sequenceModulus = MAX_SEQUENCE_COUNT + 1
+
+// SequenceTracker follows the 14-bit sequence count of every APID and reports
+// dropped, duplicated, reordered and wrapped packets. It is safe for
+// concurrent use.
+type SequenceTracker struct {
+	mu    sync.Mutex
+	apids map[uint16]*sequenceState
+}
+
+type sequenceState struct {
+	newest uint16
+	// received has a bit set for every sequence count received in the last
+	// lap of the counter
+	received [sequenceModulus / 64]uint64
+}
+
+func NewSequenceTracker() *SequenceTracker {
+	return &SequenceTracker{apids: map[uint16]*sequenceState{}}
+}
+
+// Observe records a packet and returns any link events it reveals. ts is the
+// packet timestamp, copied onto the events.
+func (t *SequenceTracker) Observe(apid, seqCount uint16, ts uint64) []*LinkEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seqCount &= seqCountMask
+	state, ok := t.apids[apid]
+	if !ok {
+		state = &sequenceState{newest: seqCount}
+		state.mark(seqCount)
+		t.apids[apid] = state
+		return nil
+	}
+
+	expected := (state.newest + 1) & seqCountMask
+	newEvent := func(kind LinkEventKind) *LinkEvent {
+		return &LinkEvent{
+			Apid:             uint32(apid),
+			Kind:             kind,
+			ExpectedSeqCount: uint32(expected),
+			ReceivedSeqCount: uint32(seqCount),
+			Timestamp:        ts,
+		}
+	}
+
+	// Distance forwards from the newest count, modulo the counter size
+	ahead := int(seqCount-state.newest) & seqCountMask
+	behind := sequenceModulus - ahead
+
+	var events []*LinkEvent
+	switch {
+	case ahead == 0:
+		return []*LinkEvent{newEvent(LinkEventKind_DUPLICATE)}
+
+	case behind <= SEQUENCE_REORDER_WINDOW:
+		if state.isMarked(seqCount) {
+			return []*LinkEvent{newEvent(LinkEventKind_DUPLICATE)}
+		}
+		state.mark(seqCount)
+		return []*LinkEvent{newEvent(LinkEventKind_OUT_OF_ORDER)}
+
+	case ahead < sequenceModulus/2:
+		if ahead > 1 {
+			gap := newEvent(LinkEventKind_GAP)
+			gap.MissingCount = uint32(ahead - 1)
+			events = append(events, gap)
+		}
+		if seqCount < state.newest {
+			events = append(events, newEvent(LinkEventKind_ROLLOVER))
+		}
+
+	default:
+		// Too far back to be a late packet. Start following the new count
+		// and forget everything received before the reset.
+		events = append(events, newEvent(LinkEventKind_RESET))
+		state.received = [sequenceModulus / 64]uint64{}
+		state.newest = seqCount
+		state.mark(seqCount)
+		return events
+	}
+
+	// Skipped counts were never received in this lap of the counter
+	for s := expected; s != seqCount; s = (s + 1) & seqCountMask {
+		state.unmark(s)
+	}
+	state.mark(seqCount)
+	state.newest = seqCount
+
+	return events
+}
+
+func (s *sequenceState) mark(seqCount uint16) {
+	s.received[seqCount/64] |= 1 << (seqCount % 64)
+}
+
+func (s *sequenceState) unmark(seqCount uint16) {
+	s.received[seqCount/64] &^= 1 << (seqCount % 64)
+}
+
+func (s *sequenceState) isMarked(seqCount uint16) bool {
+	return s.received[seqCount/64]&(1<<(seqCount%64)) != 0
+}
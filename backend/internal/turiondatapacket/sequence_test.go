@@ -0,0 +1,116 @@
+package turiondatapacket
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSequenceTracker(t *testing.T) {
+	type observation struct {
+		seq  uint16
+		want []LinkEventKind
+	}
+
+	tests := []struct {
+		name         string
+		observations []observation
+		wantMissing  uint32
+	}{
+		{
+			name: "in order",
+			observations: []observation{
+				{seq: 10}, {seq: 11}, {seq: 12},
+			},
+		},
+		{
+			name: "gap",
+			observations: []observation{
+				{seq: 10}, {seq: 14, want: []LinkEventKind{LinkEventKind_GAP}},
+			},
+			wantMissing: 3,
+		},
+		{
+			name: "duplicate of newest",
+			observations: []observation{
+				{seq: 10}, {seq: 10, want: []LinkEventKind{LinkEventKind_DUPLICATE}},
+			},
+		},
+		{
+			name: "late packet fills gap, then arrives again",
+			observations: []observation{
+				{seq: 10},
+				{seq: 12, want: []LinkEventKind{LinkEventKind_GAP}},
+				{seq: 11, want: []LinkEventKind{LinkEventKind_OUT_OF_ORDER}},
+				{seq: 11, want: []LinkEventKind{LinkEventKind_DUPLICATE}},
+				{seq: 13},
+			},
+			wantMissing: 1,
+		},
+		{
+			name: "duplicate of older packet",
+			observations: []observation{
+				{seq: 10}, {seq: 11}, {seq: 12},
+				{seq: 10, want: []LinkEventKind{LinkEventKind_DUPLICATE}},
+			},
+		},
+		{
+			name: "rollover",
+			observations: []observation{
+				{seq: MAX_SEQUENCE_COUNT - 1},
+				{seq: MAX_SEQUENCE_COUNT},
+				{seq: 0, want: []LinkEventKind{LinkEventKind_ROLLOVER}},
+				{seq: 1},
+			},
+		},
+		{
+			name: "gap across rollover",
+			observations: []observation{
+				{seq: MAX_SEQUENCE_COUNT - 1},
+				{seq: 2, want: []LinkEventKind{LinkEventKind_GAP, LinkEventKind_ROLLOVER}},
+			},
+			wantMissing: 3,
+		},
+		{
+			name: "counter reset",
+			observations: []observation{
+				{seq: 5000},
+				{seq: 0, want: []LinkEventKind{LinkEventKind_RESET}},
+				{seq: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewSequenceTracker()
+			var missing uint32
+			for i, o := range tt.observations {
+				var got []LinkEventKind
+				events := tracker.Observe(APID, o.seq, uint64(i))
+				for _, e := range events {
+					got = append(got, e.Kind)
+					missing += e.MissingCount
+				}
+				if !reflect.DeepEqual(got, o.want) {
+					t.Errorf("Observe(%d) = %v; want %v", o.seq, got, o.want)
+				}
+			}
+			if missing != tt.wantMissing {
+				t.Errorf("missing packets = %d; want %d", missing, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestSequenceTrackerSeparatesAPIDs(t *testing.T) {
+	tracker := NewSequenceTracker()
+	tracker.Observe(0x01, 10, 0)
+	tracker.Observe(0x02, 500, 0)
+
+	if events := tracker.Observe(0x01, 11, 0); len(events) != 0 {
+		t.Errorf("Observe(0x01, 11) returned %d events; want none", len(events))
+	}
+	if events := tracker.Observe(0x02, 501, 0); len(events) != 0 {
+		t.Errorf("Observe(0x02, 501) returned %d events; want none", len(events))
+	}
+}
@@ -28,6 +28,16 @@ type TurionDataPacket struct {
 	TelemetryPayload     TelemetryPayload     `json:"telemetryPayload"`
 }
 
+// PrimaryHeader implements Packet
+func (dp *TurionDataPacket) PrimaryHeader() CCSDSPrimaryHeader {
+	return dp.CCSDSPrimaryHeader
+}
+
+// Timestamp implements Packet
+func (dp *TurionDataPacket) Timestamp() uint64 {
+	return dp.CCSDSSecondaryHeader.Timestamp
+}
+
 const (
 	APID           = 0x01
 	PACKET_VERSION = 0x0
@@ -37,44 +47,20 @@ const (
 	SUBSYSTEM_ID   = 0x0001 // Main bus telemetry
 )
 
-// DetectAnomalies returns one Anomaly per field whose value is outside its normal range.
-func (dp TurionDataPacket) DetectAnomalies() []Anomaly {
-	ts := dp.CCSDSSecondaryHeader.Timestamp
-	var anomalies []Anomaly
-
+// Values implements Parameters so main bus packets can be limit checked
+func (dp TurionDataPacket) Values() []ParameterValue {
 	v := dp.TelemetryPayload
-
-	// Temperature: normal 20–30; anomaly if >35
-	if v.Temperature > 35.0 {
-		anomalies = append(
-			anomalies,
-			Anomaly{Value: v.Temperature, Timestamp: ts, Field: Field_TEMPERATURE},
-		)
-	}
-
-	// Battery: normal 70–100; anomaly if <40
-	if v.Battery < 40.0 {
-		anomalies = append(
-			anomalies,
-			Anomaly{Value: v.Battery, Timestamp: ts, Field: Field_BATTERY},
-		)
-	}
-
-	// Altitude: normal 500–550; anomaly if <400
-	if v.Altitude < 400.0 {
-		anomalies = append(
-			anomalies,
-			Anomaly{Value: v.Altitude, Timestamp: ts, Field: Field_ALTITUDE},
-		)
-	}
-
-	// Signal: normal -60…-40; anomaly if < -80
-	if v.Signal < -80.0 {
-		anomalies = append(
-			anomalies,
-			Anomaly{Value: v.Signal, Timestamp: ts, Field: Field_SIGNAL},
-		)
+	return []ParameterValue{
+		{Name: "temperature", Raw: float64(v.Temperature), Value: float64(v.Temperature), Unit: "°C"},
+		{Name: "battery", Raw: float64(v.Battery), Value: float64(v.Battery), Unit: "%"},
+		{Name: "altitude", Raw: float64(v.Altitude), Value: float64(v.Altitude), Unit: "km"},
+		{Name: "signal", Raw: float64(v.Signal), Value: float64(v.Signal), Unit: "dB"},
 	}
+}
 
-	return anomalies
+// DetectAnomalies returns one Anomaly per field whose value is outside its
+// DefaultLimitSet limits. The gateway uses a LimitChecker instead so limits can
+// be changed without a rebuild.
+func (dp TurionDataPacket) DetectAnomalies() []Anomaly {
+	return defaultLimitChecker.DetectAnomalies(&dp)
 }
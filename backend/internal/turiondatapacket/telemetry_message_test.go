@@ -35,31 +35,36 @@ func TestDetectAnomalies(t *testing.T) {
 		{
 			name: "temperature anomaly only",
 			pkt:  makePacket(36, 85, 525, -50, now),
-			want: []Anomaly{{Value: 36, Timestamp: now, Field: Field_TEMPERATURE}},
+			want: []Anomaly{{Value: 36, Timestamp: now, Field: Field_TEMPERATURE, Parameter: "temperature", Severity: Severity_RED, LimitSet: "default", Bound: LimitBound_HIGH, Threshold: 35}},
 		},
 		{
 			name: "battery anomaly only",
 			pkt:  makePacket(25, 39, 525, -50, now),
-			want: []Anomaly{{Value: 39, Timestamp: now, Field: Field_BATTERY}},
+			want: []Anomaly{{Value: 39, Timestamp: now, Field: Field_BATTERY, Parameter: "battery", Severity: Severity_RED, LimitSet: "default", Bound: LimitBound_LOW, Threshold: 40}},
 		},
 		{
 			name: "altitude anomaly only",
 			pkt:  makePacket(25, 85, 399, -50, now),
-			want: []Anomaly{{Value: 399, Timestamp: now, Field: Field_ALTITUDE}},
+			want: []Anomaly{{Value: 399, Timestamp: now, Field: Field_ALTITUDE, Parameter: "altitude", Severity: Severity_RED, LimitSet: "default", Bound: LimitBound_LOW, Threshold: 400}},
 		},
 		{
 			name: "signal anomaly only",
 			pkt:  makePacket(25, 85, 525, -81, now),
-			want: []Anomaly{{Value: -81, Timestamp: now, Field: Field_SIGNAL}},
+			want: []Anomaly{{Value: -81, Timestamp: now, Field: Field_SIGNAL, Parameter: "signal", Severity: Severity_RED, LimitSet: "default", Bound: LimitBound_LOW, Threshold: -80}},
+		},
+		{
+			name: "yellow battery",
+			pkt:  makePacket(25, 55, 525, -50, now),
+			want: []Anomaly{{Value: 55, Timestamp: now, Field: Field_BATTERY, Parameter: "battery", Severity: Severity_YELLOW, LimitSet: "default", Bound: LimitBound_LOW, Threshold: 60}},
 		},
 		{
 			name: "multiple anomalies",
 			pkt:  makePacket(50, 20, 350, -90, now),
 			want: []Anomaly{
-				{Value: 50, Timestamp: now, Field: Field_TEMPERATURE},
-				{Value: 20, Timestamp: now, Field: Field_BATTERY},
-				{Value: 350, Timestamp: now, Field: Field_ALTITUDE},
-				{Value: -90, Timestamp: now, Field: Field_SIGNAL},
+				{Value: 50, Timestamp: now, Field: Field_TEMPERATURE, Parameter: "temperature", Severity: Severity_RED, LimitSet: "default", Bound: LimitBound_HIGH, Threshold: 35},
+				{Value: 20, Timestamp: now, Field: Field_BATTERY, Parameter: "battery", Severity: Severity_RED, LimitSet: "default", Bound: LimitBound_LOW, Threshold: 40},
+				{Value: 350, Timestamp: now, Field: Field_ALTITUDE, Parameter: "altitude", Severity: Severity_RED, LimitSet: "default", Bound: LimitBound_LOW, Threshold: 400},
+				{Value: -90, Timestamp: now, Field: Field_SIGNAL, Parameter: "signal", Severity: Severity_RED, LimitSet: "default", Bound: LimitBound_LOW, Threshold: -80},
 			},
 		},
 	}
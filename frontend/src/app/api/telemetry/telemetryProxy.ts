@@ -33,11 +33,22 @@ export async function proxyTelemetry(
     url.searchParams.set("end_time", end)
   }
 
+  // paging, filters and fields pass straight through
+  req.nextUrl.searchParams.forEach((value, key) => {
+    if (key !== "start_time" && key !== "end_time") {
+      url.searchParams.append(key, value)
+    }
+  })
 
   const resp = await fetch(url)
   const data = await resp.text()
+  const headers: Record<string, string> = { "content-type": "application/json" }
+  const nextCursor = resp.headers.get("x-next-cursor")
+  if (nextCursor) {
+    headers["x-next-cursor"] = nextCursor
+  }
   return new NextResponse(data, {
     status: resp.status,
-    headers: { "content-type": "application/json" },
+    headers,
   })
 }
@@ -2,8 +2,13 @@ import { notifications } from "@mantine/notifications";
 import { useCallback, useEffect, useRef, useState } from "react";
 
 interface Anomaly {
+  event_id: string;
   field: number;  // Enum of field, not field name itself
+  parameter: string;
   value: number;
+  peak_value: number;
+  severity?: number; // 1 = YELLOW, 2 = RED
+  state?: number; // 1 = OPEN, 2 = ACKNOWLEDGED, 3 = RESOLVED
   timestamp: number; // UNIX seconds
 }
 
@@ -37,13 +42,17 @@ export const AnomalyNotifications = () => {
       if (!r.ok) throw new Error(await r.text());
       const anomalies = (await r.json()) as Anomaly[];
       anomalies.forEach((an) => {
-        const key = `${AnomalyMap[an.field]}@${an.timestamp}`;
+        // Events are returned for as long as they overlap the window, only
+        // notify when one opens, gets worse or resolves
+        const resolved = an.state === 3;
+        const key = `${an.event_id}@${an.severity}@${an.peak_value}@${resolved}`;
         if (!notifiedRef.current.has(key)) {
           notifiedRef.current.add(key);
+          const name = an.parameter || AnomalyMap[an.field];
           notifications.show({
-            title: `Anomaly: ${AnomalyMap[an.field]}`,
+            title: resolved ? `Resolved: ${name}` : `Anomaly: ${name}`,
             message: `Value ${an.value} at ${new Date(an.timestamp * 1000).toLocaleTimeString()}`,
-            color: 'red',
+            color: resolved ? 'green' : an.severity === 1 ? 'yellow' : 'red',
             autoClose: 3000,
           });
         }
@@ -50,9 +50,19 @@ export const TelemetryDashboard: React.FC = () => {
       });
       
       try {
-        const r = await fetch(`/api/telemetry?${qs.toString()}`);
-        if (!r.ok) throw new Error(await r.text());
-        const raw = (await r.json()) as Array<RawPacket>;
+        // The API returns the range a page at a time, follow the cursor
+        // until the last one
+        const raw: RawPacket[] = [];
+        let cursor: string | null = null;
+        do {
+          if (cursor) qs.set("cursor", cursor);
+          const r = await fetch(`/api/telemetry?${qs.toString()}`);
+          if (!r.ok) throw new Error(await r.text());
+          raw.push(...((await r.json()) as Array<RawPacket>));
+          cursor = r.headers.get("x-next-cursor");
+        } while (cursor && isMounted);
+        if (!isMounted) return;
+
         const rows: TelemetryRow[] = raw.map((p) => ({
           temperature:   p.telemetryPayload.temperature,
           battery:       p.telemetryPayload.battery,
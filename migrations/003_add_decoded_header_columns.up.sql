@@ -0,0 +1,3 @@
+ALTER TABLE public.turion_data_packets
+  ADD COLUMN IF NOT EXISTS apid      INTEGER,
+  ADD COLUMN IF NOT EXISTS seq_count INTEGER;
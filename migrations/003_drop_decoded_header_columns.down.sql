@@ -0,0 +1,3 @@
+ALTER TABLE public.turion_data_packets
+  DROP COLUMN IF EXISTS apid,
+  DROP COLUMN IF EXISTS seq_count;
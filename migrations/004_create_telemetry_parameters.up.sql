@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS public.telemetry_parameters (
+  apid          INTEGER          NOT NULL,
+  seq_count     INTEGER          NOT NULL,
+  ts            BIGINT           NOT NULL,
+  subsystem_id  INTEGER          NOT NULL,
+  parameter     TEXT             NOT NULL,
+  raw_value     DOUBLE PRECISION NOT NULL,
+  value         DOUBLE PRECISION NOT NULL,
+  unit          TEXT             NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS telemetry_parameters_parameter_ts_idx
+  ON public.telemetry_parameters (apid, parameter, ts);
+
+ALTER TABLE public.anomalies
+  ADD COLUMN IF NOT EXISTS parameter TEXT    NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS apid      INTEGER NOT NULL DEFAULT 0;
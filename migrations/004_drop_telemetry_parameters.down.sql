@@ -0,0 +1,5 @@
+ALTER TABLE public.anomalies
+  DROP COLUMN IF EXISTS parameter,
+  DROP COLUMN IF EXISTS apid;
+
+DROP TABLE public.telemetry_parameters;
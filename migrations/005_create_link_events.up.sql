@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS public.link_events (
+  id                  BIGSERIAL   PRIMARY KEY,
+  apid                INTEGER     NOT NULL,
+  kind                TEXT        NOT NULL,
+  expected_seq_count  INTEGER     NOT NULL,
+  received_seq_count  INTEGER     NOT NULL,
+  missing_count       INTEGER     NOT NULL DEFAULT 0,
+  timestamp           BIGINT      NOT NULL,
+  created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS link_events_timestamp_idx
+  ON public.link_events (timestamp);
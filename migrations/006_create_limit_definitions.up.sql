@@ -0,0 +1,28 @@
+-- Anomalies recorded before limit sets existed were all red
+ALTER TABLE public.anomalies
+  ADD COLUMN IF NOT EXISTS severity  TEXT NOT NULL DEFAULT 'RED',
+  ADD COLUMN IF NOT EXISTS limit_set TEXT NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS bound     TEXT NOT NULL DEFAULT 'LIMIT_BOUND_UNSPECIFIED',
+  ADD COLUMN IF NOT EXISTS threshold REAL NOT NULL DEFAULT 0;
+
+-- One row per mission phase. The gateway uses the active set, so switching
+-- phase is a single UPDATE followed by a reload.
+CREATE TABLE IF NOT EXISTS public.limit_sets (
+  name        TEXT        PRIMARY KEY,
+  active      BOOLEAN     NOT NULL DEFAULT FALSE,
+  updated_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS limit_sets_single_active_idx
+  ON public.limit_sets (active) WHERE active;
+
+CREATE TABLE IF NOT EXISTS public.limit_definitions (
+  id           BIGSERIAL        PRIMARY KEY,
+  set_name     TEXT             NOT NULL REFERENCES public.limit_sets (name) ON DELETE CASCADE,
+  parameter    TEXT             NOT NULL,
+  apid         INTEGER,
+  yellow_low   DOUBLE PRECISION,
+  yellow_high  DOUBLE PRECISION,
+  red_low      DOUBLE PRECISION,
+  red_high     DOUBLE PRECISION
+);
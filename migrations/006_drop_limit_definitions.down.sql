@@ -0,0 +1,8 @@
+DROP TABLE public.limit_definitions;
+DROP TABLE public.limit_sets;
+
+ALTER TABLE public.anomalies
+  DROP COLUMN IF EXISTS severity,
+  DROP COLUMN IF EXISTS limit_set,
+  DROP COLUMN IF EXISTS bound,
+  DROP COLUMN IF EXISTS threshold;
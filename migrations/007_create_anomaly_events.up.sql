@@ -0,0 +1,30 @@
+-- One row per anomaly event. The gateway upserts the row as the event gets
+-- worse and when it resolves, operators acknowledge it through the API.
+CREATE TABLE IF NOT EXISTS public.anomaly_events (
+  event_id        TEXT        PRIMARY KEY,
+  apid            INTEGER     NOT NULL,
+  parameter       TEXT        NOT NULL,
+  field           TEXT        NOT NULL,
+  severity        TEXT        NOT NULL,
+  limit_set       TEXT        NOT NULL,
+  bound           TEXT        NOT NULL,
+  threshold       REAL        NOT NULL,
+  state           TEXT        NOT NULL,
+  start_time      BIGINT      NOT NULL,
+  end_time        BIGINT,
+  last_value      REAL        NOT NULL,
+  last_timestamp  BIGINT      NOT NULL,
+  peak_value      REAL        NOT NULL,
+  sample_count    BIGINT      NOT NULL,
+  ack_operator    TEXT,
+  ack_note        TEXT,
+  ack_time        BIGINT,
+  created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS anomaly_events_open_idx
+  ON public.anomaly_events (start_time) WHERE state <> 'RESOLVED';
+
+CREATE INDEX IF NOT EXISTS anomaly_events_time_idx
+  ON public.anomaly_events (start_time, end_time);
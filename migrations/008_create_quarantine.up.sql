@@ -0,0 +1,14 @@
+-- Messages a pipeline stage couldn't process, kept so they can be replayed
+-- with the quarantine CLI once the cause is fixed
+CREATE TABLE IF NOT EXISTS public.quarantine (
+  id           BIGSERIAL   PRIMARY KEY,
+  received_at  TIMESTAMPTZ NOT NULL,
+  source       TEXT        NOT NULL,
+  reader       TEXT        NOT NULL,
+  error        TEXT        NOT NULL,
+  payload      BYTEA       NOT NULL,
+  replayed_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS quarantine_pending_idx
+  ON public.quarantine (id) WHERE replayed_at IS NULL;
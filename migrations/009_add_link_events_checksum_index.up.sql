@@ -0,0 +1,4 @@
+-- Packets that fail their packet error control check are recorded as CHECKSUM
+-- link events and counted per APID by the API
+CREATE INDEX IF NOT EXISTS link_events_checksum_idx
+  ON public.link_events (apid, timestamp) WHERE kind = 'CHECKSUM';
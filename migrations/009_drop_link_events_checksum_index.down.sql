@@ -0,0 +1 @@
+DROP INDEX IF EXISTS public.link_events_checksum_idx;
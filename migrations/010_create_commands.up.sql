@@ -0,0 +1,22 @@
+-- Command history. The API inserts a row when a command is accepted and marks
+-- it sent or failed once written to the uplink, the gateway marks it
+-- acknowledged or failed when the spacecraft's acknowledgement comes down.
+CREATE TABLE IF NOT EXISTS public.commands (
+  id               BIGSERIAL   PRIMARY KEY,
+  name             TEXT        NOT NULL,
+  apid             INTEGER     NOT NULL,
+  opcode           INTEGER     NOT NULL,
+  seq_count        INTEGER     NOT NULL,
+  arguments        JSONB       NOT NULL DEFAULT '{}',
+  packet           BYTEA       NOT NULL,
+  state            TEXT        NOT NULL,
+  error            TEXT,
+  created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  sent_at          TIMESTAMPTZ,
+  acknowledged_at  TIMESTAMPTZ,
+  ack_status       SMALLINT
+);
+
+-- Acknowledgements only carry the APID and sequence count of their command
+CREATE INDEX IF NOT EXISTS commands_seq_count_idx
+  ON public.commands (apid, seq_count, id DESC);
@@ -0,0 +1,7 @@
+-- A unique, increasing id breaks ties between packets with the same
+-- timestamp, so the API can page through packets by (ts, id)
+ALTER TABLE public.turion_data_packets
+  ADD COLUMN IF NOT EXISTS id BIGSERIAL;
+
+CREATE INDEX IF NOT EXISTS turion_data_packets_ts_id_idx
+  ON public.turion_data_packets (ts, id);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS public.turion_data_packets_ts_id_idx;
+
+ALTER TABLE public.turion_data_packets
+  DROP COLUMN IF EXISTS id;
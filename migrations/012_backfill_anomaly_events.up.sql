@@ -0,0 +1,32 @@
+-- Copies the anomalies written before anomaly events existed into
+-- anomaly_events, so history queries only need the one table. Every legacy row
+-- was a single main bus (APID 1) reading past its red limit, so each becomes a
+-- resolved one-sample event. Rows already copied are skipped.
+INSERT INTO public.anomaly_events (
+  event_id, apid, parameter, field, severity, limit_set, bound, threshold,
+  state, start_time, end_time, last_value, last_timestamp, peak_value,
+  sample_count
+)
+SELECT 'legacy-' || a.id,
+       1,
+       LOWER(a.field),
+       a.field,
+       'RED',
+       'default',
+       CASE a.field WHEN 'TEMPERATURE' THEN 'HIGH' ELSE 'LOW' END,
+       CASE a.field
+         WHEN 'TEMPERATURE' THEN 35
+         WHEN 'BATTERY'     THEN 40
+         WHEN 'ALTITUDE'    THEN 400
+         WHEN 'SIGNAL'      THEN -80
+         ELSE 0
+       END,
+       'RESOLVED',
+       a.timestamp,
+       a.timestamp,
+       a.value,
+       a.timestamp,
+       a.value,
+       1
+  FROM public.anomalies a
+ON CONFLICT (event_id) DO NOTHING;
@@ -0,0 +1 @@
+DELETE FROM public.anomaly_events WHERE event_id LIKE 'legacy-%';
@@ -0,0 +1,10 @@
+-- Where each packet came from and when the gateway received it, empty for
+-- packets stored before this migration and for replays of captures without
+-- a source
+ALTER TABLE public.turion_data_packets
+  ADD COLUMN IF NOT EXISTS source      TEXT,
+  ADD COLUMN IF NOT EXISTS received_at TIMESTAMPTZ;
+
+ALTER TABLE public.telemetry_parameters
+  ADD COLUMN IF NOT EXISTS source      TEXT,
+  ADD COLUMN IF NOT EXISTS received_at TIMESTAMPTZ;
@@ -0,0 +1,7 @@
+ALTER TABLE public.telemetry_parameters
+  DROP COLUMN IF EXISTS received_at,
+  DROP COLUMN IF EXISTS source;
+
+ALTER TABLE public.turion_data_packets
+  DROP COLUMN IF EXISTS received_at,
+  DROP COLUMN IF EXISTS source;